@@ -28,7 +28,7 @@ func AMPolicyControlCreate(ue *amf_context.OcfUe, anType models.AccessType) (*mo
 			Mcc: ue.PlmnId.Mcc,
 			Mnc: ue.PlmnId.Mnc,
 		},
-		Guami: &amfSelf.ServedGuamiList[0],
+		Guami: &amfSelf.Parameters().ServedGuamiList[0],
 	}
 
 	if ue.AccessAndMobilitySubscriptionData != nil {
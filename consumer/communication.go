@@ -1,10 +1,8 @@
 package consumer
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"free5gc/lib/nas/nasMessage"
 	"free5gc/lib/openapi"
 	"free5gc/lib/openapi/Namf_Communication"
 	"free5gc/lib/openapi/models"
@@ -19,6 +17,19 @@ func BuildUeContextCreateData(ue *amf_context.OcfUe, targetRanId models.NgRanTar
 	var ueContextCreateData models.UeContextCreateData
 
 	ueContext := BuildUeContextModel(ue)
+	// the Kamf is derived horizontally with the downlink NAS COUNT at N2 handover, which is not used again; the
+	// UE derives it from the NAS security parameters of the Handover Command (TS 33.501 6.9.2.3.3, A.13)
+	horizontalDerivation := amf_context.OCF_Self().Parameters().KamfHorizontalDerivation
+	count := ue.DLCount.Get()
+	if seafData, err := ue.BuildSeafData(horizontalDerivation, count); err != nil {
+		logger.ConsumerLog.Errorf("Build SEAF data of UE[%s] failed: %+v", ue.Supi, err)
+	} else {
+		ueContext.SeafData = seafData
+		if seafData != nil && horizontalDerivation {
+			ue.DLCount.AddOne()
+			ue.HandoverKamfCount = &count
+		}
+	}
 	ueContextCreateData.UeContext = &ueContext
 	ueContextCreateData.TargetId = &targetRanId
 	ueContextCreateData.SourceToTargetData = &sourceToTargetData
@@ -157,7 +168,7 @@ func ReleaseUEContextRequest(ue *amf_context.OcfUe, ngapCause models.NgApCause)
 	ueContextRelease := models.UeContextRelease{
 		NgapCause: &ngapCause,
 	}
-	if ue.RegistrationType5GS == nasMessage.RegistrationType5GSEmergencyRegistration && ue.UnauthenticatedSupi {
+	if ue.UnauthenticatedEmergency() {
 		ueContextRelease.Supi = ue.Supi
 		ueContextRelease.UnauthenticatedSupi = true
 	}
@@ -194,16 +205,15 @@ func UEContextTransferRequest(
 	req := models.UeContextTransferRequest{
 		JsonData: &ueContextTransferReqData,
 	}
+	// the old OCF checks the integrity of the Registration Request as sent by the UE
 	if transferReason == models.TransferReason_INIT_REG || transferReason == models.TransferReason_MOBI_REG {
-		var buf bytes.Buffer
-		ue.RegistrationRequest.EncodeRegistrationRequest(&buf)
 		ueContextTransferReqData.RegRequest = &models.N1MessageContainer{
 			N1MessageClass: models.N1MessageClass__5_GMM,
 			N1MessageContent: &models.RefToBinaryData{
 				ContentId: "n1Msg",
 			},
 		}
-		req.BinaryDataN1Message = buf.Bytes()
+		req.BinaryDataN1Message = ue.RegistrationRequestNasPdu
 	}
 
 	// guti format is defined at TS 29.518 Table 6.1.3.2.2-1 5g-guti-[0-9]{5,6}[0-9a-fA-F]{14}
@@ -18,33 +18,34 @@ func BuildNFInstance(context *amf_context.OCFContext) (profile models.NfProfile,
 	profile.NfInstanceId = context.NfId
 	profile.NfType = models.NfType_OCF
 	profile.NfStatus = models.NfStatus_REGISTERED
+	parameters := context.Parameters()
 	var plmns []models.PlmnId
-	for _, plmnItem := range context.PlmnSupportList {
+	for _, plmnItem := range parameters.PlmnSupportList {
 		plmns = append(plmns, plmnItem.PlmnId)
 	}
 	if len(plmns) > 0 {
 		profile.PlmnList = &plmns
 		// TODO: change to Per Plmn Support Snssai List
-		profile.SNssais = &context.PlmnSupportList[0].SNssaiList
+		profile.SNssais = &parameters.PlmnSupportList[0].SNssaiList
 	}
 	amfInfo := models.OcfInfo{}
-	if len(context.ServedGuamiList) == 0 {
+	if len(parameters.ServedGuamiList) == 0 {
 		err = fmt.Errorf("Gumai List is Empty in OCF")
 		return
 	}
-	regionId, setId, _, err1 := util.SeperateOcfId(context.ServedGuamiList[0].OcfId)
+	regionId, setId, _, err1 := util.SeperateOcfId(parameters.ServedGuamiList[0].OcfId)
 	if err1 != nil {
 		err = err1
 		return
 	}
 	amfInfo.OcfRegionId = regionId
 	amfInfo.OcfSetId = setId
-	amfInfo.GuamiList = &context.ServedGuamiList
-	if len(context.SupportTaiLists) == 0 {
+	amfInfo.GuamiList = &parameters.ServedGuamiList
+	if len(parameters.SupportTaiLists) == 0 {
 		err = fmt.Errorf("SupportTaiList is Empty in OCF")
 		return
 	}
-	amfInfo.TaiList = &context.SupportTaiLists
+	amfInfo.TaiList = &parameters.SupportTaiLists
 	profile.OcfInfo = &amfInfo
 	if context.RegisterIPv4 == "" {
 		err = fmt.Errorf("OCF Address is empty")
@@ -0,0 +1,49 @@
+package consumer
+
+import (
+	"sync"
+	"time"
+
+	"free5gc/lib/openapi/models"
+)
+
+// latency accumulates the duration of the requests to one NF type
+type latency struct {
+	total time.Duration
+	count int64
+}
+
+var (
+	latencyMutex sync.Mutex
+	latencies    = make(map[models.NfType]*latency)
+)
+
+// observeLatency records the duration of a request to an NF of nfType, it is deferred at the
+// start of the request
+func observeLatency(nfType models.NfType, start time.Time) {
+	elapsed := time.Since(start)
+	latencyMutex.Lock()
+	defer latencyMutex.Unlock()
+	l, ok := latencies[nfType]
+	if !ok {
+		l = &latency{}
+		latencies[nfType] = l
+	}
+	l.total += elapsed
+	l.count++
+}
+
+// TakeSbiLatency returns the average duration of the requests per NF type since the previous
+// call, the NF types without request are left out
+func TakeSbiLatency() map[models.NfType]time.Duration {
+	latencyMutex.Lock()
+	defer latencyMutex.Unlock()
+	averages := make(map[models.NfType]time.Duration)
+	for nfType, l := range latencies {
+		if l.count > 0 {
+			averages[nfType] = l.total / time.Duration(l.count)
+		}
+	}
+	latencies = make(map[models.NfType]*latency)
+	return averages
+}
@@ -7,6 +7,7 @@ import (
 	"free5gc/lib/openapi/models"
 	amf_context "free5gc/src/ocf/context"
 	"strconv"
+	"time"
 )
 
 type UpdateSmContextPresent string
@@ -47,6 +48,8 @@ func SendCreateSmContextRequest(
 	ue *amf_context.OcfUe, smfUri string, nasPdu []byte, smContextCreateData models.SmContextCreateData) (
 	response *models.PostSmContextsResponse, smContextRef string, errorResponse *models.PostSmContextsErrorResponse,
 	problemDetail *models.ProblemDetails, err1 error) {
+	defer observeLatency(models.NfType_SMF, time.Now())
+
 	configuration := Nsmf_PDUSession.NewConfiguration()
 	configuration.SetBasePath(smfUri)
 
@@ -90,9 +93,11 @@ func BuildCreateSmContextRequest(ue *amf_context.OcfUe, pduSessionContext models
 	smContextCreateData.SNssai = pduSessionContext.SNssai
 	smContextCreateData.Dnn = pduSessionContext.Dnn
 	smContextCreateData.ServingNfId = context.NfId
-	smContextCreateData.Guami = &context.ServedGuamiList[0]
-	smContextCreateData.ServingNetwork = context.ServedGuamiList[0].PlmnId
+	servedGuami := context.Parameters().ServedGuamiList[0]
+	smContextCreateData.Guami = &servedGuami
+	smContextCreateData.ServingNetwork = servedGuami.PlmnId
 	if requestType == models.RequestType_EXISTING_PDU_SESSION ||
+		requestType == models.RequestType_INITIAL_EMERGENCY_REQUEST ||
 		requestType == models.RequestType_EXISTING_EMERGENCY_PDU_SESSION {
 		smContextCreateData.RequestType = requestType
 	}
@@ -313,6 +318,8 @@ func SendUpdateSmContextRequest(ue *amf_context.OcfUe, smfUri, smContextRef stri
 	updateData models.SmContextUpdateData, n1Msg []byte, n2Info []byte) (
 	response *models.UpdateSmContextResponse, errorResponse *models.UpdateSmContextErrorResponse,
 	problemDetail *models.ProblemDetails, err1 error) {
+	defer observeLatency(models.NfType_SMF, time.Now())
+
 	configuration := Nsmf_PDUSession.NewConfiguration()
 	configuration.SetBasePath(smfUri)
 	client := Nsmf_PDUSession.NewAPIClient(configuration)
@@ -478,6 +485,8 @@ func BuildUpdateSmContextRequsetHandover(
 
 func SendReleaseSmContextRequest(ue *amf_context.OcfUe, pduSessionId int32,
 	smContextReleaseData models.SmContextReleaseData) (detail *models.ProblemDetails, err error) {
+	defer observeLatency(models.NfType_SMF, time.Now())
+
 	smContext, ok := ue.SmContextList[pduSessionId]
 	if !ok {
 		err = openapi.ReportError("[OCF] pduSessionId : %d is not in Ue", pduSessionId)
@@ -2,6 +2,7 @@ package consumer
 
 import (
 	"context"
+	"time"
 
 	"github.com/antihax/optional"
 
@@ -12,6 +13,7 @@ import (
 )
 
 func PutUpuAck(ue *amf_context.OcfUe, upuMacIue string) error {
+	defer observeLatency(models.NfType_UDM, time.Now())
 
 	configuration := Nudm_SubscriberDataManagement.NewConfiguration()
 	configuration.SetBasePath(ue.NudmSDMUri)
@@ -28,6 +30,7 @@ func PutUpuAck(ue *amf_context.OcfUe, upuMacIue string) error {
 }
 
 func SDMGetAmData(ue *amf_context.OcfUe) (problemDetails *models.ProblemDetails, err error) {
+	defer observeLatency(models.NfType_UDM, time.Now())
 
 	configuration := Nudm_SubscriberDataManagement.NewConfiguration()
 	configuration.SetBasePath(ue.NudmSDMUri)
@@ -56,6 +59,7 @@ func SDMGetAmData(ue *amf_context.OcfUe) (problemDetails *models.ProblemDetails,
 }
 
 func SDMGetSmfSelectData(ue *amf_context.OcfUe) (problemDetails *models.ProblemDetails, err error) {
+	defer observeLatency(models.NfType_UDM, time.Now())
 
 	configuration := Nudm_SubscriberDataManagement.NewConfiguration()
 	configuration.SetBasePath(ue.NudmSDMUri)
@@ -83,6 +87,7 @@ func SDMGetSmfSelectData(ue *amf_context.OcfUe) (problemDetails *models.ProblemD
 }
 
 func SDMGetUeContextInSmfData(ue *amf_context.OcfUe) (problemDetails *models.ProblemDetails, err error) {
+	defer observeLatency(models.NfType_UDM, time.Now())
 
 	configuration := Nudm_SubscriberDataManagement.NewConfiguration()
 	configuration.SetBasePath(ue.NudmSDMUri)
@@ -107,15 +112,21 @@ func SDMGetUeContextInSmfData(ue *amf_context.OcfUe) (problemDetails *models.Pro
 }
 
 func SDMSubscribe(ue *amf_context.OcfUe) (problemDetails *models.ProblemDetails, err error) {
+	defer observeLatency(models.NfType_UDM, time.Now())
 
 	configuration := Nudm_SubscriberDataManagement.NewConfiguration()
 	configuration.SetBasePath(ue.NudmSDMUri)
 	client := Nudm_SubscriberDataManagement.NewAPIClient(configuration)
 
 	amfSelf := amf_context.OCF_Self()
+	// the changes of the access and mobility and of the slice selection subscription data are notified to
+	// update the configuration of the UE
+	resourceUri := ue.NudmSDMUri + "/nudm-sdm/v1/" + ue.Supi
 	sdmSubscription := models.SdmSubscription{
-		NfInstanceId: amfSelf.NfId,
-		PlmnId:       &ue.PlmnId,
+		NfInstanceId:          amfSelf.NfId,
+		CallbackReference:     amfSelf.GetIPv4Uri() + "/namf-callback/v1/sdm-data-change/" + ue.Supi,
+		MonitoredResourceUris: []string{resourceUri + "/am-data", resourceUri + "/nssai"},
+		PlmnId:                &ue.PlmnId,
 	}
 
 	_, httpResp, localErr := client.SubscriptionCreationApi.Subscribe(context.Background(), ue.Supi, sdmSubscription)
@@ -135,6 +146,8 @@ func SDMSubscribe(ue *amf_context.OcfUe) (problemDetails *models.ProblemDetails,
 }
 
 func SDMGetSliceSelectionSubscriptionData(ue *amf_context.OcfUe) (problemDetails *models.ProblemDetails, err error) {
+	defer observeLatency(models.NfType_UDM, time.Now())
+
 	configuration := Nudm_SubscriberDataManagement.NewConfiguration()
 	configuration.SetBasePath(ue.NudmSDMUri)
 	client := Nudm_SubscriberDataManagement.NewAPIClient(configuration)
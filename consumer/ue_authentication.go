@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/antihax/optional"
 
@@ -19,13 +20,15 @@ import (
 
 func SendUEAuthenticationAuthenticateRequest(ue *amf_context.OcfUe,
 	resynchronizationInfo *models.ResynchronizationInfo) (*models.UeAuthenticationCtx, *models.ProblemDetails, error) {
+	defer observeLatency(models.NfType_AUSF, time.Now())
+
 	configuration := Nausf_UEAuthentication.NewConfiguration()
 	configuration.SetBasePath(ue.AusfUri)
 
 	client := Nausf_UEAuthentication.NewAPIClient(configuration)
 
 	amfSelf := amf_context.OCF_Self()
-	servedGuami := amfSelf.ServedGuamiList[0]
+	servedGuami := amfSelf.Parameters().ServedGuamiList[0]
 
 	var authInfo models.AuthenticationInfo
 	authInfo.SupiOrSuci = ue.Suci
@@ -54,6 +57,7 @@ func SendUEAuthenticationAuthenticateRequest(ue *amf_context.OcfUe,
 
 func SendAuth5gAkaConfirmRequest(ue *amf_context.OcfUe, resStar string) (
 	*models.ConfirmationDataResponse, *models.ProblemDetails, error) {
+	defer observeLatency(models.NfType_AUSF, time.Now())
 
 	var ausfUri string
 	if confirmUri, err := url.Parse(ue.AuthenticationCtx.Links["link"].Href); err != nil {
@@ -93,6 +97,7 @@ func SendAuth5gAkaConfirmRequest(ue *amf_context.OcfUe, resStar string) (
 
 func SendEapAuthConfirmRequest(ue *amf_context.OcfUe, eapMsg nasType.EAPMessage) (
 	response *models.EapSession, problemDetails *models.ProblemDetails, err1 error) {
+	defer observeLatency(models.NfType_AUSF, time.Now())
 
 	confirmUri, err := url.Parse(ue.AuthenticationCtx.Links["link"].Href)
 	if err != nil {
@@ -2,6 +2,8 @@ package consumer
 
 import (
 	"context"
+	"net/http"
+	"time"
 
 	"free5gc/lib/openapi"
 	"free5gc/lib/openapi/Nudm_UEContextManagement"
@@ -11,19 +13,21 @@ import (
 
 func UeCmRegistration(ue *amf_context.OcfUe, accessType models.AccessType, initialRegistrationInd bool) (
 	*models.ProblemDetails, error) {
+	defer observeLatency(models.NfType_UDM, time.Now())
 
 	configuration := Nudm_UEContextManagement.NewConfiguration()
 	configuration.SetBasePath(ue.NudmUECMUri)
 	client := Nudm_UEContextManagement.NewAPIClient(configuration)
 
 	amfSelf := amf_context.OCF_Self()
+	parameters := amfSelf.Parameters()
 
 	switch accessType {
 	case models.AccessType__3_GPP_ACCESS:
 		registrationData := models.Ocf3GppAccessRegistration{
 			OcfInstanceId:          amfSelf.NfId,
 			InitialRegistrationInd: initialRegistrationInd,
-			Guami:                  &amfSelf.ServedGuamiList[0],
+			Guami:                  &parameters.ServedGuamiList[0],
 			RatType:                ue.RatType,
 			// TODO: not support Homogenous Support of IMS Voice over PS Sessions this stage
 			ImsVoPs: models.ImsVoPs_HOMOGENEOUS_NON_SUPPORT,
@@ -45,7 +49,7 @@ func UeCmRegistration(ue *amf_context.OcfUe, accessType models.AccessType, initi
 	case models.AccessType_NON_3_GPP_ACCESS:
 		registrationData := models.OcfNon3GppAccessRegistration{
 			OcfInstanceId: amfSelf.NfId,
-			Guami:         &amfSelf.ServedGuamiList[0],
+			Guami:         &parameters.ServedGuamiList[0],
 			RatType:       ue.RatType,
 		}
 
@@ -66,3 +70,48 @@ func UeCmRegistration(ue *amf_context.OcfUe, accessType models.AccessType, initi
 
 	return nil, nil
 }
+
+// UeCmDeregistration sets the purge flag of the registration of the OCF for the UE on the access type,
+// the UDM then removes it (TS 29.503 5.3.2.4)
+func UeCmDeregistration(ue *amf_context.OcfUe, accessType models.AccessType) (*models.ProblemDetails, error) {
+	defer observeLatency(models.NfType_UDM, time.Now())
+
+	configuration := Nudm_UEContextManagement.NewConfiguration()
+	configuration.SetBasePath(ue.NudmUECMUri)
+	client := Nudm_UEContextManagement.NewAPIClient(configuration)
+
+	parameters := amf_context.OCF_Self().Parameters()
+
+	var httpResp *http.Response
+	var localErr error
+	switch accessType {
+	case models.AccessType__3_GPP_ACCESS:
+		modificationData := models.Ocf3GppAccessRegistrationModification{
+			Guami:     &parameters.ServedGuamiList[0],
+			PurgeFlag: true,
+		}
+		httpResp, localErr = client.OCFRegistrationFor3GPPAccessApi.Update(context.Background(), ue.Supi,
+			modificationData)
+	case models.AccessType_NON_3_GPP_ACCESS:
+		modificationData := models.OcfNon3GppAccessRegistrationModification{
+			Guami:     &parameters.ServedGuamiList[0],
+			PurgeFlag: true,
+		}
+		httpResp, localErr = client.OCFRegistrationForNon3GPPAccessApi.Update(context.Background(), ue.Supi,
+			modificationData)
+	default:
+		return nil, nil
+	}
+
+	if localErr == nil {
+		return nil, nil
+	} else if httpResp != nil {
+		if httpResp.Status != localErr.Error() {
+			return nil, localErr
+		}
+		problem := localErr.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails)
+		return &problem, nil
+	} else {
+		return nil, openapi.ReportError("server no response")
+	}
+}
@@ -19,23 +19,99 @@ const (
 	MaxT3513RetryTimes                int   = 3
 	MaxT3522RetryTimes                int   = 4
 	MaxT3550RetryTimes                int   = 4
+	MaxT3555RetryTimes                int   = 4
 	MaxT3560RetryTimes                int   = 4
 	MaxT3565RetryTimes                int   = 4
 	MAxNumOfAlgorithm                 int   = 8
+	MaxNumOfRanConfigurationChanges   int   = 32
 	DefaultT3502                      int   = 720  // 12 min
 	DefaultT3512                      int   = 3240 // 54 min
 	DefaultNon3gppDeregistrationTimer int   = 3240 // 54 min
 )
 
-// timers at OCF side, defined in TS 24.501 table 10.2.2
+// the NAS COUNT is 24 bits long (TS 33.501 6.4.3.1), the UE is re-authenticated by default once fewer
+// than DefaultNasCountHeadroom values are left
+const (
+	MaxNasCount             uint32 = 0xffffff
+	DefaultNasCountHeadroom uint32 = 0x10000
+)
+
+// FC of the horizontal Kamf derivation (TS 33.501 A.13), which is missing from UeauCommon
+const FcForKamfHorizontalDerivation = "72"
+
+// default SCTP parameters of the NGAP associations
+const (
+	DefaultSctpNumOstreams    uint16 = 3
+	DefaultSctpMaxInstreams   uint16 = 5
+	DefaultSctpMaxAttempts    uint16 = 4
+	DefaultSctpMaxInitTimeout uint16 = 8
+)
+
+// default pcap capture parameters of the N2 traffic
+const (
+	DefaultNgapCaptureDirectory         = "./log/ngap"
+	DefaultNgapCaptureMaxFileSize int64 = 100 * 1024 * 1024
+	DefaultNgapCaptureMaxFiles          = 10
+)
+
+// default parameters of the NGAP overload control
+const (
+	DefaultOverloadCheckInterval        time.Duration = 1 * time.Second
+	DefaultOverloadQueueDepthStart                    = 5000
+	DefaultOverloadQueueDepthStop                     = 2500
+	DefaultOverloadSbiLatencyStart      time.Duration = 1 * time.Second
+	DefaultOverloadSbiLatencyStop       time.Duration = 500 * time.Millisecond
+	DefaultOverloadUeLoadStart                        = 90
+	DefaultOverloadUeLoadStop                         = 80
+	DefaultOverloadTrafficLoadReduction int64         = 50
+)
+
+// actions requested from the RANs by Overload Start, the values of Overload Action (TS 38.413 9.3.1.105)
+const (
+	OverloadActionRejectNonEmergencyMoDt  = "reject-non-emergency-mo-dt"
+	OverloadActionRejectRrcCrSignalling   = "reject-rrc-cr-signalling"
+	OverloadActionPermitEmergencyAndMt    = "permit-emergency-sessions-and-mt-services-only"
+	OverloadActionPermitHighPriorityAndMt = "permit-high-priority-sessions-and-mt-services-only"
+)
+
+// an OCF Configuration Update the RAN does not answer is sent again after TimeOcfConfigurationUpdate
+const (
+	TimeOcfConfigurationUpdate          time.Duration = 5 * time.Second
+	MaxOcfConfigurationUpdateRetryTimes int           = 3
+)
+
+// default timers at OCF side, defined in TS 24.501 table 10.2.2, the timers and the Max*RetryTimes
+// are configurable, see TimerParameters
 const (
 	TimeT3513 time.Duration = 6 * time.Second
 	TimeT3522 time.Duration = 6 * time.Second
 	TimeT3550 time.Duration = 6 * time.Second
+	TimeT3555 time.Duration = 6 * time.Second
 	TimeT3560 time.Duration = 6 * time.Second
 	TimeT3565 time.Duration = 6 * time.Second
 )
 
+// the mobile reachable timer of a UE in CM-IDLE over 3GPP access runs DefaultMobileReachableTimerMargin
+// longer than its T3512, then the implicit deregistration timer starts (TS 24.501 5.3.7). Over non-3GPP
+// access the implicit deregistration timer runs DefaultNon3gppImplicitDeregistrationTimerMargin longer
+// than the non-3GPP deregistration timer of the UE (TS 24.501 5.3.8).
+const (
+	DefaultMobileReachableTimerMargin               time.Duration = 4 * time.Minute
+	DefaultImplicitDeregistrationTimer              time.Duration = 4 * time.Minute
+	DefaultNon3gppImplicitDeregistrationTimerMargin time.Duration = 4 * time.Minute
+)
+
+// strategies of the registration area allocation, see RegistrationAreaAllocator
+const (
+	RegistrationAreaStrategyCurrentTai = "current-tai" // the current TAI of the UE only
+	RegistrationAreaStrategyStatic     = "static"      // the configured neighbour TA groups of the current TAI
+	RegistrationAreaStrategyServingRan = "serving-ran" // the TAIs of the RAN serving the UE
+	RegistrationAreaStrategyHistory    = "history"     // the TAIs the UE has visited most often
+)
+
+// DefaultRegistrationAreaHistorySize is the number of TAIs visited by a UE kept for the history strategy
+const DefaultRegistrationAreaHistorySize = 32
+
 type LADN struct {
 	Dnn      string
 	TaiLists []models.Tai
@@ -4,6 +4,7 @@ import (
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/logger"
 	"reflect"
+	"strings"
 
 	"github.com/mohae/deepcopy"
 )
@@ -39,6 +40,23 @@ func InTaiList(servedTai models.Tai, taiList []models.Tai) bool {
 	return false
 }
 
+// InPlmnSupportList tells if one of the S-NSSAIs is supported by the OCF in the PLMN
+func InPlmnSupportList(plmnId models.PlmnId, snssaiList []models.Snssai) bool {
+	for _, plmnItem := range OCF_Self().Parameters().PlmnSupportList {
+		if plmnItem.PlmnId != plmnId {
+			continue
+		}
+		for _, supported := range plmnItem.SNssaiList {
+			for _, snssai := range snssaiList {
+				if supported.Sst == snssai.Sst && strings.EqualFold(supported.Sd, snssai.Sd) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func TacInAreas(targetTac string, areas []models.Area) bool {
 	for _, area := range areas {
 		for _, tac := range area.Tacs {
@@ -0,0 +1,58 @@
+package context
+
+import (
+	"free5gc/lib/nas/nasType"
+)
+
+// ConfigurationUpdate selects the parameters of the UE sent by a Configuration Update Command (TS 24.501
+// 5.4.4), their new values are the ones in the context of the UE when the command is built
+type ConfigurationUpdate struct {
+	Guti             bool
+	RegistrationArea bool
+	AllowedNssai     bool
+	ConfiguredNssai  bool // with the rejected NSSAI
+	ServiceAreaList  bool
+	NetworkName      bool // full and short name for network
+	TimeZone         bool // local time zone, universal time and daylight saving time (NITZ)
+	LadnInformation  bool
+	MicoIndication   bool
+	// the command is retransmitted at each expiry of T3555 until the UE acknowledges it, an update of the
+	// time or the network name only is not acknowledged unless it is set
+	Acknowledge bool
+	// the UE registers again once its N1 NAS signalling connection is released, the command is
+	// acknowledged too
+	RegistrationRequested    bool
+	NetworkSlicingIndication *nasType.NetworkSlicingIndication
+}
+
+// AcknowledgeRequired is true if the update has to be acknowledged by the UE, the time and the network
+// name are the only parameters the UE may take without acknowledgement (TS 24.501 5.4.4.2)
+func (u *ConfigurationUpdate) AcknowledgeRequired() bool {
+	return u.Acknowledge || u.RegistrationRequested || u.Guti || u.RegistrationArea || u.AllowedNssai ||
+		u.ConfiguredNssai || u.ServiceAreaList || u.LadnInformation || u.MicoIndication ||
+		u.NetworkSlicingIndication != nil
+}
+
+// Empty is true if the update sends no parameter and requests no registration
+func (u *ConfigurationUpdate) Empty() bool {
+	return *u == ConfigurationUpdate{Acknowledge: u.Acknowledge}
+}
+
+// Merge adds the parameters of another update, a network slicing indication of the other update replaces
+// the one of this update
+func (u *ConfigurationUpdate) Merge(other ConfigurationUpdate) {
+	u.Guti = u.Guti || other.Guti
+	u.RegistrationArea = u.RegistrationArea || other.RegistrationArea
+	u.AllowedNssai = u.AllowedNssai || other.AllowedNssai
+	u.ConfiguredNssai = u.ConfiguredNssai || other.ConfiguredNssai
+	u.ServiceAreaList = u.ServiceAreaList || other.ServiceAreaList
+	u.NetworkName = u.NetworkName || other.NetworkName
+	u.TimeZone = u.TimeZone || other.TimeZone
+	u.LadnInformation = u.LadnInformation || other.LadnInformation
+	u.MicoIndication = u.MicoIndication || other.MicoIndication
+	u.Acknowledge = u.Acknowledge || other.Acknowledge
+	u.RegistrationRequested = u.RegistrationRequested || other.RegistrationRequested
+	if other.NetworkSlicingIndication != nil {
+		u.NetworkSlicingIndication = other.NetworkSlicingIndication
+	}
+}
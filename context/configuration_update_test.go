@@ -0,0 +1,24 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigurationUpdate(t *testing.T) {
+	update := ConfigurationUpdate{Acknowledge: true}
+	assert.True(t, update.Empty())
+
+	update = ConfigurationUpdate{TimeZone: true, NetworkName: true}
+	assert.False(t, update.Empty())
+	assert.False(t, update.AcknowledgeRequired())
+
+	update.Merge(ConfigurationUpdate{RegistrationArea: true})
+	assert.Equal(t, ConfigurationUpdate{TimeZone: true, NetworkName: true, RegistrationArea: true}, update)
+	assert.True(t, update.AcknowledgeRequired())
+
+	update = ConfigurationUpdate{RegistrationRequested: true}
+	assert.False(t, update.Empty())
+	assert.True(t, update.AcknowledgeRequired())
+}
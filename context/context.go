@@ -11,58 +11,143 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var amfContext = OCFContext{}
-var tmsiGenerator *idgenerator.IDGenerator = nil
+var tmsiPool *TmsiPool = nil
 var amfUeNGAPIDGenerator *idgenerator.IDGenerator = nil
 var amfStatusSubscriptionIDGenerator *idgenerator.IDGenerator = nil
 
 func init() {
 	OCF_Self().LadnPool = make(map[string]*LADN)
 	OCF_Self().EventSubscriptionIDGenerator = idgenerator.NewGenerator(1, math.MaxInt32)
-	OCF_Self().Name = "ocf"
 	OCF_Self().UriScheme = models.UriScheme_HTTPS
-	OCF_Self().RelativeCapacity = 0xff
-	OCF_Self().ServedGuamiList = make([]models.Guami, 0, MaxNumOfServedGuamiList)
-	OCF_Self().PlmnSupportList = make([]PlmnSupportItem, 0, MaxNumOfPLMNs)
 	OCF_Self().NfService = make(map[models.ServiceName]models.NfService)
-	OCF_Self().NetworkName.Full = "free5GC"
-	tmsiGenerator = idgenerator.NewGenerator(1, math.MaxInt32)
+	OCF_Self().SctpParameters = SctpParameters{
+		NumOstreams:    DefaultSctpNumOstreams,
+		MaxInstreams:   DefaultSctpMaxInstreams,
+		MaxAttempts:    DefaultSctpMaxAttempts,
+		MaxInitTimeout: DefaultSctpMaxInitTimeout,
+	}
+	OCF_Self().SetParameters(DefaultParameters())
+	// 0 is no 5G-TMSI and the 5G-TMSI of all ones is reserved (TS 23.003 2.4)
+	tmsiPool = NewTmsiPool(1, math.MaxUint32-1)
 	amfStatusSubscriptionIDGenerator = idgenerator.NewGenerator(1, math.MaxInt32)
 	amfUeNGAPIDGenerator = idgenerator.NewGenerator(1, MaxValueOfOcfUeNgapId)
 }
 
+// DefaultNgapCaptureParameters are the NGAP capture parameters used when they are not configured
+func DefaultNgapCaptureParameters() NgapCaptureParameters {
+	return NgapCaptureParameters{
+		Directory:   DefaultNgapCaptureDirectory,
+		MaxFileSize: DefaultNgapCaptureMaxFileSize,
+		MaxFiles:    DefaultNgapCaptureMaxFiles,
+	}
+}
+
+// DefaultOverloadControlParameters are the overload control parameters used when they are not
+// configured
+func DefaultOverloadControlParameters() OverloadControlParameters {
+	return OverloadControlParameters{
+		CheckInterval:        DefaultOverloadCheckInterval,
+		QueueDepthStart:      DefaultOverloadQueueDepthStart,
+		QueueDepthStop:       DefaultOverloadQueueDepthStop,
+		SbiLatencyStart:      DefaultOverloadSbiLatencyStart,
+		SbiLatencyStop:       DefaultOverloadSbiLatencyStop,
+		UeLoadStart:          DefaultOverloadUeLoadStart,
+		UeLoadStop:           DefaultOverloadUeLoadStop,
+		Action:               OverloadActionRejectNonEmergencyMoDt,
+		TrafficLoadReduction: DefaultOverloadTrafficLoadReduction,
+	}
+}
+
 type OCFContext struct {
-	EventSubscriptionIDGenerator    *idgenerator.IDGenerator
-	EventSubscriptions              sync.Map
-	UePool                          sync.Map         // map[supi]*OcfUe
-	RanUePool                       sync.Map         // map[OcfUeNgapID]*RanUe
-	OcfRanPool                      sync.Map         // map[net.Conn]*OcfRan
-	LadnPool                        map[string]*LADN // dnn as key
-	SupportTaiLists                 []models.Tai
+	EventSubscriptionIDGenerator *idgenerator.IDGenerator
+	EventSubscriptions           sync.Map
+	UePool                       sync.Map         // map[supi]*OcfUe
+	RanUePool                    sync.Map         // map[OcfUeNgapID]*RanUe
+	OcfRanPool                   sync.Map         // map[net.Conn]*OcfRan
+	LadnPool                     map[string]*LADN // dnn as key
+	NfId                         string
+	NfService                    map[models.ServiceName]models.NfService // nfservice that ocf support
+	UriScheme                    models.UriScheme
+	BindingIPv4                  string
+	SBIPort                      int
+	RegisterIPv4                 string
+	HttpIPv6Address              string
+	TNLWeightFactor              int64
+	OCFStatusSubscriptions       sync.Map // map[subscriptionID]models.SubscriptionData
+	NrfUri                       string
+	NgapIpList                   []string // NGAP Server IP
+	SctpParameters               SctpParameters
+	parameters                   atomic.Value // *Parameters
+	parametersMutex              sync.Mutex
+}
+
+// Parameters of the OCF which can be changed at runtime. They are published as a whole so that a
+// procedure which reads them once sees either the previous or the new parameters, never a mix of
+// both; published parameters are never modified.
+type Parameters struct {
+	Name                            string
+	RelativeCapacity                int64
 	ServedGuamiList                 []models.Guami
+	SupportTaiLists                 []models.Tai
 	PlmnSupportList                 []PlmnSupportItem
-	RelativeCapacity                int64
-	NfId                            string
-	Name                            string
-	NfService                       map[models.ServiceName]models.NfService // nfservice that ocf support
-	UriScheme                       models.UriScheme
-	BindingIPv4                     string
-	SBIPort                         int
-	RegisterIPv4                    string
-	HttpIPv6Address                 string
-	TNLWeightFactor                 int64
 	SupportDnnLists                 []string
-	OCFStatusSubscriptions          sync.Map // map[subscriptionID]models.SubscriptionData
-	NrfUri                          string
 	SecurityAlgorithm               SecurityAlgorithm
 	NetworkName                     NetworkName
-	NgapIpList                      []string // NGAP Server IP
-	T3502Value                      int      // unit is second
-	T3512Value                      int      // unit is second
-	Non3gppDeregistrationTimerValue int      // unit is second
+	T3502Value                      int // unit is second
+	T3512Value                      int // unit is second
+	Non3gppDeregistrationTimerValue int // unit is second
+	NgapCapture                     NgapCaptureParameters
+	OverloadControl                 OverloadControlParameters
+	Timers                          TimerParameters
+	Mico                            MicoParameters
+	Emergency                       EmergencyParameters
+	Reauthentication                ReauthenticationParameters
+	KamfHorizontalDerivation        bool // derive a new Kamf for the new OCF of the UEs at idle mode mobility
+	GutiReallocation                GutiReallocationParameters
+	RegistrationArea                RegistrationAreaParameters
+}
+
+// DefaultParameters are the parameters used before the configuration is read
+func DefaultParameters() *Parameters {
+	return &Parameters{
+		Name:             "ocf",
+		RelativeCapacity: 0xff,
+		ServedGuamiList:  make([]models.Guami, 0, MaxNumOfServedGuamiList),
+		PlmnSupportList:  make([]PlmnSupportItem, 0, MaxNumOfPLMNs),
+		NetworkName:      NetworkName{Full: "free5GC"},
+		NgapCapture:      DefaultNgapCaptureParameters(),
+		OverloadControl:  DefaultOverloadControlParameters(),
+		Timers:           DefaultTimerParameters(),
+		RegistrationArea: DefaultRegistrationAreaParameters(),
+	}
+}
+
+// Parameters returns the current parameters, they must not be modified
+func (context *OCFContext) Parameters() *Parameters {
+	return context.parameters.Load().(*Parameters)
+}
+
+// SetParameters publishes new parameters
+func (context *OCFContext) SetParameters(parameters *Parameters) {
+	context.parametersMutex.Lock()
+	defer context.parametersMutex.Unlock()
+	context.parameters.Store(parameters)
+}
+
+// UpdateParameters publishes a copy of the current parameters changed by update, the updates do not
+// overwrite each other. update replaces the slices and maps it changes instead of modifying them
+// since they are shared with the current parameters.
+func (context *OCFContext) UpdateParameters(update func(parameters *Parameters)) {
+	context.parametersMutex.Lock()
+	defer context.parametersMutex.Unlock()
+	parameters := *context.Parameters()
+	update(&parameters)
+	context.parameters.Store(&parameters)
 }
 
 type OCFContextEventSubscription struct {
@@ -83,6 +168,103 @@ type NetworkName struct {
 	Short string `yaml:"short,omitempty"`
 }
 
+// SctpParameters of the NGAP SCTP associations, stream 0 is reserved for non-UE-associated
+// signalling and UE-associated signalling is spread over the other streams (TS 38.412 7)
+type SctpParameters struct {
+	NumOstreams    uint16
+	MaxInstreams   uint16
+	MaxAttempts    uint16
+	MaxInitTimeout uint16 // unit is second
+}
+
+// NgapCaptureParameters of the pcap capture of the N2 traffic, an empty RanList captures all RANs
+type NgapCaptureParameters struct {
+	Enable      bool
+	Directory   string
+	MaxFileSize int64 // unit is byte
+	MaxFiles    int
+	RanList     []string // Global RAN Node IDs, see OcfRan.RanIdString()
+}
+
+// OverloadControlParameters of the NGAP overload control. The OCF is overloaded when a measurement
+// reaches its start threshold and recovers when every measurement is under its stop threshold, a
+// start threshold of 0 disables the measurement.
+type OverloadControlParameters struct {
+	Enable          bool
+	CheckInterval   time.Duration
+	QueueDepthStart int // pending NGAP messages
+	QueueDepthStop  int
+	SbiLatencyStart time.Duration // average latency of the requests to UDM, AUSF or SMF
+	SbiLatencyStop  time.Duration
+	UeCapacity      int // number of UE contexts, the UE load is disabled if 0
+	UeLoadStart     int // percentage of UeCapacity
+	UeLoadStop      int
+	// Overload Start content
+	Action               string // OverloadActionXXX
+	TrafficLoadReduction int64  // percentage (1~99), 0 for none
+	SliceList            []models.Snssai
+}
+
+// MicoParameters of the MICO mode (TS 23.501 5.4.1.3). A MICO UE in CM-IDLE is reachable during the
+// active time only, then it is not paged until it connects again. The extended periodic timer replaces
+// T3512 for the MICO UEs if it is not 0.
+type MicoParameters struct {
+	Enable                  bool
+	AllPlmnRegistrationArea bool
+	ActiveTime              int // unit is second
+	ExtendedPeriodicTimer   int // unit is second
+}
+
+// EmergencyParameters of the emergency services (TS 23.501 5.16.4). The UEs without SIM and the
+// UEs which fail the authentication are registered for emergency services only if AllowWithoutSim,
+// the null security algorithms are used for them.
+type EmergencyParameters struct {
+	Enable          bool
+	Dnn             string
+	Snssai          models.Snssai
+	SmfUri          string // the SMF is discovered at the NRF if empty
+	AllowWithoutSim bool
+}
+
+// ReauthenticationParameters of the primary re-authentication of the registered UEs, which gives them a
+// new Kamf and thus new NAS COUNTs (TS 33.501 6.9.3). A UE is re-authenticated once fewer than
+// NasCountHeadroom NAS COUNT values are left in either direction, and at the registration after
+// Interval or after Registrations registrations since its last authentication, 0 disables a trigger.
+type ReauthenticationParameters struct {
+	NasCountHeadroom uint32
+	Interval         time.Duration
+	Registrations    int
+}
+
+// GutiReallocationParameters of the 5G-GUTI reallocation, which keeps the UEs from being tracked by their
+// 5G-GUTI (TS 33.501 6.12.3). A UE is given a new 5G-GUTI in the Registration Accept of each registration if
+// Registration, and by a Configuration Update Command after ServiceRequests service requests or Interval
+// since its last 5G-GUTI, 0 disables a trigger.
+type GutiReallocationParameters struct {
+	Registration    bool
+	ServiceRequests int
+	Interval        time.Duration
+}
+
+// RegistrationAreaParameters of the allocation of the registration areas. The registration area of a UE holds
+// its current TAI and the TAIs proposed by the allocator of Strategy, up to MaxTais TAIs (TS 23.501
+// 5.3.2.3). HistorySize TAIs visited by each UE are kept for the history strategy.
+type RegistrationAreaParameters struct {
+	Strategy    string         // RegistrationAreaStrategyXXX or the name of a registered allocator
+	MaxTais     int            // 1~MaxNumOfTAI
+	TaiGroups   [][]models.Tai // neighbour TAs of the static strategy
+	HistorySize int
+}
+
+// DefaultRegistrationAreaParameters are the registration area parameters used when they are not configured
+func DefaultRegistrationAreaParameters() RegistrationAreaParameters {
+	return RegistrationAreaParameters{
+		Strategy:    RegistrationAreaStrategyCurrentTai,
+		MaxTais:     MaxNumOfTAI,
+		HistorySize: DefaultRegistrationAreaHistorySize,
+	}
+}
+
 type SecurityAlgorithm struct {
 	IntegrityOrder []uint8 // slice of security.AlgIntegrityXXX
 	CipheringOrder []uint8 // slice of security.AlgCipheringXXX
@@ -93,13 +275,13 @@ func NewPlmnSupportItem() (item PlmnSupportItem) {
 	return
 }
 
-func (context *OCFContext) TmsiAllocate() int32 {
-	tmsi, err := tmsiGenerator.Allocate()
+func (context *OCFContext) TmsiAllocate() uint32 {
+	tmsi, err := tmsiPool.Allocate()
 	if err != nil {
 		logger.ContextLog.Errorf("Allocate TMSI error: %+v", err)
-		return -1
+		return 0
 	}
-	return int32(tmsi)
+	return tmsi
 }
 
 func (context *OCFContext) AllocateOcfUeNgapID() (int64, error) {
@@ -107,14 +289,40 @@ func (context *OCFContext) AllocateOcfUeNgapID() (int64, error) {
 }
 
 func (context *OCFContext) AllocateGutiToUe(ue *OcfUe) {
-	servedGuami := context.ServedGuamiList[0]
+	servedGuami := context.Parameters().ServedGuamiList[0]
 	ue.Tmsi = context.TmsiAllocate()
 
 	plmnID := servedGuami.PlmnId.Mcc + servedGuami.PlmnId.Mnc
 	tmsiStr := fmt.Sprintf("%08x", ue.Tmsi)
 	ue.Guti = plmnID + servedGuami.OcfId + tmsiStr
+	ue.GutiAllocationTime = time.Now()
+	ue.ServiceRequestsSinceGutiAllocation = 0
+}
+
+// ReallocateGuti gives the UE a new 5G-GUTI, the old one stays valid until the UE acknowledges the new one and
+// FreeOldGuti is called (TS 24.501 5.4.4.2)
+func (context *OCFContext) ReallocateGuti(ue *OcfUe) {
+	if ue.OldGuti == "" {
+		ue.OldGuti, ue.OldTmsi = ue.Guti, ue.Tmsi
+	} else {
+		// the 5G-GUTI allocated last was never acknowledged
+		tmsiPool.Free(ue.Tmsi)
+	}
+	context.AllocateGutiToUe(ue)
+	logger.ContextLog.Infof("Reallocate GUTI[%s] of UE[%s] to GUTI[%s]", ue.OldGuti, ue.Supi, ue.Guti)
 }
 
+// FreeOldGuti frees the 5G-GUTI replaced by ReallocateGuti once the UE uses the new one
+func (context *OCFContext) FreeOldGuti(ue *OcfUe) {
+	if ue.OldGuti == "" {
+		return
+	}
+	tmsiPool.Free(ue.OldTmsi)
+	ue.OldGuti, ue.OldTmsi = "", 0
+}
+
+// AllocateRegistrationArea gives the UE a registration area of its current TAI and the TAIs proposed by the
+// allocator of the configured strategy, which the OCF supports and the UE may access
 func (context *OCFContext) AllocateRegistrationArea(ue *OcfUe, anType models.AccessType) {
 
 	// clear the previous registration area if need
@@ -122,14 +330,38 @@ func (context *OCFContext) AllocateRegistrationArea(ue *OcfUe, anType models.Acc
 		ue.RegistrationArea[anType] = nil
 	}
 
+	parameters := context.Parameters()
 	// allocate a new tai list as a registration area to ue
-	// TODO: algorithm to choose TAI list
-	for _, supportTai := range context.SupportTaiLists {
+	for _, supportTai := range parameters.SupportTaiLists {
 		if reflect.DeepEqual(supportTai, ue.Tai) {
 			ue.RegistrationArea[anType] = append(ue.RegistrationArea[anType], supportTai)
 			break
 		}
 	}
+	if len(ue.RegistrationArea[anType]) == 0 {
+		return
+	}
+
+	allocator, ok := registrationAreaAllocatorOf(parameters.RegistrationArea.Strategy)
+	if !ok {
+		logger.ContextLog.Warnf("Unknown registration area strategy[%s], use the current TAI only",
+			parameters.RegistrationArea.Strategy)
+		return
+	}
+	maxTais := parameters.RegistrationArea.MaxTais
+	if maxTais <= 0 || maxTais > MaxNumOfTAI {
+		maxTais = MaxNumOfTAI
+	}
+	for _, tai := range allocator(ue, anType) {
+		if len(ue.RegistrationArea[anType]) >= maxTais {
+			break
+		}
+		if InTaiList(tai, ue.RegistrationArea[anType]) || !InTaiList(tai, parameters.SupportTaiLists) ||
+			!ue.TaiAccessible(tai, anType) {
+			continue
+		}
+		ue.RegistrationArea[anType] = append(ue.RegistrationArea[anType], tai)
+	}
 }
 
 func (context *OCFContext) NewOCFStatusSubscription(subscriptionData models.SubscriptionData) (subscriptionID string) {
@@ -260,38 +492,49 @@ func (context *OCFContext) OcfRanFindByRanID(ranNodeID models.GlobalRanNodeId) (
 	var ok bool
 	context.OcfRanPool.Range(func(key, value interface{}) bool {
 		amfRan := value.(*OcfRan)
-		switch amfRan.RanPresent {
-		case RanPresentGNbId:
-			logger.ContextLog.Infof("aaa: %+v\n", amfRan.RanId.GNbId)
-			if amfRan.RanId.GNbId.GNBValue == ranNodeID.GNbId.GNBValue {
-				ran = amfRan
-				ok = true
-				return false
-			}
-		case RanPresentNgeNbId:
-			if amfRan.RanId.NgeNbId == ranNodeID.NgeNbId {
-				ran = amfRan
-				ok = true
-				return false
-			}
-		case RanPresentN3IwfId:
-			if amfRan.RanId.N3IwfId == ranNodeID.N3IwfId {
-				ran = amfRan
-				ok = true
-				return false
-			}
+		if amfRan.RanId != nil && SameRanId(*amfRan.RanId, ranNodeID) {
+			ran = amfRan
+			ok = true
+			return false
 		}
 		return true
 	})
 	return ran, ok
 }
 
+// OcfRanFindDuplicates returns the other RANs with the Global RAN Node ID of ran, e.g. the old
+// association of a RAN which has reconnected before the old one was torn down
+func (context *OCFContext) OcfRanFindDuplicates(ran *OcfRan) (duplicates []*OcfRan) {
+	if ran.RanId == nil {
+		return nil
+	}
+	context.OcfRanPool.Range(func(key, value interface{}) bool {
+		amfRan := value.(*OcfRan)
+		if amfRan != ran && amfRan.RanId != nil && SameRanId(*amfRan.RanId, *ran.RanId) {
+			duplicates = append(duplicates, amfRan)
+		}
+		return true
+	})
+	return duplicates
+}
+
+// SetUpOcfRans returns the RANs which have completed the NG Setup
+func (context *OCFContext) SetUpOcfRans() (rans []*OcfRan) {
+	context.OcfRanPool.Range(func(key, value interface{}) bool {
+		if ran := value.(*OcfRan); ran.RanId != nil {
+			rans = append(rans, ran)
+		}
+		return true
+	})
+	return rans
+}
+
 func (context *OCFContext) DeleteOcfRan(conn net.Conn) {
 	context.OcfRanPool.Delete(conn)
 }
 
 func (context *OCFContext) InSupportDnnList(targetDnn string) bool {
-	for _, dnn := range context.SupportDnnLists {
+	for _, dnn := range context.Parameters().SupportDnnLists {
 		if dnn == targetDnn {
 			return true
 		}
@@ -302,7 +545,8 @@ func (context *OCFContext) InSupportDnnList(targetDnn string) bool {
 func (context *OCFContext) OcfUeFindByGuti(guti string) (ue *OcfUe, ok bool) {
 	context.UePool.Range(func(key, value interface{}) bool {
 		candidate := value.(*OcfUe)
-		if ok = (candidate.Guti == guti); ok {
+		// the old 5G-GUTI is valid as long as the UE has not acknowledged the new one
+		if ok = (candidate.Guti == guti || (candidate.OldGuti != "" && candidate.OldGuti == guti)); ok {
 			ue = candidate
 			return false
 		}
@@ -387,17 +631,13 @@ func (context *OCFContext) Reset() {
 	for key := range context.NfService {
 		delete(context.NfService, key)
 	}
-	context.SupportTaiLists = context.SupportTaiLists[:0]
-	context.PlmnSupportList = context.PlmnSupportList[:0]
-	context.ServedGuamiList = context.ServedGuamiList[:0]
-	context.RelativeCapacity = 0xff
+	context.SetParameters(DefaultParameters())
 	context.NfId = ""
 	context.UriScheme = models.UriScheme_HTTPS
 	context.SBIPort = 0
 	context.BindingIPv4 = ""
 	context.RegisterIPv4 = ""
 	context.HttpIPv6Address = ""
-	context.Name = "ocf"
 	context.NrfUri = ""
 }
 
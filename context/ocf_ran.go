@@ -7,6 +7,9 @@ import (
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/logger"
 	"net"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -24,9 +27,18 @@ type OcfRan struct {
 	Conn net.Conn
 	/* Supported TA List */
 	SupportedTAList []SupportedTAI
+	/* capabilities from the NG Setup */
+	DefaultPagingDRX int  // paging cycle in radio frames, 0 if unknown
+	UeRetention      bool // the RAN keeps the UE contexts over a new NG Setup
+	SetupTime        time.Time
+	/* changes by RAN Configuration Update */
+	configurationHistory []RanConfigurationChange
+	historyMutex         sync.Mutex
 
 	/* RAN UE List */
 	RanUeList []*RanUe // RanUeNgapId as key
+	// UEs of one RAN are handled by different NGAP workers concurrently
+	ranUeListMutex sync.RWMutex
 }
 
 type SupportedTAI struct {
@@ -54,21 +66,37 @@ func (ran *OcfRan) NewRanUe(ranUeNgapID int64) (*RanUe, error) {
 	ranUe.OcfUeNgapId = amfUeNgapID
 	ranUe.RanUeNgapId = ranUeNgapID
 	ranUe.Ran = ran
+	if ranUeNgapID == RanUeNgapIdUnspecified {
+		ranUe.LaneKey = UeLaneKey{Ran: ran, RanUeNgapId: ranUeNgapID, OcfUeNgapId: amfUeNgapID}
+	} else {
+		ranUe.LaneKey = UeLaneKey{Ran: ran, RanUeNgapId: ranUeNgapID}
+	}
 
-	ran.RanUeList = append(ran.RanUeList, &ranUe)
+	ran.addRanUe(&ranUe)
 	self.RanUePool.Store(ranUe.OcfUeNgapId, &ranUe)
 	return &ranUe, nil
 }
 
 func (ran *OcfRan) RemoveAllUeInRan() {
-	for _, ranUe := range ran.RanUeList {
+	for _, ranUe := range ran.RanUes() {
 		if err := ranUe.Remove(); err != nil {
 			logger.ContextLog.Errorf("Remove RanUe error: %v", err)
 		}
 	}
 }
 
+// RanUes returns a snapshot of the RAN UE list which is safe to iterate while UEs are added or removed
+func (ran *OcfRan) RanUes() []*RanUe {
+	ran.ranUeListMutex.RLock()
+	defer ran.ranUeListMutex.RUnlock()
+	ranUes := make([]*RanUe, len(ran.RanUeList))
+	copy(ranUes, ran.RanUeList)
+	return ranUes
+}
+
 func (ran *OcfRan) RanUeFindByRanUeNgapID(ranUeNgapID int64) *RanUe {
+	ran.ranUeListMutex.RLock()
+	defer ran.ranUeListMutex.RUnlock()
 	for _, ranUe := range ran.RanUeList {
 		if ranUe.RanUeNgapId == ranUeNgapID {
 			return ranUe
@@ -77,6 +105,87 @@ func (ran *OcfRan) RanUeFindByRanUeNgapID(ranUeNgapID int64) *RanUe {
 	return nil
 }
 
+func (ran *OcfRan) RanUeFindByOcfUeNgapID(amfUeNgapID int64) *RanUe {
+	ran.ranUeListMutex.RLock()
+	defer ran.ranUeListMutex.RUnlock()
+	for _, ranUe := range ran.RanUeList {
+		if ranUe.OcfUeNgapId == amfUeNgapID {
+			return ranUe
+		}
+	}
+	return nil
+}
+
+func (ran *OcfRan) addRanUe(ranUe *RanUe) {
+	ran.ranUeListMutex.Lock()
+	defer ran.ranUeListMutex.Unlock()
+	ran.RanUeList = append(ran.RanUeList, ranUe)
+}
+
+func (ran *OcfRan) removeRanUe(ranUe *RanUe) {
+	ran.ranUeListMutex.Lock()
+	defer ran.ranUeListMutex.Unlock()
+	for index, ranUe1 := range ran.RanUeList {
+		if ranUe1 == ranUe {
+			ran.RanUeList = append(ran.RanUeList[:index], ran.RanUeList[index+1:]...)
+			break
+		}
+	}
+}
+
+// RanIdString is the Global RAN Node ID of the RAN as used by OAM, e.g. 208-93-gnb-000001,
+// it is empty before the NG Setup
+func (ran *OcfRan) RanIdString() string {
+	ranId := ran.RanId
+	if ranId == nil || ranId.PlmnId == nil {
+		return ""
+	}
+	plmn := fmt.Sprintf("%s-%s", ranId.PlmnId.Mcc, ranId.PlmnId.Mnc)
+	switch ran.RanPresent {
+	case RanPresentGNbId:
+		if ranId.GNbId != nil {
+			return fmt.Sprintf("%s-gnb-%s", plmn, ranId.GNbId.GNBValue)
+		}
+	case RanPresentNgeNbId:
+		return fmt.Sprintf("%s-ngenb-%s", plmn, ranId.NgeNbId)
+	case RanPresentN3IwfId:
+		return fmt.Sprintf("%s-n3iwf-%s", plmn, ranId.N3IwfId)
+	}
+	return ""
+}
+
+// SameRanId tells if two Global RAN Node IDs are equal, PLMN included
+func SameRanId(ranId1, ranId2 models.GlobalRanNodeId) bool {
+	if ranId1.PlmnId == nil || ranId2.PlmnId == nil {
+		if ranId1.PlmnId != ranId2.PlmnId {
+			return false
+		}
+	} else if *ranId1.PlmnId != *ranId2.PlmnId {
+		return false
+	}
+	if ranId1.GNbId != nil || ranId2.GNbId != nil {
+		return ranId1.GNbId != nil && ranId2.GNbId != nil &&
+			ranId1.GNbId.BitLength == ranId2.GNbId.BitLength &&
+			strings.EqualFold(ranId1.GNbId.GNBValue, ranId2.GNbId.GNBValue)
+	}
+	return strings.EqualFold(ranId1.NgeNbId, ranId2.NgeNbId) && strings.EqualFold(ranId1.N3IwfId, ranId2.N3IwfId)
+}
+
+// PagingDRXCycle is the paging cycle in radio frames of a Paging DRX IE, 0 if unknown
+func PagingDRXCycle(pagingDRX ngapType.PagingDRX) int {
+	switch pagingDRX.Value {
+	case ngapType.PagingDRXPresentV32:
+		return 32
+	case ngapType.PagingDRXPresentV64:
+		return 64
+	case ngapType.PagingDRXPresentV128:
+		return 128
+	case ngapType.PagingDRXPresentV256:
+		return 256
+	}
+	return 0
+}
+
 func (ran *OcfRan) SetRanId(ranNodeId *ngapType.GlobalRANNodeID) {
 	ranId := ngapConvert.RanIdToModels(*ranNodeId)
 	ran.RanPresent = ranNodeId.Present
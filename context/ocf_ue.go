@@ -10,6 +10,7 @@ import (
 	"free5gc/lib/nas/nasMessage"
 	"free5gc/lib/nas/nasType"
 	"free5gc/lib/nas/security"
+	"free5gc/lib/ngap/ngapType"
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/logger"
 	"reflect"
@@ -53,6 +54,8 @@ type OcfUe struct {
 	RegistrationType5GS                uint8
 	IdentityTypeUsedForRegistration    uint8
 	RegistrationRequest                *nasMessage.RegistrationRequest
+	RegistrationRequestNasPdu          []byte // as sent by the UE, the old OCF checks its integrity at the context transfer
+	RequestedT3324Value                int    // active time requested by the UE, unit is second, 0 if none
 	ServingOcfChanged                  bool
 	DeregistrationTargetAccessType     uint8 // only used when deregistration procedure is initialized by the network
 	RegistrationAcceptForNon3GPPAccess []byte
@@ -67,8 +70,10 @@ type OcfUe struct {
 	UnauthenticatedSupi bool
 	Gpsi                string
 	Pei                 string
-	Tmsi                int32 // 5G-Tmsi
+	Tmsi                uint32 // 5G-Tmsi
 	Guti                string
+	OldGuti             string // replaced by Guti, valid until the UE acknowledges the new 5G-GUTI
+	OldTmsi             uint32
 	GroupID             string
 	EBI                 int32
 	/* Ue Identity*/
@@ -110,7 +115,8 @@ type OcfUe struct {
 	AmPolicyUri                  string
 	AmPolicyAssociation          *models.PolicyAssociation
 	RequestTriggerLocationChange bool // true if AmPolicyAssociation.Trigger contains RequestTrigger_LOC_CH
-	ConfigurationUpdateMessage   []byte
+	// configuration update of the UE in CM-IDLE, it is sent once the UE connects
+	PendingConfigurationUpdate *ConfigurationUpdate
 	/* UeContextForHandover*/
 	HandoverNotifyUri string
 	/* N1N2Message */
@@ -148,9 +154,20 @@ type OcfUe struct {
 	DLCount                  security.Count
 	CipheringAlg             uint8
 	IntegrityAlg             uint8
+	/* Re-authentication, which gives the UE a new Kamf (TS 33.501 6.9.3) */
+	AuthenticationTime               time.Time // of the current Kamf
+	RegistrationsSinceAuthentication int
+	ReauthenticationRequired         bool // e.g. the NAS COUNTs are about to wrap around
+	Reauthenticating                 bool // the re-authentication of the registered UE initiated by the OCF is ongoing
+	/* Horizontal Kamf derivation at the change of OCF (TS 33.501 6.9.2.3.3, 6.9.3) */
+	KamfHorizontallyDerived bool    // the old OCF handed over a derived Kamf, which is taken into use by an NAS SMC
+	KamfDerivedAtHandover   bool    // the UE derived it from the Handover Command, the NAS SMC does not ask it to
+	HandoverKamfCount       *uint32 // downlink NAS COUNT of the Kamf derived for the target OCF at N2 handover
 	/* Registration Area */
-	RegistrationArea map[models.AccessType][]models.Tai
-	LadnInfo         []LADN
+	RegistrationArea      map[models.AccessType][]models.Tai
+	LadnInfo              []LADN
+	TaiHistory            []models.Tai // TAIs the UE has visited, the latest last, see AddVisitedTai
+	MobilityRegistrations int          // mobility registration updates since the UE context is created
 	/* Network Slicing related context and Nssf */
 	NssfId                            string
 	NssfUri                           string
@@ -161,6 +178,9 @@ type OcfUe struct {
 	/* T3513(Paging) */
 	T3513           *time.Timer // for paging
 	T3513RetryTimes int
+	// the RANs which serve a TA of the registration area during the paging are paged with these
+	PagingPriority      *ngapType.PagingPriority
+	PagingOriginNon3GPP bool
 	/* T3565(Notification) */
 	T3565           *time.Timer // for NAS Notification
 	T3565RetryTimes int
@@ -170,6 +190,12 @@ type OcfUe struct {
 	/* T3550 (for registration accept retransmission) */
 	T3550           *time.Timer
 	T3550RetryTimes int
+	/* T3555 (for configuration update command retransmission) */
+	T3555           *time.Timer
+	T3555RetryTimes int
+	/* GUTI reallocation */
+	GutiAllocationTime                 time.Time
+	ServiceRequestsSinceGutiAllocation int
 	/* Ue Context Release Cause */
 	ReleaseCause map[models.AccessType]*CauseAll
 	/* T3502 (Assigned by OCF, and used by UE to initialize registration procedure) */
@@ -179,6 +205,18 @@ type OcfUe struct {
 	/* T3522 (for deregistration request) */
 	T3522           *time.Timer
 	T3522RetryTimes int
+	/* Mobile Reachable Timer and Implicit Deregistration Timer (3GPP access in CM-IDLE) */
+	MobileReachableTimer        *time.Timer
+	ImplicitDeregistrationTimer *time.Timer
+	/* Non-3GPP Implicit Deregistration Timer (non-3GPP access in CM-IDLE) */
+	Non3gppImplicitDeregistrationTimer *time.Timer
+	/* MICO mode, negotiated at each registration over 3GPP access */
+	MicoMode                    bool
+	MicoAllPlmnRegistrationArea bool
+	T3324Value                  int         // active time, unit is second
+	ActiveTimer                 *time.Timer // T3324 at OCF side, the UE is reachable while it runs
+	/* Emergency services */
+	EmergencyRegistered bool
 }
 
 type OcfUeEventSubscription struct {
@@ -210,6 +248,7 @@ type StoredSmContext struct {
 	PduSessionContext *models.PduSessionContext
 	AnType            models.AccessType
 	Payload           []byte
+	RequestType       models.RequestType
 }
 
 type UERadioCapabilityForPaging struct {
@@ -263,6 +302,33 @@ func (ue *OcfUe) init() {
 	ue.ReleaseCause = make(map[models.AccessType]*CauseAll)
 }
 
+// CmStateHandler reacts to a UE entering CM-CONNECTED or CM-IDLE on the access type, it is called by
+// the procedure which attaches or removes the RAN UE
+type CmStateHandler func(ue *OcfUe, anType models.AccessType, connected bool)
+
+var (
+	cmStateHandlersMutex sync.RWMutex
+	cmStateHandlers      []CmStateHandler
+)
+
+// RegisterCmStateHandler adds a handler called at each change of the CM state of a UE
+func RegisterCmStateHandler(handler CmStateHandler) {
+	cmStateHandlersMutex.Lock()
+	defer cmStateHandlersMutex.Unlock()
+	cmStateHandlers = append(cmStateHandlers, handler)
+}
+
+func publishCmState(ue *OcfUe, anType models.AccessType, connected bool) {
+	cmStateHandlersMutex.RLock()
+	handlers := make([]CmStateHandler, len(cmStateHandlers))
+	copy(handlers, cmStateHandlers)
+	cmStateHandlersMutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ue, anType, connected)
+	}
+}
+
 func (ue *OcfUe) CmConnect(anType models.AccessType) bool {
 	if _, ok := ue.RanUe[anType]; !ok {
 		return false
@@ -280,19 +346,54 @@ func (ue *OcfUe) Remove() {
 			logger.ContextLog.Errorf("Remove RanUe error: %v", err)
 		}
 	}
-	tmsiGenerator.FreeID(int64(ue.Tmsi))
+	// the timers of the UE in CM-IDLE would handle a UE which is gone
+	for _, timer := range []*time.Timer{ue.MobileReachableTimer, ue.ImplicitDeregistrationTimer,
+		ue.ActiveTimer, ue.Non3gppImplicitDeregistrationTimer} {
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	ue.MobileReachableTimer, ue.ImplicitDeregistrationTimer = nil, nil
+	ue.ActiveTimer, ue.Non3gppImplicitDeregistrationTimer = nil, nil
+	tmsiPool.Free(ue.Tmsi)
+	OCF_Self().FreeOldGuti(ue)
 	if len(ue.Supi) > 0 {
 		OCF_Self().UePool.Delete(ue.Supi)
+	} else if len(ue.Pei) > 0 {
+		OCF_Self().UePool.Delete(ue.Pei)
 	}
 }
 
+// UnauthenticatedEmergency is true for the UEs registered for emergency services without SIM or
+// whose authentication failed, the SUPI of such a UE is unauthenticated or unknown
+func (ue *OcfUe) UnauthenticatedEmergency() bool {
+	return ue.EmergencyRegistered && ue.UnauthenticatedSupi
+}
+
 func (ue *OcfUe) DetachRanUe(anType models.AccessType) {
 	delete(ue.RanUe, anType)
 }
 
+// AttachRanUe makes the RAN UE the N2 connection of the UE on its access type, the UE is in CM-CONNECTED
 func (ue *OcfUe) AttachRanUe(ranUe *RanUe) {
 	ue.RanUe[ranUe.Ran.AnType] = ranUe
 	ranUe.OcfUe = ue
+	publishCmState(ue, ranUe.Ran.AnType, true)
+}
+
+// UESpecificDRXCycle is the paging cycle in radio frames negotiated with the UE, 0 if not specified
+func (ue *OcfUe) UESpecificDRXCycle() int {
+	switch ue.UESpecificDRX {
+	case nasMessage.DRXcycleParameterT32:
+		return 32
+	case nasMessage.DRXcycleParameterT64:
+		return 64
+	case nasMessage.DRXcycleParameterT128:
+		return 128
+	case nasMessage.DRXcycleParameterT256:
+		return 256
+	}
+	return 0
 }
 
 func (ue *OcfUe) GetAnType() models.AccessType {
@@ -377,6 +478,16 @@ func (ue *OcfUe) SecurityContextIsValid() bool {
 	return ue.SecurityContextAvailable && ue.NgKsi.Ksi != nasMessage.NasKeySetIdentifierNoKeyIsAvailable && !ue.MacFailed
 }
 
+// NasCountHeadroom is the number of NAS COUNT values left before the NAS COUNT of either direction
+// wraps around
+func (ue *OcfUe) NasCountHeadroom() uint32 {
+	count := ue.ULCount.Get() & MaxNasCount
+	if dlCount := ue.DLCount.Get() & MaxNasCount; dlCount > count {
+		count = dlCount
+	}
+	return MaxNasCount - count
+}
+
 // Kamf Derivation function defined in TS 33.501 Annex A.7
 func (ue *OcfUe) DerivateKamf() {
 
@@ -405,6 +516,23 @@ func (ue *OcfUe) DerivateKamf() {
 	ue.Kamf = hex.EncodeToString(KamfBytes)
 }
 
+// Kamf horizontal derivation function defined in TS 33.501 Annex A.13, count is the uplink NAS COUNT of the
+// Registration Request at idle mode mobility and the downlink NAS COUNT at N2 handover. The derived key is
+// returned for the new OCF, the current Kamf of the UE is left as it is
+func (ue *OcfUe) DerivateHorizontalKamf(count uint32) (string, error) {
+
+	P0 := make([]byte, 4)
+	binary.BigEndian.PutUint32(P0, count)
+	L0 := UeauCommon.KDFLen(P0)
+
+	KamfBytes, err := hex.DecodeString(ue.Kamf)
+	if err != nil {
+		return "", err
+	}
+	key := UeauCommon.GetKDFValue(KamfBytes, FcForKamfHorizontalDerivation, P0, L0)
+	return hex.EncodeToString(key), nil
+}
+
 // Algorithm key Derivation function defined in TS 33.501 Annex A.9
 func (ue *OcfUe) DerivateAlgKey() {
 
@@ -497,7 +625,8 @@ func (ue *OcfUe) SelectSecurityAlg(intOrder, encOrder []uint8) {
 	for _, intAlg := range intOrder {
 		switch intAlg {
 		case security.AlgIntegrity128NIA0:
-			ueSupported = ue.UESecurityCapability.GetIA0_5G()
+			// TS 33.501 5.5.2: NIA0 is only used for the UEs not authenticated for emergency services
+			continue
 		case security.AlgIntegrity128NIA1:
 			ueSupported = ue.UESecurityCapability.GetIA1_128_5G()
 		case security.AlgIntegrity128NIA2:
@@ -532,12 +661,16 @@ func (ue *OcfUe) SelectSecurityAlg(intOrder, encOrder []uint8) {
 
 func (ue *OcfUe) ClearRegistrationRequestData(accessType models.AccessType) {
 	ue.RegistrationRequest = nil
+	ue.RegistrationRequestNasPdu = nil
+	ue.RequestedT3324Value = 0
 	ue.RegistrationType5GS = 0
 	ue.IdentityTypeUsedForRegistration = 0
 	ue.AuthFailureCauseSynchFailureTimes = 0
 	ue.ServingOcfChanged = false
 	ue.RegistrationAcceptForNon3GPPAccess = nil
-	ue.RanUe[accessType].UeContextRequest = false
+	if ranUe, ok := ue.RanUe[accessType]; ok {
+		ranUe.UeContextRequest = false
+	}
 	ue.RetransmissionOfInitialNASMsg = false
 }
 
@@ -546,6 +679,34 @@ func (ue *OcfUe) RemoveAmPolicyAssociation() {
 	ue.PolicyAssociationId = ""
 }
 
+// BuildSeafData builds the security data of the UE context handed over to another OCF, which is nil if the
+// UE has no Kamf. With horizontalDerivation, the other OCF is given the Kamf derived from the NAS COUNT count
+// instead of the current one (TS 33.501 6.9.3)
+func (ue *OcfUe) BuildSeafData(horizontalDerivation bool, count uint32) (*models.SeafData, error) {
+	if ue.Kamf == "" {
+		return nil, nil
+	}
+
+	kamf := ue.Kamf
+	if horizontalDerivation {
+		var err error
+		if kamf, err = ue.DerivateHorizontalKamf(count); err != nil {
+			return nil, err
+		}
+	}
+	ngKsi := ue.NgKsi
+	return &models.SeafData{
+		NgKsi: &ngKsi,
+		KeyOcf: &models.KeyOcf{
+			KeyType: models.KeyOcfType_KOCF,
+			KeyVal:  kamf,
+		},
+		Nh:                   hex.EncodeToString(ue.NH),
+		Ncc:                  int32(ue.NCC),
+		KeyOcfHDerivationInd: horizontalDerivation,
+	}, nil
+}
+
 func (ue *OcfUe) CopyDataFromUeContextModel(ueContext models.UeContext) {
 	if ueContext.Supi != "" {
 		ue.Supi = ueContext.Supi
@@ -612,10 +773,14 @@ func (ue *OcfUe) CopyDataFromUeContextModel(ueContext models.UeContext) {
 	if ueContext.SeafData != nil {
 		seafData := ueContext.SeafData
 
-		ue.NgKsi = *seafData.NgKsi
+		if seafData.NgKsi != nil {
+			ue.NgKsi = *seafData.NgKsi
+		}
 		if seafData.KeyOcf != nil {
 			if seafData.KeyOcf.KeyType == models.KeyOcfType_KOCF {
 				ue.Kamf = seafData.KeyOcf.KeyVal
+				// TS 33.501 6.9.3: the UE derives the same Kamf from the NAS SMC of the new OCF
+				ue.KamfHorizontallyDerived = seafData.KeyOcfHDerivationInd
 			}
 		}
 		if nh, err := hex.DecodeString(seafData.Nh); err != nil {
@@ -0,0 +1,40 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/openapi/models"
+)
+
+func TestBuildSeafData(t *testing.T) {
+	kamf := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	ue := &OcfUe{
+		Kamf:  kamf,
+		NgKsi: models.NgKsi{Tsc: models.ScType_NATIVE, Ksi: 2},
+		NH:    []byte{0x01, 0x02},
+		NCC:   3,
+	}
+
+	seafData, err := ue.BuildSeafData(false, 5)
+	require.NoError(t, err)
+	assert.Equal(t, kamf, seafData.KeyOcf.KeyVal)
+	assert.Equal(t, models.KeyOcfType_KOCF, seafData.KeyOcf.KeyType)
+	assert.Equal(t, ue.NgKsi, *seafData.NgKsi)
+	assert.Equal(t, "0102", seafData.Nh)
+	assert.Equal(t, int32(3), seafData.Ncc)
+	assert.False(t, seafData.KeyOcfHDerivationInd)
+
+	// HMAC-SHA-256(Kamf, 0x72 || NAS COUNT || 0x0004)
+	seafData, err = ue.BuildSeafData(true, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "2214c412ccc28ac35d8d2126078219f009137de4b30dbf8dde0b2b2acea639a6", seafData.KeyOcf.KeyVal)
+	assert.True(t, seafData.KeyOcfHDerivationInd)
+	assert.Equal(t, kamf, ue.Kamf)
+
+	seafData, err = (&OcfUe{}).BuildSeafData(true, 5)
+	require.NoError(t, err)
+	assert.Nil(t, seafData)
+}
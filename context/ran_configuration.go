@@ -0,0 +1,140 @@
+package context
+
+import (
+	"free5gc/lib/openapi/models"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// RanConfigurationChange is what a RAN Configuration Update changed in the configuration of a RAN,
+// the unchanged fields are left empty
+type RanConfigurationChange struct {
+	Time time.Time `json:"time"`
+	// TAIs the RAN serves from now on
+	AddedTAIs []models.Tai `json:"addedTAIs,omitempty"`
+	// TAIs the RAN does not serve anymore
+	RemovedTAIs []models.Tai `json:"removedTAIs,omitempty"`
+	// TAIs still served with another list of S-NSSAIs
+	SliceChangedTAIs []models.Tai `json:"sliceChangedTAIs,omitempty"`
+	OldName          string       `json:"oldName,omitempty"`
+	NewName          string       `json:"newName,omitempty"`
+	OldPagingDRX     int          `json:"oldPagingDRX,omitempty"`
+	NewPagingDRX     int          `json:"newPagingDRX,omitempty"`
+}
+
+// RanConfigurationHandler reacts to the change of the configuration of a RAN, it is called in the
+// worker lane of the RAN after the change is applied
+type RanConfigurationHandler func(ran *OcfRan, change RanConfigurationChange)
+
+var (
+	ranConfigurationHandlersMutex sync.RWMutex
+	ranConfigurationHandlers      []RanConfigurationHandler
+)
+
+// RegisterRanConfigurationHandler adds a handler called at each change of the configuration of a RAN
+func RegisterRanConfigurationHandler(handler RanConfigurationHandler) {
+	ranConfigurationHandlersMutex.Lock()
+	defer ranConfigurationHandlersMutex.Unlock()
+	ranConfigurationHandlers = append(ranConfigurationHandlers, handler)
+}
+
+// PublishRanConfigurationChange calls the registered handlers with the change
+func PublishRanConfigurationChange(ran *OcfRan, change RanConfigurationChange) {
+	ranConfigurationHandlersMutex.RLock()
+	handlers := make([]RanConfigurationHandler, len(ranConfigurationHandlers))
+	copy(handlers, ranConfigurationHandlers)
+	ranConfigurationHandlersMutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ran, change)
+	}
+}
+
+// Empty tells if nothing has changed
+func (change *RanConfigurationChange) Empty() bool {
+	return len(change.AddedTAIs) == 0 && len(change.RemovedTAIs) == 0 && len(change.SliceChangedTAIs) == 0 &&
+		change.OldName == change.NewName && change.OldPagingDRX == change.NewPagingDRX
+}
+
+// DiffSupportedTAList compares the supported TA lists of a RAN before and after an update
+func DiffSupportedTAList(oldList, newList []SupportedTAI) (added, removed, sliceChanged []models.Tai) {
+	for _, newTai := range newList {
+		oldTai := findSupportedTAI(oldList, newTai.Tai)
+		if oldTai == nil {
+			added = append(added, newTai.Tai)
+		} else if !sameSnssaiList(oldTai.SNssaiList, newTai.SNssaiList) {
+			sliceChanged = append(sliceChanged, newTai.Tai)
+		}
+	}
+	for _, oldTai := range oldList {
+		if findSupportedTAI(newList, oldTai.Tai) == nil {
+			removed = append(removed, oldTai.Tai)
+		}
+	}
+	return
+}
+
+func findSupportedTAI(list []SupportedTAI, tai models.Tai) *SupportedTAI {
+	for i := range list {
+		if reflect.DeepEqual(list[i].Tai, tai) {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// sameSnssaiList compares two lists of S-NSSAIs regardless of their order
+func sameSnssaiList(list1, list2 []models.Snssai) bool {
+	if len(list1) != len(list2) {
+		return false
+	}
+	for _, snssai := range list1 {
+		found := false
+		for _, other := range list2 {
+			if reflect.DeepEqual(snssai, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// TaiServed tells if a RAN which has completed its NG Setup serves the TAI
+func (context *OCFContext) TaiServed(tai models.Tai) bool {
+	served := false
+	context.OcfRanPool.Range(func(key, value interface{}) bool {
+		ran := value.(*OcfRan)
+		if ran.RanId != nil && findSupportedTAI(ran.SupportedTAList, tai) != nil {
+			served = true
+			return false
+		}
+		return true
+	})
+	return served
+}
+
+// AddConfigurationChange appends a change to the configuration history of the RAN, only the
+// latest MaxNumOfRanConfigurationChanges changes are kept
+func (ran *OcfRan) AddConfigurationChange(change RanConfigurationChange) {
+	ran.historyMutex.Lock()
+	defer ran.historyMutex.Unlock()
+	ran.configurationHistory = append(ran.configurationHistory, change)
+	if len(ran.configurationHistory) > MaxNumOfRanConfigurationChanges {
+		ran.configurationHistory = ran.configurationHistory[len(ran.configurationHistory)-
+			MaxNumOfRanConfigurationChanges:]
+	}
+}
+
+// ConfigurationHistory returns the changes of the configuration of the RAN, the oldest first
+func (ran *OcfRan) ConfigurationHistory() []RanConfigurationChange {
+	ran.historyMutex.Lock()
+	defer ran.historyMutex.Unlock()
+	history := make([]RanConfigurationChange, len(ran.configurationHistory))
+	copy(history, ran.configurationHistory)
+	return history
+}
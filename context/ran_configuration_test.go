@@ -0,0 +1,50 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"free5gc/lib/openapi/models"
+)
+
+func TestDiffSupportedTAList(t *testing.T) {
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	supportedTAI := func(tac string, snssaiList ...models.Snssai) SupportedTAI {
+		return SupportedTAI{Tai: models.Tai{PlmnId: &plmnId, Tac: tac}, SNssaiList: snssaiList}
+	}
+	slice1 := models.Snssai{Sst: 1, Sd: "010203"}
+	slice2 := models.Snssai{Sst: 1, Sd: "112233"}
+
+	oldList := []SupportedTAI{
+		supportedTAI("000001", slice1, slice2),
+		supportedTAI("000002", slice1),
+		supportedTAI("000003", slice1),
+	}
+	newList := []SupportedTAI{
+		// same slices in another order
+		supportedTAI("000001", slice2, slice1),
+		supportedTAI("000002", slice2),
+		supportedTAI("000004", slice1),
+	}
+
+	added, removed, sliceChanged := DiffSupportedTAList(oldList, newList)
+	assert.Equal(t, []models.Tai{newList[2].Tai}, added)
+	assert.Equal(t, []models.Tai{oldList[2].Tai}, removed)
+	assert.Equal(t, []models.Tai{newList[1].Tai}, sliceChanged)
+
+	added, removed, sliceChanged = DiffSupportedTAList(oldList, oldList)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+	assert.Empty(t, sliceChanged)
+}
+
+func TestConfigurationHistory(t *testing.T) {
+	ran := &OcfRan{}
+	for i := 0; i < MaxNumOfRanConfigurationChanges+2; i++ {
+		ran.AddConfigurationChange(RanConfigurationChange{NewPagingDRX: i})
+	}
+	history := ran.ConfigurationHistory()
+	assert.Len(t, history, MaxNumOfRanConfigurationChanges)
+	assert.Equal(t, 2, history[0].NewPagingDRX)
+}
@@ -7,6 +7,7 @@ import (
 	"free5gc/lib/ngap/ngapType"
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/logger"
+	"sync"
 	"time"
 
 	"github.com/mohae/deepcopy"
@@ -23,10 +24,34 @@ const (
 	UeContextReleaseUeContext
 )
 
+// UeLaneKey is the key of the NGAP worker lane of a RAN UE. The lane of a UE set up by an Initial UE
+// Message is known from the RAN UE NGAP ID before the OCF UE NGAP ID is allocated, the lane of a UE set up
+// by the OCF (e.g. the target of an N2 handover) is known from the OCF UE NGAP ID.
+type UeLaneKey struct {
+	Ran         *OcfRan
+	RanUeNgapId int64
+	OcfUeNgapId int64
+}
+
+// laneRans holds the RAN a RAN UE has switched to by the key of its lane, the key keeps the RAN the lane was
+// set up on
+var laneRans sync.Map
+
+// LaneRan returns the RAN the UE of the lane is on
+func LaneRan(key UeLaneKey) *OcfRan {
+	if ran, ok := laneRans.Load(key); ok {
+		return ran.(*OcfRan)
+	}
+	return key.Ran
+}
+
 type RanUe struct {
 	/* UE identity*/
 	RanUeNgapId int64
 	OcfUeNgapId int64
+	// the NGAP messages of the UE are handled on this lane for the whole life of the RAN UE, even after the
+	// RAN UE NGAP ID changes or the UE switches to another RAN
+	LaneKey UeLaneKey
 
 	/* HandOver Info*/
 	HandOverType        ngapType.HandoverType
@@ -71,19 +96,23 @@ func (ranUe *RanUe) Remove() error {
 	if ran == nil {
 		return fmt.Errorf("RanUe not found in Ran")
 	}
-	if ranUe.OcfUe != nil {
-		ranUe.OcfUe.DetachRanUe(ran.AnType)
+	// the UE enters CM-IDLE unless it has been handed over to another RAN UE
+	var idleUe *OcfUe
+	if ue := ranUe.OcfUe; ue != nil {
+		if ue.RanUe[ran.AnType] == ranUe {
+			ue.DetachRanUe(ran.AnType)
+			idleUe = ue
+		}
 		ranUe.DetachOcfUe()
 	}
 
-	for index, ranUe1 := range ran.RanUeList {
-		if ranUe1 == ranUe {
-			ran.RanUeList = append(ran.RanUeList[:index], ran.RanUeList[index+1:]...)
-			break
-		}
-	}
+	ran.removeRanUe(ranUe)
+	laneRans.Delete(ranUe.LaneKey)
 	self := OCF_Self()
 	self.RanUePool.Delete(ranUe.OcfUeNgapId)
+	if idleUe != nil {
+		publishCmState(idleUe, ran.AnType, false)
+	}
 	return nil
 }
 
@@ -104,19 +133,19 @@ func (ranUe *RanUe) SwitchToRan(newRan *OcfRan, ranUeNgapId int64) error {
 	oldRan := ranUe.Ran
 
 	// remove ranUe from oldRan
-	for index, ranUe1 := range oldRan.RanUeList {
-		if ranUe1 == ranUe {
-			oldRan.RanUeList = append(oldRan.RanUeList[:index], oldRan.RanUeList[index+1:]...)
-			break
-		}
-	}
+	oldRan.removeRanUe(ranUe)
 
 	// add ranUe to newRan
-	newRan.RanUeList = append(newRan.RanUeList, ranUe)
+	newRan.addRanUe(ranUe)
 
-	// switch to newRan
+	// switch to newRan, the UE keeps its lane
 	ranUe.Ran = newRan
 	ranUe.RanUeNgapId = ranUeNgapId
+	if newRan == ranUe.LaneKey.Ran {
+		laneRans.Delete(ranUe.LaneKey)
+	} else {
+		laneRans.Store(ranUe.LaneKey, newRan)
+	}
 
 	logger.ContextLog.Infof("RanUe[RanUeNgapID: %d] Switch to new Ran[Name: %s]", ranUe.RanUeNgapId, ranUe.Ran.Name)
 	return nil
@@ -128,7 +157,7 @@ func (ranUe *RanUe) UpdateLocation(userLocationInformation *ngapType.UserLocatio
 		return
 	}
 
-	amfSelf := OCF_Self()
+	parameters := OCF_Self().Parameters()
 	curTime := time.Now().UTC()
 	switch userLocationInformation.Present {
 	case ngapType.UserLocationInformationPresentUserLocationInformationEUTRA:
@@ -168,6 +197,7 @@ func (ranUe *RanUe) UpdateLocation(userLocationInformation *ngapType.UserLocatio
 			}
 			ranUe.OcfUe.Location = deepcopy.Copy(ranUe.Location).(models.UserLocation)
 			ranUe.OcfUe.Tai = deepcopy.Copy(*ranUe.OcfUe.Location.EutraLocation.Tai).(models.Tai)
+			ranUe.OcfUe.AddVisitedTai(ranUe.OcfUe.Tai)
 		}
 	case ngapType.UserLocationInformationPresentUserLocationInformationNR:
 		locationInfoNR := userLocationInformation.UserLocationInformationNR
@@ -205,6 +235,7 @@ func (ranUe *RanUe) UpdateLocation(userLocationInformation *ngapType.UserLocatio
 			}
 			ranUe.OcfUe.Location = deepcopy.Copy(ranUe.Location).(models.UserLocation)
 			ranUe.OcfUe.Tai = deepcopy.Copy(*ranUe.OcfUe.Location.NrLocation.Tai).(models.Tai)
+			ranUe.OcfUe.AddVisitedTai(ranUe.OcfUe.Tai)
 		}
 	case ngapType.UserLocationInformationPresentUserLocationInformationN3IWF:
 		locationInfoN3IWF := userLocationInformation.UserLocationInformationN3IWF
@@ -223,8 +254,8 @@ func (ranUe *RanUe) UpdateLocation(userLocationInformation *ngapType.UserLocatio
 		// N3GPP TAI is operator-specific
 		// TODO: define N3GPP TAI
 		ranUe.Location.N3gaLocation.N3gppTai = &models.Tai{
-			PlmnId: amfSelf.SupportTaiLists[0].PlmnId,
-			Tac:    amfSelf.SupportTaiLists[0].Tac,
+			PlmnId: parameters.SupportTaiLists[0].PlmnId,
+			Tac:    parameters.SupportTaiLists[0].Tac,
 		}
 		ranUe.Tai = deepcopy.Copy(*ranUe.Location.N3gaLocation.N3gppTai).(models.Tai)
 
@@ -0,0 +1,28 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaneRan(t *testing.T) {
+	oldRan, newRan := &OcfRan{}, &OcfRan{}
+	ranUe, err := oldRan.NewRanUe(1)
+	require.NoError(t, err)
+	key := ranUe.LaneKey
+	assert.Equal(t, oldRan, LaneRan(key))
+
+	// the UE keeps its lane on the new RAN
+	require.NoError(t, ranUe.SwitchToRan(newRan, 2))
+	assert.Equal(t, key, ranUe.LaneKey)
+	assert.Equal(t, newRan, LaneRan(key))
+
+	require.NoError(t, ranUe.SwitchToRan(oldRan, 3))
+	assert.Equal(t, oldRan, LaneRan(key))
+
+	require.NoError(t, ranUe.SwitchToRan(newRan, 4))
+	require.NoError(t, ranUe.Remove())
+	assert.Equal(t, oldRan, LaneRan(key))
+}
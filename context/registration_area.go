@@ -0,0 +1,168 @@
+package context
+
+import (
+	"free5gc/lib/openapi/models"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// RegistrationAreaAllocator proposes TAIs for the registration area of a UE, the most suitable first. The
+// current TAI of the UE is in the registration area already, the proposed TAIs the OCF does not support
+// or the UE may not access are left out.
+type RegistrationAreaAllocator func(ue *OcfUe, anType models.AccessType) []models.Tai
+
+var (
+	registrationAreaAllocatorsMutex sync.RWMutex
+	registrationAreaAllocators      = make(map[string]RegistrationAreaAllocator)
+)
+
+func init() {
+	RegisterRegistrationAreaAllocator(RegistrationAreaStrategyCurrentTai, currentTaiAllocator)
+	RegisterRegistrationAreaAllocator(RegistrationAreaStrategyStatic, staticAllocator)
+	RegisterRegistrationAreaAllocator(RegistrationAreaStrategyServingRan, servingRanAllocator)
+	RegisterRegistrationAreaAllocator(RegistrationAreaStrategyHistory, historyAllocator)
+}
+
+// RegisterRegistrationAreaAllocator adds the allocator of a strategy, it replaces the allocator the
+// strategy may have
+func RegisterRegistrationAreaAllocator(strategy string, allocator RegistrationAreaAllocator) {
+	registrationAreaAllocatorsMutex.Lock()
+	defer registrationAreaAllocatorsMutex.Unlock()
+	registrationAreaAllocators[strategy] = allocator
+}
+
+// RegistrationAreaStrategies returns the strategies which have an allocator, sorted by name
+func RegistrationAreaStrategies() []string {
+	registrationAreaAllocatorsMutex.RLock()
+	defer registrationAreaAllocatorsMutex.RUnlock()
+	strategies := make([]string, 0, len(registrationAreaAllocators))
+	for strategy := range registrationAreaAllocators {
+		strategies = append(strategies, strategy)
+	}
+	sort.Strings(strategies)
+	return strategies
+}
+
+// RegistrationAreaStrategyKnown tells if the strategy has an allocator
+func RegistrationAreaStrategyKnown(strategy string) bool {
+	_, ok := registrationAreaAllocatorOf(strategy)
+	return ok
+}
+
+func registrationAreaAllocatorOf(strategy string) (RegistrationAreaAllocator, bool) {
+	registrationAreaAllocatorsMutex.RLock()
+	defer registrationAreaAllocatorsMutex.RUnlock()
+	allocator, ok := registrationAreaAllocators[strategy]
+	return allocator, ok
+}
+
+func currentTaiAllocator(ue *OcfUe, anType models.AccessType) []models.Tai {
+	return nil
+}
+
+// staticAllocator proposes the TAIs of the configured groups the current TAI of the UE belongs to
+func staticAllocator(ue *OcfUe, anType models.AccessType) []models.Tai {
+	var tais []models.Tai
+	for _, group := range OCF_Self().Parameters().RegistrationArea.TaiGroups {
+		if InTaiList(ue.Tai, group) {
+			tais = append(tais, group...)
+		}
+	}
+	return tais
+}
+
+// servingRanAllocator proposes the TAIs supported by the RAN the UE is connected to
+func servingRanAllocator(ue *OcfUe, anType models.AccessType) []models.Tai {
+	ranUe := ue.RanUe[anType]
+	if ranUe == nil || ranUe.Ran == nil {
+		return nil
+	}
+	var tais []models.Tai
+	for _, supportedTai := range ranUe.Ran.SupportedTAList {
+		tais = append(tais, supportedTai.Tai)
+	}
+	return tais
+}
+
+// historyAllocator proposes the TAIs the UE has visited, the most visited first and then the latest visited
+func historyAllocator(ue *OcfUe, anType models.AccessType) []models.Tai {
+	type visitedTai struct {
+		tai    models.Tai
+		visits int
+		latest int
+	}
+	var visitedTais []visitedTai
+	for i, tai := range ue.TaiHistory {
+		found := false
+		for j := range visitedTais {
+			if reflect.DeepEqual(visitedTais[j].tai, tai) {
+				visitedTais[j].visits++
+				visitedTais[j].latest = i
+				found = true
+				break
+			}
+		}
+		if !found {
+			visitedTais = append(visitedTais, visitedTai{tai: tai, visits: 1, latest: i})
+		}
+	}
+	sort.SliceStable(visitedTais, func(i, j int) bool {
+		if visitedTais[i].visits != visitedTais[j].visits {
+			return visitedTais[i].visits > visitedTais[j].visits
+		}
+		return visitedTais[i].latest > visitedTais[j].latest
+	})
+
+	tais := make([]models.Tai, 0, len(visitedTais))
+	for _, visited := range visitedTais {
+		tais = append(tais, visited.tai)
+	}
+	return tais
+}
+
+// AddVisitedTai appends the TAI to the visited TAIs of the UE unless the UE is still in it, only the latest
+// RegistrationAreaParameters.HistorySize TAIs are kept
+func (ue *OcfUe) AddVisitedTai(tai models.Tai) {
+	historySize := OCF_Self().Parameters().RegistrationArea.HistorySize
+	if historySize <= 0 {
+		ue.TaiHistory = nil
+		return
+	}
+	if n := len(ue.TaiHistory); n > 0 && reflect.DeepEqual(ue.TaiHistory[n-1], tai) {
+		return
+	}
+	ue.TaiHistory = append(ue.TaiHistory, tai)
+	if len(ue.TaiHistory) > historySize {
+		ue.TaiHistory = ue.TaiHistory[len(ue.TaiHistory)-historySize:]
+	}
+}
+
+// TaiAccessible tells if the UE may access the TA, it is not in the forbidden areas of the UE nor outside
+// its service area restriction (TS 23.501 5.3.4.1). The service area restriction of the AM policy takes
+// precedence over the one of the subscription and applies to 3GPP access only.
+func (ue *OcfUe) TaiAccessible(tai models.Tai, anType models.AccessType) bool {
+	var serviceAreaRestriction *models.ServiceAreaRestriction
+	if subscription := ue.AccessAndMobilitySubscriptionData; subscription != nil {
+		if TacInAreas(tai.Tac, subscription.ForbiddenAreas) {
+			return false
+		}
+		serviceAreaRestriction = subscription.ServiceAreaRestriction
+	}
+	if anType != models.AccessType__3_GPP_ACCESS {
+		return true
+	}
+	if ue.AmPolicyAssociation != nil && ue.AmPolicyAssociation.ServAreaRes != nil {
+		serviceAreaRestriction = ue.AmPolicyAssociation.ServAreaRes
+	}
+	if serviceAreaRestriction == nil {
+		return true
+	}
+	switch serviceAreaRestriction.RestrictionType {
+	case models.RestrictionType_ALLOWED_AREAS:
+		return TacInAreas(tai.Tac, serviceAreaRestriction.Areas)
+	case models.RestrictionType_NOT_ALLOWED_AREAS:
+		return !TacInAreas(tai.Tac, serviceAreaRestriction.Areas)
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"free5gc/lib/openapi/models"
+)
+
+func TestAllocateRegistrationArea(t *testing.T) {
+	amfSelf := OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	tai := func(tac string) models.Tai {
+		return models.Tai{PlmnId: &plmnId, Tac: tac}
+	}
+	anType := models.AccessType__3_GPP_ACCESS
+	amfSelf.UpdateParameters(func(parameters *Parameters) {
+		parameters.SupportTaiLists = []models.Tai{tai("000001"), tai("000002"), tai("000003"), tai("000004")}
+		parameters.RegistrationArea = DefaultRegistrationAreaParameters()
+		parameters.RegistrationArea.Strategy = RegistrationAreaStrategyStatic
+		parameters.RegistrationArea.TaiGroups = [][]models.Tai{
+			{tai("000001"), tai("000002"), tai("000005")},
+			{tai("000003"), tai("000002"), tai("000004")},
+		}
+	})
+
+	ue := &OcfUe{
+		Tai:              tai("000002"),
+		RegistrationArea: make(map[models.AccessType][]models.Tai),
+		AccessAndMobilitySubscriptionData: &models.AccessAndMobilitySubscriptionData{
+			ForbiddenAreas: []models.Area{{Tacs: []string{"000003"}}},
+		},
+	}
+	// 000005 is not supported and 000003 is forbidden
+	amfSelf.AllocateRegistrationArea(ue, anType)
+	assert.Equal(t, []models.Tai{tai("000002"), tai("000001"), tai("000004")}, ue.RegistrationArea[anType])
+
+	amfSelf.UpdateParameters(func(parameters *Parameters) {
+		parameters.RegistrationArea.MaxTais = 2
+	})
+	amfSelf.AllocateRegistrationArea(ue, anType)
+	assert.Equal(t, []models.Tai{tai("000002"), tai("000001")}, ue.RegistrationArea[anType])
+
+	amfSelf.UpdateParameters(func(parameters *Parameters) {
+		parameters.RegistrationArea = DefaultRegistrationAreaParameters()
+		parameters.RegistrationArea.Strategy = RegistrationAreaStrategyHistory
+		parameters.RegistrationArea.HistorySize = 4
+	})
+	ue.AccessAndMobilitySubscriptionData = nil
+	for _, tac := range []string{"000001", "000004", "000001", "000004", "000003", "000003"} {
+		ue.AddVisitedTai(tai(tac))
+	}
+	assert.Equal(t, []models.Tai{tai("000004"), tai("000001"), tai("000004"), tai("000003")}, ue.TaiHistory)
+	// the most visited first, then the latest visited
+	amfSelf.AllocateRegistrationArea(ue, anType)
+	assert.Equal(t, []models.Tai{tai("000002"), tai("000004"), tai("000003"), tai("000001")},
+		ue.RegistrationArea[anType])
+
+	// the current TAI is not supported
+	ue.Tai = tai("000005")
+	amfSelf.AllocateRegistrationArea(ue, anType)
+	assert.Empty(t, ue.RegistrationArea[anType])
+}
+
+func TestServingRanRegistrationArea(t *testing.T) {
+	amfSelf := OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	tai := func(tac string) models.Tai {
+		return models.Tai{PlmnId: &plmnId, Tac: tac}
+	}
+	anType := models.AccessType__3_GPP_ACCESS
+	amfSelf.UpdateParameters(func(parameters *Parameters) {
+		parameters.SupportTaiLists = []models.Tai{tai("000001"), tai("000002"), tai("000003"), tai("000004")}
+		parameters.RegistrationArea = DefaultRegistrationAreaParameters()
+		parameters.RegistrationArea.Strategy = RegistrationAreaStrategyServingRan
+	})
+
+	ran := &OcfRan{
+		SupportedTAList: []SupportedTAI{{Tai: tai("000003")}, {Tai: tai("000001")}, {Tai: tai("000005")}},
+	}
+	ue := &OcfUe{
+		Tai:              tai("000001"),
+		RanUe:            map[models.AccessType]*RanUe{anType: {Ran: ran}},
+		RegistrationArea: make(map[models.AccessType][]models.Tai),
+	}
+	// 000005 is not supported
+	amfSelf.AllocateRegistrationArea(ue, anType)
+	assert.Equal(t, []models.Tai{tai("000001"), tai("000003")}, ue.RegistrationArea[anType])
+
+	// the UE is not connected over the access type
+	amfSelf.AllocateRegistrationArea(ue, models.AccessType_NON_3_GPP_ACCESS)
+	assert.Equal(t, []models.Tai{tai("000001")}, ue.RegistrationArea[models.AccessType_NON_3_GPP_ACCESS])
+}
+
+func TestServiceAreaRestriction(t *testing.T) {
+	amfSelf := OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	tai := func(tac string) models.Tai {
+		return models.Tai{PlmnId: &plmnId, Tac: tac}
+	}
+	anType := models.AccessType__3_GPP_ACCESS
+	amfSelf.UpdateParameters(func(parameters *Parameters) {
+		parameters.SupportTaiLists = []models.Tai{tai("000001"), tai("000002"), tai("000003"), tai("000004")}
+		parameters.RegistrationArea = DefaultRegistrationAreaParameters()
+		parameters.RegistrationArea.Strategy = RegistrationAreaStrategyServingRan
+	})
+
+	ran := &OcfRan{SupportedTAList: []SupportedTAI{
+		{Tai: tai("000001")}, {Tai: tai("000002")}, {Tai: tai("000003")}, {Tai: tai("000004")},
+	}}
+	ue := &OcfUe{
+		Tai:              tai("000001"),
+		RanUe:            map[models.AccessType]*RanUe{anType: {Ran: ran}},
+		RegistrationArea: make(map[models.AccessType][]models.Tai),
+		AccessAndMobilitySubscriptionData: &models.AccessAndMobilitySubscriptionData{
+			ForbiddenAreas: []models.Area{{Tacs: []string{"000004"}}},
+			ServiceAreaRestriction: &models.ServiceAreaRestriction{
+				RestrictionType: models.RestrictionType_ALLOWED_AREAS,
+				Areas:           []models.Area{{Tacs: []string{"000001", "000002", "000004"}}},
+			},
+		},
+	}
+	// 000003 is outside the allowed areas and 000004 is forbidden
+	amfSelf.AllocateRegistrationArea(ue, anType)
+	assert.Equal(t, []models.Tai{tai("000001"), tai("000002")}, ue.RegistrationArea[anType])
+
+	// the service area restriction of the AM policy takes precedence over the one of the subscription
+	ue.AmPolicyAssociation = &models.PolicyAssociation{
+		ServAreaRes: &models.ServiceAreaRestriction{
+			RestrictionType: models.RestrictionType_NOT_ALLOWED_AREAS,
+			Areas:           []models.Area{{Tacs: []string{"000002"}}},
+		},
+	}
+	amfSelf.AllocateRegistrationArea(ue, anType)
+	assert.Equal(t, []models.Tai{tai("000001"), tai("000003")}, ue.RegistrationArea[anType])
+
+	// the service area restriction applies to 3GPP access only, the forbidden areas to both accesses
+	assert.False(t, ue.TaiAccessible(tai("000002"), anType))
+	assert.True(t, ue.TaiAccessible(tai("000002"), models.AccessType_NON_3_GPP_ACCESS))
+	assert.True(t, ue.TaiAccessible(tai("000003"), models.AccessType_NON_3_GPP_ACCESS))
+	assert.False(t, ue.TaiAccessible(tai("000004"), models.AccessType_NON_3_GPP_ACCESS))
+}
@@ -0,0 +1,102 @@
+package context
+
+import (
+	"free5gc/lib/openapi/models"
+	"reflect"
+	"time"
+)
+
+// TimerName is the name of a timer as it is written in the configuration file
+type TimerName string
+
+const (
+	TimerT3513                  TimerName = "t3513"
+	TimerT3522                  TimerName = "t3522"
+	TimerT3550                  TimerName = "t3550"
+	TimerT3555                  TimerName = "t3555"
+	TimerT3560                  TimerName = "t3560"
+	TimerT3565                  TimerName = "t3565"
+	TimerOcfConfigurationUpdate TimerName = "ocfConfigurationUpdate"
+)
+
+var TimerNames = []TimerName{
+	TimerT3513, TimerT3522, TimerT3550, TimerT3555, TimerT3560, TimerT3565, TimerOcfConfigurationUpdate,
+}
+
+// Timer is the value of a retransmission timer and the number of retransmissions before the
+// procedure is aborted
+type Timer struct {
+	Value         time.Duration
+	MaxRetryTimes int
+}
+
+// SnssaiTimers override the timers of the UEs allowed to use the S-NSSAI
+type SnssaiTimers struct {
+	Snssai models.Snssai
+	Timers map[TimerName]Timer
+}
+
+// TimerParameters are the timers of the OCF. An override lists only the timers it changes, each of
+// them complete with the default of the field it does not set.
+type TimerParameters struct {
+	Default    map[TimerName]Timer
+	AccessType map[models.AccessType]map[TimerName]Timer
+	Snssai     []SnssaiTimers
+}
+
+// DefaultTimerParameters are the timers used when they are not configured
+func DefaultTimerParameters() TimerParameters {
+	return TimerParameters{
+		Default: map[TimerName]Timer{
+			TimerT3513:                  {TimeT3513, MaxT3513RetryTimes},
+			TimerT3522:                  {TimeT3522, MaxT3522RetryTimes},
+			TimerT3550:                  {TimeT3550, MaxT3550RetryTimes},
+			TimerT3555:                  {TimeT3555, MaxT3555RetryTimes},
+			TimerT3560:                  {TimeT3560, MaxT3560RetryTimes},
+			TimerT3565:                  {TimeT3565, MaxT3565RetryTimes},
+			TimerOcfConfigurationUpdate: {TimeOcfConfigurationUpdate, MaxOcfConfigurationUpdateRetryTimes},
+		},
+		AccessType: make(map[models.AccessType]map[TimerName]Timer),
+	}
+}
+
+// Timer returns the timer for a procedure on the access type concerning the S-NSSAIs. An S-NSSAI
+// override comes first, the longest one if several S-NSSAIs have one, then the access type
+// override and then the default.
+func (p *TimerParameters) Timer(name TimerName, accessType models.AccessType,
+	snssaiList []models.Snssai) Timer {
+	var timer Timer
+	found := false
+	for _, override := range p.Snssai {
+		t, ok := override.Timers[name]
+		if !ok {
+			continue
+		}
+		for _, snssai := range snssaiList {
+			if reflect.DeepEqual(override.Snssai, snssai) && (!found || t.Value > timer.Value) {
+				timer = t
+				found = true
+			}
+		}
+	}
+	if found {
+		return timer
+	}
+	if t, ok := p.AccessType[accessType][name]; ok {
+		return t
+	}
+	return p.Default[name]
+}
+
+// Timer returns the timer for a procedure of the UE on the access type, the S-NSSAI overrides of
+// its allowed NSSAI apply
+func (ue *OcfUe) Timer(name TimerName, accessType models.AccessType) Timer {
+	var snssaiList []models.Snssai
+	for _, allowedSnssai := range ue.AllowedNssai[accessType] {
+		if allowedSnssai.AllowedSnssai != nil {
+			snssaiList = append(snssaiList, *allowedSnssai.AllowedSnssai)
+		}
+	}
+	timers := OCF_Self().Parameters().Timers
+	return timers.Timer(name, accessType, snssaiList)
+}
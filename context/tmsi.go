@@ -0,0 +1,64 @@
+package context
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// maxTmsiAllocationAttempts bounds the draws of TmsiPool.Allocate, a collision is only likely once most of the
+// 5G-TMSIs are allocated
+const maxTmsiAllocationAttempts = 64
+
+// TmsiPool allocates the 5G-TMSIs at random so that the 5G-TMSIs of a UE can neither be guessed nor linked
+// to each other (TS 33.501 6.12.3), the allocated ones are kept to check the collisions
+type TmsiPool struct {
+	mu        sync.Mutex
+	min, max  uint32
+	allocated map[uint32]struct{}
+}
+
+// NewTmsiPool returns a pool of the 5G-TMSIs from min to max
+func NewTmsiPool(min, max uint32) *TmsiPool {
+	return &TmsiPool{
+		min:       min,
+		max:       max,
+		allocated: make(map[uint32]struct{}),
+	}
+}
+
+func (p *TmsiPool) Allocate() (uint32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	size := uint64(p.max) - uint64(p.min) + 1
+	if uint64(len(p.allocated)) >= size {
+		return 0, fmt.Errorf("all 5G-TMSIs are allocated")
+	}
+	var buf [8]byte
+	for attempt := 0; attempt < maxTmsiAllocationAttempts; attempt++ {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+		tmsi := p.min + uint32(binary.BigEndian.Uint64(buf[:])%size)
+		if _, ok := p.allocated[tmsi]; !ok {
+			p.allocated[tmsi] = struct{}{}
+			return tmsi, nil
+		}
+	}
+	return 0, fmt.Errorf("no free 5G-TMSI found in %d attempts", maxTmsiAllocationAttempts)
+}
+
+func (p *TmsiPool) Free(tmsi uint32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.allocated, tmsi)
+}
+
+// Len is the number of the allocated 5G-TMSIs
+func (p *TmsiPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.allocated)
+}
@@ -0,0 +1,41 @@
+package context
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTmsiPool(t *testing.T) {
+	pool := NewTmsiPool(10, 17)
+
+	allocated := make(map[uint32]bool)
+	for i := 0; i < 8; i++ {
+		tmsi, err := pool.Allocate()
+		require.NoError(t, err)
+		assert.True(t, tmsi >= 10 && tmsi <= 17, "5G-TMSI %d is out of range", tmsi)
+		assert.False(t, allocated[tmsi], "5G-TMSI %d is allocated twice", tmsi)
+		allocated[tmsi] = true
+	}
+	assert.Equal(t, 8, pool.Len())
+
+	_, err := pool.Allocate()
+	assert.Error(t, err)
+
+	pool.Free(13)
+	tmsi, err := pool.Allocate()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(13), tmsi)
+
+	// the pool spans the 32 bits of the 5G-TMSI
+	pool = NewTmsiPool(math.MaxUint32-1, math.MaxUint32)
+	for i := 0; i < 2; i++ {
+		tmsi, err = pool.Allocate()
+		require.NoError(t, err)
+		assert.True(t, tmsi >= math.MaxUint32-1, "5G-TMSI %d is out of range", tmsi)
+	}
+	_, err = pool.Allocate()
+	assert.Error(t, err)
+}
@@ -24,8 +24,17 @@ type Info struct {
 type Configuration struct {
 	OcfName string `yaml:"amfName,omitempty"`
 
+	// RelativeCapacity is the Relative OCF Capacity of TS 38.413 9.3.1.32, 255 if it is not set
+	RelativeCapacity *int64 `yaml:"relativeCapacity,omitempty"`
+
 	NgapIpList []string `yaml:"ngapIpList,omitempty"`
 
+	Sctp *Sctp `yaml:"sctp,omitempty"`
+
+	NgapCapture *NgapCapture `yaml:"ngapCapture,omitempty"`
+
+	OverloadControl *OverloadControl `yaml:"overloadControl,omitempty"`
+
 	Sbi *Sbi `yaml:"sbi,omitempty"`
 
 	ServiceNameList []string `yaml:"serviceNameList,omitempty"`
@@ -49,6 +58,16 @@ type Configuration struct {
 	T3512 int `yaml:"t3512,omitempty"`
 
 	Non3gppDeregistrationTimer int `yaml:"mon3gppDeregistrationTimer,omitempty"`
+
+	Timers *Timers `yaml:"timers,omitempty"`
+
+	Mico *Mico `yaml:"mico,omitempty"`
+
+	Emergency *Emergency `yaml:"emergency,omitempty"`
+
+	GutiReallocation *GutiReallocation `yaml:"gutiReallocation,omitempty"`
+
+	RegistrationArea *RegistrationArea `yaml:"registrationArea,omitempty"`
 }
 
 type Sbi struct {
@@ -59,7 +78,133 @@ type Sbi struct {
 	Port        int    `yaml:"port,omitempty"`
 }
 
+type Sctp struct {
+	NumOstreams    uint16 `yaml:"numOstreams,omitempty"`  // stream 0 is used for non-UE-associated signalling
+	MaxInstreams   uint16 `yaml:"maxInstreams,omitempty"` // upper limit of inbound streams the RAN may open
+	MaxAttempts    uint16 `yaml:"maxAttempts,omitempty"`
+	MaxInitTimeout uint16 `yaml:"maxInitTimeout,omitempty"` // unit is second
+}
+
+type NgapCapture struct {
+	Enable      bool     `yaml:"enable,omitempty"`
+	Directory   string   `yaml:"directory,omitempty"`   // pcap files are written to this directory
+	MaxFileSize int64    `yaml:"maxFileSize,omitempty"` // unit is MB, the file is rotated when it is exceeded
+	MaxFiles    int      `yaml:"maxFiles,omitempty"`    // older files are removed
+	RanList     []string `yaml:"ranList,omitempty"`     // Global RAN Node IDs like 208-93-gnb-000001, all RANs if empty
+}
+
+type OverloadControl struct {
+	Enable        bool               `yaml:"enable,omitempty"`
+	CheckInterval int                `yaml:"checkInterval,omitempty"` // unit is second
+	QueueDepth    *OverloadThreshold `yaml:"queueDepth,omitempty"`    // pending NGAP messages
+	SbiLatency    *OverloadThreshold `yaml:"sbiLatency,omitempty"`    // unit is millisecond, UDM/AUSF/SMF requests
+	UeCapacity    int                `yaml:"ueCapacity,omitempty"`    // number of UE contexts
+	UeLoad        *OverloadThreshold `yaml:"ueLoad,omitempty"`        // percentage of ueCapacity
+	// reject-non-emergency-mo-dt, reject-rrc-cr-signalling, permit-emergency-sessions-and-mt-services-only
+	// or permit-high-priority-sessions-and-mt-services-only
+	Action               string          `yaml:"action,omitempty"`
+	TrafficLoadReduction int64           `yaml:"trafficLoadReduction,omitempty"` // percentage (1~99)
+	SliceList            []models.Snssai `yaml:"sliceList,omitempty"`            // overload of these slices only
+}
+
+// OverloadThreshold is reached at Start and cleared under Stop, a Start of 0 disables the measurement
+type OverloadThreshold struct {
+	Start int `yaml:"start"`
+	Stop  int `yaml:"stop"`
+}
+
+// Timers of the NAS and NGAP procedures, an override sets the timers of the UEs on an access type or
+// allowed to use an S-NSSAI, the S-NSSAI overrides come first
+type Timers struct {
+	TimerSet            `yaml:",inline"`
+	AccessTypeOverrides []AccessTypeTimers `yaml:"accessTypeOverrides,omitempty"`
+	SnssaiOverrides     []SnssaiTimers     `yaml:"snssaiOverrides,omitempty"`
+}
+
+type TimerSet struct {
+	T3513                  *Timer `yaml:"t3513,omitempty"`
+	T3522                  *Timer `yaml:"t3522,omitempty"`
+	T3550                  *Timer `yaml:"t3550,omitempty"`
+	T3555                  *Timer `yaml:"t3555,omitempty"`
+	T3560                  *Timer `yaml:"t3560,omitempty"`
+	T3565                  *Timer `yaml:"t3565,omitempty"`
+	OcfConfigurationUpdate *Timer `yaml:"ocfConfigurationUpdate,omitempty"`
+}
+
+// Timers maps the timers of the set to their names, the unset ones are nil
+func (s *TimerSet) Timers() map[context.TimerName]*Timer {
+	return map[context.TimerName]*Timer{
+		context.TimerT3513:                  s.T3513,
+		context.TimerT3522:                  s.T3522,
+		context.TimerT3550:                  s.T3550,
+		context.TimerT3555:                  s.T3555,
+		context.TimerT3560:                  s.T3560,
+		context.TimerT3565:                  s.T3565,
+		context.TimerOcfConfigurationUpdate: s.OcfConfigurationUpdate,
+	}
+}
+
+// Timer leaves the unset fields to the default
+type Timer struct {
+	Value         int  `yaml:"value,omitempty"` // unit is millisecond
+	MaxRetryTimes *int `yaml:"maxRetryTimes,omitempty"`
+}
+
+type AccessTypeTimers struct {
+	AccessType models.AccessType `yaml:"accessType"` // 3GPP_ACCESS or NON_3GPP_ACCESS
+	TimerSet   `yaml:",inline"`
+}
+
+type SnssaiTimers struct {
+	Snssai   models.Snssai `yaml:"snssai"`
+	TimerSet `yaml:",inline"`
+}
+
+// Mico is the local policy of the MICO mode, it is granted to the UEs whose subscription allows it
+type Mico struct {
+	Enable                  bool `yaml:"enable,omitempty"`
+	AllPlmnRegistrationArea bool `yaml:"allPlmnRegistrationArea,omitempty"` // grant RAAI to the UEs requesting it
+	ActiveTime              int  `yaml:"activeTime,omitempty"`              // longest T3324 granted, unit is second
+	ExtendedPeriodicTimer   int  `yaml:"extendedPeriodicTimer,omitempty"`   // T3512 of the MICO UEs, unit is second
+}
+
+// Emergency services, the emergency PDU sessions are established on the DNN and S-NSSAI at the SMF,
+// which is discovered at the NRF if SmfUri is empty
+type Emergency struct {
+	Enable          bool           `yaml:"enable,omitempty"`
+	Dnn             string         `yaml:"dnn,omitempty"`
+	Snssai          *models.Snssai `yaml:"snssai,omitempty"` // the first S-NSSAI of plmnSupportList if unset
+	SmfUri          string         `yaml:"smfUri,omitempty"`
+	AllowWithoutSim bool           `yaml:"allowWithoutSim,omitempty"` // admit the IMEI-only and unauthenticated UEs
+}
+
 type Security struct {
-	IntegrityOrder []string `yaml:"integrityOrder,omitempty"`
-	CipheringOrder []string `yaml:"cipheringOrder,omitempty"`
+	IntegrityOrder   []string          `yaml:"integrityOrder,omitempty"`
+	CipheringOrder   []string          `yaml:"cipheringOrder,omitempty"`
+	NasCountHeadroom int               `yaml:"nasCountHeadroom,omitempty"` // re-authenticate when fewer NAS COUNTs are left
+	Reauthentication *Reauthentication `yaml:"reauthentication,omitempty"`
+	// hand a horizontally derived Kamf over to the new OCF of the UEs at idle mode mobility instead of the
+	// current one, not at N2 handover
+	KamfHorizontalDerivation bool `yaml:"kamfHorizontalDerivation,omitempty"`
+}
+
+// Reauthentication is the periodic primary re-authentication of the registered UEs, 0 disables a trigger
+type Reauthentication struct {
+	Interval      int `yaml:"interval,omitempty"`      // unit is hour
+	Registrations int `yaml:"registrations,omitempty"` // registrations since the last authentication
+}
+
+// GutiReallocation is the policy of the 5G-GUTI reallocation, 0 disables a trigger
+type GutiReallocation struct {
+	Registration    bool `yaml:"registration,omitempty"`    // in the Registration Accept of each registration
+	ServiceRequests int  `yaml:"serviceRequests,omitempty"` // service requests since the last reallocation
+	Interval        int  `yaml:"interval,omitempty"`        // unit is second
+}
+
+// RegistrationArea is the policy of the registration area allocation
+type RegistrationArea struct {
+	Strategy    string         `yaml:"strategy,omitempty"`    // current-tai, static, serving-ran or history
+	MaxTais     int            `yaml:"maxTais,omitempty"`     // 16 at most
+	TaiGroups   [][]models.Tai `yaml:"taiGroups,omitempty"`   // neighbour TA groups of the static strategy
+	HistorySize int            `yaml:"historySize,omitempty"` // TAIs visited by a UE kept for the history strategy
 }
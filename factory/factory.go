@@ -15,6 +15,9 @@ import (
 
 var OcfConfig Config
 
+// the file OcfConfig has been read from, it is read again on reload
+var configFile string
+
 func checkErr(err error) {
 	if err != nil {
 		err = fmt.Errorf("[Configuration] %s", err.Error())
@@ -22,15 +25,43 @@ func checkErr(err error) {
 	}
 }
 
-// TODO: Support configuration update from REST api
 func InitConfigFactory(f string) {
-	content, err := ioutil.ReadFile(f)
+	config, err := ReadConfig(f)
 	checkErr(err)
 
-	OcfConfig = Config{}
+	// the OCF starts with the defaults of InitOcfContext, see --validate-config to reject it
+	if err := Validate(config); err != nil {
+		logger.InitLog.Warnf("%+v", err)
+	}
 
-	err = yaml.Unmarshal([]byte(content), &OcfConfig)
-	checkErr(err)
+	OcfConfig = *config
+	configFile = f
 
 	logger.InitLog.Infof("Successfully initialize configuration %s", f)
 }
+
+// ReadConfig reads and parses a configuration file without applying it
+func ReadConfig(f string) (*Config, error) {
+	content, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(content)
+}
+
+// ParseConfig parses the YAML content of a configuration file
+func ParseConfig(content []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+	if config.Configuration == nil {
+		return nil, fmt.Errorf("configuration is missing")
+	}
+	return config, nil
+}
+
+// ConfigFile returns the file the configuration has been read from
+func ConfigFile() string {
+	return configFile
+}
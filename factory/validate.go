@@ -0,0 +1,378 @@
+/*
+ * OCF Configuration Factory
+ */
+
+package factory
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d problem(s) in the configuration:\n  %s", len(e.Problems),
+		strings.Join(e.Problems, "\n  "))
+}
+
+var (
+	mccPattern = regexp.MustCompile(`^[0-9]{3}$`)
+	mncPattern = regexp.MustCompile(`^[0-9]{2,3}$`)
+	sdPattern  = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+)
+
+var integrityAlgorithms = map[string]bool{"NIA0": true, "NIA1": true, "NIA2": true, "NIA3": true}
+var cipheringAlgorithms = map[string]bool{"NEA0": true, "NEA1": true, "NEA2": true, "NEA3": true}
+
+var overloadActions = map[string]bool{
+	"": true,
+	context.OverloadActionRejectNonEmergencyMoDt:  true,
+	context.OverloadActionRejectRrcCrSignalling:   true,
+	context.OverloadActionPermitEmergencyAndMt:    true,
+	context.OverloadActionPermitHighPriorityAndMt: true,
+}
+
+// validator collects the problems, each one prefixed with the path of the value in the file
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// Validate checks a configuration and returns a *ValidationError with every problem found, or nil
+func Validate(config *Config) error {
+	v := &validator{}
+	if config.Info == nil {
+		v.addf("info", "is missing")
+	}
+	if configuration := config.Configuration; configuration == nil {
+		v.addf("configuration", "is missing")
+	} else {
+		v.validateConfiguration(configuration)
+	}
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+func (v *validator) validateConfiguration(c *Configuration) {
+	if c.RelativeCapacity != nil && (*c.RelativeCapacity < 0 || *c.RelativeCapacity > 255) {
+		v.addf("configuration.relativeCapacity", "%d is out of range (0~255)", *c.RelativeCapacity)
+	}
+	for i, ip := range c.NgapIpList {
+		if net.ParseIP(ip) == nil {
+			v.addf(fmt.Sprintf("configuration.ngapIpList[%d]", i), "%q is not an IP address", ip)
+		}
+	}
+	if sctp := c.Sctp; sctp != nil && sctp.NumOstreams == 1 {
+		v.addf("configuration.sctp.numOstreams", "must be at least 2, stream 0 is reserved for "+
+			"non-UE-associated signalling")
+	}
+	if ngapCapture := c.NgapCapture; ngapCapture != nil {
+		if ngapCapture.MaxFileSize < 0 {
+			v.addf("configuration.ngapCapture.maxFileSize", "must not be negative")
+		}
+		if ngapCapture.MaxFiles < 0 {
+			v.addf("configuration.ngapCapture.maxFiles", "must not be negative")
+		}
+	}
+	if overloadControl := c.OverloadControl; overloadControl != nil {
+		v.validateOverloadControl(overloadControl)
+	}
+	if sbi := c.Sbi; sbi == nil {
+		v.addf("configuration.sbi", "is missing")
+	} else {
+		if sbi.Scheme != "http" && sbi.Scheme != "https" {
+			v.addf("configuration.sbi.scheme", "%q is neither http nor https", sbi.Scheme)
+		}
+		if sbi.RegisterIPv4 != "" && net.ParseIP(sbi.RegisterIPv4).To4() == nil {
+			v.addf("configuration.sbi.registerIPv4", "%q is not an IPv4 address", sbi.RegisterIPv4)
+		}
+		if sbi.Port < 0 || sbi.Port > 65535 {
+			v.addf("configuration.sbi.port", "%d is out of range (0~65535)", sbi.Port)
+		}
+	}
+	v.validateNames("configuration.serviceNameList", c.ServiceNameList)
+	v.validateGuamis(c)
+	v.validatePlmnSupportList(c.PlmnSupportList)
+	for i, tai := range c.SupportTAIList {
+		v.validateTai(c, fmt.Sprintf("configuration.supportTaiList[%d]", i), tai)
+	}
+	if len(c.SupportTAIList) == 0 {
+		v.addf("configuration.supportTaiList", "is empty")
+	}
+	v.validateNames("configuration.supportDnnList", c.SupportDnnList)
+	if c.NrfUri != "" {
+		v.validateUri("configuration.nrfUri", c.NrfUri)
+	}
+	if security := c.Security; security != nil {
+		for i, algorithm := range security.IntegrityOrder {
+			if !integrityAlgorithms[algorithm] {
+				v.addf(fmt.Sprintf("configuration.security.integrityOrder[%d]", i), "unknown algorithm %s", algorithm)
+			}
+		}
+		for i, algorithm := range security.CipheringOrder {
+			if !cipheringAlgorithms[algorithm] {
+				v.addf(fmt.Sprintf("configuration.security.cipheringOrder[%d]", i), "unknown algorithm %s", algorithm)
+			}
+		}
+		if security.NasCountHeadroom < 0 || security.NasCountHeadroom > 0xffffff {
+			v.addf("configuration.security.nasCountHeadroom", "%d is out of range (0~16777215)",
+				security.NasCountHeadroom)
+		}
+		if reauthentication := security.Reauthentication; reauthentication != nil {
+			if reauthentication.Interval < 0 {
+				v.addf("configuration.security.reauthentication.interval", "must not be negative")
+			}
+			if reauthentication.Registrations < 0 {
+				v.addf("configuration.security.reauthentication.registrations", "must not be negative")
+			}
+		}
+	}
+	if c.Timers != nil {
+		v.validateTimers(c.Timers)
+	}
+	timers := []struct {
+		path  string
+		value int
+	}{
+		{"configuration.t3502", c.T3502},
+		{"configuration.t3512", c.T3512},
+		{"configuration.mon3gppDeregistrationTimer", c.Non3gppDeregistrationTimer},
+	}
+	for _, timer := range timers {
+		if timer.value < 0 {
+			v.addf(timer.path, "must not be negative")
+		}
+	}
+	if mico := c.Mico; mico != nil {
+		if mico.ActiveTime < 0 {
+			v.addf("configuration.mico.activeTime", "must not be negative")
+		}
+		if mico.ExtendedPeriodicTimer < 0 {
+			v.addf("configuration.mico.extendedPeriodicTimer", "must not be negative")
+		}
+	}
+	if emergency := c.Emergency; emergency != nil {
+		if emergency.Enable && emergency.Dnn == "" {
+			v.addf("configuration.emergency.dnn", "is missing")
+		}
+		if emergency.Snssai != nil {
+			v.validateSnssai("configuration.emergency.snssai", *emergency.Snssai)
+		}
+		if emergency.SmfUri != "" {
+			v.validateUri("configuration.emergency.smfUri", emergency.SmfUri)
+		}
+	}
+	if gutiReallocation := c.GutiReallocation; gutiReallocation != nil {
+		if gutiReallocation.ServiceRequests < 0 {
+			v.addf("configuration.gutiReallocation.serviceRequests", "must not be negative")
+		}
+		if gutiReallocation.Interval < 0 {
+			v.addf("configuration.gutiReallocation.interval", "must not be negative")
+		}
+	}
+	if c.RegistrationArea != nil {
+		v.validateRegistrationArea(c)
+	}
+}
+
+func (v *validator) validateTai(c *Configuration, path string, tai models.Tai) {
+	v.validatePlmnId(path+".plmnId", tai.PlmnId)
+	if tac, err := strconv.ParseUint(tai.Tac, 10, 32); err != nil || tac > 0xffffff {
+		v.addf(path+".tac", "%q is not a decimal TAC (0~16777215)", tai.Tac)
+	}
+	if tai.PlmnId != nil && !plmnSupported(c.PlmnSupportList, *tai.PlmnId) {
+		v.addf(path+".plmnId", "%s is not in plmnSupportList", plmnString(*tai.PlmnId))
+	}
+}
+
+func (v *validator) validateRegistrationArea(c *Configuration) {
+	registrationArea := c.RegistrationArea
+	if registrationArea.Strategy != "" && !context.RegistrationAreaStrategyKnown(registrationArea.Strategy) {
+		v.addf("configuration.registrationArea.strategy", "unknown strategy %s, one of %s expected",
+			registrationArea.Strategy, strings.Join(context.RegistrationAreaStrategies(), ", "))
+	}
+	if registrationArea.Strategy == context.RegistrationAreaStrategyStatic && len(registrationArea.TaiGroups) == 0 {
+		v.addf("configuration.registrationArea.taiGroups", "is empty for the static strategy")
+	}
+	if maxTais := registrationArea.MaxTais; maxTais < 0 || maxTais > context.MaxNumOfTAI {
+		v.addf("configuration.registrationArea.maxTais", "%d is out of range (1~%d)", maxTais, context.MaxNumOfTAI)
+	}
+	if registrationArea.HistorySize < 0 {
+		v.addf("configuration.registrationArea.historySize", "must not be negative")
+	}
+	for i, group := range registrationArea.TaiGroups {
+		if len(group) == 0 {
+			v.addf(fmt.Sprintf("configuration.registrationArea.taiGroups[%d]", i), "is empty")
+		}
+		for j, tai := range group {
+			v.validateTai(c, fmt.Sprintf("configuration.registrationArea.taiGroups[%d][%d]", i, j), tai)
+		}
+	}
+}
+
+func (v *validator) validateUri(path, value string) {
+	if uri, err := url.Parse(value); err != nil {
+		v.addf(path, "%+v", err)
+	} else if (uri.Scheme != "http" && uri.Scheme != "https") || uri.Hostname() == "" {
+		v.addf(path, "%q is not an http(s)://host[:port] URI", value)
+	} else if port := uri.Port(); port != "" {
+		if number, err := strconv.ParseUint(port, 10, 16); err != nil || number == 0 {
+			v.addf(path, "%q has an invalid port", value)
+		}
+	}
+}
+
+func (v *validator) validateOverloadControl(overloadControl *OverloadControl) {
+	if overloadControl.CheckInterval < 0 {
+		v.addf("configuration.overloadControl.checkInterval", "must not be negative")
+	}
+	thresholds := []struct {
+		name      string
+		threshold *OverloadThreshold
+	}{
+		{"queueDepth", overloadControl.QueueDepth},
+		{"sbiLatency", overloadControl.SbiLatency},
+		{"ueLoad", overloadControl.UeLoad},
+	}
+	for _, t := range thresholds {
+		if t.threshold != nil && t.threshold.Stop > t.threshold.Start {
+			v.addf("configuration.overloadControl."+t.name, "stop %d is above start %d", t.threshold.Stop,
+				t.threshold.Start)
+		}
+	}
+	if !overloadActions[overloadControl.Action] {
+		v.addf("configuration.overloadControl.action", "unknown action %s", overloadControl.Action)
+	}
+	if reduction := overloadControl.TrafficLoadReduction; reduction < 0 || reduction > 99 {
+		v.addf("configuration.overloadControl.trafficLoadReduction", "%d is out of range (1~99)", reduction)
+	}
+}
+
+// validateNames reports the empty and the repeated entries of a list of names
+func (v *validator) validateNames(path string, names []string) {
+	seen := make(map[string]bool)
+	for i, name := range names {
+		if strings.TrimSpace(name) == "" {
+			v.addf(fmt.Sprintf("%s[%d]", path, i), "is empty")
+		} else if seen[name] {
+			v.addf(fmt.Sprintf("%s[%d]", path, i), "%s is repeated", name)
+		}
+		seen[name] = true
+	}
+}
+
+func (v *validator) validateGuamis(c *Configuration) {
+	if len(c.ServedGumaiList) == 0 {
+		v.addf("configuration.servedGuamiList", "is empty")
+	}
+	for i, guami := range c.ServedGumaiList {
+		path := fmt.Sprintf("configuration.servedGuamiList[%d]", i)
+		v.validatePlmnId(path+".plmnId", guami.PlmnId)
+		// region ID (8 bits), set ID (10 bits) and pointer (6 bits) in hexadecimal
+		if ocfId, err := hex.DecodeString(guami.OcfId); err != nil || len(ocfId) != 3 {
+			v.addf(path+".amfId", "%q is not 6 hexadecimal digits", guami.OcfId)
+		}
+		if guami.PlmnId != nil && !plmnSupported(c.PlmnSupportList, *guami.PlmnId) {
+			v.addf(path+".plmnId", "%s is not in plmnSupportList", plmnString(*guami.PlmnId))
+		}
+	}
+}
+
+func (v *validator) validatePlmnSupportList(plmnSupportList []context.PlmnSupportItem) {
+	if len(plmnSupportList) == 0 {
+		v.addf("configuration.plmnSupportList", "is empty")
+	}
+	for i, item := range plmnSupportList {
+		path := fmt.Sprintf("configuration.plmnSupportList[%d]", i)
+		plmnId := item.PlmnId
+		v.validatePlmnId(path+".plmnId", &plmnId)
+		for j, snssai := range item.SNssaiList {
+			v.validateSnssai(fmt.Sprintf("%s.snssaiList[%d]", path, j), snssai)
+		}
+	}
+}
+
+func (v *validator) validateSnssai(path string, snssai models.Snssai) {
+	if snssai.Sst < 0 || snssai.Sst > 255 {
+		v.addf(path+".sst", "%d is out of range (0~255)", snssai.Sst)
+	}
+	if snssai.Sd != "" && !sdPattern.MatchString(snssai.Sd) {
+		v.addf(path+".sd", "%q is not 6 hexadecimal digits", snssai.Sd)
+	}
+}
+
+func (v *validator) validateTimers(timers *Timers) {
+	v.validateTimerSet("configuration.timers", &timers.TimerSet)
+	for i, override := range timers.AccessTypeOverrides {
+		path := fmt.Sprintf("configuration.timers.accessTypeOverrides[%d]", i)
+		if override.AccessType != models.AccessType__3_GPP_ACCESS &&
+			override.AccessType != models.AccessType_NON_3_GPP_ACCESS {
+			v.addf(path+".accessType", "unknown access type %q", override.AccessType)
+		}
+		v.validateTimerSet(path, &override.TimerSet)
+	}
+	for i, override := range timers.SnssaiOverrides {
+		path := fmt.Sprintf("configuration.timers.snssaiOverrides[%d]", i)
+		v.validateSnssai(path+".snssai", override.Snssai)
+		v.validateTimerSet(path, &override.TimerSet)
+	}
+}
+
+func (v *validator) validateTimerSet(path string, timerSet *TimerSet) {
+	timers := timerSet.Timers()
+	for _, name := range context.TimerNames {
+		timer := timers[name]
+		if timer == nil {
+			continue
+		}
+		if timer.Value < 0 {
+			v.addf(fmt.Sprintf("%s.%s.value", path, name), "must not be negative")
+		}
+		if timer.MaxRetryTimes != nil && *timer.MaxRetryTimes < 0 {
+			v.addf(fmt.Sprintf("%s.%s.maxRetryTimes", path, name), "must not be negative")
+		}
+	}
+}
+
+func (v *validator) validatePlmnId(path string, plmnId *models.PlmnId) {
+	if plmnId == nil {
+		v.addf(path, "is missing")
+		return
+	}
+	if !mccPattern.MatchString(plmnId.Mcc) {
+		v.addf(path+".mcc", "%q is not 3 digits", plmnId.Mcc)
+	}
+	if !mncPattern.MatchString(plmnId.Mnc) {
+		v.addf(path+".mnc", "%q is not 2 or 3 digits", plmnId.Mnc)
+	}
+}
+
+func plmnSupported(plmnSupportList []context.PlmnSupportItem, plmnId models.PlmnId) bool {
+	for _, item := range plmnSupportList {
+		if item.PlmnId == plmnId {
+			return true
+		}
+	}
+	return false
+}
+
+func plmnString(plmnId models.PlmnId) string {
+	return plmnId.Mcc + "-" + plmnId.Mnc
+}
@@ -0,0 +1,67 @@
+package factory
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/openapi/models"
+)
+
+func TestValidate(t *testing.T) {
+	config, err := ReadConfig("../util/test/testAmfcfg.conf")
+	require.Nil(t, err)
+	assert.Nil(t, Validate(config))
+
+	config, err = ReadConfig("../util/test/testAmfcfg2.conf")
+	require.Nil(t, err)
+	configuration := config.Configuration
+	configuration.ServiceNameList = append(configuration.ServiceNameList, "")
+	configuration.ServedGumaiList = append(configuration.ServedGumaiList,
+		models.Guami{PlmnId: &models.PlmnId{Mcc: "001", Mnc: "01"}, OcfId: "cafe0"})
+	configuration.SupportTAIList[0].Tac = "0x01"
+	configuration.NrfUri = "192.168.0.2"
+	configuration.Security.Reauthentication.Registrations = -1
+	configuration.Emergency.Dnn = ""
+	configuration.Emergency.SmfUri = "smf:29502"
+
+	err = Validate(config)
+	require.IsType(t, &ValidationError{}, err)
+	assert.Equal(t, []string{
+		`configuration.serviceNameList[2]: is empty`,
+		`configuration.servedGuamiList[2].amfId: "cafe0" is not 6 hexadecimal digits`,
+		`configuration.servedGuamiList[2].plmnId: 001-01 is not in plmnSupportList`,
+		`configuration.supportTaiList[0].tac: "0x01" is not a decimal TAC (0~16777215)`,
+		`configuration.nrfUri: "192.168.0.2" is not an http(s)://host[:port] URI`,
+		`configuration.security.cipheringOrder[2]: unknown algorithm EEA2`,
+		`configuration.security.reauthentication.registrations: must not be negative`,
+		`configuration.emergency.dnn: is missing`,
+		`configuration.emergency.smfUri: "smf:29502" is not an http(s)://host[:port] URI`,
+	}, err.(*ValidationError).Problems)
+}
+
+// the schema lists every parameter of the configuration
+func TestSchema(t *testing.T) {
+	content, err := ioutil.ReadFile("ocfcfg.schema.json")
+	require.Nil(t, err)
+	var schema struct {
+		Properties struct {
+			Configuration struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"configuration"`
+		} `json:"properties"`
+	}
+	require.Nil(t, json.Unmarshal(content, &schema))
+
+	configurationType := reflect.TypeOf(Configuration{})
+	for i := 0; i < configurationType.NumField(); i++ {
+		name := strings.Split(configurationType.Field(i).Tag.Get("yaml"), ",")[0]
+		assert.Contains(t, schema.Properties.Configuration.Properties, name)
+	}
+	assert.Len(t, schema.Properties.Configuration.Properties, configurationType.NumField())
+}
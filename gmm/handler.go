@@ -3,6 +3,7 @@ package gmm
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -20,6 +21,7 @@ import (
 	"free5gc/src/ocf/context"
 	gmm_message "free5gc/src/ocf/gmm/message"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/nas/nas_security"
 	ngap_message "free5gc/src/ocf/ngap/message"
 	"free5gc/src/ocf/producer/callback"
 	"free5gc/src/ocf/util"
@@ -75,7 +77,6 @@ func HandleULNASTransport(ue *context.OcfUe, anType models.AccessType,
 				requestType = models.RequestType_EXISTING_PDU_SESSION
 			case nasMessage.ULNASTransportRequestTypeInitialEmergencyRequest:
 				requestType = models.RequestType_INITIAL_EMERGENCY_REQUEST
-				logger.GmmLog.Warnln("requestType INITIAL_EMERGENCY_REQUEST is not supported")
 			case nasMessage.ULNASTransportRequestTypeExistingEmergencyPduSession:
 				requestType = models.RequestType_EXISTING_EMERGENCY_PDU_SESSION
 			}
@@ -138,12 +139,31 @@ func HandlePDUSessionEstablishmentRequest(ue *context.OcfUe, anType models.Acces
 	pduSessionID int32, requestType models.RequestType, sNssai *models.Snssai, dnn string) error {
 
 	logger.GmmLog.Infoln("[OCF] Handle PDU Session Establishment Request")
-	// TODO Request Type Emergency requset
 	var pduSession models.PduSessionContext
 	pduSession.PduSessionId = pduSessionID
 	pduSession.AccessType = anType
 	amfSelf := context.OCF_Self()
-	if requestType == models.RequestType_INITIAL_REQUEST {
+	parameters := amfSelf.Parameters()
+	if requestType == models.RequestType_INITIAL_EMERGENCY_REQUEST {
+		if !parameters.Emergency.Enable {
+			gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
+				payload, pduSessionID, nasMessage.Cause5GMMPayloadWasNotForwarded, nil, 0)
+			return fmt.Errorf("Emergency PDU Session[%d] is rejected: emergency services are not supported",
+				pduSessionID)
+		}
+		// TS 23.501 5.16.4.4: the emergency PDU sessions are established on the DNN and S-NSSAI of the
+		// emergency configuration whatever the UE requests
+		emergencySnssai := parameters.Emergency.Snssai
+		sNssai = &emergencySnssai
+		dnn = parameters.Emergency.Dnn
+	} else if requestType == models.RequestType_INITIAL_REQUEST && ue.EmergencyRegistered {
+		gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
+			payload, pduSessionID, nasMessage.Cause5GMMPayloadWasNotForwarded, nil, 0)
+		return fmt.Errorf("PDU Session[%d] is rejected: the UE is registered for emergency services only",
+			pduSessionID)
+	}
+	if requestType == models.RequestType_INITIAL_REQUEST ||
+		requestType == models.RequestType_INITIAL_EMERGENCY_REQUEST {
 
 		if sNssai == nil {
 			if ue.SmfSelectionData != nil {
@@ -178,12 +198,20 @@ func HandlePDUSessionEstablishmentRequest(ue *context.OcfUe, anType models.Acces
 		pduSession.SNssai = sNssai
 		if dnn == "" {
 			// default DNN decided by OCF
-			dnn = amfSelf.SupportDnnLists[0]
+			dnn = parameters.SupportDnnLists[0]
 		}
 		pduSession.Dnn = dnn
 
 		var smfID, smfUri string
-		if smfIDTmp, smfUriTmp, err := selectSmf(ue, anType, &pduSession, payload); err != nil {
+		if requestType == models.RequestType_INITIAL_EMERGENCY_REQUEST {
+			if smfUri = parameters.Emergency.SmfUri; smfUri == "" {
+				var err error
+				if smfUri, err = searchSmf(ue, anType, &pduSession, payload, amfSelf.NrfUri); err != nil {
+					logger.GmmLog.Errorf("[OCF] SMF Selection for emergency DNN[%s] Failed[%+v]", dnn, err)
+					return err
+				}
+			}
+		} else if smfIDTmp, smfUriTmp, err := selectSmf(ue, anType, &pduSession, payload); err != nil {
 			logger.GmmLog.Errorf("[OCF] SMF Selection for Snssai[%+v] Failed[%+v]", sNssai, err)
 			return err
 		} else {
@@ -199,6 +227,7 @@ func HandlePDUSessionEstablishmentRequest(ue *context.OcfUe, anType models.Acces
 				PduSessionContext: &pduSession,
 				AnType:            anType,
 				Payload:           payload,
+				RequestType:       requestType,
 			}
 			updateData := models.SmContextUpdateData{
 				Release: true,
@@ -260,7 +289,8 @@ func HandlePDUSessionEstablishmentRequest(ue *context.OcfUe, anType models.Acces
 			// TODO: error handling
 			return fmt.Errorf("Failed to Create smContext[pduSessionID: %d], Error[%v]", pduSessionID, problemDetail)
 		}
-	} else if requestType == models.RequestType_EXISTING_PDU_SESSION {
+	} else if requestType == models.RequestType_EXISTING_PDU_SESSION ||
+		requestType == models.RequestType_EXISTING_EMERGENCY_PDU_SESSION {
 		smContext, ok := ue.SmContextList[pduSessionID]
 		if !ok {
 			gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
@@ -279,7 +309,9 @@ func HandlePDUSessionEstablishmentRequest(ue *context.OcfUe, anType models.Acces
 		if anType == models.AccessType__3_GPP_ACCESS {
 			targetAccessType = models.AccessType_NON_3_GPP_ACCESS
 		}
-		if !ue.InAllowedNssai(*smContext.PduSessionContext.SNssai, targetAccessType) {
+		// the S-NSSAI of an emergency PDU session is not subject to the Allowed NSSAI
+		if requestType == models.RequestType_EXISTING_PDU_SESSION &&
+			!ue.InAllowedNssai(*smContext.PduSessionContext.SNssai, targetAccessType) {
 			gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
 				payload, pduSessionID, nasMessage.Cause5GMMPayloadWasNotForwarded, nil, 0)
 			return fmt.Errorf("S-NSSAI[%v] is not present in the Allowed NSSAI of target Access",
@@ -306,7 +338,7 @@ func HandlePDUSessionEstablishmentRequest(ue *context.OcfUe, anType models.Acces
 			return nil
 		}
 	} else {
-		return fmt.Errorf("RequestType INITIAL_EMERGENCY_REQUEST and EXISTING_EMERGENCY_PDU_SESSION haven't been supported")
+		return fmt.Errorf("RequestType[%s] of PDU Session[%d] is not supported", requestType, pduSessionID)
 	}
 	return nil
 }
@@ -356,6 +388,16 @@ func selectSmf(ue *context.OcfUe, anType models.AccessType, pduSession *models.P
 		nrfUri = fmt.Sprintf("%s://%s", nrfApiUrl.Scheme, nrfApiUrl.Host)
 	}
 
+	smfUri, err = searchSmf(ue, anType, pduSession, payload, nrfUri)
+	return smfID, smfUri, err
+}
+
+// searchSmf discovers an SMF serving the DNN and S-NSSAI of the PDU session at the NRF
+func searchSmf(ue *context.OcfUe, anType models.AccessType, pduSession *models.PduSessionContext,
+	payload []byte, nrfUri string) (string, error) {
+
+	var smfUri string
+
 	param := Nnrf_NFDiscovery.SearchNFInstancesParamOpts{
 		ServiceNames: optional.NewInterface([]models.ServiceName{models.ServiceName_NSMF_PDUSESSION}),
 		Dnn:          optional.NewString(pduSession.Dnn),
@@ -375,7 +417,7 @@ func selectSmf(ue *context.OcfUe, anType models.AccessType, pduSession *models.P
 		logger.GmmLog.Errorf(err.Error())
 		gmm_message.SendDLNASTransport(ue.RanUe[anType], nasMessage.PayloadContainerTypeN1SMInfo,
 			payload, pduSession.PduSessionId, nasMessage.Cause5GMMDNNNotSupportedOrNotSubscribedInTheSlice, nil, 0)
-		return "", "", err
+		return "", err
 	}
 
 	// select the first SMF, TODO: select base on other info
@@ -385,7 +427,7 @@ func selectSmf(ue *context.OcfUe, anType models.AccessType, pduSession *models.P
 			break
 		}
 	}
-	return smfID, smfUri, nil
+	return smfUri, nil
 }
 
 func HandlePDUSessionModificationForward(ue *context.OcfUe, anType models.AccessType, payload []byte,
@@ -588,6 +630,7 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 
 	var guamiFromUeGuti models.Guami
 	amfSelf := context.OCF_Self()
+	parameters := amfSelf.Parameters()
 
 	if ue == nil {
 		return fmt.Errorf("OcfUe is nil")
@@ -597,6 +640,9 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 		return fmt.Errorf("RanUe is nil")
 	}
 
+	// the IEs the NAS library does not decode are read from the plain message
+	plainRegistrationRequest := plainNasPdu(ue.RegistrationRequestNasPdu)
+
 	// TS 24.501 8.2.6.21: if the UE is sending a REGISTRATION REQUEST message as an initial NAS message,
 	// the UE has a valid 5G NAS security context and the UE needs to send non-cleartext IEs
 	// TS 24.501 4.4.6: When the UE sends a REGISTRATION REQUEST or SERVICE REQUEST message that includes a NAS message
@@ -607,7 +653,7 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 		// TS 24.501 4.4.6: When the UE sends a REGISTRATION REQUEST or SERVICE REQUEST message that includes a NAS
 		// message container IE, the UE shall set the security header type of the initial NAS message to
 		// "integrity protected"; then the OCF shall decipher the value part of the NAS message container IE
-		err := security.NASEncrypt(ue.CipheringAlg, ue.KnasEnc, ue.ULCount.Get(), security.Bearer3GPP,
+		err := nas_security.NASEncrypt(ue.CipheringAlg, ue.KnasEnc, ue.ULCount.Get(), security.Bearer3GPP,
 			security.DirectionUplink, contents)
 		if err != nil {
 			ue.SecurityContextAvailable = false
@@ -624,28 +670,38 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 			// TS 24.501 4.4.6: The OCF shall consider the NAS message that is obtained from the NAS message container
 			// IE as the initial NAS message that triggered the procedure
 			registrationRequest = m.RegistrationRequest
+			plainRegistrationRequest = contents
 		}
 		// TS 33.501 6.4.6 step 3: if the initial NAS message was protected but did not pass the integrity check
 		ue.RetransmissionOfInitialNASMsg = ue.MacFailed
 	}
 
 	ue.RegistrationRequest = registrationRequest
+	ue.RequestedT3324Value = requestedT3324Value(plainRegistrationRequest)
 	ue.RegistrationType5GS = registrationRequest.NgksiAndRegistrationType5GS.GetRegistrationType5GS()
 	switch ue.RegistrationType5GS {
 	case nasMessage.RegistrationType5GSInitialRegistration:
 		logger.GmmLog.Debugf("RegistrationType: Initial Registration")
+		ue.EmergencyRegistered = false
 	case nasMessage.RegistrationType5GSMobilityRegistrationUpdating:
 		logger.GmmLog.Debugf("RegistrationType: Mobility Registration Updating")
 	case nasMessage.RegistrationType5GSPeriodicRegistrationUpdating:
 		logger.GmmLog.Debugf("RegistrationType: Periodic Registration Updating")
 	case nasMessage.RegistrationType5GSEmergencyRegistration:
-		return fmt.Errorf("Not Supportted RegistrationType: Emergency Registration")
+		logger.GmmLog.Debugf("RegistrationType: Emergency Registration")
+		if !parameters.Emergency.Enable {
+			gmm_message.SendRegistrationReject(ue.RanUe[anType], nasMessage.Cause5GMM5GSServicesNotAllowed, "")
+			return fmt.Errorf("Registration Reject[Emergency services are not supported]")
+		}
+		ue.EmergencyRegistered = true
 	case nasMessage.RegistrationType5GSReserved:
 		ue.RegistrationType5GS = nasMessage.RegistrationType5GSInitialRegistration
+		ue.EmergencyRegistered = false
 		logger.GmmLog.Debugf("RegistrationType: Reserved")
 	default:
 		logger.GmmLog.Debugf("RegistrationType: %v, chage state to InitialRegistration", ue.RegistrationType5GS)
 		ue.RegistrationType5GS = nasMessage.RegistrationType5GSInitialRegistration
+		ue.EmergencyRegistered = false
 	}
 
 	mobileIdentity5GSContents := registrationRequest.MobileIdentity5GS.GetMobileIdentity5GSContents()
@@ -664,7 +720,7 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 		ue.Guti = guti
 		logger.GmmLog.Debugf("GUTI: %s", guti)
 
-		servedGuami := amfSelf.ServedGuamiList[0]
+		servedGuami := parameters.ServedGuamiList[0]
 		if reflect.DeepEqual(guamiFromUeGuti, servedGuami) {
 			ue.ServingOcfChanged = false
 		} else {
@@ -681,6 +737,15 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 		logger.GmmLog.Debugf("PEI: %s", imeisv)
 	}
 
+	// TS 24.501 5.5.1.2.2: only a UE without valid SUCI and 5G-GUTI registers for emergency services with its PEI
+	if ue.IdentityTypeUsedForRegistration == nasMessage.MobileIdentity5GSTypeImei ||
+		ue.IdentityTypeUsedForRegistration == nasMessage.MobileIdentity5GSTypeImeisv {
+		if !ue.EmergencyRegistered || !parameters.Emergency.AllowWithoutSim {
+			gmm_message.SendRegistrationReject(ue.RanUe[anType], nasMessage.Cause5GMMPEINotAccepted, "")
+			return fmt.Errorf("Registration Reject[PEI not accepted]")
+		}
+	}
+
 	// NgKsi: TS 24.501 9.11.3.32
 	switch registrationRequest.NgksiAndRegistrationType5GS.GetTSC() {
 	case nasMessage.TypeOfSecurityContextFlagNative:
@@ -696,12 +761,14 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 		ue.NgKsi.Ksi = 0
 	}
 
+	checkReauthenticationPolicy(ue)
+
 	// Copy UserLocation from ranUe
 	ue.Location = ue.RanUe[anType].Location
 	ue.Tai = ue.RanUe[anType].Tai
 
 	// Check TAI
-	if !context.InTaiList(ue.Tai, amfSelf.SupportTaiLists) {
+	if !context.InTaiList(ue.Tai, parameters.SupportTaiLists) {
 		gmm_message.SendRegistrationReject(ue.RanUe[anType], nasMessage.Cause5GMMTrackingAreaNotAllowed, "")
 		return fmt.Errorf("Registration Reject[Tracking area not allowed]")
 	}
@@ -753,6 +820,64 @@ func HandleRegistrationRequest(ue *context.OcfUe, anType models.AccessType, proc
 	return nil
 }
 
+// gutiReallocationRequired tells whether the GUTI reallocation policy gives the UE a new 5G-GUTI at its
+// registration or at its service request
+func gutiReallocationRequired(ue *context.OcfUe, registration bool) bool {
+	policy := context.OCF_Self().Parameters().GutiReallocation
+
+	switch {
+	case registration && policy.Registration:
+		return true
+	case policy.ServiceRequests > 0 && ue.ServiceRequestsSinceGutiAllocation >= policy.ServiceRequests:
+		return true
+	case policy.Interval > 0 && time.Since(ue.GutiAllocationTime) >= policy.Interval:
+		return true
+	}
+	return false
+}
+
+// reallocateGutiAtRegistration gives the UE a new 5G-GUTI in the Registration Accept, the old one is freed at
+// the Registration Complete. The 5G-GUTI of a UE registering with its SUCI is a new one already.
+func reallocateGutiAtRegistration(ue *context.OcfUe) {
+	if ue.IdentityTypeUsedForRegistration == nasMessage.MobileIdentity5GSTypeSuci || !gutiReallocationRequired(ue, true) {
+		logger.GmmLog.Debugf("Use original GUTI[%s]", ue.Guti)
+		return
+	}
+	context.OCF_Self().ReallocateGuti(ue)
+}
+
+// reallocateGutiAtServiceRequest gives the connected UE a new 5G-GUTI by a Configuration Update Command, the
+// old one is freed once the UE acknowledges it
+func reallocateGutiAtServiceRequest(ue *context.OcfUe, anType models.AccessType) {
+	ue.ServiceRequestsSinceGutiAllocation++
+	if !ue.CmConnect(anType) || !ue.SecurityContextIsValid() || ue.T3555 != nil ||
+		!gutiReallocationRequired(ue, false) {
+		return
+	}
+	context.OCF_Self().ReallocateGuti(ue)
+	if err := gmm_message.UpdateUeConfiguration(ue, anType, context.ConfigurationUpdate{Guti: true}); err != nil {
+		logger.GmmLog.Errorf("GUTI reallocation of UE[%s] failed: %+v", ue.Supi, err)
+	}
+}
+
+// checkReauthenticationPolicy requires the re-authentication of the UE at its registration once the
+// interval or the number of registrations since its last authentication is reached
+func checkReauthenticationPolicy(ue *context.OcfUe) {
+	policy := context.OCF_Self().Parameters().Reauthentication
+
+	ue.RegistrationsSinceAuthentication++
+	if policy.Registrations > 0 && ue.RegistrationsSinceAuthentication >= policy.Registrations {
+		logger.GmmLog.Infof("Registration %d of UE[%s] since its last authentication, re-authentication is required",
+			ue.RegistrationsSinceAuthentication, ue.Supi)
+		ue.ReauthenticationRequired = true
+	}
+	if policy.Interval > 0 && !ue.AuthenticationTime.IsZero() && time.Since(ue.AuthenticationTime) >= policy.Interval {
+		logger.GmmLog.Infof("UE[%s] was authenticated at %s, re-authentication is required", ue.Supi,
+			ue.AuthenticationTime.Format(time.RFC3339))
+		ue.ReauthenticationRequired = true
+	}
+}
+
 func IdentityVerification(ue *context.OcfUe) bool {
 	return ue.Supi != "" || len(ue.Suci) != 0
 }
@@ -761,17 +886,23 @@ func HandleInitialRegistration(ue *context.OcfUe, anType models.AccessType) erro
 	logger.GmmLog.Infoln("[OCF] Handle InitialRegistration")
 
 	amfSelf := context.OCF_Self()
+	parameters := amfSelf.Parameters()
 
 	// update Kgnb/Kn3iwf
 	ue.UpdateSecurityContext(anType)
 
-	// Registration with OCF re-allocation (TS 23.502 4.2.2.2.3)
-	if len(ue.SubscribedNssai) == 0 {
-		getSubscribedNssai(ue)
-	}
+	// the UEs not authenticated for emergency services have no subscription at the UDM and no AM policy
+	emergencyOnly := ue.UnauthenticatedEmergency()
 
-	if err := handleRequestedNssai(ue, anType); err != nil {
-		return err
+	if !emergencyOnly {
+		// Registration with OCF re-allocation (TS 23.502 4.2.2.2.3)
+		if len(ue.SubscribedNssai) == 0 {
+			getSubscribedNssai(ue)
+		}
+
+		if err := handleRequestedNssai(ue, anType); err != nil {
+			return err
+		}
 	}
 
 	if ue.RegistrationRequest.Capability5GMM != nil {
@@ -783,11 +914,6 @@ func HandleInitialRegistration(ue *context.OcfUe, anType models.AccessType) erro
 
 	storeLastVisitedRegisteredTAI(ue, ue.RegistrationRequest.LastVisitedRegisteredTAI)
 
-	if ue.RegistrationRequest.MICOIndication != nil {
-		logger.GmmLog.Warnf("Receive MICO Indication[RAAI: %d], Not Supported",
-			ue.RegistrationRequest.MICOIndication.GetRAAI())
-	}
-
 	// TODO: Negotiate DRX value if need (TS 23.501 5.4.5)
 	negotiateDRXParameters(ue, ue.RegistrationRequest.RequestedDRXParameters)
 
@@ -818,45 +944,47 @@ func HandleInitialRegistration(ue *context.OcfUe, anType models.AccessType) erro
 	// TODO (step 12 optional): the new OCF initiates ME identity check by invoking the
 	// N5g-eir_EquipmentIdentityCheck_Get service operation
 
-	if ue.ServingOcfChanged || ue.State[models.AccessType_NON_3_GPP_ACCESS].Is(context.Registered) ||
-		!ue.ContextValid {
-		if err := communicateWithUDM(ue, anType); err != nil {
-			return err
+	if !emergencyOnly {
+		if ue.ServingOcfChanged || ue.State[models.AccessType_NON_3_GPP_ACCESS].Is(context.Registered) ||
+			!ue.ContextValid {
+			if err := communicateWithUDM(ue, anType); err != nil {
+				return err
+			}
 		}
-	}
 
-	param := Nnrf_NFDiscovery.SearchNFInstancesParamOpts{
-		Supi: optional.NewString(ue.Supi),
-	}
-	for {
-		resp, err := consumer.SendSearchNFInstances(amfSelf.NrfUri, models.NfType_PCF, models.NfType_OCF, &param)
-		if err != nil {
-			logger.GmmLog.Error("OCF can not select an PCF by NRF")
-		} else {
-			// select the first PCF, TODO: select base on other info
-			var pcfUri string
-			for _, nfProfile := range resp.NfInstances {
-				pcfUri = util.SearchNFServiceUri(nfProfile, models.ServiceName_NPCF_AM_POLICY_CONTROL,
-					models.NfServiceStatus_REGISTERED)
-				if pcfUri != "" {
-					ue.PcfId = nfProfile.NfInstanceId
-					break
-				}
-			}
-			if ue.PcfUri = pcfUri; ue.PcfUri == "" {
+		param := Nnrf_NFDiscovery.SearchNFInstancesParamOpts{
+			Supi: optional.NewString(ue.Supi),
+		}
+		for {
+			resp, err := consumer.SendSearchNFInstances(amfSelf.NrfUri, models.NfType_PCF, models.NfType_OCF, &param)
+			if err != nil {
 				logger.GmmLog.Error("OCF can not select an PCF by NRF")
 			} else {
-				break
+				// select the first PCF, TODO: select base on other info
+				var pcfUri string
+				for _, nfProfile := range resp.NfInstances {
+					pcfUri = util.SearchNFServiceUri(nfProfile, models.ServiceName_NPCF_AM_POLICY_CONTROL,
+						models.NfServiceStatus_REGISTERED)
+					if pcfUri != "" {
+						ue.PcfId = nfProfile.NfInstanceId
+						break
+					}
+				}
+				if ue.PcfUri = pcfUri; ue.PcfUri == "" {
+					logger.GmmLog.Error("OCF can not select an PCF by NRF")
+				} else {
+					break
+				}
 			}
+			time.Sleep(500 * time.Millisecond) // sleep a while when search NF Instance fail
 		}
-		time.Sleep(500 * time.Millisecond) // sleep a while when search NF Instance fail
-	}
 
-	problemDetails, err := consumer.AMPolicyControlCreate(ue, anType)
-	if problemDetails != nil {
-		logger.GmmLog.Errorf("AM Policy Control Create Failed Problem[%+v]", problemDetails)
-	} else if err != nil {
-		logger.GmmLog.Errorf("AM Policy Control Create Error[%+v]", err)
+		problemDetails, err := consumer.AMPolicyControlCreate(ue, anType)
+		if problemDetails != nil {
+			logger.GmmLog.Errorf("AM Policy Control Create Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			logger.GmmLog.Errorf("AM Policy Control Create Error[%+v]", err)
+		}
 	}
 
 	// Service Area Restriction are applicable only to 3GPP access
@@ -884,17 +1012,29 @@ func HandleInitialRegistration(ue *context.OcfUe, anType models.AccessType) erro
 	// }
 
 	amfSelf.AllocateRegistrationArea(ue, anType)
-	logger.GmmLog.Debugf("Use original GUTI[%s]", ue.Guti)
+	reallocateGutiAtRegistration(ue)
 
 	assignLadnInfo(ue, anType)
 
-	amfSelf.AddOcfUeToUePool(ue, ue.Supi)
-	ue.T3502Value = amfSelf.T3502Value
+	if oldUe, ok := amfSelf.OcfUeFindBySupi(ue.Supi); ok && oldUe != ue {
+		// the previous context of the UE is replaced, its timers must not deregister the UE
+		util.StopMobileReachableTimer(oldUe)
+		util.StopImplicitDeregistrationTimer(oldUe)
+		util.StopNon3gppImplicitDeregistrationTimer(oldUe)
+	}
+	if ue.Supi != "" {
+		amfSelf.AddOcfUeToUePool(ue, ue.Supi)
+	} else {
+		// a UE without SIM is only known by its PEI
+		amfSelf.UePool.Store(ue.Pei, ue)
+	}
+	ue.T3502Value = parameters.T3502Value
 	if anType == models.AccessType__3_GPP_ACCESS {
-		ue.T3512Value = amfSelf.T3512Value
+		ue.T3512Value = parameters.T3512Value
 	} else {
-		ue.Non3gppDeregistrationTimerValue = amfSelf.Non3gppDeregistrationTimerValue
+		ue.Non3gppDeregistrationTimerValue = parameters.Non3gppDeregistrationTimerValue
 	}
+	negotiateMicoMode(ue, anType)
 
 	if anType == models.AccessType__3_GPP_ACCESS {
 		gmm_message.SendRegistrationAccept(ue, anType, nil, nil, nil, nil, nil)
@@ -925,13 +1065,18 @@ func HandleMobilityAndPeriodicRegistrationUpdating(ue *context.OcfUe, anType mod
 		}
 	}
 
-	// Registration with OCF re-allocation (TS 23.502 4.2.2.2.3)
-	if len(ue.SubscribedNssai) == 0 {
-		getSubscribedNssai(ue)
-	}
+	// the UEs not authenticated for emergency services have no subscription at the UDM
+	emergencyOnly := ue.UnauthenticatedEmergency()
 
-	if err := handleRequestedNssai(ue, anType); err != nil {
-		return err
+	if !emergencyOnly {
+		// Registration with OCF re-allocation (TS 23.502 4.2.2.2.3)
+		if len(ue.SubscribedNssai) == 0 {
+			getSubscribedNssai(ue)
+		}
+
+		if err := handleRequestedNssai(ue, anType); err != nil {
+			return err
+		}
 	}
 
 	if ue.RegistrationRequest.Capability5GMM != nil {
@@ -945,11 +1090,6 @@ func HandleMobilityAndPeriodicRegistrationUpdating(ue *context.OcfUe, anType mod
 
 	storeLastVisitedRegisteredTAI(ue, ue.RegistrationRequest.LastVisitedRegisteredTAI)
 
-	if ue.RegistrationRequest.MICOIndication != nil {
-		logger.GmmLog.Warnf("Receive MICO Indication[RAAI: %d], Not Supported",
-			ue.RegistrationRequest.MICOIndication.GetRAAI())
-	}
-
 	// TODO: Negotiate DRX value if need (TS 23.501 5.4.5)
 	negotiateDRXParameters(ue, ue.RegistrationRequest.RequestedDRXParameters)
 
@@ -966,8 +1106,8 @@ func HandleMobilityAndPeriodicRegistrationUpdating(ue *context.OcfUe, anType mod
 	// TODO (step 12 optional): the new OCF initiates ME identity check by invoking the
 	// N5g-eir_EquipmentIdentityCheck_Get service operation
 
-	if ue.ServingOcfChanged || ue.State[models.AccessType_NON_3_GPP_ACCESS].Is(context.Registered) ||
-		!ue.ContextValid {
+	if !emergencyOnly && (ue.ServingOcfChanged || ue.State[models.AccessType_NON_3_GPP_ACCESS].Is(context.Registered) ||
+		!ue.ContextValid) {
 		if err := communicateWithUDM(ue, anType); err != nil {
 			return err
 		}
@@ -1197,12 +1337,17 @@ func HandleMobilityAndPeriodicRegistrationUpdating(ue *context.OcfUe, anType mod
 	// 	TODO: send N2 OCF Mobility Request
 	// }
 
+	if ue.RegistrationType5GS == nasMessage.RegistrationType5GSMobilityRegistrationUpdating {
+		ue.MobilityRegistrations++
+	}
 	amfSelf.AllocateRegistrationArea(ue, anType)
 	assignLadnInfo(ue, anType)
 
-	// TODO: GUTI reassignment if need (based on operator poilcy)
+	reallocateGutiAtRegistration(ue)
 	// TODO: T3512/Non3GPP de-registration timer reassignment if need (based on operator policy)
 
+	negotiateMicoMode(ue, anType)
+
 	if ue.RanUe[anType].UeContextRequest {
 		if anType == models.AccessType__3_GPP_ACCESS {
 			gmm_message.SendRegistrationAccept(ue, anType, pduSessionStatus, reactivationResult,
@@ -1279,6 +1424,129 @@ func negotiateDRXParameters(ue *context.OcfUe, requestedDRXParameters *nasType.R
 	}
 }
 
+// negotiateMicoMode grants the MICO mode requested over 3GPP access when the subscription of the UE and
+// the local policy allow it, the UE leaves the MICO mode at a registration without MICO indication
+// (TS 23.501 5.4.1.3, TS 24.501 5.5.1.2.4)
+func negotiateMicoMode(ue *context.OcfUe, anType models.AccessType) {
+	if anType != models.AccessType__3_GPP_ACCESS {
+		return
+	}
+	parameters := context.OCF_Self().Parameters()
+
+	if ue.MicoMode {
+		ue.T3512Value = parameters.T3512Value
+	}
+	ue.MicoMode = false
+	ue.MicoAllPlmnRegistrationArea = false
+	ue.T3324Value = 0
+
+	micoIndication := ue.RegistrationRequest.MICOIndication
+	if micoIndication == nil {
+		return
+	}
+	if !parameters.Mico.Enable {
+		logger.GmmLog.Infof("MICO mode is not granted to UE[%s]: disabled by local policy", ue.Supi)
+		return
+	}
+	if ue.AccessAndMobilitySubscriptionData == nil || !ue.AccessAndMobilitySubscriptionData.MicoAllowed {
+		logger.GmmLog.Infof("MICO mode is not granted to UE[%s]: not allowed by subscription", ue.Supi)
+		return
+	}
+
+	ue.MicoMode = true
+	ue.MicoAllPlmnRegistrationArea = micoIndication.GetRAAI() == 1 && parameters.Mico.AllPlmnRegistrationArea
+	// the active time is granted to the UE requesting one, no longer than the local policy allows; the
+	// value is the one the UE decodes from the T3324 value IE
+	if activeTime := ue.RequestedT3324Value; activeTime > 0 && parameters.Mico.ActiveTime > 0 {
+		if activeTime > parameters.Mico.ActiveTime {
+			activeTime = parameters.Mico.ActiveTime
+		}
+		ue.T3324Value = gprsTimer3ToSeconds(nasConvert.GPRSTimer3ToNas(activeTime))
+	}
+	if parameters.Mico.ExtendedPeriodicTimer != 0 {
+		ue.T3512Value = parameters.Mico.ExtendedPeriodicTimer
+	}
+	logger.GmmLog.Infof("MICO mode is granted to UE[%s] [RAAI: %t, T3324: %ds, T3512: %ds]", ue.Supi,
+		ue.MicoAllPlmnRegistrationArea, ue.T3324Value, ue.T3512Value)
+}
+
+// registrationRequestT3324ValueType is the IEI of the T3324 value IE of the Registration Request, which
+// the NAS library does not decode (TS 24.501 8.2.6.1)
+const registrationRequestT3324ValueType uint8 = 0x6A
+
+// plainNasPdu returns the plain NAS message of a NAS message sent by the UE which is not ciphered, nil if
+// it is
+func plainNasPdu(pdu []byte) []byte {
+	if len(pdu) < 2 {
+		return nil
+	}
+	switch nas.GetSecurityHeaderType(pdu) & 0x0f {
+	case nas.SecurityHeaderTypePlainNas:
+		return pdu
+	case nas.SecurityHeaderTypeIntegrityProtected, nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext:
+		if len(pdu) > 7 {
+			return pdu[7:]
+		}
+	}
+	return nil
+}
+
+// requestedT3324Value returns the active time the plain Registration Request asks for in seconds, 0 if
+// none. The optional IEs are skipped by their format (TS 24.007 11.2.4) up to the T3324 value IE.
+func requestedT3324Value(registrationRequest []byte) int {
+	// the header, the 5GS registration type and ngKSI octet and the 5GS mobile identity (LV-E)
+	if len(registrationRequest) < 6 {
+		return 0
+	}
+	offset := 6 + int(binary.BigEndian.Uint16(registrationRequest[4:6]))
+	for offset < len(registrationRequest) {
+		iei := registrationRequest[offset]
+		switch {
+		case iei >= 0x80: // type 1, the IEI and the value share an octet
+			offset++
+		case iei == nasMessage.RegistrationRequestLastVisitedRegisteredTAIType: // type 3
+			offset += 7
+		case iei&0xf0 == 0x70: // type 6, TLV-E
+			if offset+3 > len(registrationRequest) {
+				return 0
+			}
+			offset += 3 + int(binary.BigEndian.Uint16(registrationRequest[offset+1:offset+3]))
+		default: // type 4, TLV
+			if offset+2 > len(registrationRequest) {
+				return 0
+			}
+			length := int(registrationRequest[offset+1])
+			if iei == registrationRequestT3324ValueType && length == 1 && offset+2 < len(registrationRequest) {
+				return gprsTimer3ToSeconds(registrationRequest[offset+2])
+			}
+			offset += 2 + length
+		}
+	}
+	return 0
+}
+
+// gprsTimer3ToSeconds decodes a GPRS timer 3 value (TS 24.008 10.5.7.4a), 0 if the timer is deactivated
+func gprsTimer3ToSeconds(timerValueNas uint8) int {
+	value := int(timerValueNas & 0x1f)
+	switch timerValueNas >> 5 {
+	case nasMessage.GPRSTimer3UnitMultiplesOf2Seconds:
+		return value * 2
+	case nasMessage.GPRSTimer3UnitMultiplesOf30Seconds:
+		return value * 30
+	case nasMessage.GPRSTimer3UnitMultiplesOf1Minute:
+		return value * 60
+	case nasMessage.GPRSTimer3UnitMultiplesOf10Minutes:
+		return value * 600
+	case nasMessage.GPRSTimer3UnitMultiplesOf1Hour:
+		return value * 3600
+	case nasMessage.GPRSTimer3UnitMultiplesOf10Hours:
+		return value * 36000
+	case 0x06: // multiples of 320 hours
+		return value * 320 * 3600
+	}
+	return 0
+}
+
 func communicateWithUDM(ue *context.OcfUe, accessType models.AccessType) error {
 	logger.GmmLog.Debugln("communicateWithUDM")
 	amfSelf := context.OCF_Self()
@@ -1374,6 +1642,7 @@ func getSubscribedNssai(ue *context.OcfUe) {
 // TS 23.502 4.2.2.2.3 Registration with OCF Re-allocation
 func handleRequestedNssai(ue *context.OcfUe, anType models.AccessType) error {
 	amfSelf := context.OCF_Self()
+	parameters := amfSelf.Parameters()
 
 	var requestedNssai []models.Snssai
 	if ue.RegistrationRequest.RequestedNSSAI != nil {
@@ -1441,7 +1710,7 @@ func handleRequestedNssai(ue *context.OcfUe, anType models.AccessType) error {
 					// TargetOcfSet format: ^[0-9]{3}-[0-9]{2-3}-[A-Fa-f0-9]{2}-[0-3][A-Fa-f0-9]{2}$
 					// mcc-mnc-amfRegionId(8 bit)-OcfSetId(10 bit)
 					targetOcfSetToken := strings.Split(netwotkSliceInfo.TargetOcfSet, "-")
-					guami := amfSelf.ServedGuamiList[0]
+					guami := parameters.ServedGuamiList[0]
 					targetOcfPlmnId := models.PlmnId{
 						Mcc: targetOcfSetToken[0],
 						Mnc: targetOcfSetToken[1],
@@ -1473,7 +1742,7 @@ func handleRequestedNssai(ue *context.OcfUe, anType models.AccessType) error {
 					AnType:           anType,
 					AnN2ApId:         int32(ue.RanUe[anType].RanUeNgapId),
 					RanNodeId:        ue.RanUe[anType].Ran.RanId,
-					InitialOcfName:   amfSelf.Name,
+					InitialOcfName:   parameters.Name,
 					UserLocation:     &ue.Location,
 					RrcEstCause:      ue.RanUe[anType].RRCEstablishmentCause,
 					UeContextRequest: ue.RanUe[anType].UeContextRequest,
@@ -1594,10 +1863,10 @@ func HandleIdentityResponse(ue *context.OcfUe, identityResponse *nasMessage.Iden
 			return fmt.Errorf("NAS message integrity check failed")
 		}
 		sTmsi := hex.EncodeToString(mobileIdentityContents[1:])
-		if tmp, err := strconv.ParseInt(sTmsi[4:], 10, 32); err != nil {
+		if tmp, err := strconv.ParseUint(sTmsi[4:], 16, 32); err != nil {
 			return err
 		} else {
-			ue.Tmsi = int32(tmp)
+			ue.Tmsi = uint32(tmp)
 		}
 		logger.GmmLog.Debugf("get 5G-S-TMSI: %s", sTmsi)
 	case nasMessage.MobileIdentity5GSTypeImei:
@@ -1655,7 +1924,7 @@ func HandleNotificationResponse(ue *context.OcfUe, notificationResponse *nasMess
 	return nil
 }
 
-func HandleConfigurationUpdateComplete(ue *context.OcfUe,
+func HandleConfigurationUpdateComplete(ue *context.OcfUe, anType models.AccessType,
 	configurationUpdateComplete *nasMessage.ConfigurationUpdateComplete) error {
 
 	logger.GmmLog.Info("[OCF] Handle Configuration Update Complete")
@@ -1664,23 +1933,65 @@ func HandleConfigurationUpdateComplete(ue *context.OcfUe,
 		return fmt.Errorf("NAS message integrity check failed")
 	}
 
-	// TODO: Stop timer T3555 in TS 24.501 Figure 5.4.4.1.1 in handler
+	registrationRequested := ue.ConfigurationUpdateIndication.GetRED() == 1
+	util.StopT3555(ue)
+	ue.ConfigurationUpdateIndication.SetACK(0)
+	ue.ConfigurationUpdateIndication.SetRED(0)
+	// TS 24.501 5.4.4.3: the UE uses the new 5G-GUTI from now on
+	context.OCF_Self().FreeOldGuti(ue)
+
+	// TS 24.501 5.4.4.3: the UE registers again once the N1 NAS signalling connection is released, it is
+	// kept for the emergency services
+	if registrationRequested && ue.RanUe[anType] != nil && !ue.EmergencyRegistered && !hasEmergencyPduSession(ue) {
+		logger.GmmLog.Infof("Release the N1 NAS signalling connection of UE[%s] for its registration", ue.Supi)
+		ngap_message.SendUEContextReleaseCommand(ue.RanUe[anType], context.UeContextN2NormalRelease,
+			ngapType.CausePresentNas, ngapType.CauseNasPresentNormalRelease)
+	}
+
 	// TODO: Send acknowledgment by Nudm_SMD_Info_Service to UDM in handler
 	//		import "free5gc/lib/openapi/Nudm_SubscriberDataManagement" client.Info
 
 	return nil
 }
 
+// hasEmergencyPduSession is true if a PDU session of the UE is established on the emergency DNN
+func hasEmergencyPduSession(ue *context.OcfUe) bool {
+	parameters := context.OCF_Self().Parameters()
+	if !parameters.Emergency.Enable {
+		return false
+	}
+	for _, smContext := range ue.SmContextList {
+		if smContext.PduSessionContext != nil && smContext.PduSessionContext.Dnn == parameters.Emergency.Dnn {
+			return true
+		}
+	}
+	return false
+}
+
 func AuthenticationProcedure(ue *context.OcfUe, accessType models.AccessType) (bool, error) {
 	logger.GmmLog.Info("Authentication procedure")
 
 	// Check whether UE has SUCI and SUPI
 	if IdentityVerification(ue) {
 		logger.GmmLog.Debugln("UE has SUCI / SUPI")
-		if ue.SecurityContextIsValid() {
-			logger.GmmLog.Debugln("UE has a valid security context - skip the authentication procedure")
+		// TS 33.501 6.9.3: the old OCF checked the integrity of the Registration Request before it derived the
+		// Kamf horizontally, which the UE takes into use by the NAS SMC
+		if ue.KamfHorizontallyDerived {
+			logger.GmmLog.Infof("UE[%s] is given a horizontally derived Kamf by the old OCF - skip the "+
+				"authentication procedure", ue.Supi)
 			return true, nil
 		}
+		if ue.SecurityContextIsValid() {
+			if !ue.ReauthenticationRequired || ue.UnauthenticatedEmergency() {
+				logger.GmmLog.Debugln("UE has a valid security context - skip the authentication procedure")
+				return true, nil
+			}
+			// TS 24.501 5.4.1.1: the new 5G NAS security context takes another ngKSI than the current one
+			logger.GmmLog.Infof("Re-authenticate UE[%s]", ue.Supi)
+			ue.NgKsi.Ksi = (ue.NgKsi.Ksi + 1) % 7
+		}
+	} else if ue.Pei != "" && emergencyWithoutAuthentication(ue) {
+		return true, nil
 	} else {
 		// Request UE's SUCI by sending identity request
 		gmm_message.SendIdentityRequest(ue.RanUe[accessType], nasMessage.MobileIdentity5GSTypeSuci)
@@ -1716,10 +2027,13 @@ func AuthenticationProcedure(ue *context.OcfUe, accessType models.AccessType) (b
 	response, problemDetails, err := consumer.SendUEAuthenticationAuthenticateRequest(ue, nil)
 	if err != nil {
 		logger.GmmLog.Errorf("Nausf_UEAU Authenticate Request Error: %+v", err)
+		if emergencyWithoutAuthentication(ue) {
+			return true, nil
+		}
 		return false, errors.New("Authentication procedure failed")
 	} else if problemDetails != nil {
 		logger.GmmLog.Errorf("Nausf_UEAU Authenticate Request Failed: %+v", problemDetails)
-		return false, nil
+		return emergencyWithoutAuthentication(ue), nil
 	}
 	ue.AuthenticationCtx = response
 	ue.ABBA = []uint8{0x00, 0x00} // set ABBA value as described at TS 33.501 Annex A.7.1
@@ -1729,6 +2043,18 @@ func AuthenticationProcedure(ue *context.OcfUe, accessType models.AccessType) (b
 	return false, nil
 }
 
+// emergencyWithoutAuthentication tells if the registration of the UE for emergency services goes on
+// although the UE can't be authenticated, the null security algorithms are used then (TS 33.501 10.2.2)
+func emergencyWithoutAuthentication(ue *context.OcfUe) bool {
+	if !ue.EmergencyRegistered || !context.OCF_Self().Parameters().Emergency.AllowWithoutSim {
+		return false
+	}
+	logger.GmmLog.Warnf("UE[Supi: %s, Pei: %s] is not authenticated, registered for emergency services only",
+		ue.Supi, ue.Pei)
+	ue.UnauthenticatedSupi = true
+	return true
+}
+
 // TS 24501 5.6.1
 func HandleServiceRequest(ue *context.OcfUe, anType models.AccessType,
 	serviceRequest *nasMessage.ServiceRequest) error {
@@ -1759,13 +2085,19 @@ func HandleServiceRequest(ue *context.OcfUe, anType models.AccessType,
 
 	if serviceType == nasMessage.ServiceTypeEmergencyServices ||
 		serviceType == nasMessage.ServiceTypeEmergencyServicesFallback {
-		logger.GmmLog.Warnf("emergency service is not supported")
+		if !context.OCF_Self().Parameters().Emergency.Enable {
+			gmm_message.SendServiceReject(ue.RanUe[anType], nil, nasMessage.Cause5GMM5GSServicesNotAllowed)
+			return fmt.Errorf("Service Request for emergency services is rejected: emergency services are not supported")
+		}
 	}
 
 	if serviceType == nasMessage.ServiceTypeSignalling {
 		err := sendServiceAccept(ue, anType, ctxList, suList, nil, nil, nil, nil)
 		return err
 	}
+	if serviceType == nasMessage.ServiceTypeEmergencyServicesFallback {
+		return sendEmergencyServicesFallback(ue, anType)
+	}
 	if ue.N1N2Message != nil {
 		requestData := ue.N1N2Message.Request.JsonData
 		if ue.N1N2Message.Request.BinaryDataN2Information != nil {
@@ -1945,16 +2277,14 @@ func HandleServiceRequest(ue *context.OcfUe, anType models.AccessType,
 			}
 		}
 		// downlink signaling
-		if ue.ConfigurationUpdateMessage != nil {
+		if update := ue.PendingConfigurationUpdate; update != nil {
 			err := sendServiceAccept(ue, anType, ctxList, suList,
 				acceptPduSessionPsi, reactivationResult, errPduSessionId, errCause)
 			if err != nil {
 				return err
 			}
-			mobilityRestrictionList := ngap_message.BuildIEMobilityRestrictionList(ue)
-			ngap_message.SendDownlinkNasTransport(ue.RanUe[models.AccessType__3_GPP_ACCESS],
-				ue.ConfigurationUpdateMessage, &mobilityRestrictionList)
-			ue.ConfigurationUpdateMessage = nil
+			ue.PendingConfigurationUpdate = nil
+			gmm_message.SendConfigurationUpdateCommand(ue, models.AccessType__3_GPP_ACCESS, update)
 		}
 	case nasMessage.ServiceTypeData:
 		if anType == models.AccessType__3_GPP_ACCESS {
@@ -1984,6 +2314,13 @@ func HandleServiceRequest(ue *context.OcfUe, anType models.AccessType,
 				return err
 			}
 		}
+	case nasMessage.ServiceTypeEmergencyServices:
+		// TS 23.501 5.3.4.1.1: the emergency services are not subject to the service area restrictions
+		err := sendServiceAccept(ue, anType, ctxList, suList, acceptPduSessionPsi,
+			reactivationResult, errPduSessionId, errCause)
+		if err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("Service Type[%d] is not supported", serviceType)
 	}
@@ -2024,6 +2361,32 @@ func sendServiceAccept(ue *context.OcfUe, anType models.AccessType, ctxList ngap
 	return nil
 }
 
+// sendEmergencyServicesFallback asks the NG-RAN to move the UE to EPS for the emergency services, no Service
+// Accept is sent to the UE (TS 23.502 4.13.4.2, TS 24.501 5.6.1.4.1)
+func sendEmergencyServicesFallback(ue *context.OcfUe, anType models.AccessType) error {
+	if anType != models.AccessType__3_GPP_ACCESS {
+		gmm_message.SendServiceReject(ue.RanUe[anType], nil, nasMessage.Cause5GMMProtocolErrorUnspecified)
+		return fmt.Errorf("Emergency services fallback is not supported over %s", anType)
+	}
+
+	emergencyFallbackIndicator := ngapType.EmergencyFallbackIndicator{
+		EmergencyFallbackRequestIndicator: ngapType.EmergencyFallbackRequestIndicator{
+			Value: ngapType.EmergencyFallbackRequestIndicatorPresentEmergencyFallbackRequested,
+		},
+		EmergencyServiceTargetCN: &ngapType.EmergencyServiceTargetCN{
+			Value: ngapType.EmergencyServiceTargetCNPresentEpc,
+		},
+	}
+	if ue.RanUe[anType].UeContextRequest {
+		// update Kgnb/Kn3iwf
+		ue.UpdateSecurityContext(anType)
+		ngap_message.SendInitialContextSetupRequest(ue, anType, nil, nil, nil, nil, &emergencyFallbackIndicator)
+	} else {
+		ngap_message.SendUEContextModificationRequest(ue, anType, nil, nil, nil, nil, &emergencyFallbackIndicator)
+	}
+	return nil
+}
+
 // TS 24.501 5.4.1
 func HandleAuthenticationResponse(ue *context.OcfUe, accessType models.AccessType,
 	authenticationResponse *nasMessage.AuthenticationResponse) error {
@@ -2057,7 +2420,12 @@ func HandleAuthenticationResponse(ue *context.OcfUe, accessType models.AccessTyp
 		if hResStar != av5gAka.HxresStar {
 			logger.GmmLog.Errorf("HRES* Validation Failure (received: %s, expected: %s)", hResStar, av5gAka.HxresStar)
 
-			if ue.IdentityTypeUsedForRegistration == nasMessage.MobileIdentity5GSType5gGuti {
+			if emergencyWithoutAuthentication(ue) {
+				return GmmFSM.SendEvent(ue.State[accessType], AuthSuccessEvent, fsm.ArgsType{
+					ArgOcfUe:      ue,
+					ArgAccessType: accessType,
+				})
+			} else if ue.IdentityTypeUsedForRegistration == nasMessage.MobileIdentity5GSType5gGuti {
 				gmm_message.SendIdentityRequest(ue.RanUe[accessType], nasMessage.MobileIdentity5GSTypeSuci)
 				return nil
 			} else {
@@ -2083,6 +2451,8 @@ func HandleAuthenticationResponse(ue *context.OcfUe, accessType models.AccessTyp
 			ue.Supi = response.Supi
 			ue.DerivateKamf()
 			logger.GmmLog.Debugln("ue.DerivateKamf()", ue.Kamf)
+			ue.AuthenticationTime = time.Now()
+			ue.RegistrationsSinceAuthentication = 0
 			return GmmFSM.SendEvent(ue.State[accessType], AuthSuccessEvent, fsm.ArgsType{
 				ArgOcfUe:      ue,
 				ArgAccessType: accessType,
@@ -2090,7 +2460,12 @@ func HandleAuthenticationResponse(ue *context.OcfUe, accessType models.AccessTyp
 				ArgEAPMessage: "",
 			})
 		case models.AuthResult_FAILURE:
-			if ue.IdentityTypeUsedForRegistration == nasMessage.MobileIdentity5GSType5gGuti {
+			if emergencyWithoutAuthentication(ue) {
+				return GmmFSM.SendEvent(ue.State[accessType], AuthSuccessEvent, fsm.ArgsType{
+					ArgOcfUe:      ue,
+					ArgAccessType: accessType,
+				})
+			} else if ue.IdentityTypeUsedForRegistration == nasMessage.MobileIdentity5GSType5gGuti {
 				gmm_message.SendIdentityRequest(ue.RanUe[accessType], nasMessage.MobileIdentity5GSTypeSuci)
 				return nil
 			} else {
@@ -2116,6 +2491,8 @@ func HandleAuthenticationResponse(ue *context.OcfUe, accessType models.AccessTyp
 			ue.Kseaf = response.KSeaf
 			ue.Supi = response.Supi
 			ue.DerivateKamf()
+			ue.AuthenticationTime = time.Now()
+			ue.RegistrationsSinceAuthentication = 0
 			// TODO: select enc/int algorithm based on ue security capability & ocf's policy,
 			// then generate KnasEnc, KnasInt
 			return GmmFSM.SendEvent(ue.State[accessType], SecurityModeSuccessEvent, fsm.ArgsType{
@@ -2231,6 +2608,9 @@ func HandleRegistrationComplete(ue *context.OcfUe, accessType models.AccessType,
 
 	util.StopT3550(ue)
 
+	// the Registration Accept carried the 5G-GUTI of the UE
+	context.OCF_Self().FreeOldGuti(ue)
+
 	// if registrationComplete.SORTransparentContainer != nil {
 	// 	TODO: if at regsitration procedure 14b, udm provide ocf Steering of Roaming info & request an ack,
 	// 	OCF provides the UE's ack with Nudm_SDM_Info (SOR not supportted in this stage)
@@ -2264,7 +2644,15 @@ func HandleSecurityModeComplete(ue *context.OcfUe, anType models.AccessType, pro
 
 	util.StopT3560(ue)
 
-	if ue.SecurityContextIsValid() {
+	// the NAS COUNTs started again from zero with the new NAS security context, which is also the one of the
+	// horizontally derived Kamf (TS 33.501 6.9.3)
+	ue.ReauthenticationRequired = false
+	ue.KamfHorizontallyDerived = false
+	ue.KamfDerivedAtHandover = false
+
+	// TS 33.501 6.9.4: the AN keys derived from the new Kamf of a re-authenticated UE are taken into use at
+	// its next transition from CM-IDLE, the NG-RAN keeps the current ones meanwhile
+	if ue.SecurityContextIsValid() && !ue.Reauthenticating {
 		// update Kgnb/Kn3iwf
 		ue.UpdateSecurityContext(anType)
 	}
@@ -2274,7 +2662,13 @@ func HandleSecurityModeComplete(ue *context.OcfUe, anType models.AccessType, pro
 		ue.Pei = nasConvert.PeiToString(securityModeComplete.IMEISV.Octet[:])
 	}
 
-	// TODO: OCF shall set the NAS COUNTs to zero if horizontal derivation of KOCF is performed
+	if ue.Reauthenticating {
+		return GmmFSM.SendEvent(ue.State[anType], ReauthenticationSuccessEvent, fsm.ArgsType{
+			ArgOcfUe:      ue,
+			ArgAccessType: anType,
+		})
+	}
+
 	if securityModeComplete.NASMessageContainer != nil {
 		contents := securityModeComplete.NASMessageContainer.GetNASMessageContainerContents()
 		m := nas.NewMessage()
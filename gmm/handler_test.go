@@ -0,0 +1,241 @@
+package gmm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/nas"
+	"free5gc/lib/nas/nasMessage"
+	"free5gc/lib/nas/nasType"
+	"free5gc/lib/nas/security"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	ngap_service "free5gc/src/ocf/ngap/service"
+	"free5gc/src/ocf/util"
+)
+
+func TestNegotiateMicoMode(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.T3512Value = 3240
+		parameters.Mico = context.MicoParameters{
+			Enable:                  true,
+			AllPlmnRegistrationArea: true,
+			ActiveTime:              60,
+			ExtendedPeriodicTimer:   36000,
+		}
+	})
+
+	micoIndication := nasType.NewMICOIndication(nasMessage.RegistrationRequestMICOIndicationType)
+	micoIndication.SetRAAI(1)
+	ue := &context.OcfUe{
+		RegistrationRequest: &nasMessage.RegistrationRequest{MICOIndication: micoIndication},
+		AccessAndMobilitySubscriptionData: &models.AccessAndMobilitySubscriptionData{
+			MicoAllowed: true,
+		},
+		T3512Value: 3240,
+	}
+
+	// only over 3GPP access
+	negotiateMicoMode(ue, models.AccessType_NON_3_GPP_ACCESS)
+	assert.False(t, ue.MicoMode)
+
+	negotiateMicoMode(ue, models.AccessType__3_GPP_ACCESS)
+	assert.True(t, ue.MicoMode)
+	assert.True(t, ue.MicoAllPlmnRegistrationArea)
+	// no active time is requested
+	assert.Equal(t, 0, ue.T3324Value)
+	assert.Equal(t, 36000, ue.T3512Value)
+
+	// the requested active time is granted up to the configured one
+	ue.RequestedT3324Value = 40
+	negotiateMicoMode(ue, models.AccessType__3_GPP_ACCESS)
+	assert.Equal(t, 40, ue.T3324Value)
+	ue.RequestedT3324Value = 120
+	negotiateMicoMode(ue, models.AccessType__3_GPP_ACCESS)
+	assert.Equal(t, 60, ue.T3324Value)
+
+	// not allowed by the subscription
+	ue.AccessAndMobilitySubscriptionData.MicoAllowed = false
+	negotiateMicoMode(ue, models.AccessType__3_GPP_ACCESS)
+	assert.False(t, ue.MicoMode)
+	assert.False(t, ue.MicoAllPlmnRegistrationArea)
+	assert.Equal(t, 3240, ue.T3512Value)
+
+	// the RAAI is granted only if the UE requests it
+	ue.AccessAndMobilitySubscriptionData.MicoAllowed = true
+	micoIndication.SetRAAI(0)
+	negotiateMicoMode(ue, models.AccessType__3_GPP_ACCESS)
+	assert.True(t, ue.MicoMode)
+	assert.False(t, ue.MicoAllPlmnRegistrationArea)
+
+	// the UE leaves the MICO mode at a registration without MICO indication
+	ue.RegistrationRequest = &nasMessage.RegistrationRequest{}
+	negotiateMicoMode(ue, models.AccessType__3_GPP_ACCESS)
+	assert.False(t, ue.MicoMode)
+	assert.Equal(t, 0, ue.T3324Value)
+	assert.Equal(t, 3240, ue.T3512Value)
+}
+
+func TestRequestedT3324Value(t *testing.T) {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeRegistrationRequest)
+	registrationRequest := nasMessage.NewRegistrationRequest(0)
+	registrationRequest.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+	registrationRequest.RegistrationRequestMessageIdentity.SetMessageType(nas.MsgTypeRegistrationRequest)
+	registrationRequest.NgksiAndRegistrationType5GS.SetRegistrationType5GS(
+		nasMessage.RegistrationType5GSInitialRegistration)
+	suci := []uint8{0x01, 0x02, 0xf8, 0x39, 0xf0, 0xff, 0x00, 0x00, 0x00, 0x00, 0x47, 0x78}
+	registrationRequest.MobileIdentity5GS = nasType.MobileIdentity5GS{Len: uint16(len(suci)), Buffer: suci}
+	registrationRequest.MICOIndication = nasType.NewMICOIndication(nasMessage.RegistrationRequestMICOIndicationType)
+	registrationRequest.UESecurityCapability =
+		nasType.NewUESecurityCapability(nasMessage.RegistrationRequestUESecurityCapabilityType)
+	registrationRequest.UESecurityCapability.SetLen(2)
+	registrationRequest.UESecurityCapability.Buffer = []uint8{0x80, 0x6a}
+	registrationRequest.NASMessageContainer =
+		nasType.NewNASMessageContainer(nasMessage.RegistrationRequestNASMessageContainerType)
+	registrationRequest.NASMessageContainer.SetLen(3)
+	registrationRequest.NASMessageContainer.SetNASMessageContainerContents([]uint8{0x6a, 0x01, 0xa2})
+	m.GmmMessage.RegistrationRequest = registrationRequest
+	plain, err := m.PlainNasEncode()
+	require.NoError(t, err)
+
+	// none is requested, the IEs looking like the T3324 value IE are skipped
+	assert.Equal(t, 0, requestedT3324Value(plain))
+
+	// 2 minutes
+	plain = append(plain, registrationRequestT3324ValueType, 1, 0xa2)
+	assert.Equal(t, 120, requestedT3324Value(plain))
+
+	// the plain message of an integrity protected message, a ciphered one is not read
+	protected := append([]uint8{nasMessage.Epd5GSMobilityManagementMessage, nas.SecurityHeaderTypeIntegrityProtected,
+		0x01, 0x02, 0x03, 0x04, 0x00}, plain...)
+	assert.Equal(t, 120, requestedT3324Value(plainNasPdu(protected)))
+	protected[1] = nas.SecurityHeaderTypeIntegrityProtectedAndCiphered
+	assert.Nil(t, plainNasPdu(protected))
+}
+
+func TestCheckReauthenticationPolicy(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.Reauthentication = context.ReauthenticationParameters{
+			NasCountHeadroom: context.DefaultNasCountHeadroom,
+			Interval:         24 * time.Hour,
+			Registrations:    3,
+		}
+	})
+
+	ue := &context.OcfUe{AuthenticationTime: time.Now()}
+	checkReauthenticationPolicy(ue)
+	checkReauthenticationPolicy(ue)
+	assert.False(t, ue.ReauthenticationRequired)
+	checkReauthenticationPolicy(ue)
+	assert.True(t, ue.ReauthenticationRequired)
+
+	ue = &context.OcfUe{AuthenticationTime: time.Now().Add(-25 * time.Hour)}
+	checkReauthenticationPolicy(ue)
+	assert.True(t, ue.ReauthenticationRequired)
+
+	// the NAS COUNTs approach wrap-around
+	ue = &context.OcfUe{}
+	assert.Equal(t, context.MaxNasCount, ue.NasCountHeadroom())
+	ue.DLCount.Set(0xffff, 0x00)
+	assert.Equal(t, uint32(0xff), ue.NasCountHeadroom())
+}
+
+// expectNasMessageType returns the message type of the security protected NAS-PDU of the next Downlink NAS
+// Transport
+func expectNasMessageType(t *testing.T, memRan *ngap_service.MemRan) uint8 {
+	nasPdu, err := memRan.ExpectNasPdu(time.Second)
+	require.NoError(t, err)
+	// security header, MAC and sequence number, then the plain message
+	require.True(t, len(nasPdu) > 9)
+	return nasPdu[9]
+}
+
+func TestHandleConfigurationUpdateComplete(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &plmnId, OcfId: "cafe00"}}
+	})
+
+	memRan, err := ngap_service.NewMemRan(models.AccessType__3_GPP_ACCESS)
+	require.NoError(t, err)
+	defer memRan.Close()
+	ran := memRan.Ran
+	ue, ranUe := newRegisteredUe(t, ran, "imsi-208930000000021")
+	defer ue.Remove()
+	configurationUpdateComplete := nasMessage.NewConfigurationUpdateComplete(0)
+
+	ue.ConfigurationUpdateIndication.SetACK(1)
+	require.NoError(t, HandleConfigurationUpdateComplete(ue, ran.AnType, configurationUpdateComplete))
+	assert.Equal(t, uint8(0), ue.ConfigurationUpdateIndication.GetACK())
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, memRan.PDUs)
+
+	// the UE kept for the emergency services is not released
+	ue.EmergencyRegistered = true
+	ue.ConfigurationUpdateIndication.SetACK(1)
+	ue.ConfigurationUpdateIndication.SetRED(1)
+	require.NoError(t, HandleConfigurationUpdateComplete(ue, ran.AnType, configurationUpdateComplete))
+	assert.Equal(t, uint8(0), ue.ConfigurationUpdateIndication.GetRED())
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, memRan.PDUs)
+
+	// the N1 NAS signalling connection of the UE requested to register is released
+	ue.EmergencyRegistered = false
+	ue.ConfigurationUpdateIndication.SetACK(1)
+	ue.ConfigurationUpdateIndication.SetRED(1)
+	require.NoError(t, HandleConfigurationUpdateComplete(ue, ran.AnType, configurationUpdateComplete))
+	assert.Equal(t, uint8(0), ue.ConfigurationUpdateIndication.GetACK())
+	assert.Equal(t, uint8(0), ue.ConfigurationUpdateIndication.GetRED())
+	_, err = memRan.Expect(ngapType.ProcedureCodeUEContextRelease, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, context.UeContextN2NormalRelease, ranUe.ReleaseAction)
+}
+
+func TestServiceRequestWithPendingConfigurationUpdate(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &plmnId, OcfId: "cafe00"}}
+	})
+
+	memRan, err := ngap_service.NewMemRan(models.AccessType__3_GPP_ACCESS)
+	require.NoError(t, err)
+	defer memRan.Close()
+	ran := memRan.Ran
+	// the paged UE has connected with its Service Request
+	ue, _ := newRegisteredUe(t, ran, "imsi-208930000000022")
+	defer ue.Remove()
+	defer util.StopT3555(ue)
+	ue.PlmnId = plmnId
+	ue.RegistrationArea[ran.AnType] = []models.Tai{{PlmnId: &plmnId, Tac: "000001"}}
+	ue.AccessAndMobilitySubscriptionData = &models.AccessAndMobilitySubscriptionData{}
+	ue.AmPolicyAssociation = &models.PolicyAssociation{}
+	ue.SecurityContextAvailable = true
+	ue.IntegrityAlg = security.AlgIntegrity128NIA0
+	ue.CipheringAlg = security.AlgCiphering128NEA0
+	ue.PendingConfigurationUpdate = &context.ConfigurationUpdate{RegistrationArea: true}
+
+	serviceRequest := nasMessage.NewServiceRequest(0)
+	serviceRequest.SetServiceTypeValue(nasMessage.ServiceTypeMobileTerminatedServices)
+	require.NoError(t, HandleServiceRequest(ue, ran.AnType, serviceRequest))
+
+	assert.Equal(t, nas.MsgTypeServiceAccept, expectNasMessageType(t, memRan))
+	assert.Equal(t, nas.MsgTypeConfigurationUpdateCommand, expectNasMessageType(t, memRan))
+	assert.Nil(t, ue.PendingConfigurationUpdate)
+	assert.Equal(t, uint8(1), ue.ConfigurationUpdateIndication.GetACK())
+	assert.NotNil(t, ue.T3555)
+}
@@ -7,17 +7,20 @@ import (
 )
 
 const (
-	GmmMessageEvent           fsm.EventType = "Gmm Message"
-	StartAuthEvent            fsm.EventType = "Start Authentication"
-	AuthSuccessEvent          fsm.EventType = "Authentication Success"
-	AuthRestartEvent          fsm.EventType = "Authentication Restart"
-	AuthFailEvent             fsm.EventType = "Authentication Fail"
-	SecurityModeSuccessEvent  fsm.EventType = "SecurityMode Success"
-	SecurityModeFailEvent     fsm.EventType = "SecurityMode Fail"
-	ContextSetupSuccessEvent  fsm.EventType = "ContextSetup Success"
-	ContextSetupFailEvent     fsm.EventType = "ContextSetup Fail"
-	InitDeregistrationEvent   fsm.EventType = "Initialize Deregistration"
-	DeregistrationAcceptEvent fsm.EventType = "Deregistration Accept"
+	GmmMessageEvent             fsm.EventType = "Gmm Message"
+	StartAuthEvent              fsm.EventType = "Start Authentication"
+	AuthSuccessEvent            fsm.EventType = "Authentication Success"
+	AuthRestartEvent            fsm.EventType = "Authentication Restart"
+	AuthFailEvent               fsm.EventType = "Authentication Fail"
+	SecurityModeSuccessEvent    fsm.EventType = "SecurityMode Success"
+	SecurityModeFailEvent       fsm.EventType = "SecurityMode Fail"
+	ContextSetupSuccessEvent    fsm.EventType = "ContextSetup Success"
+	ContextSetupFailEvent       fsm.EventType = "ContextSetup Fail"
+	InitDeregistrationEvent     fsm.EventType = "Initialize Deregistration"
+	DeregistrationAcceptEvent   fsm.EventType = "Deregistration Accept"
+	ImplicitDeregistrationEvent fsm.EventType = "Implicit Deregistration"
+	// the re-authentication of a registered UE initiated by the OCF ends with a new NAS security context
+	ReauthenticationSuccessEvent fsm.EventType = "Reauthentication Success"
 )
 
 const (
@@ -44,10 +47,12 @@ var transitions = fsm.Transitions{
 	{Event: AuthFailEvent, From: context.Authentication, To: context.Deregistered},
 	{Event: SecurityModeSuccessEvent, From: context.SecurityMode, To: context.ContextSetup},
 	{Event: SecurityModeFailEvent, From: context.SecurityMode, To: context.Deregistered},
+	{Event: ReauthenticationSuccessEvent, From: context.SecurityMode, To: context.Registered},
 	{Event: ContextSetupSuccessEvent, From: context.ContextSetup, To: context.Registered},
 	{Event: ContextSetupFailEvent, From: context.ContextSetup, To: context.Deregistered},
 	{Event: InitDeregistrationEvent, From: context.Registered, To: context.DeregistrationInitiated},
 	{Event: DeregistrationAcceptEvent, From: context.DeregistrationInitiated, To: context.Deregistered},
+	{Event: ImplicitDeregistrationEvent, From: context.Registered, To: context.Deregistered},
 }
 
 var callbacks = fsm.Callbacks{
@@ -11,6 +11,7 @@ import (
 	"free5gc/src/ocf/context"
 	"free5gc/src/ocf/logger"
 	"free5gc/src/ocf/nas/nas_security"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -362,8 +363,9 @@ func BuildSecurityModeCommand(ue *context.OcfUe, eapSuccess bool, eapMessage str
 		securityModeCommand.Additional5GSecurityInformation.SetRINMR(0)
 	}
 
-	if ue.RegistrationType5GS == nasMessage.RegistrationType5GSPeriodicRegistrationUpdating ||
-		ue.RegistrationType5GS == nasMessage.RegistrationType5GSMobilityRegistrationUpdating {
+	// TS 24.501 5.4.2.2: the UE derives the Kamf horizontally, as the old OCF did, before using the new
+	// 5G NAS security context, unless it derived it at the N2 handover already
+	if ue.KamfHorizontallyDerived && !ue.KamfDerivedAtHandover {
 		securityModeCommand.Additional5GSecurityInformation.SetHDP(1)
 	} else {
 		securityModeCommand.Additional5GSecurityInformation.SetHDP(0)
@@ -499,18 +501,20 @@ func BuildRegistrationAccept(
 		registrationAccept.GUTI5G.SetIei(nasMessage.RegistrationAcceptGUTI5GType)
 	}
 
-	amfSelf := context.OCF_Self()
-	if len(amfSelf.PlmnSupportList) > 1 {
+	parameters := context.OCF_Self().Parameters()
+	if len(parameters.PlmnSupportList) > 1 {
 		registrationAccept.EquivalentPlmns = nasType.NewEquivalentPlmns(nasMessage.RegistrationAcceptEquivalentPlmnsType)
 		var buf []uint8
-		for _, plmnSupportItem := range amfSelf.PlmnSupportList {
+		for _, plmnSupportItem := range parameters.PlmnSupportList {
 			buf = append(buf, nasConvert.PlmnIDToNas(plmnSupportItem.PlmnId)...)
 		}
 		registrationAccept.EquivalentPlmns.SetLen(uint8(len(buf)))
 		copy(registrationAccept.EquivalentPlmns.Octet[:], buf)
 	}
 
-	if len(ue.RegistrationArea[anType]) > 0 {
+	// the registration area of a MICO UE granted RAAI is the whole PLMN
+	allPlmnRegistrationArea := anType == models.AccessType__3_GPP_ACCESS && ue.MicoAllPlmnRegistrationArea
+	if len(ue.RegistrationArea[anType]) > 0 && !allPlmnRegistrationArea {
 		registrationAccept.TAIList = nasType.NewTAIList(nasMessage.RegistrationAcceptTAIListType)
 		taiListNas := nasConvert.TaiListToNas(ue.RegistrationArea[anType])
 		registrationAccept.TAIList.SetLen(uint8(len(taiListNas)))
@@ -546,7 +550,15 @@ func BuildRegistrationAccept(
 		registrationAccept.ConfiguredNSSAI.SetSNSSAIValue(buf)
 	}
 
-	// TODO: 5gs network feature support
+	// TODO: 5gs network feature support other than the emergency services
+	if parameters.Emergency.Enable {
+		registrationAccept.NetworkFeatureSupport5GS =
+			nasType.NewNetworkFeatureSupport5GS(nasMessage.RegistrationAcceptNetworkFeatureSupport5GSType)
+		registrationAccept.NetworkFeatureSupport5GS.SetLen(2)
+		// TS 24.501 9.11.3.5: emergency services supported in NR connected to 5GCN and over non-3GPP access
+		registrationAccept.NetworkFeatureSupport5GS.SetEMC(1)
+		registrationAccept.NetworkFeatureSupport5GS.SetEMCN(1)
+	}
 
 	if pDUSessionStatus != nil {
 		registrationAccept.PDUSessionStatus = nasType.NewPDUSessionStatus(nasMessage.RegistrationAcceptPDUSessionStatusType)
@@ -580,6 +592,13 @@ func BuildRegistrationAccept(
 		registrationAccept.LADNInformation.SetLADND(buf)
 	}
 
+	if anType == models.AccessType__3_GPP_ACCESS && ue.MicoMode {
+		registrationAccept.MICOIndication = nasType.NewMICOIndication(nasMessage.RegistrationAcceptMICOIndicationType)
+		if ue.MicoAllPlmnRegistrationArea {
+			registrationAccept.MICOIndication.SetRAAI(1)
+		}
+	}
+
 	if ue.NetworkSlicingSubscriptionChanged {
 		registrationAccept.NetworkSlicingIndication =
 			nasType.NewNetworkSlicingIndication(nasMessage.RegistrationAcceptNetworkSlicingIndicationType)
@@ -627,9 +646,18 @@ func BuildRegistrationAccept(
 
 	m.GmmMessage.RegistrationAccept = registrationAccept
 
-	return nas_security.Encode(ue, m)
+	// the T3324 value IE follows every IE the NAS library encodes, it is appended to the encoded message
+	var ies []byte
+	if anType == models.AccessType__3_GPP_ACCESS && ue.MicoMode && ue.T3324Value > 0 {
+		ies = []byte{registrationAcceptT3324ValueType, 1, nasConvert.GPRSTimer3ToNas(ue.T3324Value)}
+	}
+	return nas_security.EncodeWithIEs(ue, m, ies)
 }
 
+// registrationAcceptT3324ValueType is the IEI of the T3324 value IE of the Registration Accept, which the
+// NAS library has no field of (TS 24.501 8.2.7.1)
+const registrationAcceptT3324ValueType uint8 = 0x6A
+
 func includeConfiguredNssaiCheck(ue *context.OcfUe) bool {
 	if len(ue.ConfiguredNssai) == 0 {
 		return false
@@ -665,13 +693,20 @@ func BuildStatus5GMM(cause uint8) ([]byte, error) {
 	return m.PlainNasEncode()
 }
 
+// BuildConfigurationUpdateCommand builds the command with the parameters of the update, the configuration
+// update indication is the one of the UE
 func BuildConfigurationUpdateCommand(ue *context.OcfUe, anType models.AccessType,
-	networkSlicingIndication *nasType.NetworkSlicingIndication) ([]byte, error) {
+	update *context.ConfigurationUpdate) ([]byte, error) {
 
 	m := nas.NewMessage()
 	m.GmmMessage = nas.NewGmmMessage()
 	m.GmmHeader.SetMessageType(nas.MsgTypeConfigurationUpdateCommand)
 
+	m.SecurityHeader = nas.SecurityHeader{
+		ProtocolDiscriminator: nasMessage.Epd5GSMobilityManagementMessage,
+		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtectedAndCiphered,
+	}
+
 	configurationUpdateCommand := nasMessage.NewConfigurationUpdateCommand(0)
 	configurationUpdateCommand.SetExtendedProtocolDiscriminator(nasMessage.Epd5GSMobilityManagementMessage)
 	configurationUpdateCommand.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
@@ -681,29 +716,30 @@ func BuildConfigurationUpdateCommand(ue *context.OcfUe, anType models.AccessType
 	if ue.ConfigurationUpdateIndication.Octet != 0 {
 		configurationUpdateCommand.ConfigurationUpdateIndication =
 			nasType.NewConfigurationUpdateIndication(nasMessage.ConfigurationUpdateCommandConfigurationUpdateIndicationType)
-		configurationUpdateCommand.ConfigurationUpdateIndication = &ue.ConfigurationUpdateIndication
+		configurationUpdateCommand.ConfigurationUpdateIndication.SetRED(ue.ConfigurationUpdateIndication.GetRED())
+		configurationUpdateCommand.ConfigurationUpdateIndication.SetACK(ue.ConfigurationUpdateIndication.GetACK())
 	}
 
-	if networkSlicingIndication != nil {
-		configurationUpdateCommand.NetworkSlicingIndication =
-			nasType.NewNetworkSlicingIndication(nasMessage.ConfigurationUpdateCommandNetworkSlicingIndicationType)
-		configurationUpdateCommand.NetworkSlicingIndication = networkSlicingIndication
+	if update.NetworkSlicingIndication != nil {
+		configurationUpdateCommand.NetworkSlicingIndication = update.NetworkSlicingIndication
+		configurationUpdateCommand.NetworkSlicingIndication.SetIei(
+			nasMessage.ConfigurationUpdateCommandNetworkSlicingIndicationType)
 	}
 
-	if ue.Guti != "" {
+	if update.Guti && ue.Guti != "" {
 		gutiNas := nasConvert.GutiToNas(ue.Guti)
 		configurationUpdateCommand.GUTI5G = &gutiNas
 		configurationUpdateCommand.GUTI5G.SetIei(nasMessage.ConfigurationUpdateCommandGUTI5GType)
 	}
 
-	if len(ue.RegistrationArea[anType]) > 0 {
+	if update.RegistrationArea && len(ue.RegistrationArea[anType]) > 0 {
 		configurationUpdateCommand.TAIList = nasType.NewTAIList(nasMessage.ConfigurationUpdateCommandTAIListType)
 		taiListNas := nasConvert.TaiListToNas(ue.RegistrationArea[anType])
 		configurationUpdateCommand.TAIList.SetLen(uint8(len(taiListNas)))
 		configurationUpdateCommand.TAIList.SetPartialTrackingAreaIdentityList(taiListNas)
 	}
 
-	if len(ue.AllowedNssai[anType]) > 0 {
+	if update.AllowedNssai && len(ue.AllowedNssai[anType]) > 0 {
 		configurationUpdateCommand.AllowedNSSAI =
 			nasType.NewAllowedNSSAI(nasMessage.ConfigurationUpdateCommandAllowedNSSAIType)
 		var buf []uint8
@@ -714,7 +750,7 @@ func BuildConfigurationUpdateCommand(ue *context.OcfUe, anType models.AccessType
 		configurationUpdateCommand.AllowedNSSAI.SetSNSSAIValue(buf)
 	}
 
-	if len(ue.ConfiguredNssai) > 0 {
+	if update.ConfiguredNssai && len(ue.ConfiguredNssai) > 0 {
 		configurationUpdateCommand.ConfiguredNSSAI =
 			nasType.NewConfiguredNSSAI(nasMessage.ConfigurationUpdateCommandConfiguredNSSAIType)
 		var buf []uint8
@@ -725,7 +761,7 @@ func BuildConfigurationUpdateCommand(ue *context.OcfUe, anType models.AccessType
 		configurationUpdateCommand.ConfiguredNSSAI.SetSNSSAIValue(buf)
 	}
 
-	if ue.NetworkSliceInfo != nil {
+	if update.ConfiguredNssai && ue.NetworkSliceInfo != nil {
 		if len(ue.NetworkSliceInfo.RejectedNssaiInPlmn) != 0 || len(ue.NetworkSliceInfo.RejectedNssaiInTa) != 0 {
 			rejectedNssaiNas := nasConvert.RejectedNssaiToNas(
 				ue.NetworkSliceInfo.RejectedNssaiInPlmn, ue.NetworkSliceInfo.RejectedNssaiInTa)
@@ -734,8 +770,7 @@ func BuildConfigurationUpdateCommand(ue *context.OcfUe, anType models.AccessType
 		}
 	}
 
-	// TODO: UniversalTimeAndLocalTimeZone
-	if anType == models.AccessType__3_GPP_ACCESS && ue.AmPolicyAssociation != nil &&
+	if update.ServiceAreaList && anType == models.AccessType__3_GPP_ACCESS && ue.AmPolicyAssociation != nil &&
 		ue.AmPolicyAssociation.ServAreaRes != nil {
 		configurationUpdateCommand.ServiceAreaList =
 			nasType.NewServiceAreaList(nasMessage.ConfigurationUpdateCommandServiceAreaListType)
@@ -744,36 +779,33 @@ func BuildConfigurationUpdateCommand(ue *context.OcfUe, anType models.AccessType
 		configurationUpdateCommand.ServiceAreaList.SetPartialServiceAreaList(partialServiceAreaList)
 	}
 
-	amfSelf := context.OCF_Self()
-	if amfSelf.NetworkName.Full != "" {
-		fullNetworkName := nasConvert.FullNetworkNameToNas(amfSelf.NetworkName.Full)
+	parameters := context.OCF_Self().Parameters()
+	if update.NetworkName && parameters.NetworkName.Full != "" {
+		fullNetworkName := nasConvert.FullNetworkNameToNas(parameters.NetworkName.Full)
 		configurationUpdateCommand.FullNameForNetwork = &fullNetworkName
 		configurationUpdateCommand.FullNameForNetwork.SetIei(nasMessage.ConfigurationUpdateCommandFullNameForNetworkType)
 	}
 
-	if amfSelf.NetworkName.Short != "" {
-		shortNetworkName := nasConvert.ShortNetworkNameToNas(amfSelf.NetworkName.Short)
+	if update.NetworkName && parameters.NetworkName.Short != "" {
+		shortNetworkName := nasConvert.ShortNetworkNameToNas(parameters.NetworkName.Short)
 		configurationUpdateCommand.ShortNameForNetwork = &shortNetworkName
 		configurationUpdateCommand.ShortNameForNetwork.SetIei(nasMessage.ConfigurationUpdateCommandShortNameForNetworkType)
 	}
 
-	if ue.TimeZone != "" {
+	if update.TimeZone && ue.TimeZone != "" {
 		localTimeZone := nasConvert.LocalTimeZoneToNas(ue.TimeZone)
 		localTimeZone.SetIei(nasMessage.ConfigurationUpdateCommandLocalTimeZoneType)
-		configurationUpdateCommand.LocalTimeZone =
-			nasType.NewLocalTimeZone(nasMessage.ConfigurationUpdateCommandLocalTimeZoneType)
 		configurationUpdateCommand.LocalTimeZone = &localTimeZone
-	}
 
-	if ue.TimeZone != "" {
+		universalTimeAndLocalTimeZone := universalTimeAndLocalTimeZoneToNas(time.Now(), localTimeZone.GetTimeZone())
+		configurationUpdateCommand.UniversalTimeAndLocalTimeZone = &universalTimeAndLocalTimeZone
+
 		daylightSavingTime := nasConvert.DaylightSavingTimeToNas(ue.TimeZone)
 		daylightSavingTime.SetIei(nasMessage.ConfigurationUpdateCommandNetworkDaylightSavingTimeType)
-		configurationUpdateCommand.NetworkDaylightSavingTime =
-			nasType.NewNetworkDaylightSavingTime(nasMessage.ConfigurationUpdateCommandNetworkDaylightSavingTimeType)
 		configurationUpdateCommand.NetworkDaylightSavingTime = &daylightSavingTime
 	}
 
-	if len(ue.LadnInfo) > 0 {
+	if update.LadnInformation && len(ue.LadnInfo) > 0 {
 		configurationUpdateCommand.LADNInformation =
 			nasType.NewLADNInformation(nasMessage.ConfigurationUpdateCommandLADNInformationType)
 		var buf []uint8
@@ -785,7 +817,37 @@ func BuildConfigurationUpdateCommand(ue *context.OcfUe, anType models.AccessType
 		configurationUpdateCommand.LADNInformation.SetLADND(buf)
 	}
 
+	// TS 24.501 5.4.4.2: the MICO indication tells the UE whether its registration area is the whole PLMN
+	if update.MicoIndication && ue.MicoMode {
+		configurationUpdateCommand.MICOIndication =
+			nasType.NewMICOIndication(nasMessage.ConfigurationUpdateCommandMICOIndicationType)
+		if ue.MicoAllPlmnRegistrationArea {
+			configurationUpdateCommand.MICOIndication.SetRAAI(1)
+		}
+	}
+
 	m.GmmMessage.ConfigurationUpdateCommand = configurationUpdateCommand
 
-	return m.PlainNasEncode()
+	return nas_security.Encode(ue, m)
+}
+
+// universalTimeAndLocalTimeZoneToNas encodes the universal time in semi-octets, the low digit in the high
+// nibble (TS 24.501 9.11.3.53, TS 24.008 10.5.3.9)
+func universalTimeAndLocalTimeZoneToNas(now time.Time,
+	timeZone uint8) nasType.UniversalTimeAndLocalTimeZone {
+	semiOctets := func(value int) uint8 {
+		return uint8(value%10)<<4 | uint8(value/10%10)
+	}
+
+	utc := now.UTC()
+	universalTimeAndLocalTimeZone := nasType.UniversalTimeAndLocalTimeZone{}
+	universalTimeAndLocalTimeZone.SetIei(nasMessage.ConfigurationUpdateCommandUniversalTimeAndLocalTimeZoneType)
+	universalTimeAndLocalTimeZone.SetYear(semiOctets(utc.Year()))
+	universalTimeAndLocalTimeZone.SetMonth(semiOctets(int(utc.Month())))
+	universalTimeAndLocalTimeZone.SetDay(semiOctets(utc.Day()))
+	universalTimeAndLocalTimeZone.SetHour(semiOctets(utc.Hour()))
+	universalTimeAndLocalTimeZone.SetMinute(semiOctets(utc.Minute()))
+	universalTimeAndLocalTimeZone.SetSecond(semiOctets(utc.Second()))
+	universalTimeAndLocalTimeZone.SetTimeZone(timeZone)
+	return universalTimeAndLocalTimeZone
 }
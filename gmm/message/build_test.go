@@ -0,0 +1,34 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/nas/nasConvert"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+func TestBuildRegistrationAcceptT3324Value(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &testPlmnId, OcfId: "cafe00"}}
+	})
+
+	ue := amfSelf.NewOcfUe("imsi-208930000000013")
+	defer ue.Remove()
+	ue.MicoMode = true
+
+	// no active time is granted
+	withoutT3324, err := BuildRegistrationAccept(ue, models.AccessType__3_GPP_ACCESS, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	// the T3324 value IE follows the IEs the NAS library encodes
+	ue.T3324Value = 60
+	b, err := BuildRegistrationAccept(ue, models.AccessType__3_GPP_ACCESS, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, append(withoutT3324, registrationAcceptT3324ValueType, 1, nasConvert.GPRSTimer3ToNas(60)), b)
+}
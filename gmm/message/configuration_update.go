@@ -0,0 +1,44 @@
+package message
+
+import (
+	"fmt"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+	ngap_message "free5gc/src/ocf/ngap/message"
+)
+
+// UpdateUeConfiguration starts the generic UE configuration update procedure (TS 23.502 4.2.4.2, TS 24.501
+// 5.4.4) with the new values in the context of the UE. The command is sent at once to the UE in
+// CM-CONNECTED, the UE in CM-IDLE over 3GPP access is paged and gets it once it connects. An update
+// waiting for the UE to connect is merged with the new one.
+func UpdateUeConfiguration(ue *context.OcfUe, anType models.AccessType, update context.ConfigurationUpdate) error {
+	if ue.State[anType] == nil || !ue.State[anType].Is(context.Registered) {
+		return fmt.Errorf("UE[%s] is not registered over %s", ue.Supi, anType)
+	}
+	if update.Empty() {
+		return fmt.Errorf("Configuration update of UE[%s] has no parameter", ue.Supi)
+	}
+
+	if pending := ue.PendingConfigurationUpdate; pending != nil {
+		pending.Merge(update)
+		update = *pending
+	}
+	if ue.CmConnect(anType) {
+		ue.PendingConfigurationUpdate = nil
+		SendConfigurationUpdateCommand(ue, anType, &update)
+		return nil
+	}
+	if anType != models.AccessType__3_GPP_ACCESS {
+		return fmt.Errorf("UE[%s] in CM-IDLE over %s can not be paged", ue.Supi, anType)
+	}
+
+	ue.PendingConfigurationUpdate = &update
+	if ue.T3513 != nil {
+		// the UE is paged already, it gets the update with the downlink signalling it is paged for
+		return nil
+	}
+	logger.GmmLog.Infof("UE[%s] is in CM-IDLE, page it for the configuration update", ue.Supi)
+	ue.OnGoing[anType].Procedure = context.OnGoingProcedurePaging
+	return ngap_message.SendPaging(ue, nil, false)
+}
@@ -0,0 +1,211 @@
+package message
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/fsm"
+	"free5gc/lib/nas"
+	"free5gc/lib/nas/nasMessage"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	ngap_service "free5gc/src/ocf/ngap/service"
+	"free5gc/src/ocf/util"
+)
+
+var (
+	testPlmnId = models.PlmnId{Mcc: "208", Mnc: "93"}
+	testTai    = models.Tai{PlmnId: &testPlmnId, Tac: "000001"}
+)
+
+func decodeConfigurationUpdateCommand(t *testing.T, b []byte) *nasMessage.ConfigurationUpdateCommand {
+	m := nas.NewMessage()
+	require.NoError(t, m.PlainNasDecode(&b))
+	require.NotNil(t, m.GmmMessage)
+	require.Equal(t, nas.MsgTypeConfigurationUpdateCommand, m.GmmHeader.GetMessageType())
+	return m.GmmMessage.ConfigurationUpdateCommand
+}
+
+func TestBuildConfigurationUpdateCommand(t *testing.T) {
+	ue := &context.OcfUe{
+		RegistrationArea: map[models.AccessType][]models.Tai{models.AccessType__3_GPP_ACCESS: {testTai}},
+		TimeZone:         "+08:00",
+		MicoMode:         true,
+	}
+
+	b, err := BuildConfigurationUpdateCommand(ue, models.AccessType__3_GPP_ACCESS,
+		&context.ConfigurationUpdate{RegistrationArea: true, MicoIndication: true})
+	require.NoError(t, err)
+	command := decodeConfigurationUpdateCommand(t, b)
+	assert.NotNil(t, command.TAIList)
+	require.NotNil(t, command.MICOIndication)
+	assert.Equal(t, uint8(0), command.MICOIndication.GetRAAI())
+	assert.Nil(t, command.ConfigurationUpdateIndication)
+	assert.Nil(t, command.LocalTimeZone)
+	assert.Nil(t, command.UniversalTimeAndLocalTimeZone)
+	assert.Nil(t, command.NetworkDaylightSavingTime)
+
+	ue.ConfigurationUpdateIndication.SetACK(1)
+	b, err = BuildConfigurationUpdateCommand(ue, models.AccessType__3_GPP_ACCESS,
+		&context.ConfigurationUpdate{TimeZone: true})
+	require.NoError(t, err)
+	command = decodeConfigurationUpdateCommand(t, b)
+	assert.Nil(t, command.TAIList)
+	assert.Nil(t, command.MICOIndication)
+	require.NotNil(t, command.ConfigurationUpdateIndication)
+	assert.Equal(t, uint8(1), command.ConfigurationUpdateIndication.GetACK())
+	assert.Equal(t, uint8(0), command.ConfigurationUpdateIndication.GetRED())
+	assert.NotNil(t, command.LocalTimeZone)
+	assert.NotNil(t, command.UniversalTimeAndLocalTimeZone)
+	assert.NotNil(t, command.NetworkDaylightSavingTime)
+
+	// the parameters the UE has no value of are not sent
+	b, err = BuildConfigurationUpdateCommand(ue, models.AccessType_NON_3_GPP_ACCESS,
+		&context.ConfigurationUpdate{RegistrationArea: true, AllowedNssai: true, Guti: true})
+	require.NoError(t, err)
+	command = decodeConfigurationUpdateCommand(t, b)
+	assert.Nil(t, command.TAIList)
+	assert.Nil(t, command.AllowedNSSAI)
+	assert.Nil(t, command.GUTI5G)
+}
+
+func TestUniversalTimeAndLocalTimeZoneToNas(t *testing.T) {
+	// 2021-12-31 19:04:05 UTC
+	now := time.Date(2022, time.January, 1, 3, 4, 5, 0, time.FixedZone("", 8*3600))
+	universalTimeAndLocalTimeZone := universalTimeAndLocalTimeZoneToNas(now, 0x23)
+	assert.Equal(t, nasMessage.ConfigurationUpdateCommandUniversalTimeAndLocalTimeZoneType,
+		universalTimeAndLocalTimeZone.GetIei())
+	assert.Equal(t, uint8(0x12), universalTimeAndLocalTimeZone.GetYear())
+	assert.Equal(t, uint8(0x21), universalTimeAndLocalTimeZone.GetMonth())
+	assert.Equal(t, uint8(0x13), universalTimeAndLocalTimeZone.GetDay())
+	assert.Equal(t, uint8(0x91), universalTimeAndLocalTimeZone.GetHour())
+	assert.Equal(t, uint8(0x40), universalTimeAndLocalTimeZone.GetMinute())
+	assert.Equal(t, uint8(0x50), universalTimeAndLocalTimeZone.GetSecond())
+	assert.Equal(t, uint8(0x23), universalTimeAndLocalTimeZone.GetTimeZone())
+}
+
+// testRan sets up a gNB serving the TA of the test UEs
+func testRan(t *testing.T) *ngap_service.MemRan {
+	memRan, err := ngap_service.NewMemRan(models.AccessType__3_GPP_ACCESS)
+	require.NoError(t, err)
+	memRan.Ran.SupportedTAList = []context.SupportedTAI{{Tai: testTai}}
+	return memRan
+}
+
+func expectNasPdu(t *testing.T, memRan *ngap_service.MemRan) []byte {
+	nasPdu, err := memRan.ExpectNasPdu(time.Second)
+	require.NoError(t, err)
+	return nasPdu
+}
+
+// newRegisteredUe returns a UE registered over 3GPP access whose NAS messages are not protected
+func newRegisteredUe(supi string) *context.OcfUe {
+	ue := context.OCF_Self().NewOcfUe(supi)
+	ue.State[models.AccessType__3_GPP_ACCESS] = fsm.NewState(context.Registered)
+	ue.PlmnId = testPlmnId
+	ue.RegistrationArea[models.AccessType__3_GPP_ACCESS] = []models.Tai{testTai}
+	ue.AccessAndMobilitySubscriptionData = &models.AccessAndMobilitySubscriptionData{}
+	ue.AmPolicyAssociation = &models.PolicyAssociation{}
+	return ue
+}
+
+func TestConfigurationUpdateCommandRetransmission(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &testPlmnId, OcfId: "cafe00"}}
+		parameters.Timers = context.DefaultTimerParameters()
+		parameters.Timers.Default[context.TimerT3555] = context.Timer{
+			Value:         200 * time.Millisecond,
+			MaxRetryTimes: 2,
+		}
+	})
+
+	memRan := testRan(t)
+	defer memRan.Close()
+	ue := newRegisteredUe("imsi-208930000000011")
+	defer ue.Remove()
+	ranUe, err := memRan.Ran.NewRanUe(1)
+	require.NoError(t, err)
+	ue.AttachRanUe(ranUe)
+
+	require.NoError(t, UpdateUeConfiguration(ue, models.AccessType__3_GPP_ACCESS,
+		context.ConfigurationUpdate{RegistrationArea: true, RegistrationRequested: true}))
+	assert.Nil(t, ue.PendingConfigurationUpdate)
+
+	// the command and its 2 retransmissions
+	for i := 0; i < 3; i++ {
+		command := decodeConfigurationUpdateCommand(t, expectNasPdu(t, memRan))
+		require.NotNil(t, command.ConfigurationUpdateIndication)
+		assert.Equal(t, uint8(1), command.ConfigurationUpdateIndication.GetACK())
+		assert.Equal(t, uint8(1), command.ConfigurationUpdateIndication.GetRED())
+		assert.NotNil(t, command.TAIList)
+	}
+
+	// the procedure is aborted at the last expiry, the UE is no longer requested to register
+	deadline := time.Now().Add(time.Second)
+	for ue.T3555 != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, ue.T3555)
+	assert.Equal(t, uint8(0), ue.ConfigurationUpdateIndication.GetACK())
+	assert.Equal(t, uint8(0), ue.ConfigurationUpdateIndication.GetRED())
+	assert.Empty(t, memRan.PDUs)
+
+	// the time zone only is not acknowledged
+	ue.TimeZone = "+08:00"
+	require.NoError(t, UpdateUeConfiguration(ue, models.AccessType__3_GPP_ACCESS,
+		context.ConfigurationUpdate{TimeZone: true}))
+	command := decodeConfigurationUpdateCommand(t, expectNasPdu(t, memRan))
+	assert.Nil(t, command.ConfigurationUpdateIndication)
+	assert.Nil(t, ue.T3555)
+}
+
+func TestUpdateUeConfigurationInCmIdle(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &testPlmnId, OcfId: "cafe00"}}
+	})
+
+	memRan := testRan(t)
+	defer memRan.Close()
+	ue := newRegisteredUe("imsi-208930000000012")
+	defer ue.Remove()
+	defer util.StopT3513(ue)
+
+	// a UE is paged over 3GPP access only
+	ue.State[models.AccessType_NON_3_GPP_ACCESS] = fsm.NewState(context.Registered)
+	assert.Error(t, UpdateUeConfiguration(ue, models.AccessType_NON_3_GPP_ACCESS,
+		context.ConfigurationUpdate{AllowedNssai: true}))
+
+	// the UE knows the 5G-GUTI reallocated last only once it connects, it is paged with the old one
+	amfSelf.AllocateGutiToUe(ue)
+	amfSelf.ReallocateGuti(ue)
+	require.NoError(t, UpdateUeConfiguration(ue, models.AccessType__3_GPP_ACCESS,
+		context.ConfigurationUpdate{AllowedNssai: true, Guti: true}))
+	pdu, err := memRan.Expect(ngapType.ProcedureCodePaging, time.Second)
+	require.NoError(t, err)
+	for _, ie := range pdu.InitiatingMessage.Value.Paging.ProtocolIEs.List {
+		if ie.Id.Value == ngapType.ProtocolIEIDUEPagingIdentity {
+			assert.Equal(t, fmt.Sprintf("%08x", ue.OldTmsi),
+				hex.EncodeToString(ie.Value.UEPagingIdentity.FiveGSTMSI.FiveGTMSI.Value))
+		}
+	}
+	assert.Equal(t, context.OnGoingProcedurePaging, ue.OnGoing[models.AccessType__3_GPP_ACCESS].Procedure)
+	assert.Equal(t, &context.ConfigurationUpdate{AllowedNssai: true, Guti: true}, ue.PendingConfigurationUpdate)
+
+	// the update waiting for the UE is merged with the new one, the UE is not paged again
+	require.NoError(t, UpdateUeConfiguration(ue, models.AccessType__3_GPP_ACCESS,
+		context.ConfigurationUpdate{RegistrationRequested: true}))
+	assert.Equal(t, &context.ConfigurationUpdate{AllowedNssai: true, Guti: true, RegistrationRequested: true},
+		ue.PendingConfigurationUpdate)
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, memRan.PDUs)
+}
@@ -2,7 +2,6 @@ package message
 
 import (
 	"free5gc/lib/nas/nasMessage"
-	"free5gc/lib/nas/nasType"
 	"free5gc/lib/ngap/ngapType"
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/context"
@@ -41,9 +40,10 @@ func SendNotification(ue *context.RanUe, nasMsg []byte) {
 		return
 	}
 
-	amfUe.T3565 = time.AfterFunc(context.TimeT3565, func() {
+	t3565 := amfUe.Timer(context.TimerT3565, ue.Ran.AnType)
+	amfUe.T3565 = time.AfterFunc(t3565.Value, func() {
 		amfUe.T3565RetryTimes++
-		if amfUe.T3565RetryTimes > context.MaxT3565RetryTimes {
+		if amfUe.T3565RetryTimes > t3565.MaxRetryTimes {
 			logger.GmmLog.Warnf("UE[%s] T3565 Expires %d times, abort notification procedure",
 				amfUe.Supi, amfUe.T3565RetryTimes)
 			if amfUe.OnGoing[models.AccessType__3_GPP_ACCESS].Procedure != context.OnGoingProcedureN2Handover {
@@ -53,7 +53,7 @@ func SendNotification(ue *context.RanUe, nasMsg []byte) {
 		} else {
 			logger.GmmLog.Warnf("[NAS] T3565 expires, retransmit Notification (retry: %d)", amfUe.T3565RetryTimes)
 			ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
-			amfUe.T3565.Reset(context.TimeT3565)
+			amfUe.T3565.Reset(t3565.Value)
 		}
 	})
 }
@@ -92,10 +92,11 @@ func SendAuthenticationRequest(ue *context.RanUe) {
 	}
 	ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
 
+	t3560 := amfUe.Timer(context.TimerT3560, ue.Ran.AnType)
 	amfUe.T3560RetryTimes = 0
-	amfUe.T3560 = time.AfterFunc(context.TimeT3560, func() {
+	amfUe.T3560 = time.AfterFunc(t3560.Value, func() {
 		amfUe.T3560RetryTimes++
-		if amfUe.T3560RetryTimes > context.MaxT3560RetryTimes {
+		if amfUe.T3560RetryTimes > t3560.MaxRetryTimes {
 			logger.GmmLog.Warnf("T3560 Expires %d times, abort authentication procedure & ongoing 5GMM procedure",
 				amfUe.T3560RetryTimes)
 			util.StopT3560(amfUe)
@@ -103,7 +104,7 @@ func SendAuthenticationRequest(ue *context.RanUe) {
 		} else {
 			logger.GmmLog.Warnf("[NAS] T3560 expires, retransmit Authentication Request (retry: %d)", amfUe.T3560RetryTimes)
 			ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
-			amfUe.T3560.Reset(context.TimeT3560)
+			amfUe.T3560.Reset(t3560.Value)
 		}
 	})
 }
@@ -122,17 +123,49 @@ func SendServiceAccept(ue *context.RanUe, pDUSessionStatus *[16]bool, reactivati
 }
 
 func SendConfigurationUpdateCommand(amfUe *context.OcfUe, accessType models.AccessType,
-	networkSlicingIndication *nasType.NetworkSlicingIndication) {
+	update *context.ConfigurationUpdate) {
 
 	logger.GmmLog.Info("[NAS] Configuration Update Command")
 
-	nasMsg, err := BuildConfigurationUpdateCommand(amfUe, accessType, networkSlicingIndication)
+	// a command still to be acknowledged is replaced by this one
+	util.StopT3555(amfUe)
+	amfUe.ConfigurationUpdateIndication.SetACK(0)
+	amfUe.ConfigurationUpdateIndication.SetRED(0)
+	if update.AcknowledgeRequired() {
+		amfUe.ConfigurationUpdateIndication.SetACK(1)
+	}
+	if update.RegistrationRequested {
+		amfUe.ConfigurationUpdateIndication.SetRED(1)
+	}
+
+	nasMsg, err := BuildConfigurationUpdateCommand(amfUe, accessType, update)
 	if err != nil {
 		logger.GmmLog.Error(err.Error())
 		return
 	}
 	mobilityRestrictionList := ngap_message.BuildIEMobilityRestrictionList(amfUe)
 	ngap_message.SendDownlinkNasTransport(amfUe.RanUe[accessType], nasMsg, &mobilityRestrictionList)
+
+	// TS 24.501 5.4.4.2: the command is retransmitted until the UE acknowledges it, if it is requested to
+	if amfUe.ConfigurationUpdateIndication.GetACK() != 1 {
+		return
+	}
+	t3555 := amfUe.Timer(context.TimerT3555, accessType)
+	amfUe.T3555 = time.AfterFunc(t3555.Value, func() {
+		amfUe.T3555RetryTimes++
+		if amfUe.T3555RetryTimes > t3555.MaxRetryTimes || amfUe.RanUe[accessType] == nil {
+			// TS 24.501 5.4.4.4: the old and the new 5G-GUTI are both kept until the UE uses the new one
+			logger.GmmLog.Warnf("T3555 Expires %d times, abort configuration update procedure", amfUe.T3555RetryTimes)
+			amfUe.ConfigurationUpdateIndication.SetACK(0)
+			amfUe.ConfigurationUpdateIndication.SetRED(0)
+			util.StopT3555(amfUe)
+		} else {
+			logger.GmmLog.Warnf("[NAS] T3555 expires, retransmit Configuration Update Command (retry: %d)",
+				amfUe.T3555RetryTimes)
+			ngap_message.SendDownlinkNasTransport(amfUe.RanUe[accessType], nasMsg, &mobilityRestrictionList)
+			amfUe.T3555.Reset(t3555.Value)
+		}
+	})
 }
 
 func SendAuthenticationReject(ue *context.RanUe, eapMsg string) {
@@ -204,17 +237,18 @@ func SendSecurityModeCommand(ue *context.RanUe, eapSuccess bool, eapMessage stri
 
 	amfUe := ue.OcfUe
 
+	t3560 := amfUe.Timer(context.TimerT3560, ue.Ran.AnType)
 	amfUe.T3560RetryTimes = 0
-	amfUe.T3560 = time.AfterFunc(context.TimeT3560, func() {
+	amfUe.T3560 = time.AfterFunc(t3560.Value, func() {
 		amfUe.T3560RetryTimes++
-		if amfUe.T3560RetryTimes > context.MaxT3560RetryTimes {
+		if amfUe.T3560RetryTimes > t3560.MaxRetryTimes {
 			logger.GmmLog.Warnf("T3560 Expires %d times, abort security mode control procedure", amfUe.T3560RetryTimes)
 			util.StopT3560(amfUe)
 			amfUe.Remove()
 		} else {
 			logger.GmmLog.Warnf("[NAS] T3560 expires, retransmit Security Mode Command (retry: %d)", amfUe.T3560RetryTimes)
 			ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
-			amfUe.T3560.Reset(context.TimeT3560)
+			amfUe.T3560.Reset(t3560.Value)
 		}
 	})
 }
@@ -232,10 +266,11 @@ func SendDeregistrationRequest(ue *context.RanUe, accessType uint8, reRegistrati
 
 	amfUe := ue.OcfUe
 
+	t3522 := amfUe.Timer(context.TimerT3522, ue.Ran.AnType)
 	amfUe.T3522RetryTimes = 0
-	amfUe.T3522 = time.AfterFunc(context.TimeT3522, func() {
+	amfUe.T3522 = time.AfterFunc(t3522.Value, func() {
 		amfUe.T3522RetryTimes++
-		if amfUe.T3522RetryTimes > context.MaxT3522RetryTimes {
+		if amfUe.T3522RetryTimes > t3522.MaxRetryTimes {
 			logger.GmmLog.Warnf("T3522 Expires %d times, abort deregistration procedure", amfUe.T3522RetryTimes)
 			if accessType == nasMessage.AccessType3GPP {
 				logger.GmmLog.Warnln("UE accessType3GPP transfer to Deregistered state")
@@ -253,7 +288,7 @@ func SendDeregistrationRequest(ue *context.RanUe, accessType uint8, reRegistrati
 		} else {
 			logger.GmmLog.Warnf("[NAS] T3522 expires, retransmit Deregistration Request (retry: %d)", amfUe.T3522RetryTimes)
 			ngap_message.SendDownlinkNasTransport(ue, nasMsg, nil)
-			amfUe.T3522.Reset(context.TimeT3522)
+			amfUe.T3522.Reset(t3522.Value)
 		}
 	})
 }
@@ -285,6 +320,8 @@ func SendRegistrationAccept(
 		logger.GmmLog.Error(err.Error())
 		return
 	}
+	// the Registration Accept carries the new configuration of the UE already
+	ue.PendingConfigurationUpdate = nil
 
 	if ue.RanUe[anType].UeContextRequest {
 		ngap_message.SendInitialContextSetupRequest(ue, anType, nasMsg, pduSessionResourceSetupList, nil, nil, nil)
@@ -292,10 +329,11 @@ func SendRegistrationAccept(
 		ngap_message.SendDownlinkNasTransport(ue.RanUe[models.AccessType__3_GPP_ACCESS], nasMsg, nil)
 	}
 
+	t3550 := ue.Timer(context.TimerT3550, anType)
 	ue.T3550RetryTimes = 0
-	ue.T3550 = time.AfterFunc(context.TimeT3550, func() {
+	ue.T3550 = time.AfterFunc(t3550.Value, func() {
 		ue.T3550RetryTimes++
-		if ue.T3550RetryTimes > context.MaxT3550RetryTimes {
+		if ue.T3550RetryTimes > t3550.MaxRetryTimes {
 			logger.GmmLog.Warnf("T3550 Expires %d times, abort retransmission of Registration Accept", ue.T3550RetryTimes)
 			// TS 24.501 5.5.1.2.8 case c, 5.5.1.3.8 case c
 			ue.State[anType].Set(context.Registered)
@@ -304,7 +342,7 @@ func SendRegistrationAccept(
 		} else {
 			logger.GmmLog.Warnf("[NAS] T3550 expires, retransmit Registration Accept (retry: %d)", ue.T3550RetryTimes)
 			ngap_message.SendDownlinkNasTransport(ue.RanUe[anType], nasMsg, nil)
-			ue.T3550.Reset(context.TimeT3550)
+			ue.T3550.Reset(t3550.Value)
 		}
 	})
 }
@@ -4,6 +4,7 @@ import (
 	"free5gc/lib/fsm"
 	"free5gc/lib/nas"
 	"free5gc/lib/nas/nasMessage"
+	"free5gc/lib/nas/security"
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/context"
 	gmm_message "free5gc/src/ocf/gmm/message"
@@ -18,6 +19,10 @@ func DeRegistered(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		amfUe := args[ArgOcfUe].(*context.OcfUe)
 		accessType := args[ArgAccessType].(models.AccessType)
 		amfUe.ClearRegistrationRequestData(accessType)
+		amfUe.Reauthenticating = false
+		amfUe.KamfHorizontallyDerived = false
+		amfUe.KamfDerivedAtHandover = false
+		amfUe.PendingConfigurationUpdate = nil
 	case GmmMessageEvent:
 		amfUe := args[ArgOcfUe].(*context.OcfUe)
 		procedureCode := args[ArgProcedureCode].(int64)
@@ -82,12 +87,14 @@ func Registered(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 				logger.GmmLog.Errorln(err)
 			}
 		case nas.MsgTypeConfigurationUpdateComplete:
-			if err := HandleConfigurationUpdateComplete(amfUe, gmmMessage.ConfigurationUpdateComplete); err != nil {
+			if err := HandleConfigurationUpdateComplete(amfUe, accessType, gmmMessage.ConfigurationUpdateComplete); err != nil {
 				logger.GmmLog.Errorln(err)
 			}
 		case nas.MsgTypeServiceRequest:
 			if err := HandleServiceRequest(amfUe, accessType, gmmMessage.ServiceRequest); err != nil {
 				logger.GmmLog.Errorln(err)
+			} else {
+				reallocateGutiAtServiceRequest(amfUe, accessType)
 			}
 		case nas.MsgTypeNotificationResponse:
 			if err := HandleNotificationResponse(amfUe, gmmMessage.NotificationResponse); err != nil {
@@ -109,10 +116,26 @@ func Registered(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 			logger.GmmLog.Errorf("UE state mismatch: receieve gmm message[message type 0x%0x] at %s state",
 				gmmMessage.GetMessageType(), state.Current())
 		}
+
+		// TS 33.501 6.9.3: the UE is re-authenticated while it is connected, e.g. before its NAS COUNTs
+		// wrap around, then a new NAS security context is taken into use by a Security Mode Command
+		if amfUe.ReauthenticationRequired && state.Is(context.Registered) && amfUe.CmConnect(accessType) &&
+			!amfUe.UnauthenticatedEmergency() {
+			logger.GmmLog.Infof("Start the re-authentication of UE[%s]", amfUe.Supi)
+			amfUe.Reauthenticating = true
+			if err := GmmFSM.SendEvent(state, StartAuthEvent, fsm.ArgsType{
+				ArgOcfUe:      amfUe,
+				ArgAccessType: accessType,
+			}); err != nil {
+				logger.GmmLog.Errorln(err)
+			}
+		}
 	case StartAuthEvent:
 		logger.GmmLog.Debugln(event)
 	case InitDeregistrationEvent:
 		logger.GmmLog.Debugln(event)
+	case ImplicitDeregistrationEvent:
+		logger.GmmLog.Debugln(event)
 	case fsm.ExitEvent:
 		logger.GmmLog.Debugln(event)
 	default:
@@ -195,7 +218,7 @@ func SecurityMode(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 	case fsm.EntryEvent:
 		amfUe := args[ArgOcfUe].(*context.OcfUe)
 		accessType := args[ArgAccessType].(models.AccessType)
-		if amfUe.SecurityContextIsValid() {
+		if amfUe.SecurityContextIsValid() && !amfUe.ReauthenticationRequired && !amfUe.KamfHorizontallyDerived {
 			logger.GmmLog.Debugln("UE has a valid security context - skip security mode control procedure")
 			if err := GmmFSM.SendEvent(state, SecurityModeSuccessEvent, fsm.ArgsType{
 				ArgOcfUe:      amfUe,
@@ -204,12 +227,31 @@ func SecurityMode(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 			}); err != nil {
 				logger.GmmLog.Errorln(err)
 			}
+		} else if amfUe.UnauthenticatedEmergency() {
+			// TS 33.501 10.2.2: the null algorithms are used for the UEs not authenticated for emergency services
+			amfUe.IntegrityAlg = security.AlgIntegrity128NIA0
+			amfUe.CipheringAlg = security.AlgCiphering128NEA0
+			amfUe.DerivateAlgKey()
+			gmm_message.SendSecurityModeCommand(amfUe.RanUe[accessType], false, "")
 		} else {
-			eapSuccess := args[ArgEAPSuccess].(bool)
-			eapMessage := args[ArgEAPMessage].(string)
+			// no EAP result is given if the authentication was skipped for a horizontally derived Kamf
+			eapSuccess, _ := args[ArgEAPSuccess].(bool)
+			eapMessage, _ := args[ArgEAPMessage].(string)
 			// Select enc/int algorithm based on ue security capability & ocf's policy,
-			amfSelf := context.OCF_Self()
-			amfUe.SelectSecurityAlg(amfSelf.SecurityAlgorithm.IntegrityOrder, amfSelf.SecurityAlgorithm.CipheringOrder)
+			securityAlgorithm := context.OCF_Self().Parameters().SecurityAlgorithm
+			amfUe.SelectSecurityAlg(securityAlgorithm.IntegrityOrder, securityAlgorithm.CipheringOrder)
+			if amfUe.IntegrityAlg == security.AlgIntegrity128NIA0 {
+				logger.GmmLog.Warnf("No integrity algorithm of the OCF is supported by UE[%s]", amfUe.Supi)
+				gmm_message.SendRegistrationReject(amfUe.RanUe[accessType],
+					nasMessage.Cause5GMMUESecurityCapabilitiesMismatch, "")
+				if err := GmmFSM.SendEvent(state, SecurityModeFailEvent, fsm.ArgsType{
+					ArgOcfUe:      amfUe,
+					ArgAccessType: accessType,
+				}); err != nil {
+					logger.GmmLog.Errorln(err)
+				}
+				return
+			}
 			// Generate KnasEnc, KnasInt
 			amfUe.DerivateAlgKey()
 			gmm_message.SendSecurityModeCommand(amfUe.RanUe[accessType], eapSuccess, eapMessage)
@@ -247,6 +289,8 @@ func SecurityMode(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		logger.GmmLog.Debugln(event)
 	case SecurityModeFailEvent:
 		logger.GmmLog.Debugln(event)
+	case ReauthenticationSuccessEvent:
+		logger.GmmLog.Debugln(event)
 	case fsm.ExitEvent:
 		logger.GmmLog.Debugln(event)
 		return
@@ -269,7 +313,7 @@ func ContextSetup(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 		case *nasMessage.RegistrationRequest:
 			amfUe.RegistrationRequest = message
 			switch amfUe.RegistrationType5GS {
-			case nasMessage.RegistrationType5GSInitialRegistration:
+			case nasMessage.RegistrationType5GSInitialRegistration, nasMessage.RegistrationType5GSEmergencyRegistration:
 				if err := HandleInitialRegistration(amfUe, accessType); err != nil {
 					logger.GmmLog.Errorln(err)
 				}
@@ -298,7 +342,7 @@ func ContextSetup(state *fsm.State, event fsm.EventType, args fsm.ArgsType) {
 				logger.GmmLog.Errorln(err)
 			}
 			switch amfUe.RegistrationType5GS {
-			case nasMessage.RegistrationType5GSInitialRegistration:
+			case nasMessage.RegistrationType5GSInitialRegistration, nasMessage.RegistrationType5GSEmergencyRegistration:
 				if err := HandleInitialRegistration(amfUe, accessType); err != nil {
 					logger.GmmLog.Errorln(err)
 					err = GmmFSM.SendEvent(state, ContextSetupFailEvent, fsm.ArgsType{
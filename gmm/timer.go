@@ -0,0 +1,245 @@
+package gmm
+
+import (
+	"time"
+
+	"free5gc/lib/fsm"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/consumer"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+	ngap_service "free5gc/src/ocf/ngap/service"
+	"free5gc/src/ocf/producer/callback"
+	"free5gc/src/ocf/util"
+)
+
+func init() {
+	context.RegisterCmStateHandler(handleCmState)
+}
+
+// handleCmState runs the timers of a registered UE in CM-IDLE from every transition to CM-IDLE until the UE
+// connects again, whichever procedure attaches or removes its RAN UE
+func handleCmState(ue *context.OcfUe, anType models.AccessType, connected bool) {
+	if connected {
+		switch anType {
+		case models.AccessType__3_GPP_ACCESS:
+			StopMobileReachableTimer(ue)
+		case models.AccessType_NON_3_GPP_ACCESS:
+			StopNon3gppImplicitDeregistrationTimer(ue)
+		}
+		return
+	}
+	if state := ue.State[anType]; state == nil || !state.Is(context.Registered) {
+		return
+	}
+	switch anType {
+	case models.AccessType__3_GPP_ACCESS:
+		StartMobileReachableTimer(ue)
+	case models.AccessType_NON_3_GPP_ACCESS:
+		StartNon3gppImplicitDeregistrationTimer(ue)
+	}
+}
+
+// StartMobileReachableTimer is called when a UE registered over 3GPP access enters CM-IDLE. The UE is
+// unreachable when the timer expires and implicitly deregistered when the implicit deregistration
+// timer expires in turn, unless it connects again in between (TS 24.501 5.3.7). A UE in MICO mode is
+// unreachable as soon as its active time is over.
+func StartMobileReachableTimer(ue *context.OcfUe) {
+	util.StopMobileReachableTimer(ue)
+	util.StopImplicitDeregistrationTimer(ue)
+	util.StopActiveTimer(ue)
+
+	if ue.MicoMode {
+		if ue.T3324Value > 0 {
+			ue.ActiveTimer = afterFuncOnUeLane(ue, time.Duration(ue.T3324Value)*time.Second,
+				func() *time.Timer { return ue.ActiveTimer }, func() {
+					ue.ActiveTimer = nil
+					if ue.CmConnect(models.AccessType__3_GPP_ACCESS) {
+						return
+					}
+					logger.GmmLog.Infof("Active Timer of UE[%s] expires, the UE in MICO mode is unreachable", ue.Supi)
+					setReachability(ue, models.UeReachability_UNREACHABLE)
+				})
+		} else {
+			setReachability(ue, models.UeReachability_UNREACHABLE)
+		}
+	}
+
+	// the timer is deactivated along with the periodic registration update of the UE
+	if ue.T3512Value <= 0 {
+		return
+	}
+	mobileReachable := time.Duration(ue.T3512Value)*time.Second + context.DefaultMobileReachableTimerMargin
+
+	logger.GmmLog.Debugf("Start Mobile Reachable Timer of UE[%s] (%s)", ue.Supi, mobileReachable)
+	ue.MobileReachableTimer = afterFuncOnUeLane(ue, mobileReachable,
+		func() *time.Timer { return ue.MobileReachableTimer }, func() {
+			mobileReachableTimerExpired(ue)
+		})
+}
+
+// afterFuncOnUeLane starts a timer whose expiry is handled on the lane of the UE, after the NGAP messages of
+// the UE already read from the RAN. The expiry is dropped if current no longer returns the timer by then,
+// the timer has been stopped or started again meanwhile.
+func afterFuncOnUeLane(ue *context.OcfUe, d time.Duration, current func() *time.Timer,
+	expired func()) *time.Timer {
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		ngap_service.PostUe(ue, func() {
+			if current() != timer {
+				return
+			}
+			expired()
+		})
+	})
+	return timer
+}
+
+// mobileReachableTimerExpired makes the UE unreachable and starts the implicit deregistration timer, the
+// timer of a UE which has connected again meanwhile expired before it could be stopped
+func mobileReachableTimerExpired(ue *context.OcfUe) {
+	ue.MobileReachableTimer = nil
+	if ue.CmConnect(models.AccessType__3_GPP_ACCESS) {
+		logger.GmmLog.Infof("Mobile Reachable Timer of UE[%s] expires in CM-CONNECTED, ignore it", ue.Supi)
+		return
+	}
+	logger.GmmLog.Infof("Mobile Reachable Timer of UE[%s] expires, the UE is unreachable", ue.Supi)
+	setReachability(ue, models.UeReachability_UNREACHABLE)
+
+	ue.ImplicitDeregistrationTimer = afterFuncOnUeLane(ue, context.DefaultImplicitDeregistrationTimer,
+		func() *time.Timer { return ue.ImplicitDeregistrationTimer }, func() {
+			implicitDeregistrationTimerExpired(ue, models.AccessType__3_GPP_ACCESS)
+		})
+}
+
+// implicitDeregistrationTimerExpired deregisters the UE from the access type unless it has connected again
+// meanwhile
+func implicitDeregistrationTimerExpired(ue *context.OcfUe, anType models.AccessType) {
+	if anType == models.AccessType__3_GPP_ACCESS {
+		ue.ImplicitDeregistrationTimer = nil
+	} else {
+		ue.Non3gppImplicitDeregistrationTimer = nil
+	}
+	if ue.CmConnect(anType) {
+		logger.GmmLog.Infof("Implicit Deregistration Timer of UE[%s] on %s expires in CM-CONNECTED, ignore it",
+			ue.Supi, anType)
+		return
+	}
+	logger.GmmLog.Infof("Implicit Deregistration Timer of UE[%s] on %s expires", ue.Supi, anType)
+	if err := ImplicitDeregistration(ue, anType); err != nil {
+		logger.GmmLog.Errorln(err)
+	}
+}
+
+// StopMobileReachableTimer is called when the UE connects again, a UE that was unreachable is reachable
+func StopMobileReachableTimer(ue *context.OcfUe) {
+	util.StopMobileReachableTimer(ue)
+	util.StopImplicitDeregistrationTimer(ue)
+	util.StopActiveTimer(ue)
+
+	if ue.Reachability == models.UeReachability_UNREACHABLE {
+		setReachability(ue, models.UeReachability_REACHABLE)
+	}
+}
+
+// setReachability raises the UE reachability event when the reachability of the UE changes
+func setReachability(ue *context.OcfUe, reachability models.UeReachability) {
+	if ue.Reachability == reachability {
+		return
+	}
+	ue.Reachability = reachability
+	callback.SendOcfEventReportNotify(ue, models.OcfEventType_REACHABILITY_REPORT)
+}
+
+// StartNon3gppImplicitDeregistrationTimer is called when the N1 NAS signalling connection of a UE
+// registered over non-3GPP access is released, the UE is implicitly deregistered from the non-3GPP
+// access when the timer expires, unless it connects again in between (TS 24.501 5.3.8)
+func StartNon3gppImplicitDeregistrationTimer(ue *context.OcfUe) {
+	util.StopNon3gppImplicitDeregistrationTimer(ue)
+
+	if ue.Non3gppDeregistrationTimerValue <= 0 {
+		return
+	}
+	implicitDeregistration := time.Duration(ue.Non3gppDeregistrationTimerValue)*time.Second +
+		context.DefaultNon3gppImplicitDeregistrationTimerMargin
+
+	logger.GmmLog.Debugf("Start Non-3GPP Implicit Deregistration Timer of UE[%s] (%s)", ue.Supi,
+		implicitDeregistration)
+	ue.Non3gppImplicitDeregistrationTimer = afterFuncOnUeLane(ue, implicitDeregistration,
+		func() *time.Timer { return ue.Non3gppImplicitDeregistrationTimer }, func() {
+			implicitDeregistrationTimerExpired(ue, models.AccessType_NON_3_GPP_ACCESS)
+		})
+}
+
+// StopNon3gppImplicitDeregistrationTimer is called when the UE connects again over non-3GPP access
+func StopNon3gppImplicitDeregistrationTimer(ue *context.OcfUe) {
+	util.StopNon3gppImplicitDeregistrationTimer(ue)
+}
+
+// ImplicitDeregistration deregisters the UE on the access type without any NAS signalling: its SM
+// contexts on the access are released, along with the AM policy association and the registration at
+// the UDM. The UE is removed once it is deregistered on both accesses (TS 23.502 4.2.2.3.3).
+func ImplicitDeregistration(ue *context.OcfUe, anType models.AccessType) error {
+	logger.GmmLog.Infof("[OCF] Implicit Deregistration of UE[%s] on %s", ue.Supi, anType)
+
+	if !ue.State[anType].Is(context.Registered) {
+		logger.GmmLog.Warnf("UE[%s] is not registered on %s", ue.Supi, anType)
+		return nil
+	}
+
+	util.StopT3513(ue)
+	util.StopT3565(ue)
+
+	for pduSessionId, smContext := range ue.SmContextList {
+		if smContext.PduSessionContext.AccessType != anType {
+			continue
+		}
+
+		releaseData := consumer.BuildReleaseSmContextRequest(ue, nil, "", nil)
+		problemDetail, err := consumer.SendReleaseSmContextRequest(ue, pduSessionId, releaseData)
+		if problemDetail != nil {
+			logger.GmmLog.Errorf("Release SmContext Failed Problem[%+v]", problemDetail)
+		} else if err != nil {
+			logger.GmmLog.Errorf("Release SmContext Error[%v]", err.Error())
+		}
+	}
+
+	otherAnType := models.AccessType_NON_3_GPP_ACCESS
+	if anType == models.AccessType_NON_3_GPP_ACCESS {
+		otherAnType = models.AccessType__3_GPP_ACCESS
+	}
+	deregistered := ue.State[otherAnType].Is(context.Deregistered)
+
+	if ue.AmPolicyAssociation != nil && deregistered {
+		problemDetails, err := consumer.AMPolicyControlDelete(ue)
+		if problemDetails != nil {
+			logger.GmmLog.Errorf("AM Policy Control Delete Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			logger.GmmLog.Errorf("AM Policy Control Delete Error[%v]", err.Error())
+		}
+	}
+
+	if ue.NudmUECMUri != "" {
+		problemDetails, err := consumer.UeCmDeregistration(ue, anType)
+		if problemDetails != nil {
+			logger.GmmLog.Errorf("UECM Deregistration Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			logger.GmmLog.Errorf("UECM Deregistration Error[%v]", err.Error())
+		}
+	}
+
+	if err := GmmFSM.SendEvent(ue.State[anType], ImplicitDeregistrationEvent, fsm.ArgsType{
+		ArgOcfUe:      ue,
+		ArgAccessType: anType,
+	}); err != nil {
+		return err
+	}
+
+	// a UE that registered again meanwhile has another context in the pool, which is kept
+	if deregistered {
+		if poolUe, ok := context.OCF_Self().OcfUeFindBySupi(ue.Supi); !ok || poolUe == ue {
+			ue.Remove()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package gmm
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/fsm"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+// newRegisteredUe returns a UE registered on the access type of the RAN and connected through it
+func newRegisteredUe(t *testing.T, ran *context.OcfRan, supi string) (*context.OcfUe, *context.RanUe) {
+	ue := context.OCF_Self().NewOcfUe(supi)
+	ue.State[ran.AnType] = fsm.NewState(context.Registered)
+	ue.T3512Value = 3240
+	ue.Non3gppDeregistrationTimerValue = 3240
+	ranUe, err := ran.NewRanUe(int64(len(ran.RanUes()) + 1))
+	require.NoError(t, err)
+	ue.AttachRanUe(ranUe)
+	return ue, ranUe
+}
+
+func TestNon3gppImplicitDeregistrationTimer(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &plmnId, OcfId: "cafe00"}}
+	})
+
+	ocfConn, n3iwfConn := net.Pipe()
+	defer n3iwfConn.Close()
+	n3iwf := amfSelf.NewOcfRan(ocfConn)
+	n3iwf.AnType = models.AccessType_NON_3_GPP_ACCESS
+	defer n3iwf.Remove()
+
+	ue, ranUe := newRegisteredUe(t, n3iwf, "imsi-208930000000001")
+	defer ue.Remove()
+	// registered over both accesses, the UE is kept once it is deregistered from the non-3GPP access
+	ue.State[models.AccessType__3_GPP_ACCESS] = fsm.NewState(context.Registered)
+	assert.Nil(t, ue.Non3gppImplicitDeregistrationTimer)
+
+	// the N1 NAS signalling connection is released
+	require.NoError(t, ranUe.Remove())
+	assert.True(t, ue.CmIdle(n3iwf.AnType))
+	assert.NotNil(t, ue.Non3gppImplicitDeregistrationTimer)
+	assert.Nil(t, ue.MobileReachableTimer)
+
+	// whichever procedure attaches the new RAN UE
+	ranUe, err := n3iwf.NewRanUe(2)
+	require.NoError(t, err)
+	ue.AttachRanUe(ranUe)
+	assert.Nil(t, ue.Non3gppImplicitDeregistrationTimer)
+
+	// the timer stopped too late keeps the connected UE registered
+	implicitDeregistrationTimerExpired(ue, n3iwf.AnType)
+	assert.True(t, ue.State[n3iwf.AnType].Is(context.Registered))
+
+	require.NoError(t, ranUe.Remove())
+	implicitDeregistrationTimerExpired(ue, n3iwf.AnType)
+	assert.True(t, ue.State[n3iwf.AnType].Is(context.Deregistered))
+	assert.Nil(t, ue.Non3gppImplicitDeregistrationTimer)
+	_, ok := amfSelf.OcfUeFindBySupi(ue.Supi)
+	assert.True(t, ok)
+}
+
+func TestMobileReachableTimer(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &plmnId, OcfId: "cafe00"}}
+	})
+
+	ocfConn, gnbConn := net.Pipe()
+	defer gnbConn.Close()
+	gnb := amfSelf.NewOcfRan(ocfConn)
+	gnb.AnType = models.AccessType__3_GPP_ACCESS
+	defer gnb.Remove()
+
+	ue, ranUe := newRegisteredUe(t, gnb, "imsi-208930000000002")
+	defer ue.Remove()
+	ue.Reachability = models.UeReachability_REACHABLE
+
+	// the UE connected again before the timer was stopped
+	mobileReachableTimerExpired(ue)
+	assert.Equal(t, models.UeReachability_REACHABLE, ue.Reachability)
+	assert.Nil(t, ue.ImplicitDeregistrationTimer)
+
+	require.NoError(t, ranUe.Remove())
+	assert.NotNil(t, ue.MobileReachableTimer)
+	mobileReachableTimerExpired(ue)
+	assert.Equal(t, models.UeReachability_UNREACHABLE, ue.Reachability)
+	assert.NotNil(t, ue.ImplicitDeregistrationTimer)
+
+	// the unreachable UE connects again
+	ranUe, err := gnb.NewRanUe(2)
+	require.NoError(t, err)
+	ue.AttachRanUe(ranUe)
+	assert.Equal(t, models.UeReachability_REACHABLE, ue.Reachability)
+	assert.Nil(t, ue.MobileReachableTimer)
+	assert.Nil(t, ue.ImplicitDeregistrationTimer)
+	implicitDeregistrationTimerExpired(ue, gnb.AnType)
+	assert.True(t, ue.State[gnb.AnType].Is(context.Registered))
+}
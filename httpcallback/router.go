@@ -93,6 +93,13 @@ var routes = Routes{
 		HTTPAmPolicyControlUpdateNotifyTerminate,
 	},
 
+	{
+		"SdmDataChangeNotify",
+		strings.ToUpper("Post"),
+		"/sdm-data-change/:supi",
+		HTTPSdmDataChangeNotify,
+	},
+
 	{
 		"N1MessageNotify",
 		strings.ToUpper("Post"),
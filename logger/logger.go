@@ -29,6 +29,8 @@ var NasLog *logrus.Entry
 var ConsumerLog *logrus.Entry
 var EeLog *logrus.Entry
 var GinLog *logrus.Entry
+var SimLog *logrus.Entry
+var ReplayLog *logrus.Entry
 
 func init() {
 	log = logrus.New()
@@ -69,6 +71,8 @@ func init() {
 	ConsumerLog = log.WithFields(logrus.Fields{"component": "OCF", "category": "Consumer"})
 	EeLog = log.WithFields(logrus.Fields{"component": "OCF", "category": "EventExposure"})
 	GinLog = log.WithFields(logrus.Fields{"component": "OCF", "category": "GIN"})
+	SimLog = log.WithFields(logrus.Fields{"component": "OCF", "category": "Sim"})
+	ReplayLog = log.WithFields(logrus.Fields{"component": "OCF", "category": "Replay"})
 }
 
 func SetLogLevel(level logrus.Level) {
@@ -1,6 +1,7 @@
 package nas
 
 import (
+	"free5gc/lib/nas"
 	"free5gc/src/ocf/context"
 	"free5gc/src/ocf/logger"
 	"free5gc/src/ocf/nas/nas_security"
@@ -24,11 +25,16 @@ func HandleNAS(ue *context.RanUe, procedureCode int64, nasPdu []byte) {
 		ue.OcfUe.AttachRanUe(ue)
 	}
 
+	// decoding deciphers the NAS message in place, the Registration Request is kept as sent by the UE
+	sentPdu := append([]byte(nil), nasPdu...)
 	msg, err := nas_security.Decode(ue.OcfUe, ue.Ran.AnType, nasPdu)
 	if err != nil {
 		logger.NasLog.Errorln(err)
 		return
 	}
+	if msg.GmmMessage != nil && msg.GmmMessage.GetMessageType() == nas.MsgTypeRegistrationRequest {
+		ue.OcfUe.RegistrationRequestNasPdu = sentPdu
+	}
 
 	if err := Dispatch(ue.OcfUe, ue.Ran.AnType, procedureCode, msg); err != nil {
 		logger.NgapLog.Errorf("Handle NAS Error: %v", err)
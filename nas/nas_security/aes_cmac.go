@@ -2,6 +2,7 @@ package nas_security
 
 import (
 	// "encoding/hex"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"free5gc/lib/aes"
@@ -360,8 +361,14 @@ func NasMacCalculateByAesCmac(AlgoID uint8, KnasInt []byte, Count []byte, Bearer
 
 	switch AlgoID {
 	case security.AlgIntegrity128NIA0:
-		logger.NgapLog.Errorf("NEA1 not implement yet.")
-		return nil, nil
+		return make([]byte, 4), nil
+	case security.AlgIntegrity128NIA1:
+		if len(Count) != 4 {
+			return nil, fmt.Errorf("Size of Count[%d] != 4 bytes)", len(Count))
+		}
+		var key [16]byte
+		copy(key[:], KnasInt)
+		return NIA1(key, binary.BigEndian.Uint32(Count), Bearer, Direction, msg, int(length)), nil
 	case security.AlgIntegrity128NIA2:
 		// Couter[0..32] | BEARER[0..4] | DIRECTION[0] | 0^26
 		m := make([]byte, len(msg)+8)
@@ -388,8 +395,12 @@ func NasMacCalculateByAesCmac(AlgoID uint8, KnasInt []byte, Count []byte, Bearer
 		return cmac[:4], nil
 
 	case security.AlgIntegrity128NIA3:
-		logger.NgapLog.Errorf("NEA3 not implement yet.")
-		return nil, nil
+		if len(Count) != 4 {
+			return nil, fmt.Errorf("Size of Count[%d] != 4 bytes)", len(Count))
+		}
+		var key [16]byte
+		copy(key[:], KnasInt)
+		return NIA3(key, binary.BigEndian.Uint32(Count), Bearer, Direction, msg, int(length)), nil
 	default:
 		return nil, fmt.Errorf("Unknown Algorithm Identity[%d]", AlgoID)
 	}
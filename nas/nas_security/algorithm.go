@@ -0,0 +1,71 @@
+package nas_security
+
+import (
+	"fmt"
+	"free5gc/lib/nas/security"
+)
+
+// NASEncrypt ciphers or deciphers the payload in place, the SNOW 3G and ZUC based algorithms are
+// provided here and the others by lib/nas/security
+func NASEncrypt(AlgoID uint8, KnasEnc [16]byte, Count uint32, Bearer uint8, Direction uint8,
+	payload []byte) error {
+	if Bearer > 0x1f {
+		return fmt.Errorf("Bearer is beyond 5 bits")
+	}
+	if Direction > 1 {
+		return fmt.Errorf("Direction is beyond 1 bits")
+	}
+	if payload == nil {
+		return fmt.Errorf("Nas Payload is nil")
+	}
+
+	switch AlgoID {
+	case security.AlgCiphering128NEA1:
+		NEA1(KnasEnc, Count, Bearer, Direction, payload, len(payload)*8)
+		return nil
+	case security.AlgCiphering128NEA3:
+		NEA3(KnasEnc, Count, Bearer, Direction, payload, len(payload)*8)
+		return nil
+	default:
+		return security.NASEncrypt(AlgoID, KnasEnc, Count, Bearer, Direction, payload)
+	}
+}
+
+// NASMacCalculate computes the 32-bit MAC of the message, the SNOW 3G and ZUC based algorithms are
+// provided here and the others by lib/nas/security
+func NASMacCalculate(AlgoID uint8, KnasInt [16]byte, Count uint32, Bearer uint8, Direction uint8,
+	msg []byte) ([]byte, error) {
+	if Bearer > 0x1f {
+		return nil, fmt.Errorf("Bearer is beyond 5 bits")
+	}
+	if Direction > 1 {
+		return nil, fmt.Errorf("Direction is beyond 1 bits")
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("Nas Payload is nil")
+	}
+
+	switch AlgoID {
+	case security.AlgIntegrity128NIA1:
+		return NIA1(KnasInt, Count, Bearer, Direction, msg, len(msg)*8), nil
+	case security.AlgIntegrity128NIA3:
+		return NIA3(KnasInt, Count, Bearer, Direction, msg, len(msg)*8), nil
+	default:
+		return security.NASMacCalculate(AlgoID, KnasInt, Count, Bearer, Direction, msg)
+	}
+}
+
+func bitsToBytes(length int) int {
+	return (length + 7) / 8
+}
+
+// xorKeystream XORs the first length bits of data with the keystream, the remaining bits of the last
+// byte are cleared
+func xorKeystream(z []uint32, data []byte, length int) {
+	for i := 0; i < bitsToBytes(length); i++ {
+		data[i] ^= uint8(z[i/4] >> uint(24-8*(i%4)))
+	}
+	if length%8 != 0 {
+		data[length/8] &= 0xff << uint(8-length%8)
+	}
+}
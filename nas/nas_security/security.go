@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"free5gc/lib/nas"
+	"free5gc/lib/nas/nasConvert"
 	"free5gc/lib/nas/security"
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/context"
@@ -12,6 +13,12 @@ import (
 )
 
 func Encode(ue *context.OcfUe, msg *nas.Message) ([]byte, error) {
+	return EncodeWithIEs(ue, msg, nil)
+}
+
+// EncodeWithIEs encodes msg like Encode, with the IEs the NAS library has no field of appended to the plain
+// NAS message before it is protected
+func EncodeWithIEs(ue *context.OcfUe, msg *nas.Message, ies []byte) ([]byte, error) {
 	if ue == nil {
 		return nil, fmt.Errorf("amfUe is nil")
 	}
@@ -21,63 +28,95 @@ func Encode(ue *context.OcfUe, msg *nas.Message) ([]byte, error) {
 
 	// Plain NAS message
 	if !ue.SecurityContextAvailable {
-		return msg.PlainNasEncode()
+		payload, err := msg.PlainNasEncode()
+		if err != nil {
+			return nil, err
+		}
+		return append(payload, ies...), nil
 	} else {
 		// Security protected NAS Message
-		// a security protected NAS message must be integrity protected, and ciphering is optional
-		needCiphering := false
-		switch msg.SecurityHeader.SecurityHeaderType {
-		case nas.SecurityHeaderTypeIntegrityProtected:
-			logger.NasLog.Debugln("Security header type: Integrity Protected")
-		case nas.SecurityHeaderTypeIntegrityProtectedAndCiphered:
-			logger.NasLog.Debugln("Security header type: Integrity Protected And Ciphered")
-			needCiphering = true
-		case nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext:
-			logger.NasLog.Debugln("Security header type: Integrity Protected With New 5G Security Context")
+		if msg.SecurityHeader.SecurityHeaderType == nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext {
 			ue.ULCount.Set(0, 0)
-			ue.DLCount.Set(0, 0)
-		default:
-			return nil, fmt.Errorf("Wrong security header type: 0x%0x", msg.SecurityHeader.SecurityHeaderType)
 		}
-
-		// encode plain nas first
-		payload, err := msg.PlainNasEncode()
+		payload, err := protect(ue, msg, ies, security.DirectionDownlink)
 		if err != nil {
-			return nil, fmt.Errorf("Plain NAS encode error: %+v", err)
+			return nil, err
 		}
+		checkNasCountHeadroom(ue)
+		return payload, nil
+	}
+}
 
-		logger.NasLog.Traceln("ue.CipheringAlg", ue.CipheringAlg)
-		logger.NasLog.Traceln("ue.DLCount()", ue.DLCount.Get())
-		logger.NasLog.Tracef("payload:\n%+v", hex.Dump(payload))
-
-		if needCiphering {
-			logger.NasLog.Debugln("Perform NAS encryption")
-			if err = security.NASEncrypt(ue.CipheringAlg, ue.KnasEnc, ue.DLCount.Get(), security.Bearer3GPP,
-				security.DirectionDownlink, payload); err != nil {
-				return nil, fmt.Errorf("Encrypt error: %+v", err)
-			}
-		}
+// Protect encodes msg as a security protected NAS message with the NAS security context of ue and the
+// NAS COUNT of the direction, which is then increased. The OCF protects its downlink messages with it, the
+// simulator the uplink messages of its UEs.
+func Protect(ue *context.OcfUe, msg *nas.Message, direction uint8) ([]byte, error) {
+	return protect(ue, msg, nil, direction)
+}
 
-		// add sequece number
-		payload = append([]byte{ue.DLCount.SQN()}, payload[:]...)
+func protect(ue *context.OcfUe, msg *nas.Message, ies []byte, direction uint8) ([]byte, error) {
+	count := &ue.DLCount
+	if direction == security.DirectionUplink {
+		count = &ue.ULCount
+	}
 
-		mac32, err := security.NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, ue.DLCount.Get(), security.Bearer3GPP,
-			security.DirectionDownlink, payload)
-		if err != nil {
-			return nil, fmt.Errorf("MAC calcuate error: %+v", err)
+	// a security protected NAS message must be integrity protected, and ciphering is optional
+	needCiphering := false
+	switch msg.SecurityHeader.SecurityHeaderType {
+	case nas.SecurityHeaderTypeIntegrityProtected:
+		logger.NasLog.Debugln("Security header type: Integrity Protected")
+	case nas.SecurityHeaderTypeIntegrityProtectedAndCiphered:
+		logger.NasLog.Debugln("Security header type: Integrity Protected And Ciphered")
+		needCiphering = true
+	case nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext:
+		logger.NasLog.Debugln("Security header type: Integrity Protected With New 5G Security Context")
+		count.Set(0, 0)
+	case nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext:
+		logger.NasLog.Debugln("Security header type: Integrity Protected And Ciphered With New 5G Security Context")
+		needCiphering = true
+		count.Set(0, 0)
+	default:
+		return nil, fmt.Errorf("Wrong security header type: 0x%0x", msg.SecurityHeader.SecurityHeaderType)
+	}
+
+	// encode plain nas first
+	payload, err := msg.PlainNasEncode()
+	if err != nil {
+		return nil, fmt.Errorf("Plain NAS encode error: %+v", err)
+	}
+	payload = append(payload, ies...)
+
+	logger.NasLog.Traceln("ue.CipheringAlg", ue.CipheringAlg)
+	logger.NasLog.Traceln("count", count.Get())
+	logger.NasLog.Tracef("payload:\n%+v", hex.Dump(payload))
+
+	if needCiphering {
+		logger.NasLog.Debugln("Perform NAS encryption")
+		if err = NASEncrypt(ue.CipheringAlg, ue.KnasEnc, count.Get(), security.Bearer3GPP,
+			direction, payload); err != nil {
+			return nil, fmt.Errorf("Encrypt error: %+v", err)
 		}
-		// Add mac value
-		logger.NasLog.Traceln("mac32", mac32)
-		payload = append(mac32, payload[:]...)
-
-		// Add EPD and Security Type
-		msgSecurityHeader := []byte{msg.SecurityHeader.ProtocolDiscriminator, msg.SecurityHeader.SecurityHeaderType}
-		payload = append(msgSecurityHeader, payload[:]...)
-		logger.NasLog.Traceln("Encode payload", payload)
-		// Increase DL Count
-		ue.DLCount.AddOne()
-		return payload, nil
 	}
+
+	// add sequece number
+	payload = append([]byte{count.SQN()}, payload[:]...)
+
+	mac32, err := NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, count.Get(), security.Bearer3GPP,
+		direction, payload)
+	if err != nil {
+		return nil, fmt.Errorf("MAC calcuate error: %+v", err)
+	}
+	// Add mac value
+	logger.NasLog.Traceln("mac32", mac32)
+	payload = append(mac32, payload[:]...)
+
+	// Add EPD and Security Type
+	msgSecurityHeader := []byte{msg.SecurityHeader.ProtocolDiscriminator, msg.SecurityHeader.SecurityHeaderType}
+	payload = append(msgSecurityHeader, payload[:]...)
+	logger.NasLog.Traceln("Encode payload", payload)
+	// Increase the NAS COUNT
+	count.AddOne()
+	return payload, nil
 }
 
 /*
@@ -136,66 +175,149 @@ func Decode(ue *context.OcfUe, accessType models.AccessType, payload []byte) (*n
 			return msg, err
 		}
 	} else { // Security protected NAS message
-		logger.NasLog.Traceln("securityHeaderType is ", msg.SecurityHeaderType)
-		securityHeader := payload[0:6]
-		logger.NasLog.Traceln("securityHeader is ", securityHeader)
-		sequenceNumber := payload[6]
-		logger.NasLog.Traceln("sequenceNumber", sequenceNumber)
-
-		receivedMac32 := securityHeader[2:]
-		// remove security Header except for sequece Number
-		payload = payload[6:]
-
-		// a security protected NAS message must be integrity protected, and ciphering is optional
-		ciphered := false
-		switch msg.SecurityHeaderType {
-		case nas.SecurityHeaderTypeIntegrityProtected:
-			logger.NasLog.Debugln("Security header type: Integrity Protected")
-		case nas.SecurityHeaderTypeIntegrityProtectedAndCiphered:
-			logger.NasLog.Debugln("Security header type: Integrity Protected And Ciphered")
-			ciphered = true
-		case nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext:
-			logger.NasLog.Debugln("Security header type: Integrity Protected And Ciphered With New 5G Security Context")
-			ciphered = true
-			ue.ULCount.Set(0, 0)
-		default:
-			return nil, fmt.Errorf("Wrong security header type: 0x%0x", msg.SecurityHeader.SecurityHeaderType)
-		}
-
-		if ue.ULCount.SQN() > sequenceNumber {
-			logger.NasLog.Debugf("set ULCount overflow")
-			ue.ULCount.SetOverflow(ue.ULCount.Overflow() + 1)
+		if msg.SecurityHeaderType == nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext {
+			return nil, fmt.Errorf("Wrong security header type: 0x%0x", msg.SecurityHeaderType)
 		}
-		ue.ULCount.SetSQN(sequenceNumber)
-
-		logger.NasLog.Debugln("Perform NAS mac calculation")
-		mac32, err := security.NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, ue.ULCount.Get(), security.Bearer3GPP,
-			security.DirectionUplink, payload)
+		msg, macOk, err := Unprotect(ue, payload, security.DirectionUplink)
 		if err != nil {
-			return nil, fmt.Errorf("MAC calcuate error: %+v", err)
+			return nil, err
 		}
+		checkNasCountHeadroom(ue)
+		ue.MacFailed = !macOk
+		return msg, nil
+	}
+}
 
-		if !reflect.DeepEqual(mac32, receivedMac32) {
-			logger.NasLog.Warnf("NAS MAC verification failed(received: 0x%08x, expected: 0x%08x)", receivedMac32, mac32)
-			ue.MacFailed = true
-		} else {
-			logger.NasLog.Tracef("cmac value: 0x%08x", mac32)
-			ue.MacFailed = false
-		}
+// Unprotect checks the NAS MAC of a security protected NAS message with the NAS security context of ue
+// and deciphers it, the NAS COUNT of the direction is updated with the sequence number of the message. The
+// message is decoded even if its MAC is wrong, macOk tells whether it is.
+func Unprotect(ue *context.OcfUe, payload []byte, direction uint8) (msg *nas.Message, macOk bool, err error) {
+	if len(payload) < 7 {
+		return nil, false, fmt.Errorf("Security protected NAS message is too short")
+	}
+	count := &ue.ULCount
+	if direction == security.DirectionDownlink {
+		count = &ue.DLCount
+	}
 
-		if ciphered {
-			logger.NasLog.Debugf("Perform NAS decryption")
-			logger.NasLog.Traceln("ue.CipheringAlg", ue.CipheringAlg)
-			// decrypt payload without sequence number (payload[1])
-			if err = security.NASEncrypt(ue.CipheringAlg, ue.KnasEnc, ue.ULCount.Get(), security.Bearer3GPP,
-				security.DirectionUplink, payload[1:]); err != nil {
-				return nil, fmt.Errorf("Encrypt error: %+v", err)
-			}
+	msg = new(nas.Message)
+	msg.SecurityHeaderType = uint8(nas.GetSecurityHeaderType(payload) & 0x0f)
+	logger.NasLog.Traceln("securityHeaderType is ", msg.SecurityHeaderType)
+	securityHeader := payload[0:6]
+	logger.NasLog.Traceln("securityHeader is ", securityHeader)
+	sequenceNumber := payload[6]
+	logger.NasLog.Traceln("sequenceNumber", sequenceNumber)
+
+	receivedMac32 := securityHeader[2:]
+	// remove security Header except for sequece Number
+	payload = payload[6:]
+
+	// a security protected NAS message must be integrity protected, and ciphering is optional
+	ciphered := false
+	switch msg.SecurityHeaderType {
+	case nas.SecurityHeaderTypeIntegrityProtected:
+		logger.NasLog.Debugln("Security header type: Integrity Protected")
+	case nas.SecurityHeaderTypeIntegrityProtectedAndCiphered:
+		logger.NasLog.Debugln("Security header type: Integrity Protected And Ciphered")
+		ciphered = true
+	case nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext:
+		logger.NasLog.Debugln("Security header type: Integrity Protected With New 5G Security Context")
+		count.Set(0, 0)
+	case nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext:
+		logger.NasLog.Debugln("Security header type: Integrity Protected And Ciphered With New 5G Security Context")
+		ciphered = true
+		count.Set(0, 0)
+	default:
+		return nil, false, fmt.Errorf("Wrong security header type: 0x%0x", msg.SecurityHeaderType)
+	}
+
+	if count.SQN() > sequenceNumber {
+		logger.NasLog.Debugf("set NAS COUNT overflow")
+		count.SetOverflow(count.Overflow() + 1)
+	}
+	count.SetSQN(sequenceNumber)
+
+	logger.NasLog.Debugln("Perform NAS mac calculation")
+	mac32, err := NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, count.Get(), security.Bearer3GPP,
+		direction, payload)
+	if err != nil {
+		return nil, false, fmt.Errorf("MAC calcuate error: %+v", err)
+	}
+
+	if !reflect.DeepEqual(mac32, receivedMac32) {
+		logger.NasLog.Warnf("NAS MAC verification failed(received: 0x%08x, expected: 0x%08x)", receivedMac32, mac32)
+	} else {
+		logger.NasLog.Tracef("cmac value: 0x%08x", mac32)
+		macOk = true
+	}
+
+	if ciphered {
+		logger.NasLog.Debugf("Perform NAS decryption")
+		logger.NasLog.Traceln("ue.CipheringAlg", ue.CipheringAlg)
+		// decrypt payload without sequence number (payload[1])
+		if err = NASEncrypt(ue.CipheringAlg, ue.KnasEnc, count.Get(), security.Bearer3GPP,
+			direction, payload[1:]); err != nil {
+			return nil, false, fmt.Errorf("Encrypt error: %+v", err)
 		}
+	}
+
+	// remove sequece Number
+	payload = payload[1:]
+	err = msg.PlainNasDecode(&payload)
+	return msg, macOk, err
+}
+
+// CheckIntegrity checks the NAS MAC of a security protected uplink NAS message with the NAS security context
+// of ue, which is left as it is. The uplink NAS COUNT estimated from the sequence number of the message is
+// returned, the old OCF checks so the Registration Request the new OCF received (TS 33.501 6.9.3).
+func CheckIntegrity(ue *context.OcfUe, payload []byte) (ulCount uint32, macOk bool, err error) {
+	if len(payload) < 7 {
+		return 0, false, fmt.Errorf("Security protected NAS message is too short")
+	}
+	switch securityHeaderType := uint8(nas.GetSecurityHeaderType(payload) & 0x0f); securityHeaderType {
+	case nas.SecurityHeaderTypeIntegrityProtected, nas.SecurityHeaderTypeIntegrityProtectedAndCiphered:
+	default:
+		return 0, false, fmt.Errorf("Wrong security header type: 0x%0x", securityHeaderType)
+	}
+
+	count := ue.ULCount
+	sequenceNumber := payload[6]
+	if count.SQN() > sequenceNumber {
+		count.SetOverflow(count.Overflow() + 1)
+	}
+	count.SetSQN(sequenceNumber)
+	mac32, err := NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, count.Get(), security.Bearer3GPP,
+		security.DirectionUplink, payload[6:])
+	if err != nil {
+		return 0, false, fmt.Errorf("MAC calcuate error: %+v", err)
+	}
+	return count.Get(), reflect.DeepEqual(mac32, payload[2:6]), nil
+}
+
+// checkNasCountHeadroom requires the re-authentication of the UE before its NAS COUNTs wrap around, the
+// NAS COUNTs start again from zero with the new Kamf (TS 33.501 6.4.3.1)
+func checkNasCountHeadroom(ue *context.OcfUe) {
+	if ue.ReauthenticationRequired {
+		return
+	}
+	if headroom := ue.NasCountHeadroom(); headroom < context.OCF_Self().Parameters().Reauthentication.NasCountHeadroom {
+		logger.NasLog.Warnf("%d NAS COUNT values are left for UE[%s], re-authentication is required",
+			headroom, ue.Supi)
+		ue.ReauthenticationRequired = true
+	}
+}
 
-		// remove sequece Number
-		payload = payload[1:]
-		err = msg.PlainNasDecode(&payload)
-		return msg, err
+// HandoverNasSecurityParameters encodes the NAS security parameters the UE derives the Kamf handed over to the
+// target OCF at N2 handover from (TS 24.501 9.11.2.6, TS 33.501 6.9.2.3.3): the NAS-MAC, the selected NAS
+// security algorithms, the K_AMF change flag and ngKSI, and the sequence number of the downlink NAS COUNT the
+// Kamf is derived with. The NAS-MAC is calculated with the current NAS integrity key and that NAS COUNT.
+func HandoverNasSecurityParameters(ue *context.OcfUe, count uint32) ([]byte, error) {
+	ngKsi := nasConvert.SpareHalfOctetAndNgksiToNas(ue.NgKsi).Octet & 0x0f
+	parameters := []byte{ue.CipheringAlg<<4 | ue.IntegrityAlg, 0x10 | ngKsi, uint8(count)}
+	mac32, err := NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, count, security.Bearer3GPP,
+		security.DirectionDownlink, parameters)
+	if err != nil {
+		return nil, fmt.Errorf("MAC calcuate error: %+v", err)
 	}
+	return append(mac32, parameters...), nil
 }
@@ -0,0 +1,63 @@
+package nas_security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/nas"
+	"free5gc/lib/nas/nasMessage"
+	"free5gc/lib/nas/security"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	ue := &context.OcfUe{
+		IntegrityAlg: security.AlgIntegrity128NIA1,
+		KnasInt:      decodeKey(t, "2bd6459f82c5b300952c49104881ff48"),
+	}
+	ue.ULCount.Set(1, 0xfe)
+
+	// the Registration Request sent by the UE after the sequence number wrapped around
+	payload := append([]byte{0x01}, decodeHex(t, "7e00417900")...)
+	mac32, err := NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, 0x201, security.Bearer3GPP,
+		security.DirectionUplink, payload)
+	require.Nil(t, err)
+	message := append([]byte{nasMessage.Epd5GSMobilityManagementMessage, nas.SecurityHeaderTypeIntegrityProtected},
+		append(mac32, payload...)...)
+
+	ulCount, macOk, err := CheckIntegrity(ue, message)
+	require.Nil(t, err)
+	assert.True(t, macOk)
+	assert.Equal(t, uint32(0x201), ulCount)
+	assert.Equal(t, uint32(0x1fe), ue.ULCount.Get())
+
+	message[len(message)-1] ^= 0x01
+	_, macOk, err = CheckIntegrity(ue, message)
+	require.Nil(t, err)
+	assert.False(t, macOk)
+
+	_, _, err = CheckIntegrity(ue, decodeHex(t, "7e00417900"))
+	assert.NotNil(t, err)
+}
+
+func TestHandoverNasSecurityParameters(t *testing.T) {
+	ue := &context.OcfUe{
+		NgKsi:        models.NgKsi{Tsc: models.ScType_NATIVE, Ksi: 2},
+		CipheringAlg: security.AlgCiphering128NEA2,
+		IntegrityAlg: security.AlgIntegrity128NIA2,
+		KnasInt:      decodeKey(t, "2bd6459f82c5b300952c49104881ff48"),
+	}
+
+	parameters, err := HandoverNasSecurityParameters(ue, 0x105)
+	require.Nil(t, err)
+	require.Len(t, parameters, 7)
+	// NEA2 and NIA2, the K_AMF change flag with the native ngKSI 2, the sequence number of the NAS COUNT
+	assert.Equal(t, []byte{0x22, 0x12, 0x05}, parameters[4:])
+	mac32, err := NASMacCalculate(ue.IntegrityAlg, ue.KnasInt, 0x105, security.Bearer3GPP,
+		security.DirectionDownlink, parameters[4:])
+	require.Nil(t, err)
+	assert.Equal(t, mac32, parameters[:4])
+}
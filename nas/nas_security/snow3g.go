@@ -0,0 +1,194 @@
+package nas_security
+
+import "encoding/binary"
+
+// SNOW 3G keystream generator (TS 35.216), the core of 128-NEA1 and 128-NIA1
+
+var snow3gSR, snow3gSQ [256]uint8
+
+func init() {
+	// SR is the S-box of AES
+	for x := 0; x < 256; x++ {
+		inverse := uint8(0)
+		if x != 0 {
+			for y := 1; y < 256; y++ {
+				if gfMultiply(uint8(x), uint8(y), 0x1b) == 1 {
+					inverse = uint8(y)
+					break
+				}
+			}
+		}
+		sr := inverse
+		for i := uint(1); i < 5; i++ {
+			sr ^= inverse<<i | inverse>>(8-i)
+		}
+		snow3gSR[x] = sr ^ 0x63
+	}
+	// SQ is derived from the Dickson polynomial g49 over GF(2^8) defined by x^8 + x^6 + x^5 + x^3 + 1
+	for x := 0; x < 256; x++ {
+		sq := uint8(0x25)
+		for _, exponent := range []int{1, 9, 13, 15, 33, 41, 45, 47, 49} {
+			power := uint8(1)
+			for i := 0; i < exponent; i++ {
+				power = gfMultiply(power, uint8(x), 0x69)
+			}
+			sq ^= power
+		}
+		snow3gSQ[x] = sq
+	}
+}
+
+// mulx multiplies by x in GF(2^8), the reduction polynomial is x^8 + c
+func mulx(v, c uint8) uint8 {
+	if v&0x80 != 0 {
+		return v<<1 ^ c
+	}
+	return v << 1
+}
+
+func mulxPow(v uint8, i int, c uint8) uint8 {
+	for ; i > 0; i-- {
+		v = mulx(v, c)
+	}
+	return v
+}
+
+func gfMultiply(a, b, c uint8) uint8 {
+	product := uint8(0)
+	for ; b != 0; b >>= 1 {
+		if b&1 != 0 {
+			product ^= a
+		}
+		a = mulx(a, c)
+	}
+	return product
+}
+
+func mulAlpha(c uint8) uint32 {
+	return uint32(mulxPow(c, 23, 0xa9))<<24 | uint32(mulxPow(c, 245, 0xa9))<<16 |
+		uint32(mulxPow(c, 48, 0xa9))<<8 | uint32(mulxPow(c, 239, 0xa9))
+}
+
+func divAlpha(c uint8) uint32 {
+	return uint32(mulxPow(c, 16, 0xa9))<<24 | uint32(mulxPow(c, 39, 0xa9))<<16 |
+		uint32(mulxPow(c, 6, 0xa9))<<8 | uint32(mulxPow(c, 64, 0xa9))
+}
+
+func snow3gS(w uint32, box *[256]uint8, c uint8) uint32 {
+	s0, s1, s2, s3 := box[w>>24], box[w>>16&0xff], box[w>>8&0xff], box[w&0xff]
+	r0 := mulx(s0, c) ^ s1 ^ s2 ^ mulx(s3, c) ^ s3
+	r1 := mulx(s0, c) ^ s0 ^ mulx(s1, c) ^ s2 ^ s3
+	r2 := s0 ^ mulx(s1, c) ^ s1 ^ mulx(s2, c) ^ s3
+	r3 := s0 ^ s1 ^ mulx(s2, c) ^ s2 ^ mulx(s3, c)
+	return uint32(r0)<<24 | uint32(r1)<<16 | uint32(r2)<<8 | uint32(r3)
+}
+
+type snow3g struct {
+	s          [16]uint32
+	r1, r2, r3 uint32
+}
+
+func newSnow3g(k, iv [4]uint32) *snow3g {
+	g := &snow3g{}
+	g.s = [16]uint32{
+		k[0] ^ 0xffffffff, k[1] ^ 0xffffffff, k[2] ^ 0xffffffff, k[3] ^ 0xffffffff,
+		k[0], k[1], k[2], k[3],
+		k[0] ^ 0xffffffff, k[1] ^ 0xffffffff ^ iv[3], k[2] ^ 0xffffffff ^ iv[2], k[3] ^ 0xffffffff,
+		k[0] ^ iv[1], k[1], k[2], k[3] ^ iv[0],
+	}
+	for i := 0; i < 32; i++ {
+		g.clockLfsr(g.clockFsm())
+	}
+	g.clockFsm()
+	g.clockLfsr(0)
+	return g
+}
+
+func (g *snow3g) clockFsm() uint32 {
+	f := (g.s[15] + g.r1) ^ g.r2
+	r := g.r2 + (g.r3 ^ g.s[5])
+	g.r3 = snow3gS(g.r2, &snow3gSQ, 0x69)
+	g.r2 = snow3gS(g.r1, &snow3gSR, 0x1b)
+	g.r1 = r
+	return f
+}
+
+// clockLfsr is in the initialisation mode with the output f of the FSM, in the keystream mode with 0
+func (g *snow3g) clockLfsr(f uint32) {
+	v := g.s[0]<<8 ^ mulAlpha(uint8(g.s[0]>>24)) ^ g.s[2] ^ g.s[11]>>8 ^ divAlpha(uint8(g.s[11])) ^ f
+	copy(g.s[:], g.s[1:])
+	g.s[15] = v
+}
+
+func (g *snow3g) keystream(n int) []uint32 {
+	z := make([]uint32, n)
+	for i := range z {
+		z[i] = g.clockFsm() ^ g.s[0]
+		g.clockLfsr(0)
+	}
+	return z
+}
+
+// snow3gKey splits the 128-bit key into the words k0 ~ k3, the first bits of the key are k3
+func snow3gKey(key [16]byte) [4]uint32 {
+	return [4]uint32{
+		binary.BigEndian.Uint32(key[12:]), binary.BigEndian.Uint32(key[8:]),
+		binary.BigEndian.Uint32(key[4:]), binary.BigEndian.Uint32(key[0:]),
+	}
+}
+
+// NEA1 ciphers or deciphers in place the first length bits of data with 128-NEA1, which is UEA2 with a
+// 5-bit BEARER (TS 33.501 D.2.1.1, TS 35.215 f8)
+func NEA1(key [16]byte, count uint32, bearer, direction uint8, data []byte, length int) {
+	fresh := uint32(bearer&0x1f)<<27 | uint32(direction&0x1)<<26
+	g := newSnow3g(snow3gKey(key), [4]uint32{fresh, count, fresh, count})
+	xorKeystream(g.keystream((length+31)/32), data, length)
+}
+
+// NIA1 computes the 32-bit MAC of the first length bits of message with 128-NIA1, which is UIA2 with
+// FRESH set to BEARER || 0^27 (TS 33.501 D.3.1.1)
+func NIA1(key [16]byte, count uint32, bearer, direction uint8, message []byte, length int) []byte {
+	return uia2(key, count, uint32(bearer&0x1f)<<27, direction, message, length)
+}
+
+// uia2 is the f9 integrity function (TS 35.215)
+func uia2(key [16]byte, count, fresh uint32, direction uint8, message []byte, length int) []byte {
+	d := uint32(direction & 0x1)
+	g := newSnow3g(snow3gKey(key), [4]uint32{fresh ^ d<<15, count ^ d<<31, fresh, count})
+	z := g.keystream(5)
+	p := uint64(z[0])<<32 | uint64(z[1])
+	q := uint64(z[2])<<32 | uint64(z[3])
+
+	eval := uint64(0)
+	for i := 0; i*64 < length; i++ {
+		var block [8]byte
+		copy(block[:], message[i*8:bitsToBytes(length)])
+		m := binary.BigEndian.Uint64(block[:])
+		if remaining := length - i*64; remaining < 64 {
+			m &= ^uint64(0) << uint(64-remaining)
+		}
+		eval = mul64(eval^m, p)
+	}
+	eval ^= uint64(length)
+	eval = mul64(eval, q)
+
+	mac := make([]byte, 4)
+	binary.BigEndian.PutUint32(mac, uint32(eval>>32)^z[4])
+	return mac
+}
+
+// mul64 multiplies in GF(2^64) defined by x^64 + x^4 + x^3 + x + 1
+func mul64(v, p uint64) uint64 {
+	product := uint64(0)
+	for ; p != 0; p >>= 1 {
+		if p&1 != 0 {
+			product ^= v
+		}
+		if v&(1<<63) != 0 {
+			v = v<<1 ^ 0x1b
+		} else {
+			v <<= 1
+		}
+	}
+	return product
+}
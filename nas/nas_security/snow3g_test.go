@@ -0,0 +1,73 @@
+package nas_security
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.Nil(t, err)
+	return b
+}
+
+func decodeKey(t *testing.T, s string) (key [16]byte) {
+	copy(key[:], decodeHex(t, s))
+	return key
+}
+
+// TS 35.216 Annex, test set 1
+func TestSnow3gKeystream(t *testing.T) {
+	g := newSnow3g([4]uint32{0x2bd6459f, 0x82c5b300, 0x952c4910, 0x4881ff48},
+		[4]uint32{0xea024714, 0xad5c4d84, 0xdf1f9b25, 0x1c0bf45f})
+	assert.Equal(t, []uint32{0xabee9704, 0x7ac31373}, g.keystream(2))
+}
+
+// TS 35.217 4.3, UEA2 test set 1
+func TestNEA1(t *testing.T) {
+	key := decodeKey(t, "d3c5d592327fb11c4035c6680af8c6d1")
+	plaintext := "981ba6824c1bfb1ab485472029b71d808ce33e2cc3c0b5fc1f3de8a6dc66b1f0"
+	ciphertext := "5d5bfe75eb04f68ce0a12377ea00b37d47c6a0ba06309155086a859c4341b378"
+
+	data := decodeHex(t, plaintext)
+	NEA1(key, 0x398a59b4, 0x15, 1, data, 253)
+	assert.Equal(t, ciphertext, hex.EncodeToString(data))
+
+	NEA1(key, 0x398a59b4, 0x15, 1, data, 253)
+	assert.Equal(t, plaintext, hex.EncodeToString(data))
+}
+
+// TS 35.217 5.3, UIA2 test set 1
+func TestUia2(t *testing.T) {
+	key := decodeKey(t, "2bd6459f82c5b300952c49104881ff48")
+	message := decodeHex(t, "6b227737296f393c8079353edc87e2e805d2ec49a4f2d8e0")
+	assert.Equal(t, "2bce1820", hex.EncodeToString(uia2(key, 0x38a6f056, 0x05d2ec49, 0, message, 189)))
+}
+
+// TS 33.401 Annex C.4, 128-EIA1 test sets 1, 2, 3 and 5 (128-NIA1 is 128-EIA1, TS 33.501 Annex D)
+func TestNIA1(t *testing.T) {
+	key := decodeKey(t, "2bd6459f82c5b300952c49104881ff48")
+	message := decodeHex(t, "3332346263393861373479")
+	assert.Equal(t, "731f1165", hex.EncodeToString(NIA1(key, 0x38a6f056, 0x1f, 0, message, 88)))
+
+	mac32, err := NASMacCalculate(1, key, 0x38a6f056, 0x1f, 0, message)
+	require.Nil(t, err)
+	assert.Equal(t, "731f1165", hex.EncodeToString(mac32))
+
+	key = decodeKey(t, "7e5e94431e11d73828d739cc6ced4573")
+	message = decodeHex(t, "b3d3c9170a4e1632f60f861013d22d84b726b6a278d802d1eeaf1321ba5929dc")
+	assert.Equal(t, "e3259f6f", hex.EncodeToString(NIA1(key, 0x36af6144, 0x18, 1, message, 254)))
+
+	key = decodeKey(t, "d3419be821087acd02123a9248033359")
+	message = decodeHex(t, "bbb057038809496bcff86d6fbc8ce5b135a06b166054f2d565be8ace75dc851e"+
+		"0bcdd8f07141c495872fb5d8c0c66a8b6da556663e4e461205d84580bee5bc7e")
+	assert.Equal(t, "9a16c77d", hex.EncodeToString(NIA1(key, 0xc7590ea9, 0x17, 0, message, 511)))
+
+	key = decodeKey(t, "6832a65cff4473621ebdd4ba26a921fe")
+	message = decodeHex(t, "d3c53839626820717765667620323837636240981ba6824c1bfb1ab485472029"+
+		"b71d808ce33e2cc3c0b5fc1f3de8a6dc")
+	assert.Equal(t, "4145e4b0", hex.EncodeToString(NIA1(key, 0x36af6144, 0x18, 0, message, 383)))
+}
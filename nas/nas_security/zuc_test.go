@@ -0,0 +1,50 @@
+package nas_security
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TS 35.222 Annex A, test sets 1 and 2
+func TestZucKeystream(t *testing.T) {
+	var key, iv [16]byte
+	assert.Equal(t, []uint32{0x27bede74, 0x018082da}, newZuc(key, iv).keystream(2))
+
+	for i := range key {
+		key[i], iv[i] = 0xff, 0xff
+	}
+	assert.Equal(t, []uint32{0x0657cfa0, 0x7096398b}, newZuc(key, iv).keystream(2))
+}
+
+// TS 35.223 4.3, test set 1
+func TestNEA3(t *testing.T) {
+	key := decodeKey(t, "173d14ba5003731d7a60049470f00a29")
+	plaintext := "6cf65340735552ab0c9752fa6f9025fe0bd675d9005875b200000000"
+	ciphertext := "a6c85fc66afb8533aafc2518dfe784940ee1e4b030238cc800000000"
+
+	data := decodeHex(t, plaintext)
+	NEA3(key, 0x66035492, 0xf, 0, data, 193)
+	assert.Equal(t, ciphertext, hex.EncodeToString(data))
+
+	NEA3(key, 0x66035492, 0xf, 0, data, 193)
+	assert.Equal(t, plaintext, hex.EncodeToString(data))
+}
+
+// TS 35.223 5.4, test sets 1 and 2
+func TestNIA3(t *testing.T) {
+	var key [16]byte
+	assert.Equal(t, "c8a9595e", hex.EncodeToString(NIA3(key, 0, 0, 0, make([]byte, 4), 1)))
+
+	key = decodeKey(t, "47054125561eb2dda94059da05097850")
+	assert.Equal(t, "6719a088", hex.EncodeToString(NIA3(key, 0x561eb2dd, 0x14, 0, make([]byte, 12), 90)))
+
+	// a whole NAS message through the AES-CMAC entry point
+	message := decodeHex(t, "7e005c000d0100f110f0ff00001032547698")
+	mac32, err := NasMacCalculateByAesCmac(3, key[:], []byte{0x56, 0x1e, 0xb2, 0xdd}, 1, 1, message,
+		int32(len(message)*8))
+	require.Nil(t, err)
+	assert.Equal(t, NIA3(key, 0x561eb2dd, 1, 1, message, len(message)*8), mac32)
+}
@@ -0,0 +1,243 @@
+// Package capture writes the NGAP messages of the N2 interface to pcap files. Every message is
+// wrapped in synthetic IP and SCTP DATA chunk headers with the NGAP PPID so that Wireshark
+// decodes the files like a capture of the SCTP associations.
+package capture
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+)
+
+// Status of the capture as shown by OAM
+type Status struct {
+	Enable      bool     `json:"enable"`
+	Directory   string   `json:"directory"`
+	MaxFileSize int64    `json:"maxFileSize"`
+	MaxFiles    int      `json:"maxFiles"`
+	RanList     []string `json:"ranList"`
+	File        string   `json:"file,omitempty"`
+	Packets     uint64   `json:"packets"`
+}
+
+// association keeps the SCTP DATA chunk counters of one direction of an association
+type association struct {
+	verTag    uint32
+	tsn       uint32
+	streamSeq map[uint16]uint16
+}
+
+var (
+	// enabled is read on every message, the other state is guarded by mutex
+	enabled int32
+
+	mutex        sync.Mutex
+	parameters   context.NgapCaptureParameters
+	ranFilter    map[string]bool
+	writer       *pcapWriter
+	packets      uint64
+	ipId         uint16
+	associations = make(map[net.Conn]*[2]association)
+)
+
+const (
+	uplink   = 0
+	downlink = 1
+)
+
+// Start (re)starts the capture with the parameters, the capture is stopped if Enable is false
+func Start(captureParameters context.NgapCaptureParameters) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if err := stopLocked(); err != nil {
+		logger.NgapLog.Warnf("Close pcap file error: %+v", err)
+	}
+	parameters = captureParameters
+	ranFilter = make(map[string]bool)
+	for _, ranId := range parameters.RanList {
+		ranFilter[ranId] = true
+	}
+	if !parameters.Enable {
+		return nil
+	}
+
+	w, err := newPcapWriter(parameters.Directory, parameters.MaxFileSize, parameters.MaxFiles)
+	if err != nil {
+		parameters.Enable = false
+		return fmt.Errorf("NGAP capture: %+v", err)
+	}
+	writer = w
+	packets = 0
+	atomic.StoreInt32(&enabled, 1)
+	logger.NgapLog.Infof("NGAP capture started in %s", parameters.Directory)
+	return nil
+}
+
+func Stop() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	parameters.Enable = false
+	return stopLocked()
+}
+
+func stopLocked() error {
+	atomic.StoreInt32(&enabled, 0)
+	if writer == nil {
+		return nil
+	}
+	err := writer.close()
+	writer = nil
+	logger.NgapLog.Infof("NGAP capture stopped after %d packets", packets)
+	return err
+}
+
+func GetStatus() Status {
+	mutex.Lock()
+	defer mutex.Unlock()
+	status := Status{
+		Enable:      parameters.Enable,
+		Directory:   parameters.Directory,
+		MaxFileSize: parameters.MaxFileSize,
+		MaxFiles:    parameters.MaxFiles,
+		RanList:     parameters.RanList,
+		Packets:     packets,
+	}
+	if writer != nil {
+		status.File = writer.fileName()
+	}
+	return status
+}
+
+// Uplink captures a message received from the RAN of conn
+func Uplink(conn net.Conn, streamId uint16, msg []byte) {
+	if atomic.LoadInt32(&enabled) == 0 {
+		return
+	}
+	capturePacket(conn, uplink, streamId, msg)
+}
+
+// Downlink captures a message sent to the RAN of conn
+func Downlink(conn net.Conn, streamId uint16, msg []byte) {
+	if atomic.LoadInt32(&enabled) == 0 {
+		return
+	}
+	capturePacket(conn, downlink, streamId, msg)
+}
+
+// Remove drops the counters of a closed association
+func Remove(conn net.Conn) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	delete(associations, conn)
+}
+
+func capturePacket(conn net.Conn, direction int, streamId uint16, msg []byte) {
+	if !selected(conn) {
+		return
+	}
+	ran, ocf := addrToEndpoint(conn.RemoteAddr()), addrToEndpoint(conn.LocalAddr())
+	timestamp := time.Now()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if writer == nil {
+		return
+	}
+
+	assoc, ok := associations[conn]
+	if !ok {
+		assoc = newAssociation(conn)
+		associations[conn] = assoc
+	}
+	counters := &assoc[direction]
+	p := &packet{
+		timestamp: timestamp,
+		verTag:    counters.verTag,
+		tsn:       counters.tsn,
+		streamId:  streamId,
+		streamSeq: counters.streamSeq[streamId],
+		ipId:      ipId,
+		payload:   msg,
+	}
+	counters.tsn++
+	counters.streamSeq[streamId]++
+	ipId++
+	if direction == uplink {
+		p.src, p.dst = ran, ocf
+	} else {
+		p.src, p.dst = ocf, ran
+	}
+
+	if err := writer.write(p); err != nil {
+		logger.NgapLog.Errorf("NGAP capture write error: %+v", err)
+		return
+	}
+	packets++
+}
+
+// selected filters by the Global RAN Node ID. The RAN ID is not known before the NG Setup, so
+// associations without one are captured in order not to miss their NG Setup.
+func selected(conn net.Conn) bool {
+	mutex.Lock()
+	filter := ranFilter
+	mutex.Unlock()
+	if len(filter) == 0 {
+		return true
+	}
+	ran, ok := context.OCF_Self().OcfRanFindByConn(conn)
+	if !ok {
+		return true
+	}
+	ranId := ran.RanIdString()
+	return ranId == "" || filter[ranId]
+}
+
+// newAssociation gives every direction its own verification tag, the tags are derived from the
+// addresses so they are stable across file rotations
+func newAssociation(conn net.Conn) *[2]association {
+	assoc := new([2]association)
+	for direction := range assoc {
+		hash := fnv.New32a()
+		fmt.Fprintf(hash, "%s-%s-%d", conn.RemoteAddr(), conn.LocalAddr(), direction)
+		assoc[direction] = association{
+			verTag:    hash.Sum32(),
+			tsn:       1,
+			streamSeq: make(map[uint16]uint16),
+		}
+	}
+	return assoc
+}
+
+// addrToEndpoint takes the primary address of an SCTP address ("ip1/ip2:port") or the address of
+// a TCP connection, other addresses are shown as the loopback address
+func addrToEndpoint(addr net.Addr) endpoint {
+	ep := endpoint{ip: net.IPv4(127, 0, 0, 1)}
+	if addr == nil {
+		return ep
+	}
+	value := addr.String()
+	index := strings.LastIndex(value, ":")
+	if index < 0 {
+		return ep
+	}
+	if port, err := strconv.ParseUint(value[index+1:], 10, 16); err == nil {
+		ep.port = uint16(port)
+	}
+	host := strings.Trim(value[:index], "[]")
+	if slash := strings.Index(host, "/"); slash >= 0 {
+		host = host[:slash]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		ep.ip = ip
+	}
+	return ep
+}
@@ -0,0 +1,232 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// pcap file format with raw IP packets, the link type Wireshark decodes without any setting
+const (
+	pcapMagic        uint32 = 0xa1b2c3d4
+	pcapVersionMajor uint16 = 2
+	pcapVersionMinor uint16 = 4
+	pcapSnapLen      uint32 = 65535
+	linkTypeRaw      uint32 = 101
+)
+
+const (
+	ipProtocolSctp    = 132
+	sctpChunkTypeData = 0
+	// beginning and ending fragment, every NGAP message is carried by one DATA chunk
+	sctpDataFlags = 0x03
+	// NGAP payload protocol identifier (TS 38.412 7)
+	ngapPpid uint32 = 60
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// sequence numbers the files of all the writers, a restarted capture does not reuse the name of a
+// file written in the same second
+var sequence int
+
+// endpoint is one side of an association in the synthetic headers
+type endpoint struct {
+	ip   net.IP
+	port uint16
+}
+
+// packet is one NGAP message with the SCTP DATA chunk parameters of its association
+type packet struct {
+	timestamp time.Time
+	src, dst  endpoint
+	verTag    uint32
+	tsn       uint32
+	streamId  uint16
+	streamSeq uint16
+	ipId      uint16
+	payload   []byte
+}
+
+// encode returns the IPv4 or IPv6 packet with the SCTP common header and one DATA chunk
+func (p *packet) encode() []byte {
+	padding := (4 - len(p.payload)%4) % 4
+	chunkLen := 16 + len(p.payload)
+	sctpLen := 12 + chunkLen + padding
+
+	sctp := make([]byte, sctpLen)
+	binary.BigEndian.PutUint16(sctp[0:2], p.src.port)
+	binary.BigEndian.PutUint16(sctp[2:4], p.dst.port)
+	binary.BigEndian.PutUint32(sctp[4:8], p.verTag)
+	chunk := sctp[12:]
+	chunk[0] = sctpChunkTypeData
+	chunk[1] = sctpDataFlags
+	binary.BigEndian.PutUint16(chunk[2:4], uint16(chunkLen))
+	binary.BigEndian.PutUint32(chunk[4:8], p.tsn)
+	binary.BigEndian.PutUint16(chunk[8:10], p.streamId)
+	binary.BigEndian.PutUint16(chunk[10:12], p.streamSeq)
+	binary.BigEndian.PutUint32(chunk[12:16], ngapPpid)
+	copy(chunk[16:], p.payload)
+	// the CRC32c of SCTP is stored in little endian (RFC 4960 Appendix B)
+	binary.LittleEndian.PutUint32(sctp[8:12], crc32.Checksum(sctp, castagnoli))
+
+	src4, dst4 := p.src.ip.To4(), p.dst.ip.To4()
+	if src4 != nil && dst4 != nil {
+		ip := make([]byte, 20, 20+sctpLen)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+sctpLen))
+		binary.BigEndian.PutUint16(ip[4:6], p.ipId)
+		binary.BigEndian.PutUint16(ip[6:8], 0x4000) // don't fragment
+		ip[8] = 64
+		ip[9] = ipProtocolSctp
+		copy(ip[12:16], src4)
+		copy(ip[16:20], dst4)
+		binary.BigEndian.PutUint16(ip[10:12], ipv4Checksum(ip))
+		return append(ip, sctp...)
+	}
+
+	ip := make([]byte, 40, 40+sctpLen)
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:6], uint16(sctpLen))
+	ip[6] = ipProtocolSctp
+	ip[7] = 64
+	copy(ip[8:24], p.src.ip.To16())
+	copy(ip[24:40], p.dst.ip.To16())
+	return append(ip, sctp...)
+}
+
+func ipv4Checksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i : i+2]))
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
+
+// pcapWriter writes the packets to files of at most maxFileSize bytes in dir, only the
+// maxFiles newest capture files of dir are kept, including those of the previous writers
+type pcapWriter struct {
+	dir         string
+	maxFileSize int64
+	maxFiles    int
+
+	file  *os.File
+	size  int64
+	files []string // the oldest first
+}
+
+func newPcapWriter(dir string, maxFileSize int64, maxFiles int) (*pcapWriter, error) {
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, err
+	}
+	files, err := captureFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &pcapWriter{
+		dir:         dir,
+		maxFileSize: maxFileSize,
+		maxFiles:    maxFiles,
+		files:       files,
+	}
+	if err := w.rotate(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *pcapWriter) fileName() string {
+	if w.file == nil {
+		return ""
+	}
+	return w.file.Name()
+}
+
+func (w *pcapWriter) rotate() error {
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	sequence++
+	name := filepath.Join(w.dir, fmt.Sprintf("ngap-%s-%04d.pcap", time.Now().Format("20060102-150405"),
+		sequence))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:6], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(header[6:8], pcapVersionMinor)
+	binary.LittleEndian.PutUint32(header[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], linkTypeRaw)
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = int64(len(header))
+	w.files = append(w.files, name)
+	for w.maxFiles > 0 && len(w.files) > w.maxFiles {
+		if err := os.Remove(w.files[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		w.files = w.files[1:]
+	}
+	return nil
+}
+
+// captureFiles returns the capture files in dir, the oldest first
+func captureFiles(dir string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "ngap-*.pcap"))
+	if err != nil {
+		return nil, err
+	}
+	modTimes := make(map[string]time.Time, len(names))
+	for _, name := range names {
+		if info, err := os.Stat(name); err == nil {
+			modTimes[name] = info.ModTime()
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		return modTimes[names[i]].Before(modTimes[names[j]])
+	})
+	return names, nil
+}
+
+func (w *pcapWriter) write(p *packet) error {
+	data := p.encode()
+	if w.maxFileSize > 0 && w.size+16+int64(len(data)) > w.maxFileSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, 16, 16+len(data))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(p.timestamp.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(p.timestamp.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(data)))
+	n, err := w.file.Write(append(record, data...))
+	w.size += int64(n)
+	return err
+}
+
+func (w *pcapWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,98 @@
+package capture
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPacket(payload []byte) *packet {
+	return &packet{
+		timestamp: time.Now(),
+		src:       endpoint{ip: net.ParseIP("10.0.0.1"), port: 9487},
+		dst:       endpoint{ip: net.ParseIP("10.0.0.2"), port: 38412},
+		verTag:    0x01020304,
+		tsn:       7,
+		streamId:  1,
+		payload:   payload,
+	}
+}
+
+func TestPacketEncode(t *testing.T) {
+	payload := []byte{0x00, 0x15, 0x00, 0x33, 0x00}
+	data := testPacket(payload).encode()
+
+	// IPv4 header
+	assert.Equal(t, uint8(0x45), data[0])
+	assert.Equal(t, uint16(len(data)), binary.BigEndian.Uint16(data[2:4]))
+	assert.Equal(t, uint8(ipProtocolSctp), data[9])
+	assert.Equal(t, uint16(0), ipv4Checksum(data[0:20]))
+	assert.Equal(t, net.ParseIP("10.0.0.1").To4(), net.IP(data[12:16]))
+
+	sctp := data[20:]
+	assert.Equal(t, uint16(9487), binary.BigEndian.Uint16(sctp[0:2]))
+	assert.Equal(t, uint16(38412), binary.BigEndian.Uint16(sctp[2:4]))
+	checksum := binary.LittleEndian.Uint32(sctp[8:12])
+	withoutChecksum := append([]byte{}, sctp...)
+	binary.LittleEndian.PutUint32(withoutChecksum[8:12], 0)
+	assert.Equal(t, crc32.Checksum(withoutChecksum, castagnoli), checksum)
+
+	chunk := sctp[12:]
+	assert.Equal(t, uint8(sctpChunkTypeData), chunk[0])
+	assert.Equal(t, uint16(16+len(payload)), binary.BigEndian.Uint16(chunk[2:4]))
+	assert.Equal(t, uint32(7), binary.BigEndian.Uint32(chunk[4:8]))
+	assert.Equal(t, uint16(1), binary.BigEndian.Uint16(chunk[8:10]))
+	assert.Equal(t, uint32(60), binary.BigEndian.Uint32(chunk[12:16]))
+	assert.Equal(t, payload, chunk[16:16+len(payload)])
+	// the chunk is padded to 4 octets
+	assert.Equal(t, 0, len(chunk)%4)
+}
+
+func TestPcapWriterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ngap-capture")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := newPcapWriter(dir, 200, 2)
+	assert.Nil(t, err)
+	for i := 0; i < 6; i++ {
+		assert.Nil(t, w.write(testPacket(make([]byte, 100))))
+	}
+	assert.Nil(t, w.close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "ngap-*.pcap"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	content, err := ioutil.ReadFile(files[len(files)-1])
+	assert.Nil(t, err)
+	assert.Equal(t, pcapMagic, binary.LittleEndian.Uint32(content[0:4]))
+	assert.Equal(t, linkTypeRaw, binary.LittleEndian.Uint32(content[20:24]))
+	recordLen := binary.LittleEndian.Uint32(content[24+8 : 24+12])
+	assert.Equal(t, 24+16+int(recordLen), len(content))
+}
+
+func TestPcapWriterRetentionAcrossWriters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ngap-capture")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// a restarted capture counts the files of the previous one
+	for i := 0; i < 3; i++ {
+		w, err := newPcapWriter(dir, 200, 2)
+		assert.Nil(t, err)
+		assert.Nil(t, w.write(testPacket(make([]byte, 100))))
+		assert.Nil(t, w.close())
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "ngap-*.pcap"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+}
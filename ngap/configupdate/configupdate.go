@@ -0,0 +1,294 @@
+// Package configupdate pushes the changes of the OCF configuration to the RANs with OCF
+// Configuration Update (TS 38.413 8.7.3) so that a change does not need a new NG Setup. The answer
+// of each RAN is tracked: a RAN which fails the update with a Time To Wait gets it again once the
+// time is over, a RAN which does not answer gets it again after the ocfConfigurationUpdate timer.
+package configupdate
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+	ngap_message "free5gc/src/ocf/ngap/message"
+)
+
+// Configuration is the part of the OCF configuration the RANs learn at NG Setup
+type Configuration struct {
+	Name             string
+	ServedGuamiList  []models.Guami
+	RelativeCapacity int64
+	PlmnSupportList  []context.PlmnSupportItem
+}
+
+type State string
+
+const (
+	StatePending      State = "pending"
+	StateAcknowledged State = "acknowledged"
+	StateFailed       State = "failed"
+)
+
+// RanStatus is the state of the latest OCF Configuration Update sent to a RAN
+type RanStatus struct {
+	RanId    string     `json:"ranId"`
+	State    State      `json:"state"`
+	Attempts int        `json:"attempts"`
+	Cause    string     `json:"cause,omitempty"`
+	RetryAt  *time.Time `json:"retryAt,omitempty"`
+	Updated  time.Time  `json:"updated"`
+}
+
+type update struct {
+	ies    ngap_message.OCFConfigurationUpdateIEs
+	status RanStatus
+	// a timer which fires after being replaced does nothing
+	timer *time.Timer
+}
+
+var (
+	mutex   sync.Mutex
+	updates = make(map[*context.OcfRan]*update)
+)
+
+// minTimeToWait is the wait before the retry of an update failed with a Time To Wait of unknown value
+const minTimeToWait = 1 * time.Second
+
+var timeToWaitDurations = map[int64]time.Duration{
+	int64(ngapType.TimeToWaitPresentV1s):  1 * time.Second,
+	int64(ngapType.TimeToWaitPresentV2s):  2 * time.Second,
+	int64(ngapType.TimeToWaitPresentV5s):  5 * time.Second,
+	int64(ngapType.TimeToWaitPresentV10s): 10 * time.Second,
+	int64(ngapType.TimeToWaitPresentV20s): 20 * time.Second,
+	int64(ngapType.TimeToWaitPresentV60s): 60 * time.Second,
+}
+
+var causeGroups = map[int]string{
+	ngapType.CausePresentRadioNetwork: "RadioNetwork",
+	ngapType.CausePresentTransport:    "Transport",
+	ngapType.CausePresentNas:          "Nas",
+	ngapType.CausePresentProtocol:     "Protocol",
+	ngapType.CausePresentMisc:         "Misc",
+}
+
+// Current returns the configuration of the OCF context
+func Current() Configuration {
+	parameters := context.OCF_Self().Parameters()
+	configuration := Configuration{
+		Name:             parameters.Name,
+		ServedGuamiList:  make([]models.Guami, len(parameters.ServedGuamiList)),
+		RelativeCapacity: parameters.RelativeCapacity,
+		PlmnSupportList:  make([]context.PlmnSupportItem, len(parameters.PlmnSupportList)),
+	}
+	copy(configuration.ServedGuamiList, parameters.ServedGuamiList)
+	copy(configuration.PlmnSupportList, parameters.PlmnSupportList)
+	return configuration
+}
+
+// Push sends OCF Configuration Update to every RAN which has completed its NG Setup if the
+// configuration of the OCF context differs from the previous one, it tells if it differs
+func Push(previous Configuration) bool {
+	ies, changed := changedIEs(previous, Current())
+	if !changed {
+		return false
+	}
+	mutex.Lock()
+	removeGoneRans()
+	mutex.Unlock()
+
+	rans := context.OCF_Self().SetUpOcfRans()
+	logger.NgapLog.Infof("OCF configuration changed, send OCF Configuration Update to %d RAN(s)", len(rans))
+	for _, ran := range rans {
+		start(ran, ies)
+	}
+	return true
+}
+
+// Acknowledged ends the update of the RAN on OCF Configuration Update Acknowledge
+func Acknowledged(ran *context.OcfRan) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	u, ok := updates[ran]
+	if !ok || u.status.State != StatePending {
+		logger.NgapLog.Warnf("RAN[%s] acknowledges an OCF Configuration Update which is not pending", ran.RanIdString())
+		return
+	}
+	stopTimer(u)
+	u.status.State = StateAcknowledged
+	u.status.Cause = ""
+	u.status.RetryAt = nil
+	u.status.Updated = time.Now()
+}
+
+// Failed handles OCF Configuration Update Failure, the update is sent again after timeToWait if
+// it is present
+func Failed(ran *context.OcfRan, cause *ngapType.Cause, timeToWait *ngapType.TimeToWait) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	u, ok := updates[ran]
+	if !ok || u.status.State != StatePending {
+		logger.NgapLog.Warnf("RAN[%s] fails an OCF Configuration Update which is not pending", ran.RanIdString())
+		return
+	}
+	stopTimer(u)
+	now := time.Now()
+	u.status.Cause = causeString(cause)
+	u.status.RetryAt = nil
+	u.status.Updated = now
+
+	if timeToWait == nil || u.status.Attempts > updateTimer(ran).MaxRetryTimes {
+		logger.NgapLog.Warnf("RAN[%s] fails the OCF Configuration Update (%s)", ran.RanIdString(), u.status.Cause)
+		u.status.State = StateFailed
+		return
+	}
+	wait, ok := timeToWaitDurations[int64(timeToWait.Value)]
+	if !ok {
+		wait = minTimeToWait
+	}
+	retryAt := now.Add(wait)
+	u.status.RetryAt = &retryAt
+	logger.NgapLog.Infof("RAN[%s] fails the OCF Configuration Update (%s), retry in %s",
+		ran.RanIdString(), u.status.Cause, wait)
+	var timer *time.Timer
+	timer = time.AfterFunc(wait, func() {
+		mutex.Lock()
+		if u.timer != timer {
+			mutex.Unlock()
+			return
+		}
+		u.status.RetryAt = nil
+		mutex.Unlock()
+		send(ran, u)
+	})
+	u.timer = timer
+}
+
+// GetStatus returns the state of the latest update of each RAN
+func GetStatus() []RanStatus {
+	mutex.Lock()
+	defer mutex.Unlock()
+	removeGoneRans()
+	status := make([]RanStatus, 0, len(updates))
+	for _, u := range updates {
+		status = append(status, u.status)
+	}
+	return status
+}
+
+func start(ran *context.OcfRan, ies ngap_message.OCFConfigurationUpdateIEs) {
+	mutex.Lock()
+	if u, ok := updates[ran]; ok {
+		stopTimer(u)
+		// the RAN has not got the IEs of the pending update yet
+		if u.status.State == StatePending {
+			ies.OCFName = ies.OCFName || u.ies.OCFName
+			ies.ServedGUAMIList = ies.ServedGUAMIList || u.ies.ServedGUAMIList
+			ies.RelativeOCFCapacity = ies.RelativeOCFCapacity || u.ies.RelativeOCFCapacity
+			ies.PLMNSupportList = ies.PLMNSupportList || u.ies.PLMNSupportList
+		}
+	}
+	u := &update{
+		ies:    ies,
+		status: RanStatus{RanId: ran.RanIdString(), State: StatePending},
+	}
+	updates[ran] = u
+	mutex.Unlock()
+
+	send(ran, u)
+}
+
+// send sends the update and waits for the answer of the RAN
+func send(ran *context.OcfRan, u *update) {
+	mutex.Lock()
+	if updates[ran] != u {
+		// replaced by a newer update
+		mutex.Unlock()
+		return
+	}
+	u.status.Attempts++
+	u.status.Updated = time.Now()
+	ies := u.ies
+	ocfConfigurationUpdate := updateTimer(ran)
+	var timer *time.Timer
+	timer = time.AfterFunc(ocfConfigurationUpdate.Value, func() {
+		mutex.Lock()
+		if u.timer != timer {
+			mutex.Unlock()
+			return
+		}
+		if u.status.Attempts > ocfConfigurationUpdate.MaxRetryTimes {
+			logger.NgapLog.Warnf("RAN[%s] does not answer the OCF Configuration Update, abort it", u.status.RanId)
+			u.timer = nil
+			u.status.State = StateFailed
+			u.status.Cause = "no answer"
+			u.status.Updated = time.Now()
+			mutex.Unlock()
+			return
+		}
+		mutex.Unlock()
+		logger.NgapLog.Warnf("RAN[%s] does not answer the OCF Configuration Update, send it again", u.status.RanId)
+		send(ran, u)
+	})
+	u.timer = timer
+	mutex.Unlock()
+
+	ngap_message.SendOCFConfigurationUpdate(ran, ies)
+}
+
+func updateTimer(ran *context.OcfRan) context.Timer {
+	timers := context.OCF_Self().Parameters().Timers
+	return timers.Timer(context.TimerOcfConfigurationUpdate, ran.AnType, nil)
+}
+
+func stopTimer(u *update) {
+	if u.timer != nil {
+		u.timer.Stop()
+		u.timer = nil
+	}
+}
+
+// changedIEs selects the IEs whose value has changed
+func changedIEs(previous, current Configuration) (ies ngap_message.OCFConfigurationUpdateIEs, changed bool) {
+	ies.OCFName = previous.Name != current.Name
+	ies.ServedGUAMIList = !reflect.DeepEqual(previous.ServedGuamiList, current.ServedGuamiList)
+	ies.RelativeOCFCapacity = previous.RelativeCapacity != current.RelativeCapacity
+	ies.PLMNSupportList = !reflect.DeepEqual(previous.PlmnSupportList, current.PlmnSupportList)
+	changed = ies.OCFName || ies.ServedGUAMIList || ies.RelativeOCFCapacity || ies.PLMNSupportList
+	return
+}
+
+func causeString(cause *ngapType.Cause) string {
+	if cause == nil {
+		return "unknown"
+	}
+	var value int64
+	switch cause.Present {
+	case ngapType.CausePresentRadioNetwork:
+		value = int64(cause.RadioNetwork.Value)
+	case ngapType.CausePresentTransport:
+		value = int64(cause.Transport.Value)
+	case ngapType.CausePresentNas:
+		value = int64(cause.Nas.Value)
+	case ngapType.CausePresentProtocol:
+		value = int64(cause.Protocol.Value)
+	case ngapType.CausePresentMisc:
+		value = int64(cause.Misc.Value)
+	default:
+		return "unknown"
+	}
+	return fmt.Sprintf("%s[%d]", causeGroups[cause.Present], value)
+}
+
+// removeGoneRans forgets the updates of the RANs which have been removed
+func removeGoneRans() {
+	for ran, u := range updates {
+		if found, ok := context.OCF_Self().OcfRanFindByConn(ran.Conn); !ok || found != ran {
+			stopTimer(u)
+			delete(updates, ran)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package configupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/aper"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	ngap_message "free5gc/src/ocf/ngap/message"
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+func TestChangedIEs(t *testing.T) {
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	previous := Configuration{
+		Name:             "OCF",
+		ServedGuamiList:  []models.Guami{{PlmnId: &plmnId, OcfId: "cafe00"}},
+		RelativeCapacity: 0xff,
+		PlmnSupportList: []context.PlmnSupportItem{{
+			PlmnId:     plmnId,
+			SNssaiList: []models.Snssai{{Sst: 1, Sd: "010203"}},
+		}},
+	}
+
+	_, changed := changedIEs(previous, previous)
+	assert.False(t, changed)
+
+	current := previous
+	current.RelativeCapacity = 0x80
+	current.PlmnSupportList = []context.PlmnSupportItem{{
+		PlmnId:     plmnId,
+		SNssaiList: []models.Snssai{{Sst: 1, Sd: "010203"}, {Sst: 2}},
+	}}
+	ies, changed := changedIEs(previous, current)
+	assert.True(t, changed)
+	assert.Equal(t, ngap_message.OCFConfigurationUpdateIEs{RelativeOCFCapacity: true, PLMNSupportList: true}, ies)
+}
+
+func TestCauseString(t *testing.T) {
+	assert.Equal(t, "unknown", causeString(nil))
+	assert.Equal(t, "Misc[1]", causeString(&ngapType.Cause{
+		Present: ngapType.CausePresentMisc,
+		Misc:    &ngapType.CauseMisc{Value: aper.Enumerated(1)},
+	}))
+}
+
+func expectUpdate(t *testing.T, memRan *ngap_service.MemRan, timeout time.Duration) {
+	_, err := memRan.Expect(ngapType.ProcedureCodeOCFConfigurationUpdate, timeout)
+	require.NoError(t, err)
+}
+
+func ranStatus(t *testing.T, ran *context.OcfRan) RanStatus {
+	for _, status := range GetStatus() {
+		if status.RanId == ran.RanIdString() {
+			return status
+		}
+	}
+	t.Fatalf("no update of RAN[%s]", ran.RanIdString())
+	return RanStatus{}
+}
+
+func TestRetryAndFailure(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.Timers = context.DefaultTimerParameters()
+		parameters.Timers.Default[context.TimerOcfConfigurationUpdate] = context.Timer{
+			Value:         200 * time.Millisecond,
+			MaxRetryTimes: 2,
+		}
+	})
+	setRelativeCapacity := func(relativeCapacity int64) {
+		amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+			parameters.RelativeCapacity = relativeCapacity
+		})
+	}
+
+	memRan, err := ngap_service.NewMemRan(models.AccessType__3_GPP_ACCESS)
+	require.NoError(t, err)
+	defer memRan.Close()
+	ran := memRan.Ran
+
+	t.Run("No answer", func(t *testing.T) {
+		previous := Current()
+		setRelativeCapacity(0x80)
+		assert.True(t, Push(previous))
+		assert.False(t, Push(Current()))
+
+		// the first update and its 2 retransmissions
+		for i := 0; i < 3; i++ {
+			expectUpdate(t, memRan, time.Second)
+		}
+		time.Sleep(300 * time.Millisecond)
+		status := ranStatus(t, ran)
+		assert.Equal(t, StateFailed, status.State)
+		assert.Equal(t, 3, status.Attempts)
+		assert.Equal(t, "no answer", status.Cause)
+		assert.Empty(t, memRan.PDUs)
+	})
+
+	t.Run("Time To Wait", func(t *testing.T) {
+		previous := Current()
+		setRelativeCapacity(0x40)
+		assert.True(t, Push(previous))
+		expectUpdate(t, memRan, time.Second)
+
+		cause := &ngapType.Cause{
+			Present: ngapType.CausePresentMisc,
+			Misc:    &ngapType.CauseMisc{Value: ngapType.CauseMiscPresentControlProcessingOverload},
+		}
+		// an unknown value waits at least minTimeToWait
+		Failed(ran, cause, &ngapType.TimeToWait{Value: aper.Enumerated(99)})
+		status := ranStatus(t, ran)
+		assert.Equal(t, StatePending, status.State)
+		require.NotNil(t, status.RetryAt)
+		assert.True(t, time.Until(*status.RetryAt) > minTimeToWait/2)
+
+		expectUpdate(t, memRan, 2*minTimeToWait)
+		Acknowledged(ran)
+		status = ranStatus(t, ran)
+		assert.Equal(t, StateAcknowledged, status.State)
+		assert.Equal(t, 2, status.Attempts)
+		assert.Nil(t, status.RetryAt)
+		assert.Empty(t, status.Cause)
+	})
+
+	t.Run("Failure without Time To Wait", func(t *testing.T) {
+		previous := Current()
+		setRelativeCapacity(0x20)
+		assert.True(t, Push(previous))
+		expectUpdate(t, memRan, time.Second)
+
+		Failed(ran, nil, nil)
+		status := ranStatus(t, ran)
+		assert.Equal(t, StateFailed, status.State)
+		assert.Equal(t, "unknown", status.Cause)
+		assert.Nil(t, status.RetryAt)
+
+		// the answers to an update which is not pending are ignored
+		Acknowledged(ran)
+		assert.Equal(t, StateFailed, ranStatus(t, ran).State)
+		time.Sleep(300 * time.Millisecond)
+		assert.Empty(t, memRan.PDUs)
+	})
+}
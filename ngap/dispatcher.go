@@ -3,8 +3,12 @@ package ngap
 import (
 	"free5gc/lib/ngap"
 	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/consumer"
 	"free5gc/src/ocf/context"
 	"free5gc/src/ocf/logger"
+	ngap_service "free5gc/src/ocf/ngap/service"
+	"free5gc/src/ocf/producer/callback"
 	"net"
 
 	"github.com/sirupsen/logrus"
@@ -16,7 +20,7 @@ func init() {
 	Ngaplog = logger.NgapLog
 }
 
-func Dispatch(conn net.Conn, msg []byte) {
+func Dispatch(conn net.Conn, streamId uint16, msg []byte) {
 	var ran *context.OcfRan
 	amfSelf := context.OCF_Self()
 
@@ -27,8 +31,11 @@ func Dispatch(conn net.Conn, msg []byte) {
 	}
 
 	if len(msg) == 0 {
-		Ngaplog.Infof("RAN[ID: %+v] close the connection.", ran.RanId)
-		ran.Remove()
+		ngap_service.Submit(ran, func() {
+			Ngaplog.Infof("RAN[ID: %+v] close the connection.", ran.RanId)
+			drainUeLanes(ran)
+			ran.Remove()
+		})
 		return
 	}
 
@@ -38,6 +45,141 @@ func Dispatch(conn net.Conn, msg []byte) {
 		return
 	}
 
+	key := laneKey(ran, pdu)
+	if key == ran && streamId != ngap_service.NonUeAssociatedStreamId {
+		Ngaplog.Warnf("Non-UE-associated signalling received on stream %d instead of stream %d",
+			streamId, ngap_service.NonUeAssociatedStreamId)
+	}
+
+	ngap_service.Submit(key, func() {
+		dispatchPdu(ran, pdu)
+	})
+}
+
+// HandleSCTPNotification cleans up the RAN after its SCTP association is gone:
+//   - COMM_LOST, SHUTDOWN_COMP, CANT_STR_ASSOC and SHUTDOWN_EVENT: the UEs of the RAN go to CM-IDLE
+//     and the RAN is removed
+//   - RESTART: the peer has lost its UE contexts, the UEs of the RAN go to CM-IDLE but the RAN is kept
+//   - PEER_ADDR_CHANGE: the association survives the loss of a path, it is only logged
+func HandleSCTPNotification(conn net.Conn, notification *ngap_service.Notification) {
+	amfSelf := context.OCF_Self()
+
+	ran, ok := amfSelf.OcfRanFindByConn(conn)
+	if !ok {
+		Ngaplog.Debugf("No RAN context for SCTP association[peer: %s]", conn.RemoteAddr())
+		return
+	}
+
+	switch {
+	case notification.AssociationEnded():
+		ngap_service.Submit(ran, func() {
+			Ngaplog.Infof("RAN[ID: %+v] association ended by %s, remove the RAN", ran.RanId, notification)
+			releaseRanUesToIdle(ran)
+			ran.Remove()
+		})
+	case notification.Type == ngap_service.SCTP_ASSOC_CHANGE &&
+		notification.AssocState == ngap_service.SCTP_RESTART:
+		ngap_service.Submit(ran, func() {
+			Ngaplog.Infof("RAN[ID: %+v] association restarted, release all UEs of the RAN", ran.RanId)
+			releaseRanUesToIdle(ran)
+		})
+	}
+}
+
+// releaseRanUesToIdle moves the UEs of the RAN to CM-IDLE without N2 signalling: the SMFs
+// deactivate the user plane of the PDU sessions on this access, the N2 connection is removed
+// and the connectivity state change is reported to the event subscribers
+func releaseRanUesToIdle(ran *context.OcfRan) {
+	cause := context.CauseAll{
+		NgapCause: &models.NgApCause{
+			Group: int32(ngapType.CausePresentTransport),
+			Value: int32(ngapType.CauseTransportPresentTransportResourceUnavailable),
+		},
+	}
+
+	drainUeLanes(ran)
+	for _, ranUe := range ran.RanUes() {
+		amfUe := ranUe.OcfUe
+		if amfUe == nil {
+			if err := ranUe.Remove(); err != nil {
+				Ngaplog.Errorln(err.Error())
+			}
+			continue
+		}
+
+		if amfUe.State[ran.AnType].Is(context.Registered) {
+			for pduSessionID, smContext := range amfUe.SmContextList {
+				if smContext.PduSessionContext == nil || smContext.PduSessionContext.AccessType != ran.AnType {
+					continue
+				}
+				response, _, _, err := consumer.SendUpdateSmContextDeactivateUpCnxState(amfUe, pduSessionID, cause)
+				if err != nil {
+					Ngaplog.Errorf("Send Update SmContextDeactivate UpCnxState Error[%s]", err.Error())
+				} else if response == nil {
+					Ngaplog.Errorln("Send Update SmContextDeactivate UpCnxState Error")
+				}
+			}
+		}
+
+		Ngaplog.Infof("UE[%s] of RAN[ID: %+v] enters CM-IDLE", amfUe.Supi, ran.RanId)
+		if err := ranUe.Remove(); err != nil {
+			Ngaplog.Errorln(err.Error())
+		}
+		callback.SendOcfEventReportNotify(amfUe, models.OcfEventType_CONNECTIVITY_STATE_REPORT)
+	}
+}
+
+// releaseStaleRan tears down the old association of a RAN which has set up a new association:
+// no more message is read from the old association and its UEs go to CM-IDLE
+func releaseStaleRan(ran *context.OcfRan) {
+	Ngaplog.Warnf("RAN[ID: %+v] set up on a new association, release the association[peer: %s]",
+		ran.RanId, ran.Conn.RemoteAddr())
+	if err := ran.Conn.Close(); err != nil {
+		Ngaplog.Errorf("Close association error: %+v", err)
+	}
+	// the lookups by Global RAN Node ID find the new association from now on
+	context.OCF_Self().DeleteOcfRan(ran.Conn)
+	ngap_service.Post(ran, func() {
+		releaseRanUesToIdle(ran)
+		ran.Remove()
+	})
+}
+
+// drainUeLanes waits for the lanes of the UEs of the RAN to handle the messages already read from the
+// association, the RAN UEs are not removed under a running handler. The lane of a UE switched from
+// another RAN is drained with the RAN the UE is on now.
+func drainUeLanes(ran *context.OcfRan) {
+	ngap_service.Drain(func(key interface{}) bool {
+		ueLaneKey, ok := key.(context.UeLaneKey)
+		return ok && context.LaneRan(ueLaneKey) == ran
+	})
+}
+
+// laneKey selects the worker lane of a PDU: UE-associated signalling is serialized per UE,
+// non-UE-associated signalling (NG Setup, NG Reset, RAN Configuration Update, ...) per RAN.
+// A UE keeps the lane it got at its first message (see RanUe.LaneKey), the messages already
+// queued for it are never overtaken by the ones carrying the OCF UE NGAP ID.
+func laneKey(ran *context.OcfRan, pdu *ngapType.NGAPPDU) interface{} {
+	amfUeNgapId, ranUeNgapId := ngap_service.UeNgapIds(pdu)
+	if amfUeNgapId != nil {
+		if ranUe := context.OCF_Self().RanUeFindByOcfUeNgapID(*amfUeNgapId); ranUe != nil {
+			return ranUe.LaneKey
+		}
+	}
+	if ranUeNgapId != nil {
+		if ranUe := ran.RanUeFindByRanUeNgapID(*ranUeNgapId); ranUe != nil {
+			return ranUe.LaneKey
+		}
+		return context.UeLaneKey{Ran: ran, RanUeNgapId: *ranUeNgapId}
+	}
+	if amfUeNgapId != nil {
+		// the UE is unknown, the handler answers with an error
+		return context.UeLaneKey{Ran: ran, RanUeNgapId: context.RanUeNgapIdUnspecified, OcfUeNgapId: *amfUeNgapId}
+	}
+	return ran
+}
+
+func dispatchPdu(ran *context.OcfRan, pdu *ngapType.NGAPPDU) {
 	switch pdu.Present {
 	case ngapType.NGAPPDUPresentInitiatingMessage:
 		initiatingMessage := pdu.InitiatingMessage
@@ -0,0 +1,54 @@
+package ngap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/fsm"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+func TestReleaseRanUesToIdle(t *testing.T) {
+	amfSelf := context.OCF_Self()
+	defer amfSelf.SetParameters(amfSelf.Parameters())
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &plmnId, OcfId: "cafe00"}}
+	})
+
+	ocfConn, ranConn := net.Pipe()
+	defer ranConn.Close()
+	ran := amfSelf.NewOcfRan(ocfConn)
+	ran.AnType = models.AccessType__3_GPP_ACCESS
+	defer ran.Remove()
+
+	registered := amfSelf.NewOcfUe("imsi-208930000000001")
+	defer registered.Remove()
+	registered.State[ran.AnType] = fsm.NewState(context.Registered)
+	registered.T3512Value = 3240
+	deregistered := amfSelf.NewOcfUe("imsi-208930000000002")
+	defer deregistered.Remove()
+	for i, ue := range []*context.OcfUe{registered, deregistered} {
+		ranUe, err := ran.NewRanUe(int64(i + 1))
+		require.NoError(t, err)
+		ue.AttachRanUe(ranUe)
+	}
+
+	releaseRanUesToIdle(ran)
+	assert.Empty(t, ran.RanUes())
+	assert.True(t, registered.CmIdle(ran.AnType))
+	assert.NotNil(t, registered.MobileReachableTimer)
+	assert.True(t, deregistered.CmIdle(ran.AnType))
+	assert.Nil(t, deregistered.MobileReachableTimer)
+
+	// the UE connects again
+	ranUe, err := ran.NewRanUe(3)
+	require.NoError(t, err)
+	registered.AttachRanUe(ranUe)
+	assert.True(t, registered.CmConnect(ran.AnType))
+	assert.Nil(t, registered.MobileReachableTimer)
+}
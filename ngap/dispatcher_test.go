@@ -0,0 +1,224 @@
+package ngap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/aper"
+	libngap "free5gc/lib/ngap"
+	"free5gc/lib/ngap/ngapConvert"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/ngap"
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+func buildNGSetupRequest(plmnId models.PlmnId, tac string) ([]byte, error) {
+	pdu := ngapType.NGAPPDU{}
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeNGSetup
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentReject
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentNGSetupRequest
+	initiatingMessage.Value.NGSetupRequest = new(ngapType.NGSetupRequest)
+
+	nGSetupRequestIEs := &initiatingMessage.Value.NGSetupRequest.ProtocolIEs
+	plmnIdentity := ngapConvert.PlmnIdToNgap(plmnId)
+
+	ie := ngapType.NGSetupRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDGlobalRANNodeID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.NGSetupRequestIEsPresentGlobalRANNodeID
+	ie.Value.GlobalRANNodeID = &ngapType.GlobalRANNodeID{
+		Present: ngapType.GlobalRANNodeIDPresentGlobalGNBID,
+		GlobalGNBID: &ngapType.GlobalGNBID{
+			PLMNIdentity: plmnIdentity,
+			GNBID: ngapType.GNBID{
+				Present: ngapType.GNBIDPresentGNBID,
+				GNBID: &aper.BitString{
+					Bytes:     []byte{0x45, 0x46, 0x47},
+					BitLength: 24,
+				},
+			},
+		},
+	}
+	nGSetupRequestIEs.List = append(nGSetupRequestIEs.List, ie)
+
+	ie = ngapType.NGSetupRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDSupportedTAList
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.NGSetupRequestIEsPresentSupportedTAList
+	ie.Value.SupportedTAList = new(ngapType.SupportedTAList)
+	supportedTAItem := ngapType.SupportedTAItem{}
+	supportedTAItem.TAC.Value = ngapConvert.TacToNgap(tac)
+	broadcastPLMNItem := ngapType.BroadcastPLMNItem{}
+	broadcastPLMNItem.PLMNIdentity = plmnIdentity
+	sliceSupportItem := ngapType.SliceSupportItem{}
+	sliceSupportItem.SNSSAI = ngapConvert.SNssaiToNgap(models.Snssai{Sst: 1, Sd: "010203"})
+	broadcastPLMNItem.TAISliceSupportList.List = append(broadcastPLMNItem.TAISliceSupportList.List, sliceSupportItem)
+	supportedTAItem.BroadcastPLMNList.List = append(supportedTAItem.BroadcastPLMNList.List, broadcastPLMNItem)
+	ie.Value.SupportedTAList.List = append(ie.Value.SupportedTAList.List, supportedTAItem)
+	nGSetupRequestIEs.List = append(nGSetupRequestIEs.List, ie)
+
+	ie = ngapType.NGSetupRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDDefaultPagingDRX
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.NGSetupRequestIEsPresentDefaultPagingDRX
+	ie.Value.DefaultPagingDRX = &ngapType.PagingDRX{Value: ngapType.PagingDRXPresentV128}
+	nGSetupRequestIEs.List = append(nGSetupRequestIEs.List, ie)
+
+	return libngap.Encoder(pdu)
+}
+
+func TestDispatchThroughServiceLoop(t *testing.T) {
+	plmnId := models.PlmnId{Mcc: "208", Mnc: "93"}
+	amfSelf := context.OCF_Self()
+	amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+		parameters.SupportTaiLists = []models.Tai{{PlmnId: &plmnId, Tac: "000001"}}
+		parameters.ServedGuamiList = []models.Guami{{PlmnId: &plmnId, OcfId: "cafe00"}}
+		parameters.PlmnSupportList = []context.PlmnSupportItem{{
+			PlmnId:     plmnId,
+			SNssaiList: []models.Snssai{{Sst: 1, Sd: "010203"}},
+		}}
+	})
+
+	transport := &ngap_service.MemTransport{NumStreams: 3}
+	ngap_service.Run(transport, []string{"127.0.0.1"}, 38412, ngap_service.Handler{
+		HandleMessage:      ngap.Dispatch,
+		HandleNotification: ngap.HandleSCTPNotification,
+	})
+	defer ngap_service.Stop()
+
+	ranConn, err := transport.Dial()
+	require.NoError(t, err)
+	defer ranConn.Close()
+
+	readPdu := func() (*ngapType.NGAPPDU, *ngap_service.MsgInfo) {
+		require.NoError(t, ranConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		buf := make([]byte, 8192)
+		n, info, _, err := ranConn.ReadMsg(buf)
+		require.NoError(t, err)
+		pdu, err := libngap.Decoder(buf[:n])
+		require.NoError(t, err)
+		return pdu, info
+	}
+
+	t.Run("NG Setup with an unknown TAI", func(t *testing.T) {
+		msg, err := buildNGSetupRequest(plmnId, "000002")
+		require.NoError(t, err)
+		_, err = ranConn.WriteMsg(msg, &ngap_service.MsgInfo{Stream: 0, PPID: ngap_service.NGAP_PPID})
+		require.NoError(t, err)
+
+		pdu, info := readPdu()
+		assert.Equal(t, ngap_service.NGAP_PPID, info.PPID)
+		assert.Equal(t, ngap_service.NonUeAssociatedStreamId, info.Stream)
+		assert.Equal(t, ngapType.NGAPPDUPresentUnsuccessfulOutcome, pdu.Present)
+		assert.Equal(t, ngapType.ProcedureCodeNGSetup, pdu.UnsuccessfulOutcome.ProcedureCode.Value)
+		var timeToWait *ngapType.TimeToWait
+		for _, ie := range pdu.UnsuccessfulOutcome.Value.NGSetupFailure.ProtocolIEs.List {
+			if ie.Id.Value == ngapType.ProtocolIEIDTimeToWait {
+				timeToWait = ie.Value.TimeToWait
+			}
+		}
+		require.NotNil(t, timeToWait)
+		assert.Equal(t, ngapType.TimeToWaitPresentV60s, timeToWait.Value)
+	})
+
+	t.Run("NG Setup without a supported slice", func(t *testing.T) {
+		plmnSupportList := amfSelf.Parameters().PlmnSupportList
+		amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+			parameters.PlmnSupportList = []context.PlmnSupportItem{{PlmnId: plmnId, SNssaiList: []models.Snssai{{Sst: 2}}}}
+		})
+		defer amfSelf.UpdateParameters(func(parameters *context.Parameters) {
+			parameters.PlmnSupportList = plmnSupportList
+		})
+
+		msg, err := buildNGSetupRequest(plmnId, "000001")
+		require.NoError(t, err)
+		_, err = ranConn.WriteMsg(msg, &ngap_service.MsgInfo{Stream: 0, PPID: ngap_service.NGAP_PPID})
+		require.NoError(t, err)
+
+		pdu, _ := readPdu()
+		require.Equal(t, ngapType.NGAPPDUPresentUnsuccessfulOutcome, pdu.Present)
+		var cause *ngapType.Cause
+		for _, ie := range pdu.UnsuccessfulOutcome.Value.NGSetupFailure.ProtocolIEs.List {
+			if ie.Id.Value == ngapType.ProtocolIEIDCause {
+				cause = ie.Value.Cause
+			}
+		}
+		require.NotNil(t, cause)
+		require.Equal(t, ngapType.CausePresentRadioNetwork, cause.Present)
+		assert.Equal(t, ngapType.CauseRadioNetworkPresentSliceNotSupported, cause.RadioNetwork.Value)
+	})
+
+	t.Run("Message with another PPID is discarded", func(t *testing.T) {
+		msg, err := buildNGSetupRequest(plmnId, "000001")
+		require.NoError(t, err)
+		_, err = ranConn.WriteMsg(msg, &ngap_service.MsgInfo{Stream: 0, PPID: 0})
+		require.NoError(t, err)
+		_, err = ranConn.WriteMsg(msg, &ngap_service.MsgInfo{Stream: 0, PPID: ngap_service.NGAP_PPID})
+		require.NoError(t, err)
+
+		pdu, _ := readPdu()
+		assert.Equal(t, ngapType.NGAPPDUPresentSuccessfulOutcome, pdu.Present)
+		assert.Equal(t, ngapType.ProcedureCodeNGSetup, pdu.SuccessfulOutcome.ProcedureCode.Value)
+
+		var ran *context.OcfRan
+		amfSelf.OcfRanPool.Range(func(key, value interface{}) bool {
+			ran = value.(*context.OcfRan)
+			return false
+		})
+		require.NotNil(t, ran)
+		assert.Equal(t, 128, ran.DefaultPagingDRX)
+		assert.Len(t, ran.SupportedTAList, 1)
+		assert.False(t, ran.SetupTime.IsZero())
+	})
+
+	t.Run("NG Setup on a new association releases the old one", func(t *testing.T) {
+		newRanConn, err := transport.Dial()
+		require.NoError(t, err)
+		defer newRanConn.Close()
+
+		msg, err := buildNGSetupRequest(plmnId, "000001")
+		require.NoError(t, err)
+		_, err = newRanConn.WriteMsg(msg, &ngap_service.MsgInfo{Stream: 0, PPID: ngap_service.NGAP_PPID})
+		require.NoError(t, err)
+
+		require.NoError(t, newRanConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		buf := make([]byte, 8192)
+		n, _, _, err := newRanConn.ReadMsg(buf)
+		require.NoError(t, err)
+		pdu, err := libngap.Decoder(buf[:n])
+		require.NoError(t, err)
+		assert.Equal(t, ngapType.NGAPPDUPresentSuccessfulOutcome, pdu.Present)
+
+		// the OCF has closed the old association
+		require.NoError(t, ranConn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, _, _, err = ranConn.ReadMsg(buf)
+		assert.Error(t, err)
+
+		rans := 0
+		amfSelf.OcfRanPool.Range(func(key, value interface{}) bool {
+			rans++
+			return true
+		})
+		assert.Equal(t, 1, rans)
+		ran, ok := amfSelf.OcfRanFindByRanID(models.GlobalRanNodeId{
+			PlmnId: &plmnId,
+			GNbId:  &models.GNbId{BitLength: 24, GNBValue: "454647"},
+		})
+		require.True(t, ok)
+		assert.Equal(t, "454647", ran.RanId.GNbId.GNBValue)
+		_, ok = amfSelf.OcfRanFindByRanID(models.GlobalRanNodeId{
+			PlmnId: &models.PlmnId{Mcc: "208", Mnc: "95"},
+			GNbId:  &models.GNbId{BitLength: 24, GNBValue: "454647"},
+		})
+		assert.False(t, ok)
+	})
+}
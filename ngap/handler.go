@@ -3,6 +3,7 @@ package ngap
 import (
 	"encoding/hex"
 	"strconv"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -17,7 +18,17 @@ import (
 	gmm_message "free5gc/src/ocf/gmm/message"
 	"free5gc/src/ocf/logger"
 	"free5gc/src/ocf/nas"
+	"free5gc/src/ocf/ngap/configupdate"
 	ngap_message "free5gc/src/ocf/ngap/message"
+	"free5gc/src/ocf/ngap/overload"
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+// Time To Wait of the NG Setup Failure: an overloaded OCF may accept the RAN soon, a RAN of an
+// unknown PLMN needs a change of configuration first
+const (
+	ngSetupTimeToWaitOverload    = ngapType.TimeToWaitPresentV10s
+	ngSetupTimeToWaitUnknownPLMN = ngapType.TimeToWaitPresentV60s
 )
 
 func HandleNGSetupRequest(ran *context.OcfRan, message *ngapType.NGAPPDU) {
@@ -25,6 +36,7 @@ func HandleNGSetupRequest(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 	var rANNodeName *ngapType.RANNodeName
 	var supportedTAList *ngapType.SupportedTAList
 	var pagingDRX *ngapType.PagingDRX
+	var uERetentionInformation *ngapType.UERetentionInformation
 
 	var cause ngapType.Cause
 
@@ -78,6 +90,13 @@ func HandleNGSetupRequest(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 				logger.NgapLog.Error("DefaultPagingDRX is nil")
 				return
 			}
+		case ngapType.ProtocolIEIDUERetentionInformation:
+			uERetentionInformation = ie.Value.UERetentionInformation
+			logger.NgapLog.Trace("[NGAP] Decode IE UERetentionInformation")
+			if uERetentionInformation == nil {
+				logger.NgapLog.Error("UERetentionInformation is nil")
+				return
+			}
 		}
 	}
 
@@ -86,67 +105,42 @@ func HandleNGSetupRequest(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 		ran.Name = rANNodeName.Value
 	}
 	if pagingDRX != nil {
-		logger.NgapLog.Tracef("PagingDRX[%d]", pagingDRX.Value)
+		ran.DefaultPagingDRX = context.PagingDRXCycle(*pagingDRX)
+		logger.NgapLog.Tracef("PagingDRX[%d]", ran.DefaultPagingDRX)
 	}
+	ran.UeRetention = uERetentionInformation != nil &&
+		uERetentionInformation.Value == ngapType.UERetentionInformationPresentUesRetained
 
-	for i := 0; i < len(supportedTAList.List); i++ {
-		supportedTAItem := supportedTAList.List[i]
-		tac := hex.EncodeToString(supportedTAItem.TAC.Value)
-		capOfSupportTai := cap(ran.SupportedTAList)
-		for j := 0; j < len(supportedTAItem.BroadcastPLMNList.List); j++ {
-			supportedTAI := context.NewSupportedTAI()
-			supportedTAI.Tai.Tac = tac
-			broadcastPLMNItem := supportedTAItem.BroadcastPLMNList.List[j]
-			plmnId := ngapConvert.PlmnIdToModels(broadcastPLMNItem.PLMNIdentity)
-			supportedTAI.Tai.PlmnId = &plmnId
-			capOfSNssaiList := cap(supportedTAI.SNssaiList)
-			for k := 0; k < len(broadcastPLMNItem.TAISliceSupportList.List); k++ {
-				tAISliceSupportItem := broadcastPLMNItem.TAISliceSupportList.List[k]
-				if len(supportedTAI.SNssaiList) < capOfSNssaiList {
-					supportedTAI.SNssaiList = append(supportedTAI.SNssaiList, ngapConvert.SNssaiToModels(tAISliceSupportItem.SNSSAI))
-				} else {
-					break
-				}
-			}
-			logger.NgapLog.Tracef("PLMN_ID[MCC:%s MNC:%s] TAC[%s]", plmnId.Mcc, plmnId.Mnc, tac)
-			if len(ran.SupportedTAList) < capOfSupportTai {
-				ran.SupportedTAList = append(ran.SupportedTAList, supportedTAI)
-
-			} else {
-				break
-			}
-		}
+	// a repeated NG Setup replaces the TAs of the previous one
+	ran.SupportedTAList = supportedTAIs(supportedTAList)
 
-	}
+	// a RAN which reconnects is set up even if the OCF is overloaded, its UEs need the new association
+	duplicates := context.OCF_Self().OcfRanFindDuplicates(ran)
 
-	if len(ran.SupportedTAList) == 0 {
-		logger.NgapLog.Warn("NG-Setup failure: No supported TA exist in NG-Setup request")
+	var timeToWait *ngapType.TimeToWait
+	if overload.Overloaded() && len(duplicates) == 0 {
+		logger.NgapLog.Warn("NG-Setup failure: OCF is overloaded")
 		cause.Present = ngapType.CausePresentMisc
 		cause.Misc = &ngapType.CauseMisc{
-			Value: ngapType.CauseMiscPresentUnspecified,
+			Value: ngapType.CauseMiscPresentControlProcessingOverload,
 		}
+		timeToWait = &ngapType.TimeToWait{Value: ngSetupTimeToWaitOverload}
 	} else {
-		var found bool
-		for i, tai := range ran.SupportedTAList {
-			if context.InTaiList(tai.Tai, context.OCF_Self().SupportTaiLists) {
-				logger.NgapLog.Tracef("SERVED_TAI_INDEX[%d]", i)
-				found = true
-				break
-			}
-		}
-		if !found {
-			logger.NgapLog.Warn("NG-Setup failure: Cannot find Served TAI in OCF")
-			cause.Present = ngapType.CausePresentMisc
-			cause.Misc = &ngapType.CauseMisc{
-				Value: ngapType.CauseMiscPresentUnknownPLMN,
-			}
+		cause = checkSupportedTAIs("NG-Setup", ran.SupportedTAList)
+		if cause.Present == ngapType.CausePresentMisc && cause.Misc.Value == ngapType.CauseMiscPresentUnknownPLMN {
+			timeToWait = &ngapType.TimeToWait{Value: ngSetupTimeToWaitUnknownPLMN}
 		}
 	}
 
 	if cause.Present == ngapType.CausePresentNothing {
+		for _, staleRan := range duplicates {
+			releaseStaleRan(staleRan)
+		}
+		ran.SetupTime = time.Now()
 		ngap_message.SendNGSetupResponse(ran)
+		overload.RanSetUp(ran)
 	} else {
-		ngap_message.SendNGSetupFailure(ran, cause)
+		ngap_message.SendNGSetupFailure(ran, cause, timeToWait)
 	}
 }
 
@@ -292,6 +286,7 @@ func HandleNGReset(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 	switch resetType.Present {
 	case ngapType.ResetTypePresentNGInterface:
 		logger.NgapLog.Trace("ResetType Present: NG Interface")
+		drainUeLanes(ran)
 		ran.RemoveAllUeInRan()
 		ngap_message.SendNGResetAcknowledge(ran, nil, nil)
 	case ngapType.ResetTypePresentPartOfNGInterface:
@@ -308,12 +303,7 @@ func HandleNGReset(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 		for _, ueAssociatedLogicalNGConnectionItem := range partOfNGInterface.List {
 			if ueAssociatedLogicalNGConnectionItem.OCFUENGAPID != nil {
 				logger.NgapLog.Tracef("OcfUeNgapID[%d]", ueAssociatedLogicalNGConnectionItem.OCFUENGAPID.Value)
-				for _, ue := range ran.RanUeList {
-					if ue.OcfUeNgapId == ueAssociatedLogicalNGConnectionItem.OCFUENGAPID.Value {
-						ranUe = ue
-						break
-					}
-				}
+				ranUe = ran.RanUeFindByOcfUeNgapID(ueAssociatedLogicalNGConnectionItem.OCFUENGAPID.Value)
 			} else if ueAssociatedLogicalNGConnectionItem.RANUENGAPID != nil {
 				logger.NgapLog.Tracef("RanUeNgapID[%d]", ueAssociatedLogicalNGConnectionItem.RANUENGAPID.Value)
 				ranUe = ran.RanUeFindByRanUeNgapID(ueAssociatedLogicalNGConnectionItem.RANUENGAPID.Value)
@@ -567,6 +557,7 @@ func HandleUEContextReleaseComplete(ran *context.OcfRan, message *ngapType.NGAPP
 	case context.UeContextN2NormalRelease:
 		logger.NgapLog.Infof("Release UE[%s] Context : N2 Connection Release", amfUe.Supi)
 		// amfUe.DetachRanUe(ran.AnType)
+		// the UE enters CM-IDLE
 		err := ranUe.Remove()
 		if err != nil {
 			logger.NgapLog.Errorln(err.Error())
@@ -952,7 +943,7 @@ func HandleInitialUEMessage(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 		if fiveGSTMSI != nil {
 			Ngaplog.Debug("Receive 5G-S-TMSI")
 
-			servedGuami := amfSelf.ServedGuamiList[0]
+			servedGuami := amfSelf.Parameters().ServedGuamiList[0]
 
 			// <5G-S-TMSI> := <OCF Set ID><OCF Pointer><5G-TMSI>
 			// GUAMI := <MCC><MNC><OCF Region ID><OCF Set ID><OCF Pointer>
@@ -979,7 +970,6 @@ func HandleInitialUEMessage(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 						amfUe.RanUe[ran.AnType].RanUeNgapId)
 					amfUe.DetachRanUe(ran.AnType)
 				}
-				// TODO: stop Implicit Deregistration timer
 				Ngaplog.Debugf("OcfUe Attach RanUe [RanUeNgapID: %d]", ranUe.RanUeNgapId)
 				amfUe.AttachRanUe(ranUe)
 			}
@@ -3334,66 +3324,179 @@ func HandleRanConfigurationUpdate(ran *context.OcfRan, message *ngapType.NGAPPDU
 		}
 	}
 
+	// the IEs which are absent keep their value, the Supported TA List replaces the whole list
+	newSupportedTAList := ran.SupportedTAList
+	if supportedTAList != nil {
+		newSupportedTAList = supportedTAIs(supportedTAList)
+		cause = checkSupportedTAIs("RanConfigurationUpdate", newSupportedTAList)
+	}
+
+	if cause.Present != ngapType.CausePresentNothing {
+		logger.NgapLog.Info("[OCF] RanConfigurationUpdateAcknowledgeFailure")
+		ngap_message.SendRanConfigurationUpdateFailure(ran, cause, nil)
+		return
+	}
+
+	change := context.RanConfigurationChange{Time: time.Now()}
+	if supportedTAList != nil {
+		change.AddedTAIs, change.RemovedTAIs, change.SliceChangedTAIs =
+			context.DiffSupportedTAList(ran.SupportedTAList, newSupportedTAList)
+		ran.SupportedTAList = newSupportedTAList
+	}
+	if rANNodeName != nil && rANNodeName.Value != ran.Name {
+		change.OldName, change.NewName = ran.Name, rANNodeName.Value
+		ran.Name = rANNodeName.Value
+	}
+	if pagingDRX != nil {
+		if cycle := context.PagingDRXCycle(*pagingDRX); cycle != ran.DefaultPagingDRX {
+			change.OldPagingDRX, change.NewPagingDRX = ran.DefaultPagingDRX, cycle
+			ran.DefaultPagingDRX = cycle
+		}
+	}
+
+	logger.NgapLog.Info("[OCF] RanConfigurationUpdateAcknowledge")
+	ngap_message.SendRanConfigurationUpdateAcknowledge(ran, nil)
+
+	if change.Empty() {
+		return
+	}
+	logger.NgapLog.Infof("RAN[%s] configuration changed: %d TAI(s) added, %d TAI(s) removed, "+
+		"%d TAI(s) with other slices", ran.RanIdString(), len(change.AddedTAIs), len(change.RemovedTAIs),
+		len(change.SliceChangedTAIs))
+	ran.AddConfigurationChange(change)
+	context.PublishRanConfigurationChange(ran, change)
+}
+
+func init() {
+	// the registration areas and the ongoing pagings follow the TAs served by the RANs
+	context.RegisterRanConfigurationHandler(updateRegistrationAreas)
+	context.RegisterRanConfigurationHandler(ngap_message.PageInAddedTAs)
+}
+
+// supportedTAIs converts the Supported TA List of NG Setup and RAN Configuration Update
+func supportedTAIs(supportedTAList *ngapType.SupportedTAList) []context.SupportedTAI {
+	supportedTAIs := make([]context.SupportedTAI, 0, context.MaxNumOfTAI*context.MaxNumOfBroadcastPLMNs)
 	for i := 0; i < len(supportedTAList.List); i++ {
 		supportedTAItem := supportedTAList.List[i]
 		tac := hex.EncodeToString(supportedTAItem.TAC.Value)
-		capOfSupportTai := cap(ran.SupportedTAList)
 		for j := 0; j < len(supportedTAItem.BroadcastPLMNList.List); j++ {
 			supportedTAI := context.NewSupportedTAI()
 			supportedTAI.Tai.Tac = tac
 			broadcastPLMNItem := supportedTAItem.BroadcastPLMNList.List[j]
 			plmnId := ngapConvert.PlmnIdToModels(broadcastPLMNItem.PLMNIdentity)
 			supportedTAI.Tai.PlmnId = &plmnId
-			capOfSNssaiList := cap(supportedTAI.SNssaiList)
 			for k := 0; k < len(broadcastPLMNItem.TAISliceSupportList.List); k++ {
 				tAISliceSupportItem := broadcastPLMNItem.TAISliceSupportList.List[k]
-				if len(supportedTAI.SNssaiList) < capOfSNssaiList {
-					supportedTAI.SNssaiList = append(supportedTAI.SNssaiList, ngapConvert.SNssaiToModels(tAISliceSupportItem.SNSSAI))
-				} else {
-					break
-				}
+				supportedTAI.SNssaiList = append(supportedTAI.SNssaiList, ngapConvert.SNssaiToModels(tAISliceSupportItem.SNSSAI))
 			}
-			logger.NgapLog.Tracef("PLMN_ID[MCC:%s MNC:%s] TAC[%s]", plmnId.Mcc, plmnId.Mnc, tac)
-			if len(ran.SupportedTAList) < capOfSupportTai {
-				ran.SupportedTAList = append(ran.SupportedTAList, supportedTAI)
-
-			} else {
-				break
+			if len(supportedTAI.SNssaiList) > context.MaxNumOfSlice {
+				logger.NgapLog.Warnf("TAC[%s] supports %d slices, more than the %d expected",
+					tac, len(supportedTAI.SNssaiList), context.MaxNumOfSlice)
 			}
+			logger.NgapLog.Tracef("PLMN_ID[MCC:%s MNC:%s] TAC[%s]", plmnId.Mcc, plmnId.Mnc, tac)
+			supportedTAIs = append(supportedTAIs, supportedTAI)
 		}
-
 	}
+	if maxNumOfTAI := context.MaxNumOfTAI * context.MaxNumOfBroadcastPLMNs; len(supportedTAIs) > maxNumOfTAI {
+		logger.NgapLog.Warnf("RAN supports %d TAIs, more than the %d expected", len(supportedTAIs), maxNumOfTAI)
+	}
+	return supportedTAIs
+}
 
-	if len(ran.SupportedTAList) == 0 {
-		logger.NgapLog.Warn("RanConfigurationUpdate failure: No supported TA exist in RanConfigurationUpdate")
+// checkSupportedTAIs returns the cause of the failure of the procedure if the OCF serves none of
+// the TAIs or none of their slices, the cause is absent otherwise
+func checkSupportedTAIs(procedure string, supportedTAIs []context.SupportedTAI) (cause ngapType.Cause) {
+	if len(supportedTAIs) == 0 {
+		logger.NgapLog.Warnf("%s failure: No supported TA exist in %s", procedure, procedure)
 		cause.Present = ngapType.CausePresentMisc
 		cause.Misc = &ngapType.CauseMisc{
 			Value: ngapType.CauseMiscPresentUnspecified,
 		}
-	} else {
-		var found bool
-		for i, tai := range ran.SupportedTAList {
-			if context.InTaiList(tai.Tai, context.OCF_Self().SupportTaiLists) {
-				logger.NgapLog.Tracef("SERVED_TAI_INDEX[%d]", i)
-				found = true
+		return
+	}
+
+	var found, sliceFound bool
+	for i, tai := range supportedTAIs {
+		if context.InTaiList(tai.Tai, context.OCF_Self().Parameters().SupportTaiLists) {
+			logger.NgapLog.Tracef("SERVED_TAI_INDEX[%d]", i)
+			found = true
+			if context.InPlmnSupportList(*tai.Tai.PlmnId, tai.SNssaiList) {
+				sliceFound = true
 				break
 			}
 		}
-		if !found {
-			logger.NgapLog.Warn("RanConfigurationUpdate failure: Cannot find Served TAI in OCF")
-			cause.Present = ngapType.CausePresentMisc
-			cause.Misc = &ngapType.CauseMisc{
-				Value: ngapType.CauseMiscPresentUnknownPLMN,
-			}
+	}
+	if !found {
+		logger.NgapLog.Warnf("%s failure: Cannot find Served TAI in OCF", procedure)
+		cause.Present = ngapType.CausePresentMisc
+		cause.Misc = &ngapType.CauseMisc{
+			Value: ngapType.CauseMiscPresentUnknownPLMN,
+		}
+	} else if !sliceFound {
+		logger.NgapLog.Warnf("%s failure: No slice of the served TAIs is supported by OCF", procedure)
+		cause.Present = ngapType.CausePresentRadioNetwork
+		cause.RadioNetwork = &ngapType.CauseRadioNetwork{
+			Value: ngapType.CauseRadioNetworkPresentSliceNotSupported,
 		}
 	}
+	return
+}
 
-	if cause.Present == ngapType.CausePresentNothing {
-		logger.NgapLog.Info("[OCF] RanConfigurationUpdateAcknowledge")
-		ngap_message.SendRanConfigurationUpdateAcknowledge(ran, nil)
-	} else {
-		logger.NgapLog.Info("[OCF] RanConfigurationUpdateAcknowledgeFailure")
-		ngap_message.SendRanConfigurationUpdateFailure(ran, cause, nil)
+// updateRegistrationAreas is the RAN configuration handler of the registration areas, the TAIs which
+// no RAN serves anymore are removed from the registration areas of the CM-CONNECTED UEs in their own
+// lane. The CM-IDLE UEs get a new registration area at their next registration.
+func updateRegistrationAreas(ran *context.OcfRan, change context.RanConfigurationChange) {
+	amfSelf := context.OCF_Self()
+	var unservedTAIs []models.Tai
+	for _, tai := range change.RemovedTAIs {
+		if !amfSelf.TaiServed(tai) {
+			unservedTAIs = append(unservedTAIs, tai)
+		}
+	}
+	if len(unservedTAIs) == 0 {
+		return
+	}
+
+	amfSelf.OcfRanPool.Range(func(key, value interface{}) bool {
+		for _, ranUe := range value.(*context.OcfRan).RanUes() {
+			ranUe := ranUe
+			ngap_service.Post(ranUe.LaneKey, func() {
+				removeUnservedTAIs(ranUe, unservedTAIs)
+			})
+		}
+		return true
+	})
+}
+
+// removeUnservedTAIs updates the registration area of the UE of ranUe, the UE gets its new registration
+// area by Configuration Update Command
+func removeUnservedTAIs(ranUe *context.RanUe, unservedTAIs []models.Tai) {
+	anType := models.AccessType__3_GPP_ACCESS
+	ue := ranUe.OcfUe
+	if ue == nil || ranUe.Ran.AnType != anType || ue.RanUe[anType] != ranUe {
+		return
+	}
+
+	registrationArea := ue.RegistrationArea[anType]
+	var servedArea []models.Tai
+	for _, tai := range registrationArea {
+		if !context.InTaiList(tai, unservedTAIs) {
+			servedArea = append(servedArea, tai)
+		}
+	}
+	if len(servedArea) == len(registrationArea) {
+		return
+	}
+
+	logger.NgapLog.Infof("Remove %d TAI(s) no longer served from the registration area of UE[%s]",
+		len(registrationArea)-len(servedArea), ue.Supi)
+	ue.RegistrationArea[anType] = servedArea
+	// an empty TAI list cannot be sent, the UE registers again in a served TA
+	if len(servedArea) > 0 && ue.State[anType].Is(context.Registered) {
+		update := context.ConfigurationUpdate{RegistrationArea: true}
+		if err := gmm_message.UpdateUeConfiguration(ue, anType, update); err != nil {
+			logger.NgapLog.Errorf("Configuration update of UE[%s] failed: %+v", ue.Supi, err)
+		}
 	}
 }
 
@@ -3796,6 +3899,7 @@ func HandleUERadioCapabilityInfoIndication(ran *context.OcfRan, message *ngapTyp
 func HandleOCFconfigurationUpdateFailure(ran *context.OcfRan, message *ngapType.NGAPPDU) {
 
 	var cause *ngapType.Cause
+	var timeToWait *ngapType.TimeToWait
 	var criticalityDiagnostics *ngapType.CriticalityDiagnostics
 	if ran == nil {
 		logger.NgapLog.Error("ran is nil")
@@ -3828,17 +3932,23 @@ func HandleOCFconfigurationUpdateFailure(ran *context.OcfRan, message *ngapType.
 				logger.NgapLog.Error("Cause is nil")
 				return
 			}
+		case ngapType.ProtocolIEIDTimeToWait:
+			timeToWait = ie.Value.TimeToWait
+			logger.NgapLog.Trace("[NGAP] Decode IE TimeToWait")
 		case ngapType.ProtocolIEIDCriticalityDiagnostics:
 			criticalityDiagnostics = ie.Value.CriticalityDiagnostics
 			Ngaplog.Trace("[NGAP] Decode IE CriticalityDiagnostics")
 		}
 	}
 
-	//	TODO: Time To Wait
-
+	if cause != nil {
+		printAndGetCause(cause)
+	}
 	if criticalityDiagnostics != nil {
 		printCriticalityDiagnostics(criticalityDiagnostics)
 	}
+
+	configupdate.Failed(ran, cause, timeToWait)
 }
 
 func HandleOCFconfigurationUpdateAcknowledge(ran *context.OcfRan, message *ngapType.NGAPPDU) {
@@ -3894,6 +4004,8 @@ func HandleOCFconfigurationUpdateAcknowledge(ran *context.OcfRan, message *ngapT
 	if criticalityDiagnostics != nil {
 		printCriticalityDiagnostics(criticalityDiagnostics)
 	}
+
+	configupdate.Acknowledged(ran)
 }
 
 func HandleErrorIndication(ran *context.OcfRan, message *ngapType.NGAPPDU) {
@@ -10,6 +10,7 @@ import (
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/context"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/nas/nas_security"
 	"strings"
 )
 
@@ -79,7 +80,7 @@ func BuildPDUSessionResourceReleaseCommand(ue *context.RanUe, nasPdu []byte,
 
 func BuildNGSetupResponse() ([]byte, error) {
 
-	amfSelf := context.OCF_Self()
+	parameters := context.OCF_Self().Parameters()
 	var pdu ngapType.NGAPPDU
 	pdu.Present = ngapType.NGAPPDUPresentSuccessfulOutcome
 	pdu.SuccessfulOutcome = new(ngapType.SuccessfulOutcome)
@@ -101,7 +102,7 @@ func BuildNGSetupResponse() ([]byte, error) {
 	ie.Value.OCFName = new(ngapType.OCFName)
 
 	aMFName := ie.Value.OCFName
-	aMFName.Value = amfSelf.Name
+	aMFName.Value = parameters.Name
 
 	nGSetupResponseIEs.List = append(nGSetupResponseIEs.List, ie)
 
@@ -113,7 +114,7 @@ func BuildNGSetupResponse() ([]byte, error) {
 	ie.Value.ServedGUAMIList = new(ngapType.ServedGUAMIList)
 
 	servedGUAMIList := ie.Value.ServedGUAMIList
-	for _, guami := range amfSelf.ServedGuamiList {
+	for _, guami := range parameters.ServedGuamiList {
 		servedGUAMIItem := ngapType.ServedGUAMIItem{}
 		servedGUAMIItem.GUAMI.PLMNIdentity = ngapConvert.PlmnIdToNgap(*guami.PlmnId)
 		regionId, setId, prtId := ngapConvert.OcfIdToNgap(guami.OcfId)
@@ -132,7 +133,7 @@ func BuildNGSetupResponse() ([]byte, error) {
 	ie.Value.Present = ngapType.NGSetupResponseIEsPresentRelativeOCFCapacity
 	ie.Value.RelativeOCFCapacity = new(ngapType.RelativeOCFCapacity)
 	relativeOCFCapacity := ie.Value.RelativeOCFCapacity
-	relativeOCFCapacity.Value = amfSelf.RelativeCapacity
+	relativeOCFCapacity.Value = parameters.RelativeCapacity
 
 	nGSetupResponseIEs.List = append(nGSetupResponseIEs.List, ie)
 
@@ -144,14 +145,10 @@ func BuildNGSetupResponse() ([]byte, error) {
 	ie.Value.PLMNSupportList = new(ngapType.PLMNSupportList)
 
 	pLMNSupportList := ie.Value.PLMNSupportList
-	for _, plmnItem := range amfSelf.PlmnSupportList {
+	for _, plmnItem := range parameters.PlmnSupportList {
 		pLMNSupportItem := ngapType.PLMNSupportItem{}
 		pLMNSupportItem.PLMNIdentity = ngapConvert.PlmnIdToNgap(plmnItem.PlmnId)
-		for _, snssai := range plmnItem.SNssaiList {
-			sliceSupportItem := ngapType.SliceSupportItem{}
-			sliceSupportItem.SNSSAI = ngapConvert.SNssaiToNgap(snssai)
-			pLMNSupportItem.SliceSupportList.List = append(pLMNSupportItem.SliceSupportList.List, sliceSupportItem)
-		}
+		pLMNSupportItem.SliceSupportList = BuildIESliceSupportList(plmnItem.SNssaiList)
 		pLMNSupportList.List = append(pLMNSupportList.List, pLMNSupportItem)
 	}
 
@@ -160,7 +157,8 @@ func BuildNGSetupResponse() ([]byte, error) {
 	return ngap.Encoder(pdu)
 }
 
-func BuildNGSetupFailure(cause ngapType.Cause) ([]byte, error) {
+// timeToWait: optional, the time the RAN waits before retrying the NG Setup
+func BuildNGSetupFailure(cause ngapType.Cause, timeToWait *ngapType.TimeToWait) ([]byte, error) {
 	var pdu ngapType.NGAPPDU
 	pdu.Present = ngapType.NGAPPDUPresentUnsuccessfulOutcome
 	pdu.UnsuccessfulOutcome = new(ngapType.UnsuccessfulOutcome)
@@ -183,6 +181,16 @@ func BuildNGSetupFailure(cause ngapType.Cause) ([]byte, error) {
 
 	nGSetupFailureIEs.List = append(nGSetupFailureIEs.List, ie)
 
+	// Time To Wait (optional)
+	if timeToWait != nil {
+		ie = ngapType.NGSetupFailureIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDTimeToWait
+		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+		ie.Value.Present = ngapType.NGSetupFailureIEsPresentTimeToWait
+		ie.Value.TimeToWait = timeToWait
+		nGSetupFailureIEs.List = append(nGSetupFailureIEs.List, ie)
+	}
+
 	return ngap.Encoder(pdu)
 }
 
@@ -989,7 +997,7 @@ func BuildInitialContextSetupRequest(
 	amfSetID := &guami.OCFSetID
 	amfPtrID := &guami.OCFPointer
 
-	servedGuami := amfSelf.ServedGuamiList[0]
+	servedGuami := amfSelf.Parameters().ServedGuamiList[0]
 
 	*plmnID = ngapConvert.PlmnIdToNgap(*servedGuami.PlmnId)
 	amfRegionID.Value, amfSetID.Value, amfPtrID.Value = ngapConvert.OcfIdToNgap(servedGuami.OcfId)
@@ -1029,31 +1037,8 @@ func BuildInitialContextSetupRequest(
 	ie.Id.Value = ngapType.ProtocolIEIDUESecurityCapabilities
 	ie.Criticality.Value = ngapType.CriticalityPresentReject
 	ie.Value.Present = ngapType.InitialContextSetupRequestIEsPresentUESecurityCapabilities
-	ie.Value.UESecurityCapabilities = new(ngapType.UESecurityCapabilities)
-
-	ueSecurityCapabilities := ie.Value.UESecurityCapabilities
-	nrEncryptionAlgorighm := []byte{0x00, 0x00}
-	nrEncryptionAlgorighm[0] |= amfUe.UESecurityCapability.GetEA1_128_5G()
-	nrEncryptionAlgorighm[0] |= amfUe.UESecurityCapability.GetEA2_128_5G()
-	nrEncryptionAlgorighm[0] |= amfUe.UESecurityCapability.GetEA3_128_5G()
-	ueSecurityCapabilities.NRencryptionAlgorithms.Value =
-		ngapConvert.ByteToBitString(nrEncryptionAlgorighm, 16)
-
-	nrIntegrityAlgorithm := []byte{0x00, 0x00}
-	nrIntegrityAlgorithm[0] |= amfUe.UESecurityCapability.GetIA1_128_5G()
-	nrIntegrityAlgorithm[0] |= amfUe.UESecurityCapability.GetIA2_128_5G()
-	nrIntegrityAlgorithm[0] |= amfUe.UESecurityCapability.GetIA3_128_5G()
-	ueSecurityCapabilities.NRintegrityProtectionAlgorithms.Value =
-		ngapConvert.ByteToBitString(nrIntegrityAlgorithm, 16)
-
-	// only support NR algorithms
-	eutraEncryptionAlgorithm := []byte{0x00, 0x00}
-	ueSecurityCapabilities.EUTRAencryptionAlgorithms.Value =
-		ngapConvert.ByteToBitString(eutraEncryptionAlgorithm, 16)
-
-	eutraIntegrityAlgorithm := []byte{0x00, 0x00}
-	ueSecurityCapabilities.EUTRAintegrityProtectionAlgorithms.Value =
-		ngapConvert.ByteToBitString(eutraIntegrityAlgorithm, 16)
+	ueSecurityCapabilities := BuildIEUESecurityCapabilities(&amfUe.UESecurityCapability)
+	ie.Value.UESecurityCapabilities = &ueSecurityCapabilities
 
 	initialContextSetupRequestIEs.List = append(initialContextSetupRequestIEs.List, ie)
 
@@ -1441,6 +1426,23 @@ func BuildHandoverCommand(
 		ie.Value.Present = ngapType.HandoverCommandIEsPresentNASSecurityParametersFromNGRAN
 		ie.Value.NASSecurityParametersFromNGRAN = new(ngapType.NASSecurityParametersFromNGRAN)
 
+		handoverCommandIEs.List = append(handoverCommandIEs.List, ie)
+	} else if amfUe := sourceUe.OcfUe; amfUe != nil && amfUe.HandoverKamfCount != nil {
+		// the UE derives the Kamf the target OCF is given horizontally (TS 33.501 6.9.2.3.3)
+		nasSecurityParameters, err := nas_security.HandoverNasSecurityParameters(amfUe, *amfUe.HandoverKamfCount)
+		if err != nil {
+			return nil, err
+		}
+		amfUe.HandoverKamfCount = nil
+
+		ie = ngapType.HandoverCommandIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDNASSecurityParametersFromNGRAN
+		ie.Criticality.Value = ngapType.CriticalityPresentReject
+		ie.Value.Present = ngapType.HandoverCommandIEsPresentNASSecurityParametersFromNGRAN
+		ie.Value.NASSecurityParametersFromNGRAN = &ngapType.NASSecurityParametersFromNGRAN{
+			Value: nasSecurityParameters,
+		}
+
 		handoverCommandIEs.List = append(handoverCommandIEs.List, ie)
 	}
 
@@ -1571,7 +1573,7 @@ func BuildHandoverRequest(ue *context.RanUe, cause ngapType.Cause,
 	pduSessionResourceSetupListHOReq ngapType.PDUSessionResourceSetupListHOReq,
 	sourceToTargetTransparentContainer ngapType.SourceToTargetTransparentContainer, nsci bool) ([]byte, error) {
 
-	amfSelf := context.OCF_Self()
+	parameters := context.OCF_Self().Parameters()
 	amfUe := ue.OcfUe
 	if amfUe == nil {
 		return nil, fmt.Errorf("OcfUe is nil")
@@ -1644,31 +1646,8 @@ func BuildHandoverRequest(ue *context.RanUe, cause ngapType.Cause,
 	ie.Id.Value = ngapType.ProtocolIEIDUESecurityCapabilities
 	ie.Criticality.Value = ngapType.CriticalityPresentReject
 	ie.Value.Present = ngapType.HandoverRequestIEsPresentUESecurityCapabilities
-	ie.Value.UESecurityCapabilities = new(ngapType.UESecurityCapabilities)
-
-	ueSecurityCapabilities := ie.Value.UESecurityCapabilities
-
-	nrEncryptionAlgorighm := []byte{0x00, 0x00}
-	nrEncryptionAlgorighm[0] |= amfUe.UESecurityCapability.GetEA1_128_5G()
-	nrEncryptionAlgorighm[0] |= amfUe.UESecurityCapability.GetEA2_128_5G()
-	nrEncryptionAlgorighm[0] |= amfUe.UESecurityCapability.GetEA3_128_5G()
-	ueSecurityCapabilities.NRencryptionAlgorithms.Value = ngapConvert.ByteToBitString(nrEncryptionAlgorighm, 16)
-
-	nrIntegrityAlgorithm := []byte{0x00, 0x00}
-	nrIntegrityAlgorithm[0] |= amfUe.UESecurityCapability.GetIA1_128_5G()
-	nrIntegrityAlgorithm[0] |= amfUe.UESecurityCapability.GetIA2_128_5G()
-	nrIntegrityAlgorithm[0] |= amfUe.UESecurityCapability.GetIA3_128_5G()
-	ueSecurityCapabilities.NRintegrityProtectionAlgorithms.Value =
-		ngapConvert.ByteToBitString(nrIntegrityAlgorithm, 16)
-
-	// only support NR algorithms
-	eutraEncryptionAlgorithm := []byte{0x00, 0x00}
-	ueSecurityCapabilities.EUTRAencryptionAlgorithms.Value =
-		ngapConvert.ByteToBitString(eutraEncryptionAlgorithm, 16)
-
-	eutraIntegrityAlgorithm := []byte{0x00, 0x00}
-	ueSecurityCapabilities.EUTRAintegrityProtectionAlgorithms.Value =
-		ngapConvert.ByteToBitString(eutraIntegrityAlgorithm, 16)
+	ueSecurityCapabilities := BuildIEUESecurityCapabilities(&amfUe.UESecurityCapability)
+	ie.Value.UESecurityCapabilities = &ueSecurityCapabilities
 
 	handoverRequestIEs.List = append(handoverRequestIEs.List, ie)
 
@@ -1701,7 +1680,7 @@ func BuildHandoverRequest(ue *context.RanUe, cause ngapType.Cause,
 	ie.Value.AllowedNSSAI = new(ngapType.AllowedNSSAI)
 
 	allowedNSSAI := ie.Value.AllowedNSSAI
-	for _, snssaiItem := range amfSelf.PlmnSupportList[0].SNssaiList {
+	for _, snssaiItem := range parameters.PlmnSupportList[0].SNssaiList {
 		allowedNSSAIItem := ngapType.AllowedNSSAIItem{}
 
 		ngapSnssai := ngapConvert.SNssaiToNgap(snssaiItem)
@@ -1735,7 +1714,7 @@ func BuildHandoverRequest(ue *context.RanUe, cause ngapType.Cause,
 	amfSetID := &guami.OCFSetID
 	amfPtrID := &guami.OCFPointer
 
-	servedGuami := amfSelf.ServedGuamiList[0]
+	servedGuami := parameters.ServedGuamiList[0]
 
 	*plmnID = ngapConvert.PlmnIdToNgap(*servedGuami.PlmnId)
 	amfRegionID.Value, amfSetID.Value, amfPtrID.Value = ngapConvert.OcfIdToNgap(servedGuami.OcfId)
@@ -1841,30 +1820,8 @@ func BuildPathSwitchRequestAcknowledge(
 	ie.Id.Value = ngapType.ProtocolIEIDUESecurityCapabilities
 	ie.Criticality.Value = ngapType.CriticalityPresentReject
 	ie.Value.Present = ngapType.PathSwitchRequestAcknowledgeIEsPresentUESecurityCapabilities
-	ie.Value.UESecurityCapabilities = new(ngapType.UESecurityCapabilities)
-
-	ueSecurityCapabilities := ie.Value.UESecurityCapabilities
-	nrEncryptionAlgorighm := []byte{0x00, 0x00}
-	nrEncryptionAlgorighm[0] |= ue.OcfUe.UESecurityCapability.GetEA1_128_5G()
-	nrEncryptionAlgorighm[0] |= ue.OcfUe.UESecurityCapability.GetEA2_128_5G()
-	nrEncryptionAlgorighm[0] |= ue.OcfUe.UESecurityCapability.GetEA3_128_5G()
-	ueSecurityCapabilities.NRencryptionAlgorithms.Value = ngapConvert.ByteToBitString(nrEncryptionAlgorighm, 16)
-
-	nrIntegrityAlgorithm := []byte{0x00, 0x00}
-	nrIntegrityAlgorithm[0] |= ue.OcfUe.UESecurityCapability.GetIA1_128_5G()
-	nrIntegrityAlgorithm[0] |= ue.OcfUe.UESecurityCapability.GetIA2_128_5G()
-	nrIntegrityAlgorithm[0] |= ue.OcfUe.UESecurityCapability.GetIA3_128_5G()
-	ueSecurityCapabilities.NRintegrityProtectionAlgorithms.Value =
-		ngapConvert.ByteToBitString(nrIntegrityAlgorithm, 16)
-
-	// only support NR algorithms
-	eutraEncryptionAlgorithm := []byte{0x00, 0x00}
-	ueSecurityCapabilities.EUTRAencryptionAlgorithms.Value =
-		ngapConvert.ByteToBitString(eutraEncryptionAlgorithm, 16)
-
-	eutraIntegrityAlgorithm := []byte{0x00, 0x00}
-	ueSecurityCapabilities.EUTRAintegrityProtectionAlgorithms.Value =
-		ngapConvert.ByteToBitString(eutraIntegrityAlgorithm, 16)
+	ueSecurityCapabilities := BuildIEUESecurityCapabilities(&ue.OcfUe.UESecurityCapability)
+	ie.Value.UESecurityCapabilities = &ueSecurityCapabilities
 
 	pathSwitchRequestAckIEs.List = append(pathSwitchRequestAckIEs.List, ie)
 
@@ -1919,7 +1876,7 @@ func BuildPathSwitchRequestAcknowledge(
 
 	allowedNSSAI := ie.Value.AllowedNSSAI
 	// plmnSupportList[0] is serving plmn
-	for _, modelSnssai := range amfSelf.PlmnSupportList[0].SNssaiList {
+	for _, modelSnssai := range amfSelf.Parameters().PlmnSupportList[0].SNssaiList {
 		allowedNSSAIItem := ngapType.AllowedNSSAIItem{}
 
 		ngapSnssai := ngapConvert.SNssaiToNgap(modelSnssai)
@@ -2093,10 +2050,9 @@ func BuildDownlinkRanStatusTransfer(ue *context.RanUe,
 // is associated with non-3GPP access, the OCF sends a Paging message with associated access "non-3GPP" to
 // NG-RAN node(s) via 3GPP access.
 // more paging policy with 3gpp/non-3gpp access is described in TS 23.501 5.6.8
-func BuildPaging(
-	ue *context.OcfUe, pagingPriority *ngapType.PagingPriority, pagingOriginNon3GPP bool) ([]byte, error) {
-
-	// TODO: Paging DRX (optional)
+// ran: the Paging DRX is the shorter of the UE specific DRX and the default paging DRX of the RAN
+func BuildPaging(ue *context.OcfUe, ran *context.OcfRan,
+	pagingPriority *ngapType.PagingPriority, pagingOriginNon3GPP bool) ([]byte, error) {
 
 	var pdu ngapType.NGAPPDU
 	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
@@ -2121,31 +2077,33 @@ func BuildPaging(
 
 	uePagingIdentity := ie.Value.UEPagingIdentity
 	uePagingIdentity.Present = ngapType.UEPagingIdentityPresentFiveGSTMSI
-	uePagingIdentity.FiveGSTMSI = new(ngapType.FiveGSTMSI)
-
-	var amfID string
-	var tmsi string
-	if len(ue.Guti) == 19 {
-		amfID = ue.Guti[5:11]
-		tmsi = ue.Guti[11:]
-	} else {
-		amfID = ue.Guti[6:12]
-		tmsi = ue.Guti[12:]
+	// the UE is paged with the 5G-GUTI it knows, a 5G-GUTI reallocated meanwhile is sent once it connects
+	guti := ue.Guti
+	if ue.OldGuti != "" {
+		guti = ue.OldGuti
 	}
-	_, amfSetID, amfPointer := ngapConvert.OcfIdToNgap(amfID)
-
-	var err error
-	uePagingIdentity.FiveGSTMSI.OCFSetID.Value = amfSetID
-	uePagingIdentity.FiveGSTMSI.OCFPointer.Value = amfPointer
-	uePagingIdentity.FiveGSTMSI.FiveGTMSI.Value, err = hex.DecodeString(tmsi)
+	fiveGSTMSI, err := BuildIEFiveGSTMSI(guti)
 	if err != nil {
 		logger.NgapLog.Errorf(
 			"[Build Error] DecodeString tmsi error: %+v", err)
 	}
+	uePagingIdentity.FiveGSTMSI = &fiveGSTMSI
 
 	pagingIEs.List = append(pagingIEs.List, ie)
 
 	// Paging DRX (optional)
+	if cycle := ue.UESpecificDRXCycle(); cycle != 0 {
+		if ran != nil && ran.DefaultPagingDRX != 0 && ran.DefaultPagingDRX < cycle {
+			cycle = ran.DefaultPagingDRX
+		}
+		ie = ngapType.PagingIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDPagingDRX
+		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+		ie.Value.Present = ngapType.PagingIEsPresentPagingDRX
+		ie.Value.PagingDRX = new(ngapType.PagingDRX)
+		ie.Value.PagingDRX.Value = pagingDRXValue(cycle)
+		pagingIEs.List = append(pagingIEs.List, ie)
+	}
 
 	// TAI List for Paging
 	ie = ngapType.PagingIEs{}
@@ -2268,6 +2226,20 @@ func BuildPaging(
 	return ngap.Encoder(pdu)
 }
 
+// pagingDRXValue is the Paging DRX of a paging cycle in radio frames
+func pagingDRXValue(cycle int) aper.Enumerated {
+	switch cycle {
+	case 32:
+		return ngapType.PagingDRXPresentV32
+	case 64:
+		return ngapType.PagingDRXPresentV64
+	case 128:
+		return ngapType.PagingDRXPresentV128
+	default:
+		return ngapType.PagingDRXPresentV256
+	}
+}
+
 // TS 23.502 4.2.2.2.3
 // anType: indicate amfUe send this msg for which accessType
 // amfUeNgapID: initial OCF get it from target OCF
@@ -2876,11 +2848,18 @@ func BuildUETNLABindingReleaseRequest(ue *context.RanUe) ([]byte, error) {
 	return ngap.Encoder(pdu)
 }
 
-// Weight Factor associated with each of the TNL association within the OCF
-func BuildOCFConfigurationUpdate(tNLassociationUsage ngapType.TNLAssociationUsage,
-	tNLAddressWeightFactor ngapType.TNLAddressWeightFactor) ([]byte, error) {
+// OCFConfigurationUpdateIEs selects the optional IEs of OCF Configuration Update, their values are
+// the current values of the OCF context
+type OCFConfigurationUpdateIEs struct {
+	OCFName             bool
+	ServedGUAMIList     bool
+	RelativeOCFCapacity bool
+	PLMNSupportList     bool
+}
 
-	amfSelf := context.OCF_Self()
+func BuildOCFConfigurationUpdate(ies OCFConfigurationUpdateIEs) ([]byte, error) {
+
+	parameters := context.OCF_Self().Parameters()
 	var pdu ngapType.NGAPPDU
 
 	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
@@ -2896,151 +2875,72 @@ func BuildOCFConfigurationUpdate(tNLassociationUsage ngapType.TNLAssociationUsag
 	aMFConfigurationUpdateIEs := &aMFConfigurationUpdate.ProtocolIEs
 
 	//	OCF Name(optional)
-	ie := ngapType.OCFConfigurationUpdateIEs{}
-	ie.Id.Value = ngapType.ProtocolIEIDOCFName
-	ie.Criticality.Value = ngapType.CriticalityPresentReject
-	ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentOCFName
-	ie.Value.OCFName = new(ngapType.OCFName)
-
-	aMFName := ie.Value.OCFName
-	aMFName.Value = amfSelf.Name
+	if ies.OCFName {
+		ie := ngapType.OCFConfigurationUpdateIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDOCFName
+		ie.Criticality.Value = ngapType.CriticalityPresentReject
+		ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentOCFName
+		ie.Value.OCFName = new(ngapType.OCFName)
 
-	aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
+		aMFName := ie.Value.OCFName
+		aMFName.Value = parameters.Name
 
-	//	Served GUAMI List
-	ie = ngapType.OCFConfigurationUpdateIEs{}
-	ie.Id.Value = ngapType.ProtocolIEIDServedGUAMIList
-	ie.Criticality.Value = ngapType.CriticalityPresentReject
-	ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentServedGUAMIList
-	ie.Value.ServedGUAMIList = new(ngapType.ServedGUAMIList)
-
-	servedGUAMIList := ie.Value.ServedGUAMIList
-	for _, guami := range amfSelf.ServedGuamiList {
-		servedGUAMIItem := ngapType.ServedGUAMIItem{}
-		servedGUAMIItem.GUAMI.PLMNIdentity = ngapConvert.PlmnIdToNgap(*guami.PlmnId)
-		regionId, setId, prtId := ngapConvert.OcfIdToNgap(guami.OcfId)
-		servedGUAMIItem.GUAMI.OCFRegionID.Value = regionId
-		servedGUAMIItem.GUAMI.OCFSetID.Value = setId
-		servedGUAMIItem.GUAMI.OCFPointer.Value = prtId
-		servedGUAMIList.List = append(servedGUAMIList.List, servedGUAMIItem)
+		aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
 	}
 
-	aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
-
-	//	relative OCF Capability
-	ie = ngapType.OCFConfigurationUpdateIEs{}
-	ie.Id.Value = ngapType.ProtocolIEIDRelativeOCFCapacity
-	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
-	ie.Value.Present = ngapType.NGSetupResponseIEsPresentRelativeOCFCapacity
-	ie.Value.RelativeOCFCapacity = new(ngapType.RelativeOCFCapacity)
-	relativeOCFCapacity := ie.Value.RelativeOCFCapacity
-	relativeOCFCapacity.Value = amfSelf.RelativeCapacity
-
-	aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
-
-	//	PLMN Support List
-	ie = ngapType.OCFConfigurationUpdateIEs{}
-	ie.Id.Value = ngapType.ProtocolIEIDPLMNSupportList
-	ie.Criticality.Value = ngapType.CriticalityPresentReject
-	ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentPLMNSupportList
-	ie.Value.PLMNSupportList = new(ngapType.PLMNSupportList)
-
-	pLMNSupportList := ie.Value.PLMNSupportList
-	for _, plmnItem := range amfSelf.PlmnSupportList {
-		pLMNSupportItem := ngapType.PLMNSupportItem{}
-		pLMNSupportItem.PLMNIdentity = ngapConvert.PlmnIdToNgap(plmnItem.PlmnId)
-		for _, snssai := range plmnItem.SNssaiList {
-			sliceSupportItem := ngapType.SliceSupportItem{}
-			sliceSupportItem.SNSSAI = ngapConvert.SNssaiToNgap(snssai)
-			pLMNSupportItem.SliceSupportList.List =
-				append(pLMNSupportItem.SliceSupportList.List, sliceSupportItem)
+	//	Served GUAMI List(optional)
+	if ies.ServedGUAMIList {
+		ie := ngapType.OCFConfigurationUpdateIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDServedGUAMIList
+		ie.Criticality.Value = ngapType.CriticalityPresentReject
+		ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentServedGUAMIList
+		ie.Value.ServedGUAMIList = new(ngapType.ServedGUAMIList)
+
+		servedGUAMIList := ie.Value.ServedGUAMIList
+		for _, guami := range parameters.ServedGuamiList {
+			servedGUAMIItem := ngapType.ServedGUAMIItem{}
+			servedGUAMIItem.GUAMI.PLMNIdentity = ngapConvert.PlmnIdToNgap(*guami.PlmnId)
+			regionId, setId, prtId := ngapConvert.OcfIdToNgap(guami.OcfId)
+			servedGUAMIItem.GUAMI.OCFRegionID.Value = regionId
+			servedGUAMIItem.GUAMI.OCFSetID.Value = setId
+			servedGUAMIItem.GUAMI.OCFPointer.Value = prtId
+			servedGUAMIList.List = append(servedGUAMIList.List, servedGUAMIItem)
 		}
-		pLMNSupportList.List = append(pLMNSupportList.List, pLMNSupportItem)
-	}
 
-	aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
-
-	//	OCF TNL Association to Add List
-	ie = ngapType.OCFConfigurationUpdateIEs{}
-	ie.Id.Value = ngapType.ProtocolIEIDOCFTNLAssociationToAddList
-	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
-	ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentOCFTNLAssociationToAddList
-	ie.Value.OCFTNLAssociationToAddList = new(ngapType.OCFTNLAssociationToAddList)
-
-	aMFTNLAssociationToAddList := ie.Value.OCFTNLAssociationToAddList
+		aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
+	}
 
-	//	OCFTNLAssociationToAddItem in OCFTNLAssociationToAddList
-	aMFTNLAssociationToAddItem := ngapType.OCFTNLAssociationToAddItem{}
-	aMFTNLAssociationToAddItem.OCFTNLAssociationAddress.Present =
-		ngapType.CPTransportLayerInformationPresentEndpointIPAddress
-	aMFTNLAssociationToAddItem.OCFTNLAssociationAddress.EndpointIPAddress =
-		new(ngapType.TransportLayerAddress)
-	*aMFTNLAssociationToAddItem.OCFTNLAssociationAddress.EndpointIPAddress =
-		ngapConvert.IPAddressToNgap(amfSelf.RegisterIPv4, amfSelf.HttpIPv6Address)
+	//	Relative OCF Capacity(optional)
+	if ies.RelativeOCFCapacity {
+		ie := ngapType.OCFConfigurationUpdateIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDRelativeOCFCapacity
+		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+		ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentRelativeOCFCapacity
+		ie.Value.RelativeOCFCapacity = new(ngapType.RelativeOCFCapacity)
+		relativeOCFCapacity := ie.Value.RelativeOCFCapacity
+		relativeOCFCapacity.Value = parameters.RelativeCapacity
 
-	//	OCF TNL Association Usage[optional]
-	if aMFTNLAssociationToAddItem.TNLAssociationUsage != nil {
-		aMFTNLAssociationToAddItem.TNLAssociationUsage = new(ngapType.TNLAssociationUsage)
-		aMFTNLAssociationToAddItem.TNLAssociationUsage = &tNLassociationUsage
+		aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
 	}
 
-	//	OCF TNL Address Weight Factor
-	aMFTNLAssociationToAddItem.TNLAddressWeightFactor = tNLAddressWeightFactor
-
-	aMFTNLAssociationToAddList.List = append(aMFTNLAssociationToAddList.List, aMFTNLAssociationToAddItem)
-	aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
+	//	PLMN Support List(optional)
+	if ies.PLMNSupportList {
+		ie := ngapType.OCFConfigurationUpdateIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDPLMNSupportList
+		ie.Criticality.Value = ngapType.CriticalityPresentReject
+		ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentPLMNSupportList
+		ie.Value.PLMNSupportList = new(ngapType.PLMNSupportList)
+
+		pLMNSupportList := ie.Value.PLMNSupportList
+		for _, plmnItem := range parameters.PlmnSupportList {
+			pLMNSupportItem := ngapType.PLMNSupportItem{}
+			pLMNSupportItem.PLMNIdentity = ngapConvert.PlmnIdToNgap(plmnItem.PlmnId)
+			pLMNSupportItem.SliceSupportList = BuildIESliceSupportList(plmnItem.SNssaiList)
+			pLMNSupportList.List = append(pLMNSupportList.List, pLMNSupportItem)
+		}
 
-	//	OCF TNL Association to Remove List
-	ie = ngapType.OCFConfigurationUpdateIEs{}
-	ie.Id.Value = ngapType.ProtocolIEIDOCFTNLAssociationToRemoveList
-	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
-	ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentOCFTNLAssociationToRemoveList
-	ie.Value.OCFTNLAssociationToRemoveList = new(ngapType.OCFTNLAssociationToRemoveList)
-
-	aMFTNLAssociationToRemoveList := ie.Value.OCFTNLAssociationToRemoveList
-
-	//	OCFTNLAssociationToRemoveItem
-	aMFTNLAssociationToRemoveItem := ngapType.OCFTNLAssociationToRemoveItem{}
-	aMFTNLAssociationToRemoveItem.OCFTNLAssociationAddress.Present =
-		ngapType.CPTransportLayerInformationPresentEndpointIPAddress
-	aMFTNLAssociationToRemoveItem.OCFTNLAssociationAddress.EndpointIPAddress =
-		new(ngapType.TransportLayerAddress)
-	*aMFTNLAssociationToRemoveItem.OCFTNLAssociationAddress.EndpointIPAddress =
-		ngapConvert.IPAddressToNgap(amfSelf.RegisterIPv4, amfSelf.HttpIPv6Address)
-
-	aMFTNLAssociationToRemoveList.List = append(aMFTNLAssociationToRemoveList.List, aMFTNLAssociationToRemoveItem)
-	aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
-
-	//	OCFTNLAssociationToUpdateList
-	ie = ngapType.OCFConfigurationUpdateIEs{}
-	ie.Id.Value = ngapType.ProtocolIEIDOCFTNLAssociationToUpdateList
-	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
-	ie.Value.Present = ngapType.OCFConfigurationUpdateIEsPresentOCFTNLAssociationToUpdateList
-	ie.Value.OCFTNLAssociationToUpdateList = new(ngapType.OCFTNLAssociationToUpdateList)
-
-	aMFTNLAssociationToUpdateList := ie.Value.OCFTNLAssociationToUpdateList
-
-	//	OCFTNLAssociationAddress in OCFTNLAssociationtoUpdateItem
-	aMFTNLAssociationToUpdateItem := ngapType.OCFTNLAssociationToUpdateItem{}
-	aMFTNLAssociationToUpdateItem.OCFTNLAssociationAddress.Present =
-		ngapType.CPTransportLayerInformationPresentEndpointIPAddress
-	aMFTNLAssociationToUpdateItem.OCFTNLAssociationAddress.EndpointIPAddress =
-		new(ngapType.TransportLayerAddress)
-	*aMFTNLAssociationToUpdateItem.OCFTNLAssociationAddress.EndpointIPAddress =
-		ngapConvert.IPAddressToNgap(amfSelf.RegisterIPv4, amfSelf.HttpIPv6Address)
-
-	//	TNLAssociationUsage in OCFTNLAssociationtoUpdateItem [optional]
-	if aMFTNLAssociationToUpdateItem.TNLAssociationUsage != nil {
-		aMFTNLAssociationToUpdateItem.TNLAssociationUsage = new(ngapType.TNLAssociationUsage)
-		aMFTNLAssociationToUpdateItem.TNLAssociationUsage = &tNLassociationUsage
-	}
-	//	TNLAddressWeightFactor in OCFTNLAssociationtoUpdateItem [optional]
-	if aMFTNLAssociationToUpdateItem.TNLAddressWeightFactor != nil {
-		aMFTNLAssociationToUpdateItem.TNLAddressWeightFactor = new(ngapType.TNLAddressWeightFactor)
-		aMFTNLAssociationToUpdateItem.TNLAddressWeightFactor = &tNLAddressWeightFactor
-	}
-	aMFTNLAssociationToUpdateList.List = append(aMFTNLAssociationToUpdateList.List, aMFTNLAssociationToUpdateItem)
-	aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
+		aMFConfigurationUpdateIEs.List = append(aMFConfigurationUpdateIEs.List, ie)
+	}
 
 	return ngap.Encoder(pdu)
 }
@@ -2,6 +2,8 @@ package message
 
 import (
 	"encoding/hex"
+	"fmt"
+	"free5gc/lib/nas/nasType"
 	"free5gc/lib/ngap/ngapConvert"
 	"free5gc/lib/ngap/ngapType"
 	"free5gc/lib/openapi/models"
@@ -158,3 +160,60 @@ func BuildUnavailableGUAMIList(guamiList []models.Guami) (unavailableGUAMIList n
 	}
 	return
 }
+
+// The builders below are shared by the OCF and the gNB simulator (see sim), both sides of N2 encode these IEs the
+// same way
+
+// BuildIEUESecurityCapabilities maps the NR algorithms of the UE security capability (TS 24.501 9.11.3.54)
+// to the UE Security Capabilities IE (TS 38.413 9.3.1.86), only NR algorithms are supported
+func BuildIEUESecurityCapabilities(
+	ueSecurityCapability *nasType.UESecurityCapability) (ueSecurityCapabilities ngapType.UESecurityCapabilities) {
+	// the bit of 128-NEA1/128-NIA1 is the first bit of the IE, the bit of NEA0/NIA0 is dropped
+	nrEncryptionAlgorithm := []byte{0x00, 0x00}
+	nrIntegrityAlgorithm := []byte{0x00, 0x00}
+	if ueSecurityCapability != nil && len(ueSecurityCapability.Buffer) >= 2 {
+		nrEncryptionAlgorithm[0] = ueSecurityCapability.Buffer[0] << 1 & 0xe0
+		nrIntegrityAlgorithm[0] = ueSecurityCapability.Buffer[1] << 1 & 0xe0
+	}
+	ueSecurityCapabilities.NRencryptionAlgorithms.Value = ngapConvert.ByteToBitString(nrEncryptionAlgorithm, 16)
+	ueSecurityCapabilities.NRintegrityProtectionAlgorithms.Value =
+		ngapConvert.ByteToBitString(nrIntegrityAlgorithm, 16)
+
+	// only support NR algorithms
+	ueSecurityCapabilities.EUTRAencryptionAlgorithms.Value = ngapConvert.ByteToBitString([]byte{0x00, 0x00}, 16)
+	ueSecurityCapabilities.EUTRAintegrityProtectionAlgorithms.Value =
+		ngapConvert.ByteToBitString([]byte{0x00, 0x00}, 16)
+	return
+}
+
+// BuildIESliceSupportList is the Slice Support List of the PLMN Support List or of the TAI Slice Support List
+func BuildIESliceSupportList(sNssaiList []models.Snssai) (sliceSupportList ngapType.SliceSupportList) {
+	for _, snssai := range sNssaiList {
+		sliceSupportItem := ngapType.SliceSupportItem{}
+		sliceSupportItem.SNSSAI = ngapConvert.SNssaiToNgap(snssai)
+		sliceSupportList.List = append(sliceSupportList.List, sliceSupportItem)
+	}
+	return
+}
+
+// BuildIEFiveGSTMSI is the 5G-S-TMSI of a 5G-GUTI, i.e. the OCF Set ID, the OCF Pointer and the 5G-TMSI
+func BuildIEFiveGSTMSI(guti string) (fiveGSTMSI ngapType.FiveGSTMSI, err error) {
+	var amfID string
+	var tmsi string
+	switch len(guti) {
+	case 19:
+		amfID = guti[5:11]
+		tmsi = guti[11:]
+	case 20:
+		amfID = guti[6:12]
+		tmsi = guti[12:]
+	default:
+		return fiveGSTMSI, fmt.Errorf("Wrong 5G-GUTI[%s]", guti)
+	}
+	_, amfSetID, amfPointer := ngapConvert.OcfIdToNgap(amfID)
+
+	fiveGSTMSI.OCFSetID.Value = amfSetID
+	fiveGSTMSI.OCFPointer.Value = amfPointer
+	fiveGSTMSI.FiveGTMSI.Value, err = hex.DecodeString(tmsi)
+	return
+}
@@ -1,11 +1,14 @@
 package message
 
 import (
+	"fmt"
 	"free5gc/lib/aper"
 	"free5gc/lib/ngap/ngapType"
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/context"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/ngap/capture"
+	ngap_service "free5gc/src/ocf/ngap/service"
 	"free5gc/src/ocf/producer/callback"
 	"free5gc/src/ocf/util"
 	"time"
@@ -19,7 +22,12 @@ func init() {
 	ngaplog = logger.NgapLog
 }
 
+// SendToRan sends non-UE-associated signalling on the SCTP stream reserved for it
 func SendToRan(ran *context.OcfRan, packet []byte) {
+	sendToRanOnStream(ran, ngap_service.NonUeAssociatedStreamId, packet)
+}
+
+func sendToRanOnStream(ran *context.OcfRan, streamId uint16, packet []byte) {
 
 	if ran == nil {
 		ngaplog.Error("Ran is nil")
@@ -31,14 +39,15 @@ func SendToRan(ran *context.OcfRan, packet []byte) {
 		return
 	}
 
-	ngaplog.Debugf("[NGAP] Send To Ran [IP: %s]", ran.Conn.RemoteAddr().String())
+	ngaplog.Debugf("[NGAP] Send To Ran [IP: %s, Stream: %d]", ran.Conn.RemoteAddr().String(), streamId)
 
-	if n, err := ran.Conn.Write(packet); err != nil {
+	if n, err := ngap_service.Write(ran.Conn, streamId, packet); err != nil {
 		ngaplog.Errorf("Send error: %+v", err)
 		return
 	} else {
 		ngaplog.Debugf("Write %d bytes", n)
 	}
+	capture.Downlink(ran.Conn, streamId, packet)
 }
 
 func SendToRanUe(ue *context.RanUe, packet []byte) {
@@ -59,7 +68,7 @@ func SendToRanUe(ue *context.RanUe, packet []byte) {
 		ngaplog.Warn("OcfUe is nil")
 	}
 
-	SendToRan(ran, packet)
+	sendToRanOnStream(ran, ngap_service.UeAssociatedStreamId(ran.Conn, ue.OcfUeNgapId), packet)
 }
 
 func NasSendToRan(ue *context.OcfUe, accessType models.AccessType, packet []byte) {
@@ -90,7 +99,7 @@ func SendNGSetupResponse(ran *context.OcfRan) {
 	SendToRan(ran, pkt)
 }
 
-func SendNGSetupFailure(ran *context.OcfRan, cause ngapType.Cause) {
+func SendNGSetupFailure(ran *context.OcfRan, cause ngapType.Cause, timeToWait *ngapType.TimeToWait) {
 
 	ngaplog.Info("[OCF] Send NG-Setup failure")
 
@@ -99,7 +108,7 @@ func SendNGSetupFailure(ran *context.OcfRan, cause ngapType.Cause) {
 		return
 	}
 
-	pkt, err := BuildNGSetupFailure(cause)
+	pkt, err := BuildNGSetupFailure(cause, timeToWait)
 	if err != nil {
 		ngaplog.Errorf("Build NGSetupFailure failed : %s", err.Error())
 		return
@@ -220,7 +229,11 @@ func SendErrorIndication(ran *context.OcfRan, amfUeNgapId, ranUeNgapId *int64, c
 		ngaplog.Errorf("Build ErrorIndication failed : %s", err.Error())
 		return
 	}
-	SendToRan(ran, pkt)
+	if amfUeNgapId != nil {
+		sendToRanOnStream(ran, ngap_service.UeAssociatedStreamId(ran.Conn, *amfUeNgapId), pkt)
+	} else {
+		SendToRan(ran, pkt)
+	}
 }
 
 func SendUERadioCapabilityCheckRequest(ue *context.RanUe) {
@@ -590,7 +603,7 @@ func SendPathSwitchRequestFailure(
 		ngaplog.Errorf("Build PathSwitchRequestFailure failed : %s", err.Error())
 		return
 	}
-	SendToRan(ran, pkt)
+	sendToRanOnStream(ran, ngap_service.UeAssociatedStreamId(ran.Conn, amfUeNgapId), pkt)
 }
 
 //RanStatusTransferTransparentContainer from Uplink Ran Configuration Transfer
@@ -626,40 +639,37 @@ func SendDownlinkRanStatusTransfer(ue *context.RanUe, container ngapType.RANStat
 // is associated with non-3GPP access, the OCF sends a Paging message with associated access "non-3GPP" to
 // NG-RAN node(s) via 3GPP access.
 // more paging policy with 3gpp/non-3gpp access is described in TS 23.501 5.6.8
-func SendPaging(ue *context.OcfUe, ngapBuf []byte) {
-
-	// var pagingPriority *ngapType.PagingPriority
+// The Paging is built for each RAN serving a TAI of the registration area with the paging DRX of the RAN
+func SendPaging(ue *context.OcfUe, pagingPriority *ngapType.PagingPriority, pagingOriginNon3GPP bool) error {
 	if ue == nil {
-		ngaplog.Error("OcfUe is nil")
-		return
+		return fmt.Errorf("OcfUe is nil")
+	}
+	// a UE in MICO mode is not paged once its active time is over
+	if ue.Reachability == models.UeReachability_UNREACHABLE {
+		return fmt.Errorf("Ue[%s] is not reachable", ue.Supi)
+	}
+	if ue.RegistrationArea[models.AccessType__3_GPP_ACCESS] == nil {
+		return fmt.Errorf("Registration Area of Ue[%s] is empty", ue.Supi)
 	}
 
-	// if ppi != nil {
-	// pagingPriority = new(ngapType.PagingPriority)
-	// pagingPriority.Value = aper.Enumerated(*ppi)
-	// }
-	// pkt, err := BuildPaging(ue, pagingPriority, pagingOriginNon3GPP)
-	// if err != nil {
-	// 	ngaplog.Errorf("Build Paging failed : %s", err.Error())
-	// }
-	taiList := ue.RegistrationArea[models.AccessType__3_GPP_ACCESS]
-	context.OCF_Self().OcfRanPool.Range(func(key, value interface{}) bool {
-		ran := value.(*context.OcfRan)
-		for _, item := range ran.SupportedTAList {
-			if context.InTaiList(item.Tai, taiList) {
-				ngaplog.Infof("[OCF] Send Paging to TAI(%+v, Tac:%+v) for Ue[%s]",
-					item.Tai.PlmnId, item.Tai.Tac, ue.Supi)
-				SendToRan(ran, ngapBuf)
-				break
-			}
-		}
-		return true
-	})
+	ue.PagingPriority = pagingPriority
+	ue.PagingOriginNon3GPP = pagingOriginNon3GPP
+
+	// the RANs are selected again at each retransmission, the TAs of the RANs and the registration
+	// area may have changed in between
+	page := func() {
+		context.OCF_Self().OcfRanPool.Range(func(key, value interface{}) bool {
+			pageRan(ue, value.(*context.OcfRan))
+			return true
+		})
+	}
+	page()
 
+	t3513 := ue.Timer(context.TimerT3513, models.AccessType__3_GPP_ACCESS)
 	ue.T3513RetryTimes = 0
-	ue.T3513 = time.AfterFunc(context.TimeT3513, func() {
+	ue.T3513 = time.AfterFunc(t3513.Value, func() {
 		ue.T3513RetryTimes++
-		if ue.T3513RetryTimes > context.MaxT3513RetryTimes {
+		if ue.T3513RetryTimes > t3513.MaxRetryTimes {
 			logger.GmmLog.Warnf("UE[%s] T3513 expires %d times, abort paging procedure", ue.Supi, ue.T3513RetryTimes)
 			if ue.OnGoing[models.AccessType__3_GPP_ACCESS].Procedure != context.OnGoingProcedureN2Handover {
 				callback.SendN1N2TransferFailureNotification(ue, models.N1N2MessageTransferCause_UE_NOT_RESPONDING)
@@ -668,19 +678,50 @@ func SendPaging(ue *context.OcfUe, ngapBuf []byte) {
 		} else {
 			logger.NgapLog.Warnf("[NGAP] T3513 expires, retransmit Paging (UE: [%s], retry: %d)",
 				ue.Supi, ue.T3513RetryTimes)
-			context.OCF_Self().OcfRanPool.Range(func(key, value interface{}) bool {
-				ran := value.(*context.OcfRan)
-				for _, item := range ran.SupportedTAList {
-					if context.InTaiList(item.Tai, taiList) {
-						SendToRan(ran, ngapBuf)
-						break
-					}
-				}
-				return true
-			})
-			ue.T3513.Reset(context.TimeT3513)
+			page()
+			ue.T3513.Reset(t3513.Value)
 		}
 	})
+	return nil
+}
+
+// pageRan sends the Paging of the UE to the RAN if the RAN serves a TA of the registration area of the UE
+func pageRan(ue *context.OcfUe, ran *context.OcfRan) {
+	taiList := ue.RegistrationArea[models.AccessType__3_GPP_ACCESS]
+	for _, item := range ran.SupportedTAList {
+		if context.InTaiList(item.Tai, taiList) {
+			pkt, err := BuildPaging(ue, ran, ue.PagingPriority, ue.PagingOriginNon3GPP)
+			if err != nil {
+				ngaplog.Errorf("Build Paging failed : %s", err.Error())
+				return
+			}
+			ngaplog.Infof("[OCF] Send Paging to TAI(%+v, Tac:%+v) for Ue[%s]",
+				item.Tai.PlmnId, item.Tai.Tac, ue.Supi)
+			SendToRan(ran, pkt)
+			return
+		}
+	}
+}
+
+// PageInAddedTAs is the RAN configuration handler of the paging: the UEs being paged in a TA the RAN
+// serves from now on are paged by the RAN at once, not at the next retransmission of their paging
+func PageInAddedTAs(ran *context.OcfRan, change context.RanConfigurationChange) {
+	if len(change.AddedTAIs) == 0 || ran.AnType != models.AccessType__3_GPP_ACCESS {
+		return
+	}
+	context.OCF_Self().UePool.Range(func(key, value interface{}) bool {
+		ue := value.(*context.OcfUe)
+		if ue.T3513 == nil {
+			return true
+		}
+		for _, tai := range change.AddedTAIs {
+			if context.InTaiList(tai, ue.RegistrationArea[models.AccessType__3_GPP_ACCESS]) {
+				pageRan(ue, ran)
+				break
+			}
+		}
+		return true
+	})
 }
 
 // TS 23.502 4.2.2.2.3
@@ -872,7 +913,7 @@ func SendDownlinkNonUEAssociatedNRPPATransport(ue *context.RanUe, nRPPaPDU ngapT
 		ngaplog.Errorf("Build DownlinkNonUEAssociatedNRPPATransport failed : %s", err.Error())
 		return
 	}
-	SendToRanUe(ue, pkt)
+	SendToRan(ue.Ran, pkt)
 }
 
 func SendDeactivateTrace(amfUe *context.OcfUe, anType models.AccessType) {
@@ -958,9 +999,8 @@ func SendUETNLABindingReleaseRequest(ue *context.RanUe) {
 	SendToRanUe(ue, pkt)
 }
 
-// Weight Factor associated with each of the TNL association within the OCF
-func SendOCFConfigurationUpdate(ran *context.OcfRan, usage ngapType.TNLAssociationUsage,
-	weightfactor ngapType.TNLAddressWeightFactor) {
+// ies: the IEs of the OCF configuration which have changed
+func SendOCFConfigurationUpdate(ran *context.OcfRan, ies OCFConfigurationUpdateIEs) {
 
 	ngaplog.Info("[OCF] Send OCF Configuration Update")
 
@@ -969,7 +1009,7 @@ func SendOCFConfigurationUpdate(ran *context.OcfRan, usage ngapType.TNLAssociati
 		return
 	}
 
-	pkt, err := BuildOCFConfigurationUpdate(usage, weightfactor)
+	pkt, err := BuildOCFConfigurationUpdate(ies)
 	if err != nil {
 		ngaplog.Errorf("Build OCFConfigurationUpdate failed : %s", err.Error())
 		return
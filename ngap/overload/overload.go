@@ -0,0 +1,312 @@
+// Package overload detects the overload of the OCF from its load measurements: the depth of the
+// NGAP worker queues, the latency of the requests to UDM, AUSF and SMF, and the number of UE
+// contexts against the UE capacity. When overloaded the OCF asks every RAN to reduce the
+// signalling with Overload Start, Overload Stop is sent once the load is back to normal.
+package overload
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"free5gc/lib/aper"
+	"free5gc/lib/ngap/ngapConvert"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/consumer"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+	ngap_message "free5gc/src/ocf/ngap/message"
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+// the NF types whose request latency is measured
+var measuredNfTypes = []models.NfType{models.NfType_UDM, models.NfType_AUSF, models.NfType_SMF}
+
+var overloadActions = map[string]aper.Enumerated{
+	context.OverloadActionRejectNonEmergencyMoDt:  ngapType.OverloadActionPresentRejectNonEmergencyMoDt,
+	context.OverloadActionRejectRrcCrSignalling:   ngapType.OverloadActionPresentRejectRrcCrSignalling,
+	context.OverloadActionPermitEmergencyAndMt:    ngapType.OverloadActionPresentPermitEmergencySessionsAndMobileTerminatedServicesOnly,
+	context.OverloadActionPermitHighPriorityAndMt: ngapType.OverloadActionPresentPermitHighPrioritySessionsAndMobileTerminatedServicesOnly,
+}
+
+// Measurement is the load of the OCF at one check
+type Measurement struct {
+	QueueDepth int
+	SbiLatency map[models.NfType]time.Duration
+	UeCount    int
+}
+
+// Status of the overload control as shown by OAM
+type Status struct {
+	Enable               bool                    `json:"enable"`
+	Overloaded           bool                    `json:"overloaded"`
+	Since                *time.Time              `json:"since,omitempty"`
+	Reason               string                  `json:"reason,omitempty"`
+	Action               string                  `json:"action"`
+	TrafficLoadReduction int64                   `json:"trafficLoadReduction,omitempty"`
+	SliceList            []models.Snssai         `json:"sliceList,omitempty"`
+	QueueDepth           int                     `json:"queueDepth"`
+	SbiLatency           map[models.NfType]int64 `json:"sbiLatency"` // unit is millisecond
+	UeCount              int                     `json:"ueCount"`
+	UeLoad               int                     `json:"ueLoad"` // percentage of the UE capacity
+	OverloadStarts       int                     `json:"overloadStarts"`
+	LastCheck            *time.Time              `json:"lastCheck,omitempty"`
+}
+
+var (
+	mutex      sync.Mutex
+	parameters context.OverloadControlParameters
+	stopCheck  chan struct{}
+	status     = Status{SbiLatency: make(map[models.NfType]int64)}
+)
+
+// Start (re)starts the periodic check with the parameters, the check is stopped if Enable is false
+func Start(overloadParameters context.OverloadControlParameters) {
+	mutex.Lock()
+	if stopCheck != nil {
+		close(stopCheck)
+		stopCheck = nil
+	}
+	parameters = overloadParameters
+	status.Enable = parameters.Enable
+	status.Action = parameters.Action
+	status.TrafficLoadReduction = parameters.TrafficLoadReduction
+	status.SliceList = parameters.SliceList
+	overloaded := status.Overloaded
+	if !parameters.Enable {
+		clearOverload()
+		mutex.Unlock()
+		if overloaded {
+			sendOverloadStop()
+		}
+		return
+	}
+	stopCheck = make(chan struct{})
+	go checkLoop(parameters.CheckInterval, stopCheck)
+	mutex.Unlock()
+
+	// the RANs apply the new action and reduction at once
+	if overloaded {
+		sendOverloadStart(overloadParameters)
+	}
+	logger.NgapLog.Infof("NGAP overload control started, check every %s", parameters.CheckInterval)
+}
+
+func Stop() {
+	mutex.Lock()
+	overloadParameters := parameters
+	mutex.Unlock()
+	overloadParameters.Enable = false
+	Start(overloadParameters)
+}
+
+// Overloaded tells if the RANs have been asked to reduce the signalling
+func Overloaded() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return status.Overloaded
+}
+
+func GetStatus() Status {
+	mutex.Lock()
+	defer mutex.Unlock()
+	current := status
+	current.SbiLatency = make(map[models.NfType]int64)
+	for nfType, latency := range status.SbiLatency {
+		current.SbiLatency[nfType] = latency
+	}
+	return current
+}
+
+// RanSetUp sends Overload Start to a RAN which completes its NG Setup while the OCF is overloaded
+func RanSetUp(ran *context.OcfRan) {
+	mutex.Lock()
+	overloaded := status.Overloaded
+	overloadParameters := parameters
+	mutex.Unlock()
+	if overloaded {
+		response, reduction, nssaiList := overloadStartIEs(overloadParameters)
+		ngap_message.SendOverloadStart(ran, response, reduction, nssaiList)
+	}
+}
+
+func checkLoop(interval time.Duration, stop chan struct{}) {
+	if interval <= 0 {
+		interval = context.DefaultOverloadCheckInterval
+	}
+	// the latency measured before the start does not count
+	consumer.TakeSbiLatency()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			check(stop)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func measure() Measurement {
+	m := Measurement{
+		QueueDepth: ngap_service.QueueDepth(),
+		SbiLatency: consumer.TakeSbiLatency(),
+	}
+	context.OCF_Self().UePool.Range(func(key, value interface{}) bool {
+		m.UeCount++
+		return true
+	})
+	return m
+}
+
+func check(stop chan struct{}) {
+	m := measure()
+	now := time.Now()
+
+	mutex.Lock()
+	if stop != stopCheck {
+		// restarted while measuring
+		mutex.Unlock()
+		return
+	}
+	status.QueueDepth = m.QueueDepth
+	status.SbiLatency = make(map[models.NfType]int64)
+	for nfType, latency := range m.SbiLatency {
+		status.SbiLatency[nfType] = int64(latency / time.Millisecond)
+	}
+	status.UeCount = m.UeCount
+	status.UeLoad = ueLoad(parameters, m)
+	status.LastCheck = &now
+
+	var start, stopOverload bool
+	if !status.Overloaded {
+		if reason := exceeded(parameters, m); reason != "" {
+			start = true
+			status.Overloaded = true
+			status.Since = &now
+			status.Reason = reason
+			status.OverloadStarts++
+		}
+	} else if recovered(parameters, m) {
+		stopOverload = true
+		clearOverload()
+	}
+	overloadParameters := parameters
+	reason := status.Reason
+	mutex.Unlock()
+
+	if start {
+		logger.NgapLog.Warnf("OCF overloaded (%s), send Overload Start to all RANs", reason)
+		sendOverloadStart(overloadParameters)
+	} else if stopOverload {
+		logger.NgapLog.Infof("OCF load back to normal, send Overload Stop to all RANs")
+		sendOverloadStop()
+	}
+}
+
+func clearOverload() {
+	status.Overloaded = false
+	status.Since = nil
+	status.Reason = ""
+}
+
+func ueLoad(p context.OverloadControlParameters, m Measurement) int {
+	if p.UeCapacity <= 0 {
+		return 0
+	}
+	return m.UeCount * 100 / p.UeCapacity
+}
+
+// exceeded returns which measurement reached its start threshold, empty if none
+func exceeded(p context.OverloadControlParameters, m Measurement) string {
+	if p.QueueDepthStart > 0 && m.QueueDepth >= p.QueueDepthStart {
+		return fmt.Sprintf("queue depth %d >= %d", m.QueueDepth, p.QueueDepthStart)
+	}
+	if p.SbiLatencyStart > 0 {
+		for _, nfType := range measuredNfTypes {
+			if latency := m.SbiLatency[nfType]; latency >= p.SbiLatencyStart {
+				return fmt.Sprintf("%s latency %s >= %s", nfType, latency, p.SbiLatencyStart)
+			}
+		}
+	}
+	if p.UeCapacity > 0 && p.UeLoadStart > 0 {
+		if load := ueLoad(p, m); load >= p.UeLoadStart {
+			return fmt.Sprintf("UE load %d%% >= %d%%", load, p.UeLoadStart)
+		}
+	}
+	return ""
+}
+
+// recovered tells if every measurement is under its stop threshold, a stop threshold which is
+// not under the start threshold is taken as the start threshold
+func recovered(p context.OverloadControlParameters, m Measurement) bool {
+	if p.QueueDepthStart > 0 && m.QueueDepth >= stopThreshold(p.QueueDepthStart, p.QueueDepthStop) {
+		return false
+	}
+	if p.SbiLatencyStart > 0 {
+		stop := time.Duration(stopThreshold(int(p.SbiLatencyStart), int(p.SbiLatencyStop)))
+		for _, nfType := range measuredNfTypes {
+			if m.SbiLatency[nfType] >= stop {
+				return false
+			}
+		}
+	}
+	if p.UeCapacity > 0 && p.UeLoadStart > 0 && ueLoad(p, m) >= stopThreshold(p.UeLoadStart, p.UeLoadStop) {
+		return false
+	}
+	return true
+}
+
+func stopThreshold(start, stop int) int {
+	if stop <= 0 || stop > start {
+		return start
+	}
+	return stop
+}
+
+// overloadStartIEs builds the optional IEs of Overload Start, the action and the reduction apply
+// to the slices of SliceList only if it is set
+func overloadStartIEs(p context.OverloadControlParameters) (
+	*ngapType.OverloadResponse, int64, *ngapType.OverloadStartNSSAIList) {
+	action, ok := overloadActions[p.Action]
+	if !ok {
+		action = ngapType.OverloadActionPresentRejectNonEmergencyMoDt
+	}
+	response := &ngapType.OverloadResponse{
+		Present:        ngapType.OverloadResponsePresentOverloadAction,
+		OverloadAction: &ngapType.OverloadAction{Value: action},
+	}
+	if len(p.SliceList) == 0 {
+		return response, p.TrafficLoadReduction, nil
+	}
+
+	item := ngapType.OverloadStartNSSAIItem{
+		SliceOverloadResponse: response,
+	}
+	if p.TrafficLoadReduction != 0 {
+		item.SliceTrafficLoadReductionIndication = &ngapType.TrafficLoadReductionIndication{
+			Value: p.TrafficLoadReduction,
+		}
+	}
+	for _, snssai := range p.SliceList {
+		item.SliceOverloadList.List = append(item.SliceOverloadList.List, ngapType.SliceOverloadItem{
+			SNSSAI: ngapConvert.SNssaiToNgap(snssai),
+		})
+	}
+	return nil, 0, &ngapType.OverloadStartNSSAIList{List: []ngapType.OverloadStartNSSAIItem{item}}
+}
+
+func sendOverloadStart(p context.OverloadControlParameters) {
+	response, reduction, nssaiList := overloadStartIEs(p)
+	for _, ran := range context.OCF_Self().SetUpOcfRans() {
+		ngap_message.SendOverloadStart(ran, response, reduction, nssaiList)
+	}
+}
+
+func sendOverloadStop() {
+	for _, ran := range context.OCF_Self().SetUpOcfRans() {
+		ngap_message.SendOverloadStop(ran)
+	}
+}
@@ -0,0 +1,72 @@
+package overload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+func testParameters() context.OverloadControlParameters {
+	return context.OverloadControlParameters{
+		Enable:               true,
+		QueueDepthStart:      100,
+		QueueDepthStop:       50,
+		SbiLatencyStart:      time.Second,
+		SbiLatencyStop:       500 * time.Millisecond,
+		UeCapacity:           1000,
+		UeLoadStart:          90,
+		UeLoadStop:           80,
+		Action:               context.OverloadActionRejectRrcCrSignalling,
+		TrafficLoadReduction: 30,
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	p := testParameters()
+
+	assert.Equal(t, "", exceeded(p, Measurement{QueueDepth: 99, UeCount: 899}))
+	assert.Equal(t, "queue depth 100 >= 100", exceeded(p, Measurement{QueueDepth: 100}))
+	assert.Equal(t, "UE load 90% >= 90%", exceeded(p, Measurement{UeCount: 900}))
+	assert.Equal(t, "AUSF latency 1.2s >= 1s", exceeded(p, Measurement{
+		SbiLatency: map[models.NfType]time.Duration{models.NfType_AUSF: 1200 * time.Millisecond},
+	}))
+
+	// hysteresis: still overloaded between the stop and the start thresholds
+	assert.False(t, recovered(p, Measurement{QueueDepth: 60}))
+	assert.False(t, recovered(p, Measurement{UeCount: 850}))
+	assert.False(t, recovered(p, Measurement{
+		SbiLatency: map[models.NfType]time.Duration{models.NfType_SMF: 700 * time.Millisecond},
+	}))
+	assert.True(t, recovered(p, Measurement{QueueDepth: 49, UeCount: 799}))
+
+	// a start threshold of 0 disables the measurement
+	p.QueueDepthStart = 0
+	p.UeCapacity = 0
+	assert.Equal(t, "", exceeded(p, Measurement{QueueDepth: 1000, UeCount: 100000}))
+	assert.True(t, recovered(p, Measurement{QueueDepth: 1000, UeCount: 100000}))
+}
+
+func TestOverloadStartIEs(t *testing.T) {
+	p := testParameters()
+
+	response, reduction, nssaiList := overloadStartIEs(p)
+	assert.Equal(t, ngapType.OverloadResponsePresentOverloadAction, response.Present)
+	assert.Equal(t, ngapType.OverloadActionPresentRejectRrcCrSignalling, response.OverloadAction.Value)
+	assert.Equal(t, int64(30), reduction)
+	assert.Nil(t, nssaiList)
+
+	p.SliceList = []models.Snssai{{Sst: 1, Sd: "010203"}, {Sst: 2}}
+	response, reduction, nssaiList = overloadStartIEs(p)
+	assert.Nil(t, response)
+	assert.Equal(t, int64(0), reduction)
+	assert.Len(t, nssaiList.List, 1)
+	item := nssaiList.List[0]
+	assert.Len(t, item.SliceOverloadList.List, 2)
+	assert.Equal(t, ngapType.OverloadActionPresentRejectRrcCrSignalling, item.SliceOverloadResponse.OverloadAction.Value)
+	assert.Equal(t, int64(30), item.SliceTrafficLoadReductionIndication.Value)
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+)
+
+// a frame is the message length, the PPID and the stream followed by the message,
+// the header is in network byte order
+const frameHeaderLen = 10
+
+// FramedConn carries NGAP messages over a stream oriented connection (TCP, net.Pipe) and keeps
+// what SCTP provides to NGAP: message boundaries, the PPID and the stream of every message
+type FramedConn struct {
+	net.Conn
+	readMutex  sync.Mutex
+	writeMutex sync.Mutex
+}
+
+func NewFramedConn(conn net.Conn) *FramedConn {
+	return &FramedConn{Conn: conn}
+}
+
+func (c *FramedConn) ReadMsg(buf []byte) (int, *MsgInfo, *Notification, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(c.Conn, header[:]); err != nil {
+		return 0, nil, nil, err
+	}
+	length := int(binary.BigEndian.Uint32(header[0:4]))
+	info := &MsgInfo{
+		PPID:   binary.BigEndian.Uint32(header[4:8]),
+		Stream: binary.BigEndian.Uint16(header[8:10]),
+	}
+	if length > len(buf) {
+		// drain the message to stay in sync with the frames
+		if _, err := io.CopyN(ioutil.Discard, c.Conn, int64(length)); err != nil {
+			return 0, nil, nil, err
+		}
+		return 0, nil, nil, fmt.Errorf("message of %d bytes exceeds the read buffer of %d bytes", length, len(buf))
+	}
+	if _, err := io.ReadFull(c.Conn, buf[:length]); err != nil {
+		return 0, nil, nil, err
+	}
+	return length, info, nil, nil
+}
+
+func (c *FramedConn) WriteMsg(msg []byte, info *MsgInfo) (int, error) {
+	frame := make([]byte, frameHeaderLen+len(msg))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(msg)))
+	binary.BigEndian.PutUint32(frame[4:8], info.PPID)
+	binary.BigEndian.PutUint16(frame[8:10], info.Stream)
+	copy(frame[frameHeaderLen:], msg)
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if _, err := c.Conn.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(msg), nil
+}
+
+// Write sends msg as one NGAP message on stream 0
+func (c *FramedConn) Write(msg []byte) (int, error) {
+	return c.WriteMsg(msg, &MsgInfo{Stream: NonUeAssociatedStreamId, PPID: NGAP_PPID})
+}
+
+// Read receives one message, PPID and stream are dropped
+func (c *FramedConn) Read(buf []byte) (int, error) {
+	n, _, _, err := c.ReadMsg(buf)
+	return n, err
+}
+
+// TCPTransport listens on the first address with framed TCP connections
+type TCPTransport struct {
+	// NumStreams is the number of streams used towards the RAN, 0 uses a single stream
+	NumStreams uint16
+}
+
+func (t *TCPTransport) Listen(addresses []string, port int) (Listener, error) {
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no address to listen on")
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(addresses[0], fmt.Sprint(port)))
+	if err != nil {
+		return nil, err
+	}
+	return &tcpListener{Listener: listener, numStreams: t.NumStreams}, nil
+}
+
+type tcpListener struct {
+	net.Listener
+	numStreams uint16
+}
+
+func (l *tcpListener) Accept() (Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	framedConn := NewFramedConn(conn)
+	if l.numStreams != 0 {
+		setOutboundStreams(framedConn, l.numStreams)
+	}
+	return framedConn, nil
+}
+
+// MemTransport is an in-process transport for unit tests: the RAN side of an association is
+// created by Dial and the OCF side is returned by Accept of the listener
+type MemTransport struct {
+	// NumStreams is the number of streams used towards the RAN, 0 uses a single stream
+	NumStreams uint16
+
+	mutex    sync.Mutex
+	listener *memListener
+}
+
+func (t *MemTransport) Listen(addresses []string, port int) (Listener, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.listener != nil {
+		return nil, fmt.Errorf("memory transport is already listening")
+	}
+	t.listener = &memListener{
+		addr:   &memAddr{name: fmt.Sprintf("ocf:%d", port)},
+		conns:  make(chan *FramedConn, 16),
+		closed: make(chan struct{}),
+	}
+	return t.listener, nil
+}
+
+// Dial creates a new association and returns its RAN side
+func (t *MemTransport) Dial() (*FramedConn, error) {
+	t.mutex.Lock()
+	listener := t.listener
+	t.mutex.Unlock()
+	if listener == nil {
+		return nil, fmt.Errorf("memory transport is not listening")
+	}
+
+	ranSide, ocfSide := net.Pipe()
+	ocfConn := NewFramedConn(ocfSide)
+	if t.NumStreams != 0 {
+		setOutboundStreams(ocfConn, t.NumStreams)
+	}
+	select {
+	case listener.conns <- ocfConn:
+		return NewFramedConn(ranSide), nil
+	case <-listener.closed:
+		return nil, fmt.Errorf("memory transport is closed")
+	}
+}
+
+type memListener struct {
+	addr      net.Addr
+	conns     chan *FramedConn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *memListener) Accept() (Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, fmt.Errorf("memory transport is closed")
+	}
+}
+
+func (l *memListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *memListener) Addr() net.Addr {
+	return l.addr
+}
+
+type memAddr struct {
+	name string
+}
+
+func (a *memAddr) Network() string {
+	return "memory"
+}
+
+func (a *memAddr) String() string {
+	return a.name
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	libngap "free5gc/lib/ngap"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+// MemRan is a RAN set up on the memory transport for unit tests: the OCF knows it as Ran, the messages the
+// OCF sends to it are decoded into PDUs
+type MemRan struct {
+	Ran  *context.OcfRan
+	PDUs <-chan *ngapType.NGAPPDU
+
+	listener Listener
+	ranConn  *FramedConn
+	ocfConn  Conn
+}
+
+// NewMemRan adds a gNB of PLMN 208/93 on the access type to the RANs of the OCF, as if it had completed the
+// NG Setup
+func NewMemRan(anType models.AccessType) (*MemRan, error) {
+	transport := &MemTransport{}
+	listener, err := transport.Listen(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	ranConn, err := transport.Dial()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	ocfConn, err := listener.Accept()
+	if err != nil {
+		ranConn.Close()
+		listener.Close()
+		return nil, err
+	}
+
+	pdus := make(chan *ngapType.NGAPPDU, 16)
+	go func() {
+		buf := make([]byte, 8192)
+		for {
+			n, _, _, err := ranConn.ReadMsg(buf)
+			if err != nil {
+				return
+			}
+			if pdu, err := libngap.Decoder(buf[:n]); err == nil {
+				pdus <- pdu
+			}
+		}
+	}()
+
+	ran := context.OCF_Self().NewOcfRan(ocfConn)
+	ran.AnType = anType
+	ran.RanPresent = context.RanPresentGNbId
+	ran.RanId = &models.GlobalRanNodeId{
+		PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"},
+		GNbId:  &models.GNbId{BitLength: 24, GNBValue: "000001"},
+	}
+	return &MemRan{Ran: ran, PDUs: pdus, listener: listener, ranConn: ranConn, ocfConn: ocfConn}, nil
+}
+
+// Close removes the RAN from the OCF and closes its association
+func (r *MemRan) Close() {
+	context.OCF_Self().DeleteOcfRan(r.ocfConn)
+	r.ranConn.Close()
+	r.ocfConn.Close()
+	r.listener.Close()
+}
+
+// Expect returns the next message the OCF sends to the RAN, which has to be an initiating message of the
+// procedure
+func (r *MemRan) Expect(procedureCode int64, timeout time.Duration) (*ngapType.NGAPPDU, error) {
+	select {
+	case pdu := <-r.PDUs:
+		if pdu.InitiatingMessage == nil {
+			return nil, fmt.Errorf("NGAP message[%d] expected, got an outcome", procedureCode)
+		}
+		if pdu.InitiatingMessage.ProcedureCode.Value != procedureCode {
+			return nil, fmt.Errorf("NGAP message[%d] expected, got NGAP message[%d]", procedureCode,
+				pdu.InitiatingMessage.ProcedureCode.Value)
+		}
+		return pdu, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no NGAP message[%d] within %s", procedureCode, timeout)
+	}
+}
+
+// ExpectNasPdu returns the NAS-PDU of the next message the OCF sends to the RAN, which has to be a Downlink
+// NAS Transport
+func (r *MemRan) ExpectNasPdu(timeout time.Duration) ([]byte, error) {
+	pdu, err := r.Expect(ngapType.ProcedureCodeDownlinkNASTransport, timeout)
+	if err != nil {
+		return nil, err
+	}
+	for _, ie := range pdu.InitiatingMessage.Value.DownlinkNASTransport.ProtocolIEs.List {
+		if ie.Id.Value == ngapType.ProtocolIEIDNASPDU {
+			return ie.Value.NASPDU.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("no NAS-PDU in Downlink NAS Transport")
+}
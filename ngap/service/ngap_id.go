@@ -0,0 +1,75 @@
+package service
+
+import (
+	"free5gc/lib/ngap/ngapType"
+	"reflect"
+)
+
+// UeNgapIds returns the OCF UE NGAP ID (or Source OCF UE NGAP ID) and RAN UE NGAP ID carried
+// in the top level IEs of the PDU, nil if absent. The pointers refer to the IEs of the PDU, so
+// an ID can be rewritten through them.
+func UeNgapIds(pdu *ngapType.NGAPPDU) (amfUeNgapId, ranUeNgapId *int64) {
+	var value reflect.Value
+	switch pdu.Present {
+	case ngapType.NGAPPDUPresentInitiatingMessage:
+		if pdu.InitiatingMessage == nil {
+			return
+		}
+		value = reflect.ValueOf(pdu.InitiatingMessage.Value)
+	case ngapType.NGAPPDUPresentSuccessfulOutcome:
+		if pdu.SuccessfulOutcome == nil {
+			return
+		}
+		value = reflect.ValueOf(pdu.SuccessfulOutcome.Value)
+	case ngapType.NGAPPDUPresentUnsuccessfulOutcome:
+		if pdu.UnsuccessfulOutcome == nil {
+			return
+		}
+		value = reflect.ValueOf(pdu.UnsuccessfulOutcome.Value)
+	default:
+		return
+	}
+
+	// the message value is a CHOICE, only the present message is non-nil
+	for i := 0; i < value.NumField(); i++ {
+		message := value.Field(i)
+		if message.Kind() != reflect.Ptr || message.IsNil() {
+			continue
+		}
+		protocolIEs := message.Elem().FieldByName("ProtocolIEs")
+		if !protocolIEs.IsValid() {
+			return
+		}
+		list := protocolIEs.FieldByName("List")
+		for j := 0; j < list.Len(); j++ {
+			ieValue := list.Index(j).FieldByName("Value")
+			if id := ngapIdValue(ieValue, "OCFUENGAPID"); id != nil {
+				amfUeNgapId = id
+			} else if id := ngapIdValue(ieValue, "SourceOCFUENGAPID"); id != nil {
+				amfUeNgapId = id
+			} else if id := ngapIdValue(ieValue, "RANUENGAPID"); id != nil {
+				ranUeNgapId = id
+			} else if ids := ieValue.FieldByName("UENGAPIDs"); ids.IsValid() && !ids.IsNil() {
+				// UE Context Release Command carries the IDs as a CHOICE of the pair or the OCF UE NGAP ID
+				uENGAPIDs := ids.Interface().(*ngapType.UENGAPIDs)
+				switch uENGAPIDs.Present {
+				case ngapType.UENGAPIDsPresentUENGAPIDPair:
+					amfUeNgapId = &uENGAPIDs.UENGAPIDPair.OCFUENGAPID.Value
+					ranUeNgapId = &uENGAPIDs.UENGAPIDPair.RANUENGAPID.Value
+				case ngapType.UENGAPIDsPresentOCFUENGAPID:
+					amfUeNgapId = &uENGAPIDs.OCFUENGAPID.Value
+				}
+			}
+		}
+		return
+	}
+	return
+}
+
+func ngapIdValue(ieValue reflect.Value, name string) *int64 {
+	field := ieValue.FieldByName(name)
+	if !field.IsValid() || field.Kind() != reflect.Ptr || field.IsNil() {
+		return nil
+	}
+	return field.Elem().FieldByName("Value").Addr().Interface().(*int64)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// SCTP notification types (RFC 6458 6.1)
+const (
+	SCTP_ASSOC_CHANGE     uint16 = 0x8001
+	SCTP_PEER_ADDR_CHANGE uint16 = 0x8002
+	SCTP_SHUTDOWN_EVENT   uint16 = 0x8005
+)
+
+// sac_state of SCTP_ASSOC_CHANGE (RFC 6458 6.1.1)
+const (
+	SCTP_COMM_UP        uint16 = 0
+	SCTP_COMM_LOST      uint16 = 1
+	SCTP_RESTART        uint16 = 2
+	SCTP_SHUTDOWN_COMP  uint16 = 3
+	SCTP_CANT_STR_ASSOC uint16 = 4
+)
+
+// spc_state of SCTP_PEER_ADDR_CHANGE (RFC 6458 6.1.2)
+const (
+	SCTP_ADDR_AVAILABLE   int32 = 0
+	SCTP_ADDR_UNREACHABLE int32 = 1
+	SCTP_ADDR_REMOVED     int32 = 2
+	SCTP_ADDR_ADDED       int32 = 3
+	SCTP_ADDR_MADE_PRIM   int32 = 4
+	SCTP_ADDR_CONFIRMED   int32 = 5
+)
+
+const (
+	notificationHeaderLen = 8   // sn_type, sn_flags, sn_length
+	sockaddrStorageLen    = 128 // sizeof(struct sockaddr_storage)
+)
+
+// Notification is a SCTP notification received on an NGAP association
+type Notification struct {
+	Type uint16
+
+	// SCTP_ASSOC_CHANGE
+	AssocState      uint16
+	OutboundStreams uint16
+	InboundStreams  uint16
+
+	// SCTP_PEER_ADDR_CHANGE
+	PeerAddr      *net.TCPAddr // only IP and Port are used
+	PeerAddrState int32
+
+	Error int32
+}
+
+// AssociationEnded reports whether the association can not carry NGAP messages anymore
+func (n *Notification) AssociationEnded() bool {
+	switch n.Type {
+	case SCTP_ASSOC_CHANGE:
+		return n.AssocState == SCTP_COMM_LOST || n.AssocState == SCTP_SHUTDOWN_COMP ||
+			n.AssocState == SCTP_CANT_STR_ASSOC
+	case SCTP_SHUTDOWN_EVENT:
+		return true
+	}
+	return false
+}
+
+func (n *Notification) String() string {
+	switch n.Type {
+	case SCTP_ASSOC_CHANGE:
+		return fmt.Sprintf("SCTP_ASSOC_CHANGE[state: %s, error: %d, outbound streams: %d, inbound streams: %d]",
+			assocStateString(n.AssocState), n.Error, n.OutboundStreams, n.InboundStreams)
+	case SCTP_PEER_ADDR_CHANGE:
+		return fmt.Sprintf("SCTP_PEER_ADDR_CHANGE[addr: %s, state: %s, error: %d]",
+			n.PeerAddr, peerAddrStateString(n.PeerAddrState), n.Error)
+	case SCTP_SHUTDOWN_EVENT:
+		return "SCTP_SHUTDOWN_EVENT"
+	default:
+		return fmt.Sprintf("SCTP notification[type: 0x%x]", n.Type)
+	}
+}
+
+func assocStateString(state uint16) string {
+	switch state {
+	case SCTP_COMM_UP:
+		return "SCTP_COMM_UP"
+	case SCTP_COMM_LOST:
+		return "SCTP_COMM_LOST"
+	case SCTP_RESTART:
+		return "SCTP_RESTART"
+	case SCTP_SHUTDOWN_COMP:
+		return "SCTP_SHUTDOWN_COMP"
+	case SCTP_CANT_STR_ASSOC:
+		return "SCTP_CANT_STR_ASSOC"
+	default:
+		return fmt.Sprintf("unknown(%d)", state)
+	}
+}
+
+func peerAddrStateString(state int32) string {
+	switch state {
+	case SCTP_ADDR_AVAILABLE:
+		return "SCTP_ADDR_AVAILABLE"
+	case SCTP_ADDR_UNREACHABLE:
+		return "SCTP_ADDR_UNREACHABLE"
+	case SCTP_ADDR_REMOVED:
+		return "SCTP_ADDR_REMOVED"
+	case SCTP_ADDR_ADDED:
+		return "SCTP_ADDR_ADDED"
+	case SCTP_ADDR_MADE_PRIM:
+		return "SCTP_ADDR_MADE_PRIM"
+	case SCTP_ADDR_CONFIRMED:
+		return "SCTP_ADDR_CONFIRMED"
+	default:
+		return fmt.Sprintf("unknown(%d)", state)
+	}
+}
+
+// notifications are delivered in host byte order, except the port and address of the sockaddr
+var hostByteOrder binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		hostByteOrder = binary.BigEndian
+	}
+}
+
+func parseNotification(buf []byte) (*Notification, error) {
+	if len(buf) < notificationHeaderLen {
+		return nil, fmt.Errorf("SCTP notification too short: %d bytes", len(buf))
+	}
+
+	n := &Notification{
+		Type: hostByteOrder.Uint16(buf[0:2]),
+	}
+
+	switch n.Type {
+	case SCTP_ASSOC_CHANGE:
+		// sac_state, sac_error, sac_outbound_streams, sac_inbound_streams, sac_assoc_id
+		if len(buf) < notificationHeaderLen+12 {
+			return nil, fmt.Errorf("SCTP_ASSOC_CHANGE too short: %d bytes", len(buf))
+		}
+		n.AssocState = hostByteOrder.Uint16(buf[8:10])
+		n.Error = int32(hostByteOrder.Uint16(buf[10:12]))
+		n.OutboundStreams = hostByteOrder.Uint16(buf[12:14])
+		n.InboundStreams = hostByteOrder.Uint16(buf[14:16])
+	case SCTP_PEER_ADDR_CHANGE:
+		// spc_aaddr, spc_state, spc_error, spc_assoc_id
+		if len(buf) < notificationHeaderLen+sockaddrStorageLen+8 {
+			return nil, fmt.Errorf("SCTP_PEER_ADDR_CHANGE too short: %d bytes", len(buf))
+		}
+		n.PeerAddr = parseSockaddr(buf[notificationHeaderLen : notificationHeaderLen+sockaddrStorageLen])
+		offset := notificationHeaderLen + sockaddrStorageLen
+		n.PeerAddrState = int32(hostByteOrder.Uint32(buf[offset : offset+4]))
+		n.Error = int32(hostByteOrder.Uint32(buf[offset+4 : offset+8]))
+	}
+	return n, nil
+}
+
+func parseSockaddr(buf []byte) *net.TCPAddr {
+	addr := &net.TCPAddr{}
+	switch hostByteOrder.Uint16(buf[0:2]) {
+	case syscall.AF_INET:
+		addr.Port = int(binary.BigEndian.Uint16(buf[2:4]))
+		addr.IP = net.IP(append([]byte(nil), buf[4:8]...))
+	case syscall.AF_INET6:
+		addr.Port = int(binary.BigEndian.Uint16(buf[2:4]))
+		addr.IP = net.IP(append([]byte(nil), buf[8:24]...))
+	}
+	return addr
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"net"
+
+	"github.com/ishidawataru/sctp"
+
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+)
+
+type sctpTransport struct {
+	parameters context.SctpParameters
+}
+
+// NewSctpTransport returns the production NGAP transport
+func NewSctpTransport(parameters context.SctpParameters) Transport {
+	return &sctpTransport{parameters: parameters}
+}
+
+func (t *sctpTransport) Listen(addresses []string, port int) (Listener, error) {
+	addr := &sctp.SCTPAddr{
+		IPAddrs: resolveAddresses(addresses),
+		Port:    port,
+	}
+
+	initMsg := sctp.InitMsg{
+		NumOstreams:    t.parameters.NumOstreams,
+		MaxInstreams:   t.parameters.MaxInstreams,
+		MaxAttempts:    t.parameters.MaxAttempts,
+		MaxInitTimeout: t.parameters.MaxInitTimeout,
+	}
+
+	listener, err := sctp.ListenSCTPExt("sctp", addr, initMsg)
+	if err != nil {
+		return nil, err
+	}
+	numOutboundStreams = t.parameters.NumOstreams
+	return &sctpListener{SCTPListener: listener}, nil
+}
+
+// DialSctp opens an association towards an NGAP server, it is the RAN side used by the simulator
+func DialSctp(addresses []string, port int, parameters context.SctpParameters) (Conn, error) {
+	addr := &sctp.SCTPAddr{
+		IPAddrs: resolveAddresses(addresses),
+		Port:    port,
+	}
+
+	initMsg := sctp.InitMsg{
+		NumOstreams:    parameters.NumOstreams,
+		MaxInstreams:   parameters.MaxInstreams,
+		MaxAttempts:    parameters.MaxAttempts,
+		MaxInitTimeout: parameters.MaxInitTimeout,
+	}
+
+	conn, err := sctp.DialSCTPExt("sctp", nil, addr, initMsg)
+	if err != nil {
+		return nil, err
+	}
+	if err := setupSctpConn(conn); err != nil {
+		if closeErr := conn.Close(); closeErr != nil {
+			logger.NgapLog.Errorf("Close error: %+v", closeErr)
+		}
+		return nil, err
+	}
+	return &sctpConn{SCTPConn: conn}, nil
+}
+
+func resolveAddresses(addresses []string) []net.IPAddr {
+	ips := []net.IPAddr{}
+
+	for _, addr := range addresses {
+		if netAddr, err := net.ResolveIPAddr("ip", addr); err != nil {
+			logger.NgapLog.Errorf("Error resolving address '%s': %v\n", addr, err)
+		} else {
+			logger.NgapLog.Debugf("Resolved address '%s' to %s\n", addr, netAddr)
+			ips = append(ips, *netAddr)
+		}
+	}
+	return ips
+}
+
+type sctpListener struct {
+	*sctp.SCTPListener
+}
+
+// Accept returns the next association which is ready for NGAP, associations failing the
+// socket setup are closed and skipped
+func (l *sctpListener) Accept() (Conn, error) {
+	for {
+		conn, err := l.AcceptSCTP()
+		if err != nil {
+			return nil, err
+		}
+		if err := setupSctpConn(conn); err != nil {
+			logger.NgapLog.Errorf("Failed to accept: %+v", err)
+			if err = conn.Close(); err != nil {
+				logger.NgapLog.Errorf("Close error: %+v", err)
+			}
+			continue
+		}
+		return &sctpConn{SCTPConn: conn}, nil
+	}
+}
+
+func setupSctpConn(conn *sctp.SCTPConn) error {
+	info, err := conn.GetDefaultSentParam()
+	if err != nil {
+		return err
+	}
+
+	info.PPID = NGAP_PPID
+	if err := conn.SetDefaultSentParam(info); err != nil {
+		return err
+	}
+	logger.NgapLog.Debugf("Set default sent param[value: %+v] successfully", info)
+
+	events := sctp.SCTP_EVENT_DATA_IO | sctp.SCTP_EVENT_ASSOCIATION | sctp.SCTP_EVENT_ADDRESS | sctp.SCTP_EVENT_SHUTDOWN
+	if err := conn.SubscribeEvents(events); err != nil {
+		return err
+	}
+	logger.NgapLog.Debugln("Subscribe SCTP event DATA_IO, ASSOCIATION, ADDRESS and SHUTDOWN successfully")
+
+	if err := conn.SetReadBuffer(int(readBufSize)); err != nil {
+		return err
+	}
+	logger.NgapLog.Debugf("Set read buffer to %d bytes", readBufSize)
+	return nil
+}
+
+type sctpConn struct {
+	*sctp.SCTPConn
+}
+
+func (c *sctpConn) ReadMsg(buf []byte) (int, *MsgInfo, *Notification, error) {
+	n, info, err := c.SCTPRead(buf)
+	if err != nil {
+		return n, nil, nil, err
+	}
+
+	// with DATA_IO subscribed every user message comes with a SndRcvInfo, the others are notifications
+	if info == nil {
+		notification, err := parseNotification(buf[:n])
+		if err != nil {
+			logger.NgapLog.Warnf("Discard SCTP notification: %+v", err)
+			return n, nil, nil, nil
+		}
+		return n, nil, notification, nil
+	}
+	return n, &MsgInfo{Stream: info.Stream, PPID: info.PPID}, nil, nil
+}
+
+func (c *sctpConn) WriteMsg(msg []byte, info *MsgInfo) (int, error) {
+	return c.SCTPWrite(msg, &sctp.SndRcvInfo{
+		Stream: info.Stream,
+		PPID:   info.PPID,
+	})
+}
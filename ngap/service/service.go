@@ -3,108 +3,68 @@ package service
 import (
 	"encoding/hex"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/ngap/capture"
 	"io"
 	"net"
 	"sync"
-
-	"github.com/ishidawataru/sctp"
 )
 
-type Handler func(conn net.Conn, msg []byte)
+// Handler functions are called on the read loop of the connection, they must not block on message
+// handling but hand the work over to the worker lanes (see Submit)
+type Handler struct {
+	// HandleMessage is called for every NGAP message, streamId is the SCTP stream it was received on
+	HandleMessage func(conn net.Conn, streamId uint16, msg []byte)
+	// HandleNotification is called for SCTP association, peer address and shutdown notifications
+	HandleNotification func(conn net.Conn, notification *Notification)
+}
 
 const NGAP_PPID uint32 = 0x3c000000
 const readBufSize uint32 = 8192
 
-var sctpListener *sctp.SCTPListener
+var (
+	listenerMutex sync.Mutex
+	listener      Listener
+	stopped       bool
+)
 var connections sync.Map
 
-func Run(addresses []string, port int, msgHandler Handler) {
-	ips := []net.IPAddr{}
-
-	for _, addr := range addresses {
-		if netAddr, err := net.ResolveIPAddr("ip", addr); err != nil {
-			logger.NgapLog.Errorf("Error resolving address '%s': %v\n", addr, err)
-		} else {
-			logger.NgapLog.Debugf("Resolved address '%s' to %s\n", addr, netAddr)
-			ips = append(ips, *netAddr)
-		}
-	}
-
-	addr := &sctp.SCTPAddr{
-		IPAddrs: ips,
-		Port:    port,
-	}
-
-	go listenAndServe(addr, msgHandler)
-}
-
-func listenAndServe(addr *sctp.SCTPAddr, msgHandler Handler) {
-	initMsg := sctp.InitMsg{NumOstreams: 3, MaxInstreams: 5, MaxAttempts: 4, MaxInitTimeout: 8}
-
-	if listener, err := sctp.ListenSCTPExt("sctp", addr, initMsg); err != nil {
+// Run starts the NGAP server on the transport, use NewSctpTransport() for the N2 interface
+func Run(transport Transport, addresses []string, port int, handler Handler) {
+	l, err := transport.Listen(addresses, port)
+	if err != nil {
 		logger.NgapLog.Errorf("Failed to listen: %+v", err)
 		return
-	} else {
-		sctpListener = listener
 	}
 
-	logger.NgapLog.Infof("Listen on %s", sctpListener.Addr())
+	listenerMutex.Lock()
+	listener = l
+	stopped = false
+	listenerMutex.Unlock()
 
-	for {
-		var conn *sctp.SCTPConn
-		if newConn, err := sctpListener.AcceptSCTP(); err != nil {
-			logger.NgapLog.Errorf("Failed to accept: %+v", err)
-			continue
-		} else {
-			conn = newConn
-		}
-
-		var info *sctp.SndRcvInfo
-		if infoTmp, err := conn.GetDefaultSentParam(); err != nil {
-			logger.NgapLog.Errorf("Failed to accept: %+v", err)
-			if err = conn.Close(); err != nil {
-				logger.NgapLog.Errorf("Close error: %+v", err)
-			}
-		} else {
-			info = infoTmp
-		}
+	logger.NgapLog.Infof("Listen on %s", l.Addr())
 
-		info.PPID = NGAP_PPID
-		if err := conn.SetDefaultSentParam(info); err != nil {
-			logger.NgapLog.Errorf("Failed to accept: %+v", err)
-			if err = conn.Close(); err != nil {
-				logger.NgapLog.Errorf("Close error: %+v", err)
-			}
-			continue
-		} else {
-			logger.NgapLog.Debugf("Set default sent param[value: %+v] successfully", info)
-		}
+	go serve(l, handler)
+}
 
-		if err := conn.SubscribeEvents(sctp.SCTP_EVENT_DATA_IO); err != nil {
-			logger.NgapLog.Errorf("Failed to accept: %+v", err)
-			if err = conn.Close(); err != nil {
-				logger.NgapLog.Errorf("Close error: %+v", err)
+func serve(l Listener, handler Handler) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			listenerMutex.Lock()
+			isStopped := stopped
+			listenerMutex.Unlock()
+			if isStopped {
+				return
 			}
-			continue
-		} else {
-			logger.NgapLog.Debugln("Subscribe SCTP event DATA_IO successfully")
-		}
-
-		if err := conn.SetReadBuffer(int(readBufSize)); err != nil {
 			logger.NgapLog.Errorf("Failed to accept: %+v", err)
-			if err = conn.Close(); err != nil {
-				logger.NgapLog.Errorf("Close error: %+v", err)
-			}
 			continue
-		} else {
-			logger.NgapLog.Debugf("Set read buffer to %d bytes", readBufSize)
 		}
 
-		logger.NgapLog.Infof("[OCF] SCTP Accept from: %s", conn.RemoteAddr().String())
+		logger.NgapLog.Infof("[OCF] Accept from: %s", conn.RemoteAddr().String())
 
 		connections.Store(conn, conn)
 		go func() {
-			if err := handleConnection(conn, readBufSize, msgHandler); err != nil {
+			if err := handleConnection(conn, readBufSize, handler); err != nil {
 				logger.NgapLog.Errorf("Handle connection[addr: %+v] error: %+v", conn.RemoteAddr(), err)
 			}
 			// if OCF call Stop(), then conn.Close() will return "bad file descriptor" error
@@ -113,16 +73,23 @@ func listenAndServe(addr *sctp.SCTPAddr, msgHandler Handler) {
 				logger.NgapLog.Errorf("close connection error: %+v", err)
 			}
 			connections.Delete(conn)
+			outboundStreams.Delete(conn)
+			capture.Remove(conn)
 		}()
 	}
 }
 
 func Stop() {
-	logger.NgapLog.Infof("Close SCTP server...")
-	if err := sctpListener.Close(); err != nil {
-		logger.NgapLog.Error(err)
-		logger.NgapLog.Infof("SCTP server may not close normally.")
+	logger.NgapLog.Infof("Close NGAP server...")
+	listenerMutex.Lock()
+	stopped = true
+	if listener != nil {
+		if err := listener.Close(); err != nil {
+			logger.NgapLog.Error(err)
+			logger.NgapLog.Infof("NGAP server may not close normally.")
+		}
 	}
+	listenerMutex.Unlock()
 
 	connections.Range(func(key, value interface{}) bool {
 		conn := value.(net.Conn)
@@ -132,14 +99,14 @@ func Stop() {
 		return true
 	})
 
-	logger.NgapLog.Infof("SCTP server closed")
+	logger.NgapLog.Infof("NGAP server closed")
 }
 
-func handleConnection(conn *sctp.SCTPConn, bufsize uint32, msgHandler Handler) error {
+func handleConnection(conn Conn, bufsize uint32, handler Handler) error {
 	for {
 		buf := make([]byte, bufsize)
 
-		n, info, err := conn.SCTPRead(buf)
+		n, info, notification, err := conn.ReadMsg(buf)
 		if err != nil {
 			if err == io.EOF || err == io.ErrUnexpectedEOF {
 				logger.NgapLog.Debugln("Read EOF from client")
@@ -149,15 +116,40 @@ func handleConnection(conn *sctp.SCTPConn, bufsize uint32, msgHandler Handler) e
 			}
 		}
 
-		if info == nil || info.PPID != NGAP_PPID {
+		if notification != nil {
+			handleNotification(conn, notification, handler)
+			if notification.AssociationEnded() {
+				return nil
+			}
+			continue
+		}
+
+		if info == nil {
+			continue
+		}
+
+		if info.PPID != NGAP_PPID {
 			logger.NgapLog.Warnln("Received SCTP PPID != 60, discard this packet")
 			continue
 		}
 
-		logger.NgapLog.Tracef("Read %d bytes", n)
+		logger.NgapLog.Tracef("Read %d bytes on stream %d", n, info.Stream)
 		logger.NgapLog.Tracef("Packet content:\n%+v", hex.Dump(buf[:n]))
+		capture.Uplink(conn, info.Stream, buf[:n])
+
+		handler.HandleMessage(conn, info.Stream, buf[:n])
+	}
+}
+
+func handleNotification(conn Conn, notification *Notification, handler Handler) {
+	logger.NgapLog.Infof("[OCF] SCTP association[peer: %s] %s", conn.RemoteAddr(), notification)
+
+	if notification.Type == SCTP_ASSOC_CHANGE &&
+		(notification.AssocState == SCTP_COMM_UP || notification.AssocState == SCTP_RESTART) {
+		setOutboundStreams(conn, notification.OutboundStreams)
+	}
 
-		// TODO: concurrent on per-UE message
-		msgHandler(conn, buf[:n])
+	if handler.HandleNotification != nil {
+		handler.HandleNotification(conn, notification)
 	}
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net"
+	"sync"
+)
+
+// NonUeAssociatedStreamId is reserved for non-UE-associated signalling (TS 38.412 7)
+const NonUeAssociatedStreamId uint16 = 0
+
+// number of outbound streams requested in the SCTP INIT, set by Run()
+var numOutboundStreams = uint16(1)
+
+// number of outbound streams negotiated with the peer, map[net.Conn]uint16
+var outboundStreams sync.Map
+
+func setOutboundStreams(conn net.Conn, streams uint16) {
+	outboundStreams.Store(conn, streams)
+}
+
+// UeAssociatedStreamId spreads the UE-associated signalling over the streams other than
+// the non-UE-associated one. All messages of one UE use the same stream so they are
+// delivered in order.
+func UeAssociatedStreamId(conn net.Conn, amfUeNgapId int64) uint16 {
+	streams := numOutboundStreams
+	if value, ok := outboundStreams.Load(conn); ok {
+		streams = value.(uint16)
+	}
+	if streams <= 1 {
+		return NonUeAssociatedStreamId
+	}
+	return 1 + uint16(uint64(amfUeNgapId)%uint64(streams-1))
+}
+
+// Write sends one NGAP message on the given stream of the association
+func Write(conn net.Conn, streamId uint16, packet []byte) (int, error) {
+	if ngapConn, ok := conn.(Conn); ok {
+		return ngapConn.WriteMsg(packet, &MsgInfo{
+			Stream: streamId,
+			PPID:   NGAP_PPID,
+		})
+	}
+	return conn.Write(packet)
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"net"
+)
+
+// Transport creates the listener of the NGAP server. SCTP is the transport of the N2 interface
+// (TS 38.412), the framed transports carry the same messages over TCP or in memory for testing
+// on hosts without SCTP support.
+type Transport interface {
+	Listen(addresses []string, port int) (Listener, error)
+}
+
+// Listener accepts the associations of the RANs
+type Listener interface {
+	Accept() (Conn, error)
+	Close() error
+	Addr() net.Addr
+}
+
+// MsgInfo is the per message information of the association
+type MsgInfo struct {
+	Stream uint16
+	PPID   uint32
+}
+
+// Conn is one association with a RAN, every read and write carries exactly one NGAP message
+type Conn interface {
+	net.Conn
+	// ReadMsg reads one message into buf. Either info is set for a user message or notification
+	// is set for an association event; both are nil for input which has to be discarded.
+	ReadMsg(buf []byte) (n int, info *MsgInfo, notification *Notification, err error)
+	WriteMsg(msg []byte, info *MsgInfo) (int, error)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+)
+
+// ueLaneKey is the key of the lane of the RAN UE of the UE, over 3GPP access first. A UE in CM-IDLE has no
+// such lane, the UE itself is the key of its lane.
+func ueLaneKey(ue *context.OcfUe) interface{} {
+	for _, anType := range []models.AccessType{
+		models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS,
+	} {
+		if ranUe := ue.RanUe[anType]; ranUe != nil {
+			return ranUe.LaneKey
+		}
+	}
+	return ue
+}
+
+// PostUe queues a job changing the context of the UE from outside its NGAP signalling (timer expiry, SBI
+// notification, OAM) on the lane of the UE, behind the messages of the UE already read from the RAN. The
+// job queued for a UE in CM-IDLE moves to the lane of the RAN UE if the UE has connected by the time it is
+// run. The Initial UE Message the UE connects with is handled on the lane of the new RAN UE though, a job
+// of the UE in CM-IDLE which has started already is not serialized with it and checks the CM state of the
+// UE again where it matters.
+func PostUe(ue *context.OcfUe, job func()) {
+	key := ueLaneKey(ue)
+	if key != interface{}(ue) {
+		Post(key, job)
+		return
+	}
+	Post(ue, func() {
+		if key := ueLaneKey(ue); key != interface{}(ue) {
+			Post(key, job)
+			return
+		}
+		job()
+	})
+}
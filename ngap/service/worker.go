@@ -0,0 +1,134 @@
+package service
+
+import (
+	"runtime/debug"
+	"sync"
+
+	"free5gc/src/ocf/logger"
+)
+
+// MaxLaneJobs bounds the jobs waiting on a lane, Submit blocks the caller while the lane is full so that
+// a slow UE or RAN pushes back on the association it is read from instead of growing the queue forever
+var MaxLaneJobs = 1024
+
+// lane is a FIFO of jobs sharing the same key. At most one goroutine drains
+// a lane at a time, so jobs of the same lane are never run concurrently.
+type lane struct {
+	jobs []func()
+}
+
+var (
+	lanesMutex sync.Mutex
+	lanes      = make(map[interface{}]*lane)
+	// pending counts the submitted jobs which have not finished yet
+	pending int
+	// idle is signalled whenever a lane runs out of jobs, space whenever a job leaves a lane
+	idle  = sync.NewCond(&lanesMutex)
+	space = sync.NewCond(&lanesMutex)
+)
+
+// Submit queues job on the lane identified by key. Jobs with the same key are
+// run one after another in submission order, jobs with different keys are run
+// concurrently. A lane only owns a goroutine while it has pending jobs.
+// Submit blocks while the lane holds MaxLaneJobs jobs, a job running on a lane
+// queues work on other lanes with Post so that lanes never wait for each other.
+func Submit(key interface{}, job func()) {
+	submit(key, job, true)
+}
+
+// Post queues job on the lane identified by key like Submit but never blocks,
+// the lane may go beyond MaxLaneJobs
+func Post(key interface{}, job func()) {
+	submit(key, job, false)
+}
+
+func submit(key interface{}, job func(), wait bool) {
+	if job == nil {
+		return
+	}
+
+	lanesMutex.Lock()
+	for wait {
+		if l, ok := lanes[key]; !ok || len(l.jobs) < MaxLaneJobs {
+			break
+		}
+		space.Wait()
+	}
+	pending++
+	if l, ok := lanes[key]; ok {
+		l.jobs = append(l.jobs, job)
+		lanesMutex.Unlock()
+		return
+	}
+	l := &lane{}
+	lanes[key] = l
+	lanesMutex.Unlock()
+
+	go l.run(key, job)
+}
+
+func (l *lane) run(key interface{}, job func()) {
+	for job != nil {
+		runJob(key, job)
+
+		lanesMutex.Lock()
+		pending--
+		if len(l.jobs) == 0 {
+			delete(lanes, key)
+			job = nil
+			idle.Broadcast()
+		} else {
+			job = l.jobs[0]
+			l.jobs[0] = nil
+			l.jobs = l.jobs[1:]
+			space.Broadcast()
+		}
+		lanesMutex.Unlock()
+	}
+}
+
+// WaitIdle blocks until every submitted job has been run, including the jobs submitted while
+// waiting. The replay tool uses it to let the OCF answer one message before feeding the next.
+func WaitIdle() {
+	lanesMutex.Lock()
+	for pending > 0 {
+		idle.Wait()
+	}
+	lanesMutex.Unlock()
+}
+
+// Drain blocks until the lanes whose key is selected by match have run all their jobs, a job must not
+// drain its own lane. The RAN lane drains the lanes of its UEs before the RAN is removed.
+func Drain(match func(key interface{}) bool) {
+	lanesMutex.Lock()
+	defer lanesMutex.Unlock()
+	for busy(match) {
+		idle.Wait()
+	}
+}
+
+func busy(match func(key interface{}) bool) bool {
+	for key := range lanes {
+		if match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueDepth is the number of submitted jobs which have not finished yet
+func QueueDepth() int {
+	lanesMutex.Lock()
+	defer lanesMutex.Unlock()
+	return pending
+}
+
+// runJob keeps a panic in one lane from tearing down the other lanes
+func runJob(key interface{}, job func()) {
+	defer func() {
+		if p := recover(); p != nil {
+			logger.NgapLog.Errorf("NGAP worker[key: %+v] panic: %v\n%s", key, p, debug.Stack())
+		}
+	}()
+	job()
+}
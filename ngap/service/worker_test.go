@@ -0,0 +1,161 @@
+package service_test
+
+import (
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/ngap/service"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitKeepsOrderPerKey(t *testing.T) {
+	const numKeys = 8
+	const numJobs = 1000
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	results := make(map[int][]int)
+
+	wg.Add(numKeys * numJobs)
+	for j := 0; j < numJobs; j++ {
+		for k := 0; k < numKeys; k++ {
+			key, seq := k, j
+			service.Submit(key, func() {
+				mutex.Lock()
+				results[key] = append(results[key], seq)
+				mutex.Unlock()
+				wg.Done()
+			})
+		}
+	}
+	wg.Wait()
+
+	for k := 0; k < numKeys; k++ {
+		assert.Len(t, results[k], numJobs)
+		for j, seq := range results[k] {
+			assert.Equal(t, j, seq)
+		}
+	}
+}
+
+func TestSubmitRecoversPanic(t *testing.T) {
+	done := make(chan struct{})
+	service.Submit("panic", func() {
+		panic("test")
+	})
+	service.Submit("panic", func() {
+		close(done)
+	})
+	<-done
+}
+
+func TestSubmitBlocksOnFullLane(t *testing.T) {
+	maxLaneJobs := service.MaxLaneJobs
+	service.MaxLaneJobs = 2
+	defer func() { service.MaxLaneJobs = maxLaneJobs }()
+
+	release := make(chan struct{})
+	service.Submit("full", func() { <-release })
+	service.Submit("full", func() {})
+	service.Submit("full", func() {})
+
+	submitted := make(chan struct{})
+	go func() {
+		service.Submit("full", func() {})
+		close(submitted)
+	}()
+	// Post never waits for the lane
+	service.Post("full", func() {})
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit on a full lane has not blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-submitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit has not been unblocked")
+	}
+	service.WaitIdle()
+}
+
+func TestDrain(t *testing.T) {
+	release := make(chan struct{})
+	var done bool
+	service.Submit("ue-1", func() {
+		<-release
+		done = true
+	})
+	service.Submit("ue-2", func() {})
+
+	drained := make(chan struct{})
+	go func() {
+		service.Drain(func(key interface{}) bool {
+			return key == "ue-1"
+		})
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		t.Fatal("Drain has not waited for the lane")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-drained
+	assert.True(t, done)
+}
+
+func TestPostUe(t *testing.T) {
+	ue := &context.OcfUe{RanUe: make(map[models.AccessType]*context.RanUe)}
+	ranUe := &context.RanUe{LaneKey: context.UeLaneKey{RanUeNgapId: 1}}
+
+	var mutex sync.Mutex
+	var order []int
+	record := func(i int) {
+		mutex.Lock()
+		order = append(order, i)
+		mutex.Unlock()
+	}
+
+	// the job queued while the UE is in CM-IDLE runs behind the messages of the RAN UE the UE connects with
+	release1, release2, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	service.Post(ue, func() {
+		<-release1
+		record(1)
+	})
+	service.PostUe(ue, func() {
+		record(3)
+		close(done)
+	})
+	ue.RanUe[models.AccessType__3_GPP_ACCESS] = ranUe
+	service.Post(ranUe.LaneKey, func() {
+		<-release2
+		record(2)
+	})
+	close(release1)
+	time.Sleep(50 * time.Millisecond)
+	close(release2)
+	<-done
+	assert.Equal(t, []int{1, 2, 3}, order)
+
+	// the job of the UE in CM-CONNECTED is queued on the lane of its RAN UE
+	release, done := make(chan struct{}), make(chan struct{})
+	order = nil
+	service.Post(ranUe.LaneKey, func() {
+		<-release
+		record(1)
+	})
+	service.PostUe(ue, func() {
+		record(2)
+		close(done)
+	})
+	close(release)
+	<-done
+	assert.Equal(t, []int{1, 2}, order)
+}
@@ -0,0 +1,34 @@
+package oam
+
+import (
+	"free5gc/lib/http_wrapper"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/producer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPReloadConfiguration applies the YAML configuration in the request body, or the configuration
+// file again if the body is empty
+func HTTPReloadConfiguration(c *gin.Context) {
+	setCorsHeader(c)
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.MtLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	req := http_wrapper.NewRequest(c.Request, requestBody)
+	rsp := producer.HandleOAMReloadConfiguration(req)
+	sendOAMResponse(c, rsp)
+}
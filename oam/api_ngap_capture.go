@@ -0,0 +1,71 @@
+package oam
+
+import (
+	"free5gc/lib/http_wrapper"
+	"free5gc/lib/openapi"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/producer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func HTTPGetNgapCapture(c *gin.Context) {
+	setCorsHeader(c)
+
+	req := http_wrapper.NewRequest(c.Request, nil)
+	rsp := producer.HandleOAMGetNgapCapture(req)
+	sendOAMResponse(c, rsp)
+}
+
+func HTTPSetNgapCapture(c *gin.Context) {
+	setCorsHeader(c)
+
+	var setting producer.NgapCaptureSetting
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.MtLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&setting, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.MtLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	req := http_wrapper.NewRequest(c.Request, setting)
+	rsp := producer.HandleOAMSetNgapCapture(req)
+	sendOAMResponse(c, rsp)
+}
+
+func sendOAMResponse(c *gin.Context, rsp *http_wrapper.Response) {
+	responseBody, err := openapi.Serialize(rsp.Body, "application/json")
+	if err != nil {
+		logger.MtLog.Errorln(err)
+		problemDetails := models.ProblemDetails{
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+			Detail: err.Error(),
+		}
+		c.JSON(http.StatusInternalServerError, problemDetails)
+	} else {
+		c.Data(rsp.Status, "application/json", responseBody)
+	}
+}
@@ -0,0 +1,16 @@
+package oam
+
+import (
+	"free5gc/lib/http_wrapper"
+	"free5gc/src/ocf/producer"
+
+	"github.com/gin-gonic/gin"
+)
+
+func HTTPGetOverload(c *gin.Context) {
+	setCorsHeader(c)
+
+	req := http_wrapper.NewRequest(c.Request, nil)
+	rsp := producer.HandleOAMGetOverload(req)
+	sendOAMResponse(c, rsp)
+}
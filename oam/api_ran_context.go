@@ -0,0 +1,20 @@
+package oam
+
+import (
+	"free5gc/lib/http_wrapper"
+	"free5gc/src/ocf/producer"
+
+	"github.com/gin-gonic/gin"
+)
+
+func HTTPRanContext(c *gin.Context) {
+	setCorsHeader(c)
+
+	req := http_wrapper.NewRequest(c.Request, nil)
+	if ranId, exists := c.Params.Get("ranId"); exists {
+		req.Params["ranId"] = ranId
+	}
+
+	rsp := producer.HandleOAMRanContext(req)
+	sendOAMResponse(c, rsp)
+}
@@ -41,3 +41,40 @@ func HTTPRegisteredUEContext(c *gin.Context) {
 		c.Data(rsp.Status, "application/json", responseBody)
 	}
 }
+
+func HTTPUpdateUeConfiguration(c *gin.Context) {
+	setCorsHeader(c)
+
+	var setting producer.UeConfigurationUpdate
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.MtLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&setting, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.MtLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	req := http_wrapper.NewRequest(c.Request, setting)
+	req.Params["supi"] = c.Params.ByName("supi")
+	rsp := producer.HandleOAMUpdateUeConfiguration(req)
+	sendOAMResponse(c, rsp)
+}
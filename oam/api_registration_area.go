@@ -0,0 +1,56 @@
+package oam
+
+import (
+	"free5gc/lib/http_wrapper"
+	"free5gc/lib/openapi"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/producer"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func HTTPGetRegistrationArea(c *gin.Context) {
+	setCorsHeader(c)
+
+	req := http_wrapper.NewRequest(c.Request, nil)
+	rsp := producer.HandleOAMGetRegistrationArea(req)
+	sendOAMResponse(c, rsp)
+}
+
+func HTTPSetRegistrationArea(c *gin.Context) {
+	setCorsHeader(c)
+
+	var setting producer.RegistrationAreaSetting
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.MtLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&setting, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.MtLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	req := http_wrapper.NewRequest(c.Request, setting)
+	rsp := producer.HandleOAMSetRegistrationArea(req)
+	sendOAMResponse(c, rsp)
+}
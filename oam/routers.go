@@ -49,6 +49,8 @@ func AddService(engine *gin.Engine) *gin.RouterGroup {
 		switch route.Method {
 		case "GET":
 			group.GET(route.Pattern, route.HandlerFunc)
+		case "PUT":
+			group.PUT(route.Pattern, route.HandlerFunc)
 		}
 	}
 	return group
@@ -80,4 +82,81 @@ var routes = Routes{
 		"/registered-ue-context/:supi",
 		HTTPRegisteredUEContext,
 	},
+
+	{
+		"UE Configuration Update",
+		"PUT",
+		"/registered-ue-context/:supi/configuration-update",
+		HTTPUpdateUeConfiguration,
+	},
+
+	{
+		"RAN Context",
+		"GET",
+		"/ran-context",
+		HTTPRanContext,
+	},
+
+	{
+		"Individual RAN Context",
+		"GET",
+		"/ran-context/:ranId",
+		HTTPRanContext,
+	},
+
+	{
+		"NGAP Capture",
+		"GET",
+		"/ngap-capture",
+		HTTPGetNgapCapture,
+	},
+
+	{
+		"Set NGAP Capture",
+		"PUT",
+		"/ngap-capture",
+		HTTPSetNgapCapture,
+	},
+
+	{
+		"Overload Control",
+		"GET",
+		"/overload",
+		HTTPGetOverload,
+	},
+
+	{
+		"OCF Configuration Update",
+		"GET",
+		"/ocf-configuration-update",
+		HTTPGetOcfConfigurationUpdate,
+	},
+
+	{
+		"Timers",
+		"GET",
+		"/timers",
+		HTTPGetTimers,
+	},
+
+	{
+		"Registration Area",
+		"GET",
+		"/registration-area",
+		HTTPGetRegistrationArea,
+	},
+
+	{
+		"Set Registration Area",
+		"PUT",
+		"/registration-area",
+		HTTPSetRegistrationArea,
+	},
+
+	{
+		"Reload Configuration",
+		"PUT",
+		"/configuration",
+		HTTPReloadConfiguration,
+	},
 }
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"fmt"
 	"free5gc/src/app"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/factory"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/replay"
 	"free5gc/src/ocf/service"
+	"free5gc/src/ocf/sim"
+	"free5gc/src/ocf/util"
 	"free5gc/src/ocf/version"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -26,14 +34,104 @@ func main() {
 	appLog.Infoln("OCF version: ", version.GetVersion())
 	app.Usage = "-free5gccfg common configuration file -amfcfg ocf configuration file"
 	app.Action = action
-	app.Flags = OCF.GetCliCmd()
+	app.Flags = append(OCF.GetCliCmd(), cli.BoolFlag{
+		Name:  "validate-config",
+		Usage: "check the ocf config file and exit, with a non-zero status if it has a problem",
+	})
+	app.Commands = []cli.Command{
+		{
+			Name:   "sim",
+			Usage:  "run the gNB/UE simulator scenario of -scenario against an OCF",
+			Flags:  []cli.Flag{cli.StringFlag{Name: "scenario", Usage: "scenario yaml file"}},
+			Action: simAction,
+		},
+		{
+			Name:  "replay",
+			Usage: "replay the uplink NGAP messages of -pcap and compare the downlink messages with the capture",
+			Flags: append(OCF.GetCliCmd(),
+				cli.StringFlag{Name: "pcap", Usage: "N2 capture file"},
+				cli.StringFlag{Name: "stubs", Usage: "SBI stub yaml file"},
+				cli.UintFlag{Name: "ocfPort", Value: uint(replay.DefaultOcfPort), Usage: "NGAP port of the OCF in the capture"},
+				cli.StringFlag{Name: "ignoreIEs", Usage: "comma separated IDs of the IEs not compared"},
+				cli.DurationFlag{Name: "settle", Usage: "time waited after every uplink message"},
+			),
+			Action: replayAction,
+		},
+	}
 	if err := app.Run(os.Args); err != nil {
 		logger.AppLog.Errorf("OCF Run error: %v", err)
 	}
 }
 
-func action(c *cli.Context) {
+func action(c *cli.Context) error {
+	if c.Bool("validate-config") {
+		return validateConfigAction(c)
+	}
 	app.AppInitializeWillInitialize(c.String("free5gccfg"))
 	OCF.Initialize(c)
 	OCF.Start()
+	return nil
+}
+
+func validateConfigAction(c *cli.Context) error {
+	configFile := OCF.ConfigFile(c)
+	config, err := factory.ReadConfig(configFile)
+	if err == nil {
+		err = factory.Validate(config)
+	}
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("%s: %+v", configFile, err), 1)
+	}
+	fmt.Printf("%s: OK\n", configFile)
+	return nil
+}
+
+func simAction(c *cli.Context) error {
+	scenario, err := sim.LoadScenario(c.String("scenario"))
+	if err != nil {
+		return err
+	}
+	simulator, err := sim.New(scenario)
+	if err != nil {
+		return err
+	}
+	logger.SimLog.Infof("Run scenario %s with %s", c.String("scenario"), simulator)
+	stats, runErr := simulator.Run()
+	if err := stats.Report(os.Stdout); err != nil {
+		return err
+	}
+	return runErr
+}
+
+func replayAction(c *cli.Context) error {
+	app.AppInitializeWillInitialize(c.String("free5gccfg"))
+	OCF.Initialize(c)
+	util.InitOcfContext(context.OCF_Self())
+
+	options := replay.Options{
+		Pcap:    c.String("pcap"),
+		Stubs:   c.String("stubs"),
+		OcfPort: uint16(c.Uint("ocfPort")),
+		Settle:  c.Duration("settle"),
+	}
+	if ignoreIEs := c.String("ignoreIEs"); ignoreIEs != "" {
+		for _, value := range strings.Split(ignoreIEs, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid IE ID %q: %+v", value, err)
+			}
+			options.IgnoreIEs = append(options.IgnoreIEs, id)
+		}
+	}
+	report, err := replay.Run(options)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(os.Stdout); err != nil {
+		return err
+	}
+	if mismatches := report.Mismatches(); mismatches > 0 {
+		return fmt.Errorf("%d downlink message(s) differ from the capture", mismatches)
+	}
+	return nil
 }
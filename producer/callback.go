@@ -11,9 +11,10 @@ import (
 	gmm_message "free5gc/src/ocf/gmm/message"
 	"free5gc/src/ocf/logger"
 	"free5gc/src/ocf/nas"
-	ngap_message "free5gc/src/ocf/ngap/message"
+	ngap_service "free5gc/src/ocf/ngap/service"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/mohae/deepcopy"
 )
@@ -70,7 +71,7 @@ func SmContextStatusNotifyProcedure(guti string, pduSessionID int32,
 	if storedSmContext, exist := ue.StoredSmContext[pduSessionID]; exist {
 		go func() {
 			smContextCreateData := consumer.BuildCreateSmContextRequest(ue, *storedSmContext.PduSessionContext,
-				models.RequestType_INITIAL_REQUEST)
+				storedSmContext.RequestType)
 
 			response, smContextRef, errResponse, problemDetail, err := consumer.SendCreateSmContextRequest(
 				ue, storedSmContext.SmfUri, storedSmContext.Payload, smContextCreateData)
@@ -149,31 +150,16 @@ func AmPolicyControlUpdateNotifyUpdateProcedure(polAssoID string,
 		ue.AmPolicyAssociation.Rfsp = policyUpdate.Rfsp
 	}
 
-	if ue != nil {
-		// use go routine to write response first to ensure the order of the procedure
-		go func() {
-			// UE is CM-Connected State
-			if ue.CmConnect(models.AccessType__3_GPP_ACCESS) {
-				gmm_message.SendConfigurationUpdateCommand(ue, models.AccessType__3_GPP_ACCESS, nil)
-				// UE is CM-IDLE => paging
-			} else {
-				message, err := gmm_message.BuildConfigurationUpdateCommand(ue, models.AccessType__3_GPP_ACCESS, nil)
-				if err != nil {
-					logger.GmmLog.Errorf("Build Configuration Update Command Failed : %s", err.Error())
-					return
-				}
-
-				ue.ConfigurationUpdateMessage = message
-				ue.OnGoing[models.AccessType__3_GPP_ACCESS].Procedure = context.OnGoingProcedurePaging
-
-				pkg, err := ngap_message.BuildPaging(ue, nil, false)
-				if err != nil {
-					logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
-					return
-				}
-				ngap_message.SendPaging(ue, pkg)
+	// TS 23.502 4.2.4.2: the new service area restrictions are sent to the UE
+	if policyUpdate.ServAreaRes != nil {
+		// the UE is updated on its lane once the response is written, the NAS and NGAP procedures of the UE
+		// are not raced
+		ngap_service.PostUe(ue, func() {
+			update := context.ConfigurationUpdate{ServiceAreaList: true}
+			if err := gmm_message.UpdateUeConfiguration(ue, models.AccessType__3_GPP_ACCESS, update); err != nil {
+				logger.GmmLog.Errorf("Configuration update of UE[%s] failed: %+v", ue.Supi, err)
 			}
-		}()
+		})
 	}
 	return nil
 }
@@ -292,3 +278,124 @@ func N1MessageNotifyProcedure(n1MessageNotify models.N1MessageNotify) *models.Pr
 	}()
 	return nil
 }
+
+// TS 29.503 5.2.2.3.2 Data Change Notification to NF
+func HandleSdmDataChangeNotify(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infoln("Handle SDM Data Change Notification")
+
+	supi := request.Params["supi"]
+	modificationNotification := request.Body.(models.ModificationNotification)
+
+	problemDetails := SdmDataChangeNotifyProcedure(supi, modificationNotification)
+	if problemDetails != nil {
+		return http_wrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	} else {
+		return http_wrapper.NewResponse(http.StatusNoContent, nil, nil)
+	}
+}
+
+// SdmDataChangeNotifyProcedure retrieves the changed subscription data of the UE from the UDM and sends
+// the parameters of the UE it changes by the UE configuration update procedure (TS 23.502 4.2.4.2)
+func SdmDataChangeNotifyProcedure(supi string,
+	modificationNotification models.ModificationNotification) *models.ProblemDetails {
+	amfSelf := context.OCF_Self()
+
+	ue, ok := amfSelf.OcfUeFindBySupi(supi)
+	if !ok {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+			Detail: fmt.Sprintf("UE[%s] Not Found", supi),
+		}
+		return problemDetails
+	}
+
+	// the UE context is changed on the lane of the UE, the NAS and NGAP procedures of the UE are not raced
+	ngap_service.PostUe(ue, func() {
+		update := sdmDataChanged(ue, modificationNotification)
+		if update.Empty() {
+			return
+		}
+		for _, anType := range []models.AccessType{
+			models.AccessType__3_GPP_ACCESS, models.AccessType_NON_3_GPP_ACCESS,
+		} {
+			if ue.State[anType] == nil || !ue.State[anType].Is(context.Registered) {
+				continue
+			}
+			if err := gmm_message.UpdateUeConfiguration(ue, anType, update); err != nil {
+				logger.GmmLog.Errorf("Configuration update of UE[%s] failed: %+v", ue.Supi, err)
+			}
+		}
+	})
+	return nil
+}
+
+// sdmDataChanged gets the subscription data changed from the UDM and returns the configuration update
+// the UE is sent
+func sdmDataChanged(ue *context.OcfUe,
+	modificationNotification models.ModificationNotification) (update context.ConfigurationUpdate) {
+	for _, notifyItem := range modificationNotification.NotifyItems {
+		switch {
+		case strings.HasSuffix(notifyItem.ResourceId, "/am-data"):
+			problemDetails, err := consumer.SDMGetAmData(ue)
+			if problemDetails != nil {
+				logger.ProducerLog.Errorf("SDM_Get AM Data Failed Problem[%+v]", problemDetails)
+				continue
+			} else if err != nil {
+				logger.ProducerLog.Errorf("SDM_Get AM Data Error[%+v]", err)
+				continue
+			}
+			// TS 23.501 5.4.1.3: the UE leaves the MICO mode at the registration it is requested to
+			if ue.MicoMode && !ue.AccessAndMobilitySubscriptionData.MicoAllowed {
+				update.RegistrationRequested = true
+			}
+		case strings.HasSuffix(notifyItem.ResourceId, "/nssai"):
+			subscribedNssai := ue.SubscribedNssai
+			ue.SubscribedNssai = nil
+			problemDetails, err := consumer.SDMGetSliceSelectionSubscriptionData(ue)
+			if problemDetails != nil || err != nil {
+				logger.ProducerLog.Errorf("SDM_Get Slice Selection Subscription Data Failed Problem[%+v] Error[%+v]",
+					problemDetails, err)
+				ue.SubscribedNssai = subscribedNssai
+				continue
+			}
+			update.Merge(updateNssaiFromSubscription(ue))
+		default:
+			logger.ProducerLog.Debugf("Data change of resource[%s] needs no configuration update",
+				notifyItem.ResourceId)
+		}
+	}
+	return update
+}
+
+// updateNssaiFromSubscription makes the subscribed S-NSSAIs the configured NSSAI of the UE and takes the
+// S-NSSAIs no longer subscribed out of its allowed NSSAI, the UE without allowed S-NSSAI left registers
+// again (TS 23.502 4.2.4.2, TS 24.501 5.4.4.2)
+func updateNssaiFromSubscription(ue *context.OcfUe) context.ConfigurationUpdate {
+	update := context.ConfigurationUpdate{ConfiguredNssai: true}
+
+	ue.ConfiguredNssai = nil
+	for _, subscribedSnssai := range ue.SubscribedNssai {
+		ue.ConfiguredNssai = append(ue.ConfiguredNssai, models.ConfiguredSnssai{
+			ConfiguredSnssai: subscribedSnssai.SubscribedSnssai,
+		})
+	}
+
+	for anType, allowedNssai := range ue.AllowedNssai {
+		var subscribedAllowedNssai []models.AllowedSnssai
+		for _, allowedSnssai := range allowedNssai {
+			if allowedSnssai.AllowedSnssai != nil && ue.InSubscribedNssai(*allowedSnssai.AllowedSnssai) {
+				subscribedAllowedNssai = append(subscribedAllowedNssai, allowedSnssai)
+			}
+		}
+		if len(subscribedAllowedNssai) == len(allowedNssai) {
+			continue
+		}
+		ue.AllowedNssai[anType] = subscribedAllowedNssai
+		update.AllowedNssai = true
+		if len(subscribedAllowedNssai) == 0 {
+			update.RegistrationRequested = true
+		}
+	}
+	return update
+}
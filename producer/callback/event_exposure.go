@@ -0,0 +1,156 @@
+package callback
+
+import (
+	"bytes"
+	"encoding/json"
+	"free5gc/lib/openapi/models"
+	amf_context "free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+	"net/http"
+	"time"
+)
+
+// SendOcfEventReportNotify reports an event of the UE to every subscription of the UE that
+// contains the event type (TS 29.518 5.3.2.4 Namf_EventExposure_Notify)
+func SendOcfEventReportNotify(ue *amf_context.OcfUe, eventType models.OcfEventType) {
+	if ue == nil {
+		return
+	}
+
+	for subscriptionId, ueSubscription := range ue.EventSubscriptionsInfo {
+		subscription := ueSubscription.EventSubscription
+		if subscription == nil || subscription.EventList == nil || subscription.EventNotifyUri == "" {
+			continue
+		}
+		subscribed := false
+		for _, event := range *subscription.EventList {
+			if event.Type == eventType {
+				subscribed = true
+				break
+			}
+		}
+		if !subscribed {
+			continue
+		}
+
+		if ueSubscription.RemainReports != nil {
+			*ueSubscription.RemainReports--
+		}
+		report, ok := NewOcfEventReport(ue, eventType, subscriptionId)
+		if !ok {
+			continue
+		}
+		if !report.State.Active {
+			delete(ue.EventSubscriptionsInfo, subscriptionId)
+		}
+
+		notification := models.OcfEventNotification{
+			NotifyCorrelationId: subscription.NotifyCorrelationId,
+			ReportList:          []models.OcfEventReport{report},
+		}
+		logger.EeLog.Infof("[OCF] Send Event[%s] Notify to %s", eventType, subscription.EventNotifyUri)
+		if err := sendEventNotification(subscription.EventNotifyUri, notification); err != nil {
+			HttpLog.Errorln(err.Error())
+		}
+	}
+}
+
+func sendEventNotification(uri string, notification models.OcfEventNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	httpResponse, err := http.Post(uri, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusNoContent && httpResponse.StatusCode != http.StatusOK {
+		logger.EeLog.Warnf("Event notify to %s responded %s", uri, httpResponse.Status)
+	}
+	return nil
+}
+
+// DO NOT handle OcfEventType_PRESENCE_IN_AOI_REPORT and OcfEventType_UES_IN_AREA_REPORT(about area)
+func NewOcfEventReport(ue *amf_context.OcfUe, Type models.OcfEventType, subscriptionId string) (
+	report models.OcfEventReport, ok bool) {
+	ueSubscription, ok := ue.EventSubscriptionsInfo[subscriptionId]
+	if !ok {
+		return report, ok
+	}
+
+	report.AnyUe = ueSubscription.AnyUe
+	report.Supi = ue.Supi
+	report.Type = Type
+	report.TimeStamp = &ueSubscription.Timestamp
+	report.State = new(models.OcfEventState)
+	mode := ueSubscription.EventSubscription.Options
+	if mode == nil {
+		report.State.Active = true
+	} else if mode.Trigger == models.OcfEventTrigger_ONE_TIME {
+		report.State.Active = false
+	} else if *ueSubscription.RemainReports <= 0 {
+		report.State.Active = false
+	} else {
+		report.State.Active = getDuration(mode.Expiry, &report.State.RemainDuration)
+		if report.State.Active {
+			report.State.RemainReports = *ueSubscription.RemainReports
+		}
+	}
+
+	switch Type {
+	case models.OcfEventType_LOCATION_REPORT:
+		report.Location = &ue.Location
+	// case models.OcfEventType_PRESENCE_IN_AOI_REPORT:
+	// report.AreaList = (*subscription.EventList)[eventIndex].AreaList
+	case models.OcfEventType_TIMEZONE_REPORT:
+		report.Timezone = ue.TimeZone
+	case models.OcfEventType_ACCESS_TYPE_REPORT:
+		for accessType, state := range ue.State {
+			if state.Is(amf_context.Registered) {
+				report.AccessTypeList = append(report.AccessTypeList, accessType)
+			}
+		}
+	case models.OcfEventType_REGISTRATION_STATE_REPORT:
+		var rmInfos []models.RmInfo
+		for accessType, state := range ue.State {
+			rmInfo := models.RmInfo{
+				RmState:    models.RmState_DEREGISTERED,
+				AccessType: accessType,
+			}
+			if state.Is(amf_context.Registered) {
+				rmInfo.RmState = models.RmState_REGISTERED
+			}
+			rmInfos = append(rmInfos, rmInfo)
+		}
+		report.RmInfoList = rmInfos
+	case models.OcfEventType_CONNECTIVITY_STATE_REPORT:
+		report.CmInfoList = ue.GetCmInfo()
+	case models.OcfEventType_REACHABILITY_REPORT:
+		report.Reachability = ue.Reachability
+	case models.OcfEventType_SUBSCRIBED_DATA_REPORT:
+		report.SubscribedData = &ue.SubscribedData
+	case models.OcfEventType_COMMUNICATION_FAILURE_REPORT:
+		// TODO : report.CommFailure
+	case models.OcfEventType_SUBSCRIPTION_ID_CHANGE:
+		report.SubscriptionId = subscriptionId
+	case models.OcfEventType_SUBSCRIPTION_ID_ADDITION:
+		report.SubscriptionId = subscriptionId
+	}
+	return report, ok
+
+}
+
+func getDuration(expiry *time.Time, remainDuration *int32) bool {
+
+	if expiry != nil {
+		if time.Now().After(*expiry) {
+			return false
+		} else {
+			duration := time.Until(*expiry)
+			*remainDuration = int32(duration.Seconds())
+		}
+	}
+	return true
+
+}
@@ -5,6 +5,7 @@ import (
 	"free5gc/lib/openapi/models"
 	"free5gc/src/ocf/context"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/producer/callback"
 	"net/http"
 	"strconv"
 	"time"
@@ -124,7 +125,7 @@ func CreateOCFEventSubscriptionProcedure(createEventSubscription models.OcfCreat
 			}
 			for i, flag := range immediateFlags {
 				if flag {
-					report, ok := NewOcfEventReport(ue, (*subscription.EventList)[i].Type, newSubscriptionID)
+					report, ok := callback.NewOcfEventReport(ue, (*subscription.EventList)[i].Type, newSubscriptionID)
 					if ok {
 						reportlist = append(reportlist, report)
 					}
@@ -145,7 +146,7 @@ func CreateOCFEventSubscriptionProcedure(createEventSubscription models.OcfCreat
 			if ue.GroupID == subscription.GroupId {
 				for i, flag := range immediateFlags {
 					if flag {
-						report, ok := NewOcfEventReport(ue, (*subscription.EventList)[i].Type, newSubscriptionID)
+						report, ok := callback.NewOcfEventReport(ue, (*subscription.EventList)[i].Type, newSubscriptionID)
 						if ok {
 							reportlist = append(reportlist, report)
 						}
@@ -165,7 +166,7 @@ func CreateOCFEventSubscriptionProcedure(createEventSubscription models.OcfCreat
 		}
 		for i, flag := range immediateFlags {
 			if flag {
-				report, ok := NewOcfEventReport(ue, (*subscription.EventList)[i].Type, newSubscriptionID)
+				report, ok := callback.NewOcfEventReport(ue, (*subscription.EventList)[i].Type, newSubscriptionID)
 				if ok {
 					reportlist = append(reportlist, report)
 				}
@@ -311,87 +312,3 @@ func subReports(ue *context.OcfUe, subscriptionId string) {
 	}
 	*remainReport--
 }
-
-// DO NOT handle OcfEventType_PRESENCE_IN_AOI_REPORT and OcfEventType_UES_IN_AREA_REPORT(about area)
-func NewOcfEventReport(ue *context.OcfUe, Type models.OcfEventType, subscriptionId string) (
-	report models.OcfEventReport, ok bool) {
-	ueSubscription, ok := ue.EventSubscriptionsInfo[subscriptionId]
-	if !ok {
-		return report, ok
-	}
-
-	report.AnyUe = ueSubscription.AnyUe
-	report.Supi = ue.Supi
-	report.Type = Type
-	report.TimeStamp = &ueSubscription.Timestamp
-	report.State = new(models.OcfEventState)
-	mode := ueSubscription.EventSubscription.Options
-	if mode == nil {
-		report.State.Active = true
-	} else if mode.Trigger == models.OcfEventTrigger_ONE_TIME {
-		report.State.Active = false
-	} else if *ueSubscription.RemainReports <= 0 {
-		report.State.Active = false
-	} else {
-		report.State.Active = getDuration(mode.Expiry, &report.State.RemainDuration)
-		if report.State.Active {
-			report.State.RemainReports = *ueSubscription.RemainReports
-		}
-	}
-
-	switch Type {
-	case models.OcfEventType_LOCATION_REPORT:
-		report.Location = &ue.Location
-	// case models.OcfEventType_PRESENCE_IN_AOI_REPORT:
-	// report.AreaList = (*subscription.EventList)[eventIndex].AreaList
-	case models.OcfEventType_TIMEZONE_REPORT:
-		report.Timezone = ue.TimeZone
-	case models.OcfEventType_ACCESS_TYPE_REPORT:
-		for accessType, state := range ue.State {
-			if state.Is(context.Registered) {
-				report.AccessTypeList = append(report.AccessTypeList, accessType)
-			}
-		}
-	case models.OcfEventType_REGISTRATION_STATE_REPORT:
-		var rmInfos []models.RmInfo
-		for accessType, state := range ue.State {
-			rmInfo := models.RmInfo{
-				RmState:    models.RmState_DEREGISTERED,
-				AccessType: accessType,
-			}
-			if state.Is(context.Registered) {
-				rmInfo.RmState = models.RmState_REGISTERED
-			}
-			rmInfos = append(rmInfos, rmInfo)
-		}
-		report.RmInfoList = rmInfos
-	case models.OcfEventType_CONNECTIVITY_STATE_REPORT:
-		report.CmInfoList = ue.GetCmInfo()
-	case models.OcfEventType_REACHABILITY_REPORT:
-		report.Reachability = ue.Reachability
-	case models.OcfEventType_SUBSCRIBED_DATA_REPORT:
-		report.SubscribedData = &ue.SubscribedData
-	case models.OcfEventType_COMMUNICATION_FAILURE_REPORT:
-		// TODO : report.CommFailure
-	case models.OcfEventType_SUBSCRIPTION_ID_CHANGE:
-		report.SubscriptionId = subscriptionId
-	case models.OcfEventType_SUBSCRIPTION_ID_ADDITION:
-		report.SubscriptionId = subscriptionId
-	}
-	return report, ok
-
-}
-
-func getDuration(expiry *time.Time, remainDuration *int32) bool {
-
-	if expiry != nil {
-		if time.Now().After(*expiry) {
-			return false
-		} else {
-			duration := time.Until(*expiry)
-			*remainDuration = int32(duration.Seconds())
-		}
-	}
-	return true
-
-}
@@ -222,7 +222,8 @@ func N1N2MessageTransferProcedure(ueContextID string, reqUri string,
 		return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
 	}
 	// 504: the UE in MICO mode or the UE is only registered over Non-3GPP access and its state is CM-IDLE
-	if !ue.State[models.AccessType__3_GPP_ACCESS].Is(context.Registered) {
+	if !ue.State[models.AccessType__3_GPP_ACCESS].Is(context.Registered) ||
+		ue.Reachability == models.UeReachability_UNREACHABLE {
 		transferErr = new(models.N1N2MessageTransferError)
 		transferErr.Error = &models.ProblemDetails{
 			Status: http.StatusGatewayTimeout,
@@ -268,12 +269,10 @@ func N1N2MessageTransferProcedure(ueContextID string, reqUri string,
 				pagingPriority = new(ngapType.PagingPriority)
 				pagingPriority.Value = aper.Enumerated(onGoing.Ppi)
 			}
-			pkg, err := ngap_message.BuildPaging(ue, pagingPriority, false)
-			if err != nil {
-				logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
+			if err := ngap_message.SendPaging(ue, pagingPriority, false); err != nil {
+				logger.NgapLog.Errorf("Send Paging failed : %s", err.Error())
 				return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
 			}
-			ngap_message.SendPaging(ue, pkg)
 		}
 		// TODO: WAITING_FOR_ASYNCHRONOUS_TRANSFER
 		return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
@@ -318,11 +317,9 @@ func N1N2MessageTransferProcedure(ueContextID string, reqUri string,
 		pagingPriority = new(ngapType.PagingPriority)
 		pagingPriority.Value = aper.Enumerated(onGoing.Ppi)
 	}
-	pkg, err := ngap_message.BuildPaging(ue, pagingPriority, true)
-	if err != nil {
-		logger.NgapLog.Errorf("Build Paging failed : %s", err.Error())
+	if err := ngap_message.SendPaging(ue, pagingPriority, true); err != nil {
+		logger.NgapLog.Errorf("Send Paging failed : %s", err.Error())
 	}
-	ngap_message.SendPaging(ue, pkg)
 	return n1n2MessageTransferRspData, locationHeader, problemDetails, transferErr
 }
 
@@ -1,12 +1,24 @@
 package producer
 
 import (
+	"fmt"
 	"free5gc/lib/http_wrapper"
 	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/consumer"
 	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/factory"
+	gmm_message "free5gc/src/ocf/gmm/message"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/ngap/capture"
+	"free5gc/src/ocf/ngap/configupdate"
+	"free5gc/src/ocf/ngap/overload"
+	ngap_service "free5gc/src/ocf/ngap/service"
+	"free5gc/src/ocf/util"
 	"net/http"
+	"sort"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type PduSession struct {
@@ -29,10 +41,106 @@ type UEContext struct {
 	PduSessions []PduSession
 	/*Connection state */
 	CmState models.CmState
+	/* effective timers on the access type */
+	Timers []TimerValue
 }
 
 type UEContexts []UEContext
 
+// RanContext is a RAN with the capabilities it sent in its NG Setup
+type RanContext struct {
+	RanId            string                 `json:"ranId"`
+	Name             string                 `json:"name,omitempty"`
+	AnType           models.AccessType      `json:"anType"`
+	Address          string                 `json:"address,omitempty"`
+	SupportedTAList  []context.SupportedTAI `json:"supportedTAList"`
+	DefaultPagingDRX int                    `json:"defaultPagingDRX,omitempty"` // unit is radio frame
+	UeRetention      bool                   `json:"ueRetention"`
+	SetupTime        *time.Time             `json:"setupTime,omitempty"`
+	UeCount          int                    `json:"ueCount"`
+	// changes by RAN Configuration Update, the oldest first
+	ConfigurationHistory []context.RanConfigurationChange `json:"configurationHistory,omitempty"`
+}
+
+type RanContexts []RanContext
+
+// NgapCaptureSetting changes the NGAP capture at runtime, unset fields keep their value
+type NgapCaptureSetting struct {
+	Enable      *bool    `json:"enable,omitempty"`
+	Directory   string   `json:"directory,omitempty"`
+	MaxFileSize int64    `json:"maxFileSize,omitempty"` // unit is MB
+	MaxFiles    int      `json:"maxFiles,omitempty"`
+	RanList     []string `json:"ranList,omitempty"`
+}
+
+// UeConfigurationUpdate selects the parameters sent to a UE by the UE configuration update procedure, they
+// take their current value in the context of the UE. A new 5G-GUTI is allocated if guti is set.
+type UeConfigurationUpdate struct {
+	AccessType            models.AccessType `json:"accessType,omitempty"` // 3GPP access if it is not set
+	Guti                  bool              `json:"guti,omitempty"`
+	RegistrationArea      bool              `json:"registrationArea,omitempty"`
+	AllowedNssai          bool              `json:"allowedNssai,omitempty"`
+	ConfiguredNssai       bool              `json:"configuredNssai,omitempty"`
+	ServiceAreaList       bool              `json:"serviceAreaList,omitempty"`
+	NetworkName           bool              `json:"networkName,omitempty"`
+	TimeZone              bool              `json:"timeZone,omitempty"`
+	LadnInformation       bool              `json:"ladnInformation,omitempty"`
+	MicoIndication        bool              `json:"micoIndication,omitempty"`
+	Acknowledge           bool              `json:"acknowledge,omitempty"`
+	RegistrationRequested bool              `json:"registrationRequested,omitempty"`
+}
+
+// UeRegistrationArea is the registration area of a UE over 3GPP access and its number of mobility
+// registration updates
+type UeRegistrationArea struct {
+	Supi                  string       `json:"supi"`
+	RegistrationArea      []models.Tai `json:"registrationArea,omitempty"`
+	MobilityRegistrations int          `json:"mobilityRegistrations"`
+}
+
+// RegistrationAreaStatus is the registration area allocation with the statistics of the registered UEs
+type RegistrationAreaStatus struct {
+	Strategy              string               `json:"strategy"`
+	Strategies            []string             `json:"strategies"` // strategies the OCF has an allocator of
+	MaxTais               int                  `json:"maxTais"`
+	MobilityRegistrations int                  `json:"mobilityRegistrations"` // of all the UEs
+	Ues                   []UeRegistrationArea `json:"ues"`
+}
+
+// RegistrationAreaSetting changes the registration area allocation at runtime, unset fields keep their value
+type RegistrationAreaSetting struct {
+	Strategy string `json:"strategy,omitempty"`
+	MaxTais  int    `json:"maxTais,omitempty"`
+}
+
+// TimerValue is the value of a timer once the defaults and the overrides are applied
+type TimerValue struct {
+	Name          context.TimerName `json:"name"`
+	Value         int64             `json:"value"` // unit is millisecond
+	MaxRetryTimes int               `json:"maxRetryTimes"`
+}
+
+type SnssaiTimerValues struct {
+	Snssai models.Snssai `json:"snssai"`
+	Timers []TimerValue  `json:"timers"`
+}
+
+// Timers are the timers of the OCF, the overrides list only the timers they change
+type Timers struct {
+	Default    []TimerValue                       `json:"default"`
+	AccessType map[models.AccessType][]TimerValue `json:"accessType,omitempty"`
+	Snssai     []SnssaiTimerValues                `json:"snssai,omitempty"`
+}
+
+// ConfigurationReload is the result of a reload of the configuration
+type ConfigurationReload struct {
+	File    string   `json:"file,omitempty"` // empty if the configuration came in the request
+	Changed []string `json:"changed"`        // parameters as they are named in the configuration file
+}
+
+// one reload at a time so that each one is compared with the configuration applied by the previous
+var reloadMutex sync.Mutex
+
 func HandleOAMRegisteredUEContext(request *http_wrapper.Request) *http_wrapper.Response {
 	logger.ProducerLog.Infof("[OAM] Handle Registered UE Context")
 
@@ -116,7 +224,420 @@ func buildUEContext(ue *context.OcfUe, accessType models.AccessType) *UEContext
 		} else {
 			ueContext.CmState = models.CmState_IDLE
 		}
+
+		timers := make(map[context.TimerName]context.Timer)
+		for _, name := range context.TimerNames {
+			timers[name] = ue.Timer(name, accessType)
+		}
+		ueContext.Timers = buildTimerValues(timers)
 		return ueContext
 	}
 	return nil
 }
+
+func HandleOAMRanContext(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle RAN Context")
+
+	ranId := request.Params["ranId"]
+
+	ranContexts, problemDetails := OAMRanContextProcedure(ranId)
+	if problemDetails != nil {
+		return http_wrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return http_wrapper.NewResponse(http.StatusOK, nil, ranContexts)
+}
+
+// OAMRanContextProcedure returns the RANs which have completed the NG Setup, only the RAN of
+// ranId if it is set
+func OAMRanContextProcedure(ranId string) (RanContexts, *models.ProblemDetails) {
+	ranContexts := RanContexts{}
+	context.OCF_Self().OcfRanPool.Range(func(key, value interface{}) bool {
+		ran := value.(*context.OcfRan)
+		id := ran.RanIdString()
+		if id == "" || (ranId != "" && id != ranId) {
+			return true
+		}
+		ranContexts = append(ranContexts, buildRanContext(ran))
+		return true
+	})
+
+	if ranId != "" && len(ranContexts) == 0 {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+		return nil, problemDetails
+	}
+	return ranContexts, nil
+}
+
+func buildRanContext(ran *context.OcfRan) RanContext {
+	ranContext := RanContext{
+		RanId:            ran.RanIdString(),
+		Name:             ran.Name,
+		AnType:           ran.AnType,
+		SupportedTAList:  ran.SupportedTAList,
+		DefaultPagingDRX: ran.DefaultPagingDRX,
+		UeRetention:      ran.UeRetention,
+		UeCount:          len(ran.RanUes()),
+
+		ConfigurationHistory: ran.ConfigurationHistory(),
+	}
+	if ran.Conn != nil {
+		ranContext.Address = ran.Conn.RemoteAddr().String()
+	}
+	if !ran.SetupTime.IsZero() {
+		setupTime := ran.SetupTime
+		ranContext.SetupTime = &setupTime
+	}
+	return ranContext
+}
+
+func HandleOAMGetNgapCapture(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Get NGAP Capture")
+	return http_wrapper.NewResponse(http.StatusOK, nil, capture.GetStatus())
+}
+
+func HandleOAMGetOverload(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Get Overload")
+	return http_wrapper.NewResponse(http.StatusOK, nil, overload.GetStatus())
+}
+
+func HandleOAMGetTimers(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Get Timers")
+	return http_wrapper.NewResponse(http.StatusOK, nil, OAMGetTimersProcedure())
+}
+
+func OAMGetTimersProcedure() Timers {
+	parameters := context.OCF_Self().Parameters().Timers
+	timers := Timers{
+		Default:    buildTimerValues(parameters.Default),
+		AccessType: make(map[models.AccessType][]TimerValue),
+	}
+	for accessType, overrideTimers := range parameters.AccessType {
+		timers.AccessType[accessType] = buildTimerValues(overrideTimers)
+	}
+	for _, override := range parameters.Snssai {
+		timers.Snssai = append(timers.Snssai, SnssaiTimerValues{
+			Snssai: override.Snssai,
+			Timers: buildTimerValues(override.Timers),
+		})
+	}
+	return timers
+}
+
+// buildTimerValues lists the timers in the order of context.TimerNames
+func buildTimerValues(timers map[context.TimerName]context.Timer) []TimerValue {
+	values := []TimerValue{}
+	for _, name := range context.TimerNames {
+		if timer, ok := timers[name]; ok {
+			values = append(values, TimerValue{
+				Name:          name,
+				Value:         int64(timer.Value / time.Millisecond),
+				MaxRetryTimes: timer.MaxRetryTimes,
+			})
+		}
+	}
+	return values
+}
+
+func HandleOAMGetOcfConfigurationUpdate(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Get OCF Configuration Update")
+	return http_wrapper.NewResponse(http.StatusOK, nil, configupdate.GetStatus())
+}
+
+func HandleOAMSetNgapCapture(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Set NGAP Capture")
+
+	setting := request.Body.(NgapCaptureSetting)
+
+	status, problemDetails := OAMSetNgapCaptureProcedure(setting)
+	if problemDetails != nil {
+		return http_wrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return http_wrapper.NewResponse(http.StatusOK, nil, status)
+}
+
+func OAMSetNgapCaptureProcedure(setting NgapCaptureSetting) (*capture.Status, *models.ProblemDetails) {
+	amfSelf := context.OCF_Self()
+
+	var err error
+	// the capture is restarted under the update so that it runs with the published parameters
+	amfSelf.UpdateParameters(func(current *context.Parameters) {
+		parameters := current.NgapCapture
+		if setting.Enable != nil {
+			parameters.Enable = *setting.Enable
+		}
+		if setting.Directory != "" {
+			parameters.Directory = setting.Directory
+		}
+		if setting.MaxFileSize != 0 {
+			parameters.MaxFileSize = setting.MaxFileSize * 1024 * 1024
+		}
+		if setting.MaxFiles != 0 {
+			parameters.MaxFiles = setting.MaxFiles
+		}
+		if setting.RanList != nil {
+			parameters.RanList = setting.RanList
+		}
+		if err = capture.Start(parameters); err == nil {
+			current.NgapCapture = parameters
+		}
+	})
+	if err != nil {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+			Detail: err.Error(),
+		}
+		return nil, problemDetails
+	}
+
+	status := capture.GetStatus()
+	return &status, nil
+}
+
+func HandleOAMUpdateUeConfiguration(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle UE Configuration Update")
+
+	supi := request.Params["supi"]
+	setting := request.Body.(UeConfigurationUpdate)
+
+	problemDetails := OAMUpdateUeConfigurationProcedure(supi, setting)
+	if problemDetails != nil {
+		return http_wrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return http_wrapper.NewResponse(http.StatusNoContent, nil, nil)
+}
+
+func OAMUpdateUeConfigurationProcedure(supi string, setting UeConfigurationUpdate) *models.ProblemDetails {
+	amfSelf := context.OCF_Self()
+
+	ue, ok := amfSelf.OcfUeFindBySupi(supi)
+	if !ok {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "CONTEXT_NOT_FOUND",
+		}
+		return problemDetails
+	}
+
+	anType := setting.AccessType
+	if anType == "" {
+		anType = models.AccessType__3_GPP_ACCESS
+	}
+	if ue.State[anType] == nil || !ue.State[anType].Is(context.Registered) {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusConflict,
+			Cause:  "UE_NOT_REGISTERED",
+			Detail: fmt.Sprintf("UE[%s] is not registered over %s", supi, anType),
+		}
+		return problemDetails
+	}
+
+	update := context.ConfigurationUpdate{
+		Guti:                  setting.Guti,
+		RegistrationArea:      setting.RegistrationArea,
+		AllowedNssai:          setting.AllowedNssai,
+		ConfiguredNssai:       setting.ConfiguredNssai,
+		ServiceAreaList:       setting.ServiceAreaList,
+		NetworkName:           setting.NetworkName,
+		TimeZone:              setting.TimeZone,
+		LadnInformation:       setting.LadnInformation,
+		MicoIndication:        setting.MicoIndication,
+		Acknowledge:           setting.Acknowledge,
+		RegistrationRequested: setting.RegistrationRequested,
+	}
+	if update.Empty() {
+		problemDetails := &models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: "No parameter to update",
+		}
+		return problemDetails
+	}
+
+	// the UE context is changed on the lane of the UE, the NAS and NGAP procedures of the UE are not raced
+	var err error
+	done := make(chan struct{})
+	ngap_service.PostUe(ue, func() {
+		defer close(done)
+		if update.Guti {
+			amfSelf.ReallocateGuti(ue)
+		}
+		err = gmm_message.UpdateUeConfiguration(ue, anType, update)
+	})
+	<-done
+	if err != nil {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+			Detail: err.Error(),
+		}
+		return problemDetails
+	}
+	return nil
+}
+
+func HandleOAMGetRegistrationArea(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Get Registration Area")
+	return http_wrapper.NewResponse(http.StatusOK, nil, OAMGetRegistrationAreaProcedure())
+}
+
+func OAMGetRegistrationAreaProcedure() RegistrationAreaStatus {
+	amfSelf := context.OCF_Self()
+	parameters := amfSelf.Parameters()
+
+	status := RegistrationAreaStatus{
+		Strategy:   parameters.RegistrationArea.Strategy,
+		Strategies: context.RegistrationAreaStrategies(),
+		MaxTais:    parameters.RegistrationArea.MaxTais,
+		Ues:        []UeRegistrationArea{},
+	}
+	amfSelf.UePool.Range(func(key, value interface{}) bool {
+		ue := value.(*context.OcfUe)
+		status.MobilityRegistrations += ue.MobilityRegistrations
+		status.Ues = append(status.Ues, UeRegistrationArea{
+			Supi:                  ue.Supi,
+			RegistrationArea:      ue.RegistrationArea[models.AccessType__3_GPP_ACCESS],
+			MobilityRegistrations: ue.MobilityRegistrations,
+		})
+		return true
+	})
+	sort.Slice(status.Ues, func(i, j int) bool {
+		return status.Ues[i].Supi < status.Ues[j].Supi
+	})
+	return status
+}
+
+func HandleOAMSetRegistrationArea(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Set Registration Area")
+
+	setting := request.Body.(RegistrationAreaSetting)
+
+	status, problemDetails := OAMSetRegistrationAreaProcedure(setting)
+	if problemDetails != nil {
+		return http_wrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return http_wrapper.NewResponse(http.StatusOK, nil, status)
+}
+
+// OAMSetRegistrationAreaProcedure changes the registration area allocation of the next registrations, the
+// registration areas already allocated are kept
+func OAMSetRegistrationAreaProcedure(setting RegistrationAreaSetting) (*RegistrationAreaStatus,
+	*models.ProblemDetails) {
+	if setting.Strategy != "" && !context.RegistrationAreaStrategyKnown(setting.Strategy) {
+		problemDetails := &models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("Unknown registration area strategy[%s]", setting.Strategy),
+		}
+		return nil, problemDetails
+	}
+	if setting.MaxTais < 0 || setting.MaxTais > context.MaxNumOfTAI {
+		problemDetails := &models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("maxTais %d is out of range (1~%d)", setting.MaxTais, context.MaxNumOfTAI),
+		}
+		return nil, problemDetails
+	}
+
+	// the setting is applied to the current parameters, neither a reload nor another setting is lost
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+	context.OCF_Self().UpdateParameters(func(current *context.Parameters) {
+		if setting.Strategy != "" {
+			current.RegistrationArea.Strategy = setting.Strategy
+		}
+		if setting.MaxTais != 0 {
+			current.RegistrationArea.MaxTais = setting.MaxTais
+		}
+	})
+
+	status := OAMGetRegistrationAreaProcedure()
+	return &status, nil
+}
+
+func HandleOAMReloadConfiguration(request *http_wrapper.Request) *http_wrapper.Response {
+	logger.ProducerLog.Infof("[OAM] Handle Reload Configuration")
+
+	content := request.Body.([]byte)
+
+	reload, problemDetails := OAMReloadConfigurationProcedure(content)
+	if problemDetails != nil {
+		return http_wrapper.NewResponse(int(problemDetails.Status), nil, problemDetails)
+	}
+	return http_wrapper.NewResponse(http.StatusOK, nil, reload)
+}
+
+// OAMReloadConfigurationProcedure applies the reloadable parameters of a new configuration at
+// runtime, the configuration file is read again if content is empty. The new configuration is
+// rejected as a whole if it changes a parameter which needs a restart of the OCF.
+func OAMReloadConfigurationProcedure(content []byte) (*ConfigurationReload, *models.ProblemDetails) {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	reload := &ConfigurationReload{Changed: []string{}}
+	var config *factory.Config
+	var err error
+	if len(content) == 0 {
+		reload.File = factory.ConfigFile()
+		config, err = factory.ReadConfig(reload.File)
+	} else {
+		config, err = factory.ParseConfig(content)
+	}
+	if err != nil {
+		logger.ProducerLog.Errorf("Read configuration error: %+v", err)
+		problemDetails := &models.ProblemDetails{
+			Title:  "Malformed configuration",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+		}
+		return nil, problemDetails
+	}
+
+	amfSelf := context.OCF_Self()
+	previous := configupdate.Current()
+	changed, err := util.ReloadOcfContext(amfSelf, factory.OcfConfig.Configuration, config.Configuration)
+	if err != nil {
+		logger.ProducerLog.Errorf("Reload configuration rejected: %+v", err)
+		problemDetails := &models.ProblemDetails{
+			Title:  "Configuration not reloadable",
+			Status: http.StatusConflict,
+			Detail: err.Error(),
+		}
+		return nil, problemDetails
+	}
+	factory.OcfConfig = *config
+	if len(changed) == 0 {
+		logger.ProducerLog.Infof("Configuration unchanged")
+		return reload, nil
+	}
+	reload.Changed = changed
+	logger.ProducerLog.Infof("Configuration reloaded, changed: %v", changed)
+
+	parameters := amfSelf.Parameters()
+	updateNfProfile := false
+	for _, parameter := range changed {
+		switch parameter {
+		case "ngapCapture":
+			if err := capture.Start(parameters.NgapCapture); err != nil {
+				logger.ProducerLog.Errorf("Restart NGAP capture failed: %+v", err)
+			}
+		case "overloadControl":
+			overload.Start(parameters.OverloadControl)
+		case "servedGuamiList", "supportTaiList", "plmnSupportList":
+			updateNfProfile = true
+		}
+	}
+	configupdate.Push(previous)
+	if updateNfProfile {
+		if profile, err := consumer.BuildNFInstance(amfSelf); err != nil {
+			logger.ProducerLog.Errorf("Build OCF Profile Error: %+v", err)
+		} else if _, _, err := consumer.SendRegisterNFInstance(amfSelf.NrfUri, amfSelf.NfId, profile); err != nil {
+			logger.ProducerLog.Warnf("Update NF profile in NRF failed: %+v", err)
+		}
+	}
+	return reload, nil
+}
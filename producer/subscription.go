@@ -31,7 +31,7 @@ func OCFStatusChangeSubscribeProcedure(subscriptionDataReq models.SubscriptionDa
 	amfSelf := context.OCF_Self()
 
 	for _, guami := range subscriptionDataReq.GuamiList {
-		for _, servedGumi := range amfSelf.ServedGuamiList {
+		for _, servedGumi := range amfSelf.Parameters().ServedGuamiList {
 			if reflect.DeepEqual(guami, servedGumi) {
 				//OCF status is available
 				subscriptionDataRsp.GuamiList = append(subscriptionDataRsp.GuamiList, guami)
@@ -6,6 +6,7 @@ import (
 	"free5gc/src/ocf/consumer"
 	"free5gc/src/ocf/context"
 	"free5gc/src/ocf/logger"
+	"free5gc/src/ocf/nas/nas_security"
 	"net/http"
 	"strings"
 )
@@ -72,6 +73,14 @@ func CreateUEContextProcedure(ueContextID string, createUeContextRequest models.
 
 	ue.RoutingIndicator = ueContextCreateData.UeContext.RoutingIndicator
 
+	// the UE keeps the security context of the source OCF, a Kamf derived horizontally by the source OCF is
+	// derived by the UE from the Handover Command and taken into use by the NAS SMC of the registration after
+	// the handover (TS 33.501 6.9.2.3.3)
+	if seafData := ueContextCreateData.UeContext.SeafData; seafData != nil {
+		ue.CopyDataFromUeContextModel(models.UeContext{SeafData: seafData})
+		ue.KamfDerivedAtHandover = seafData.KeyOcfHDerivationInd
+	}
+
 	// optional
 	ue.UdmGroupId = ueContextCreateData.UeContext.UdmGroupId
 	ue.AusfGroupId = ueContextCreateData.UeContext.AusfGroupId
@@ -91,7 +100,6 @@ func CreateUEContextProcedure(ueContextID string, createUeContextRequest models.
 	//ue.UEAMBR = ueContextCreateData.UeContext.SubUeAmbr
 	//ueContextCreateData.UeContext.SmsSupport
 	//ueContextCreateData.UeContext.SmsfId
-	//ueContextCreateData.UeContext.SeafData
 	//ueContextCreateData.UeContext.Var5gMmCapability
 	//ueContextCreateData.UeContext.PcfId
 	//ueContextCreateData.UeContext.PcfAmPolicyUri
@@ -221,7 +229,7 @@ func UEContextTransferProcedure(ueContextID string, ueContextTransferRequest mod
 		return nil, problemDetails
 	}
 
-	var ueContextTransferResponse *models.UeContextTransferResponse
+	ueContextTransferResponse := new(models.UeContextTransferResponse)
 	ueContextTransferResponse.JsonData = new(models.UeContextTransferRspData)
 	ueContextTransferRspData := ueContextTransferResponse.JsonData
 
@@ -233,13 +241,28 @@ func UEContextTransferProcedure(ueContextID string, ueContextTransferRequest mod
 	//}
 	//}
 
+	// the Kamf derived horizontally for the new OCF needs the uplink NAS COUNT of the Registration Request,
+	// which is checked first; the current Kamf is given otherwise, like to the UE validated by the new OCF
+	var ulCount uint32
+	var problemDetails *models.ProblemDetails
+	horizontalDerivation := false
 	switch UeContextTransferReqData.Reason {
 	case models.TransferReason_INIT_REG:
-		// TODO: check integrity of the registration request included in ueContextTransferRequest
+		horizontalDerivation = amfSelf.Parameters().KamfHorizontalDerivation
+		if horizontalDerivation {
+			if ulCount, problemDetails = checkRegistrationRequest(ue, ueContextTransferRequest); problemDetails != nil {
+				return nil, problemDetails
+			}
+		}
 		// TODO: handle condition of TS 29.518 5.2.2.2.1.1 step 2a case b
 		ueContextTransferRspData.UeContext = buildUEContextModel(ue)
 	case models.TransferReason_MOBI_REG:
-		// TODO: check integrity of the registration request included in ueContextTransferRequest
+		horizontalDerivation = amfSelf.Parameters().KamfHorizontalDerivation
+		if horizontalDerivation {
+			if ulCount, problemDetails = checkRegistrationRequest(ue, ueContextTransferRequest); problemDetails != nil {
+				return nil, problemDetails
+			}
+		}
 		ueContextTransferRspData.UeContext = buildUEContextModel(ue)
 
 		sessionContextList := &ueContextTransferRspData.UeContext.SessionContextList
@@ -286,9 +309,53 @@ func UEContextTransferProcedure(ueContextID string, ueContextTransferRequest mod
 		}
 		return nil, problemDetails
 	}
+
+	seafData, err := ue.BuildSeafData(horizontalDerivation, ulCount)
+	if err != nil {
+		logger.ProducerLog.Errorf("Build SEAF data of UE[%s] failed: %+v", ue.Supi, err)
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusInternalServerError,
+			Cause:  "SYSTEM_FAILURE",
+		}
+		return nil, problemDetails
+	}
+	ueContextTransferRspData.UeContext.SeafData = seafData
 	return ueContextTransferResponse, nil
 }
 
+// checkRegistrationRequest checks the integrity of the Registration Request the new OCF received, the uplink
+// NAS COUNT it is protected with is returned (TS 29.518 5.2.2.2.1.1, TS 33.501 A.13)
+func checkRegistrationRequest(ue *context.OcfUe, ueContextTransferRequest models.UeContextTransferRequest) (
+	uint32, *models.ProblemDetails) {
+	if ueContextTransferRequest.JsonData.RegRequest == nil || len(ueContextTransferRequest.BinaryDataN1Message) == 0 {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_MISSING",
+			InvalidParams: []models.InvalidParam{
+				{
+					Param: "regRequest",
+				},
+			},
+		}
+		return 0, problemDetails
+	}
+
+	ulCount, macOk, err := nas_security.CheckIntegrity(ue, ueContextTransferRequest.BinaryDataN1Message)
+	if err != nil {
+		logger.ProducerLog.Errorf("Check the Registration Request of UE[%s] error: %+v", ue.Supi, err)
+	} else if !macOk {
+		logger.ProducerLog.Warnf("Integrity check of the Registration Request of UE[%s] failed", ue.Supi)
+	}
+	if err != nil || !macOk {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  "INTEGRITY_CHECK_FAIL",
+		}
+		return 0, problemDetails
+	}
+	return ulCount, nil
+}
+
 func buildUEContextModel(ue *context.OcfUe) *models.UeContext {
 	ueContext := new(models.UeContext)
 	ueContext.Supi = ue.Supi
@@ -0,0 +1,111 @@
+package replay
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+type address string
+
+func (a address) Network() string {
+	return "sctp"
+}
+
+func (a address) String() string {
+	return string(a)
+}
+
+// downlink is a message the OCF sent on a fake association
+type downlink struct {
+	streamId uint16
+	payload  []byte
+}
+
+// conn stands for the SCTP association of a captured RAN, it records the messages the OCF sends
+// to the RAN instead of sending them
+type conn struct {
+	ran, ocf address
+
+	mutex    sync.Mutex
+	downlink []downlink
+	closed   bool
+}
+
+var _ ngap_service.Conn = (*conn)(nil)
+
+func newConn(ran, ocf string) *conn {
+	return &conn{
+		ran: address(ran),
+		ocf: address(ocf),
+	}
+}
+
+// take returns the messages sent since the last call
+func (c *conn) take() []downlink {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	sent := c.downlink
+	c.downlink = nil
+	return sent
+}
+
+func (c *conn) WriteMsg(msg []byte, info *ngap_service.MsgInfo) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+	var streamId uint16
+	if info != nil {
+		streamId = info.Stream
+	}
+	c.downlink = append(c.downlink, downlink{
+		streamId: streamId,
+		payload:  append([]byte(nil), msg...),
+	})
+	return len(msg), nil
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	return c.WriteMsg(b, nil)
+}
+
+// ReadMsg and Read are never called, the replay hands the uplink messages to ngap.Dispatch
+func (c *conn) ReadMsg(buf []byte) (int, *ngap_service.MsgInfo, *ngap_service.Notification, error) {
+	return 0, nil, nil, io.EOF
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (c *conn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.ocf
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.ran
+}
+
+func (c *conn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,251 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// link types of the captures taken on the OCF, on a gNB or by the NGAP capture of the OCF
+const (
+	linkTypeEthernet uint32 = 1
+	linkTypeRaw      uint32 = 101
+	linkTypeLinuxSll uint32 = 113
+	linkTypeIPv4     uint32 = 228
+	linkTypeIPv6     uint32 = 229
+	linkTypeLinuxSl2 uint32 = 276
+)
+
+const (
+	etherTypeIPv4  = 0x0800
+	etherTypeIPv6  = 0x86dd
+	etherTypeVlan  = 0x8100
+	etherTypeQinQ  = 0x88a8
+	ipProtocolSctp = 132
+
+	sctpChunkTypeData = 0
+	sctpDataFlagEnd   = 0x01
+	sctpDataFlagBegin = 0x02
+	// NGAP payload protocol identifier (TS 38.412 7)
+	ngapPpid uint32 = 60
+)
+
+// Message is one NGAP message of the capture
+type Message struct {
+	Time time.Time
+	// Ran and Ocf are the "ip:port" of the association endpoints
+	Ran, Ocf string
+	Uplink   bool
+	StreamId uint16
+	Payload  []byte
+}
+
+// Association is the key of the association of the message
+func (m *Message) Association() string {
+	return m.Ran + "-" + m.Ocf
+}
+
+// sctpPeer is one direction of an association, it keeps the chunks of a fragmented message
+// and the TSNs already seen to drop retransmissions
+type sctpPeer struct {
+	fragments map[uint16][]byte
+	tsns      map[uint32]bool
+}
+
+// ReadPcap returns the NGAP messages of a pcap file in capture order. A message is uplink if it
+// is sent to ocfPort and downlink if it is sent from ocfPort, the other SCTP traffic is skipped.
+// The associations are told apart by their addresses, so a multi-homed association whose
+// messages use several paths shows as several associations.
+func ReadPcap(r io.Reader, ocfPort uint16) ([]Message, error) {
+	reader := bufio.NewReader(r)
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("read pcap header: %+v", err)
+	}
+
+	var order binary.ByteOrder
+	var nanosecond bool
+	switch {
+	case binary.LittleEndian.Uint32(header[0:4]) == 0xa1b2c3d4:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header[0:4]) == 0xa1b2c3d4:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(header[0:4]) == 0xa1b23c4d:
+		order, nanosecond = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(header[0:4]) == 0xa1b23c4d:
+		order, nanosecond = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("not a pcap file (pcapng is not supported, convert it with editcap -F pcap)")
+	}
+	linkType := order.Uint32(header[20:24]) & 0x0fffffff
+
+	peers := make(map[string]*sctpPeer)
+	var messages []Message
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(reader, record); err == io.EOF {
+			return messages, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("read pcap record: %+v", err)
+		}
+		fraction := int64(order.Uint32(record[4:8]))
+		if !nanosecond {
+			fraction *= 1000
+		}
+		timestamp := time.Unix(int64(order.Uint32(record[0:4])), fraction)
+		data := make([]byte, order.Uint32(record[8:12]))
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("read pcap record: %+v", err)
+		}
+
+		src, dst, sctp := ipPayload(linkType, data)
+		if sctp == nil {
+			continue
+		}
+		messages = append(messages, sctpMessages(peers, timestamp, src, dst, sctp, ocfPort)...)
+	}
+}
+
+// ipPayload returns the addresses and the SCTP packet of a frame, nil if the frame is not SCTP
+func ipPayload(linkType uint32, frame []byte) (src, dst net.IP, sctp []byte) {
+	var etherType uint16
+	switch linkType {
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[12:14]), frame[14:]
+		for (etherType == etherTypeVlan || etherType == etherTypeQinQ) && len(frame) >= 4 {
+			etherType, frame = binary.BigEndian.Uint16(frame[2:4]), frame[4:]
+		}
+	case linkTypeLinuxSll:
+		if len(frame) < 16 {
+			return
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[14:16]), frame[16:]
+	case linkTypeLinuxSl2:
+		if len(frame) < 20 {
+			return
+		}
+		etherType, frame = binary.BigEndian.Uint16(frame[0:2]), frame[20:]
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		if len(frame) == 0 {
+			return
+		}
+		if frame[0]>>4 == 6 {
+			etherType = etherTypeIPv6
+		} else {
+			etherType = etherTypeIPv4
+		}
+	default:
+		return
+	}
+
+	switch etherType {
+	case etherTypeIPv4:
+		if len(frame) < 20 || frame[9] != ipProtocolSctp {
+			return
+		}
+		// fragmented IP packets are not reassembled
+		if binary.BigEndian.Uint16(frame[6:8])&0x3fff != 0 {
+			return
+		}
+		headerLen := int(frame[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(frame[2:4]))
+		if headerLen < 20 || totalLen < headerLen || totalLen > len(frame) {
+			return
+		}
+		return net.IP(frame[12:16]), net.IP(frame[16:20]), frame[headerLen:totalLen]
+	case etherTypeIPv6:
+		if len(frame) < 40 || frame[6] != ipProtocolSctp {
+			return
+		}
+		payloadLen := int(binary.BigEndian.Uint16(frame[4:6]))
+		if 40+payloadLen > len(frame) {
+			return
+		}
+		return net.IP(frame[8:24]), net.IP(frame[24:40]), frame[40 : 40+payloadLen]
+	}
+	return
+}
+
+// sctpMessages returns the complete NGAP messages of the DATA chunks of an SCTP packet
+func sctpMessages(peers map[string]*sctpPeer, timestamp time.Time, src, dst net.IP, sctp []byte,
+	ocfPort uint16) (messages []Message) {
+	if len(sctp) < 12 {
+		return
+	}
+	srcPort, dstPort := binary.BigEndian.Uint16(sctp[0:2]), binary.BigEndian.Uint16(sctp[2:4])
+	srcAddr := net.JoinHostPort(src.String(), strconv.Itoa(int(srcPort)))
+	dstAddr := net.JoinHostPort(dst.String(), strconv.Itoa(int(dstPort)))
+
+	message := Message{Time: timestamp}
+	switch ocfPort {
+	case dstPort:
+		message.Ran, message.Ocf, message.Uplink = srcAddr, dstAddr, true
+	case srcPort:
+		message.Ran, message.Ocf = dstAddr, srcAddr
+	default:
+		return
+	}
+
+	peerKey := srcAddr + ">" + dstAddr
+	peer, ok := peers[peerKey]
+	if !ok {
+		peer = &sctpPeer{
+			fragments: make(map[uint16][]byte),
+			tsns:      make(map[uint32]bool),
+		}
+		peers[peerKey] = peer
+	}
+
+	for chunks := sctp[12:]; len(chunks) >= 4; {
+		chunkLen := int(binary.BigEndian.Uint16(chunks[2:4]))
+		if chunkLen < 4 || chunkLen > len(chunks) {
+			return
+		}
+		chunk := chunks[:chunkLen]
+		if padded := (chunkLen + 3) &^ 3; padded < len(chunks) {
+			chunks = chunks[padded:]
+		} else {
+			chunks = nil
+		}
+		if chunk[0] != sctpChunkTypeData || chunkLen < 16 {
+			continue
+		}
+
+		flags := chunk[1]
+		tsn := binary.BigEndian.Uint32(chunk[4:8])
+		streamId := binary.BigEndian.Uint16(chunk[8:10])
+		ppid := binary.BigEndian.Uint32(chunk[12:16])
+		// some RANs leave the PPID unspecified, the port already tells the protocol
+		if ppid != ngapPpid && ppid != 0 {
+			continue
+		}
+		if peer.tsns[tsn] {
+			continue
+		}
+		peer.tsns[tsn] = true
+
+		payload := peer.fragments[streamId]
+		if flags&sctpDataFlagBegin != 0 {
+			payload = nil
+		}
+		payload = append(payload, chunk[16:]...)
+		if flags&sctpDataFlagEnd == 0 {
+			peer.fragments[streamId] = payload
+			continue
+		}
+		delete(peer.fragments, streamId)
+
+		m := message
+		m.StreamId = streamId
+		m.Payload = payload
+		messages = append(messages, m)
+	}
+	return
+}
@@ -0,0 +1,110 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dataChunk is a DATA chunk with the NGAP PPID
+func dataChunk(flags byte, tsn uint32, streamId uint16, payload []byte) []byte {
+	chunk := make([]byte, 16, 16+len(payload)+3)
+	chunk[1] = flags
+	binary.BigEndian.PutUint16(chunk[2:4], uint16(16+len(payload)))
+	binary.BigEndian.PutUint32(chunk[4:8], tsn)
+	binary.BigEndian.PutUint16(chunk[8:10], streamId)
+	binary.BigEndian.PutUint32(chunk[12:16], ngapPpid)
+	chunk = append(chunk, payload...)
+	for len(chunk)%4 != 0 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// ipv4Frame is an IPv4 packet from src to dst carrying the chunks
+func ipv4Frame(src, dst [4]byte, srcPort, dstPort uint16, chunks ...[]byte) []byte {
+	sctp := make([]byte, 12)
+	binary.BigEndian.PutUint16(sctp[0:2], srcPort)
+	binary.BigEndian.PutUint16(sctp[2:4], dstPort)
+	for _, chunk := range chunks {
+		sctp = append(sctp, chunk...)
+	}
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(sctp)))
+	ip[9] = ipProtocolSctp
+	copy(ip[12:16], src[:])
+	copy(ip[16:20], dst[:])
+	return append(ip, sctp...)
+}
+
+func pcapFile(linkType uint32, frames ...[]byte) []byte {
+	file := make([]byte, 24)
+	binary.LittleEndian.PutUint32(file[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(file[4:6], 2)
+	binary.LittleEndian.PutUint16(file[6:8], 4)
+	binary.LittleEndian.PutUint32(file[16:20], 65535)
+	binary.LittleEndian.PutUint32(file[20:24], linkType)
+	for i, frame := range frames {
+		record := make([]byte, 16)
+		binary.LittleEndian.PutUint32(record[0:4], uint32(1600000000+i))
+		binary.LittleEndian.PutUint32(record[8:12], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(record[12:16], uint32(len(frame)))
+		file = append(append(file, record...), frame...)
+	}
+	return file
+}
+
+func TestReadPcap(t *testing.T) {
+	ran, ocf := [4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}
+	file := pcapFile(linkTypeRaw,
+		ipv4Frame(ran, ocf, 9487, 38412, dataChunk(0x03, 1, 0, []byte{0x00, 0x15})),
+		// a fragmented message and its retransmission
+		ipv4Frame(ocf, ran, 38412, 9487, dataChunk(0x02, 7, 1, []byte{0x20, 0x15, 0x00})),
+		ipv4Frame(ocf, ran, 38412, 9487, dataChunk(0x01, 8, 1, []byte{0x01})),
+		ipv4Frame(ocf, ran, 38412, 9487, dataChunk(0x01, 8, 1, []byte{0x01})),
+		// two messages in one packet
+		ipv4Frame(ran, ocf, 9487, 38412, dataChunk(0x03, 2, 1, []byte{0x00, 0x0f, 0x40}),
+			dataChunk(0x03, 3, 1, []byte{0x00, 0x2e})),
+		// not NGAP
+		ipv4Frame(ran, ocf, 9487, 36412, dataChunk(0x03, 4, 0, []byte{0x00})),
+	)
+
+	messages, err := ReadPcap(bytes.NewReader(file), DefaultOcfPort)
+	require.Nil(t, err)
+	require.Len(t, messages, 4)
+
+	assert.True(t, messages[0].Uplink)
+	assert.Equal(t, "10.0.0.1:9487", messages[0].Ran)
+	assert.Equal(t, "10.0.0.2:38412", messages[0].Ocf)
+	assert.Equal(t, uint16(0), messages[0].StreamId)
+	assert.Equal(t, []byte{0x00, 0x15}, messages[0].Payload)
+
+	assert.False(t, messages[1].Uplink)
+	assert.Equal(t, messages[0].Association(), messages[1].Association())
+	assert.Equal(t, uint16(1), messages[1].StreamId)
+	assert.Equal(t, []byte{0x20, 0x15, 0x00, 0x01}, messages[1].Payload)
+
+	assert.Equal(t, []byte{0x00, 0x0f, 0x40}, messages[2].Payload)
+	assert.Equal(t, []byte{0x00, 0x2e}, messages[3].Payload)
+	assert.Equal(t, int64(1600000004), messages[3].Time.Unix())
+}
+
+func TestReadPcapEthernet(t *testing.T) {
+	ip := ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, 9487, 38412,
+		dataChunk(0x03, 1, 0, []byte{0x00, 0x15}))
+	// destination and source MAC, 802.1Q tag of VLAN 100, IPv4
+	frame := append(make([]byte, 12), 0x81, 0x00, 0x00, 0x64, 0x08, 0x00)
+	frame = append(frame, ip...)
+
+	messages, err := ReadPcap(bytes.NewReader(pcapFile(linkTypeEthernet, frame)), DefaultOcfPort)
+	require.Nil(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, []byte{0x00, 0x15}, messages[0].Payload)
+
+	_, err = ReadPcap(bytes.NewReader([]byte("not a capture file at all")), DefaultOcfPort)
+	assert.NotNil(t, err)
+}
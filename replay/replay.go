@@ -0,0 +1,262 @@
+// Package replay feeds the uplink NGAP messages of an N2 capture into the OCF and compares the
+// downlink messages of the OCF with the captured ones, which turns field captures into
+// regression tests of the NGAP and GMM handlers without live gNBs.
+//
+// Every association of the capture gets a fake connection which records what the OCF sends.
+// The SBI requests of the OCF go to a local server which answers with the stubs and, for the
+// NF discovery, with profiles pointing back to itself. The OCF UE NGAP IDs of the capture are
+// mapped to the IDs the OCF allocates while replaying. Downlink messages triggered by SBI
+// requests toward the OCF (e.g. N1N2MessageTransfer of the SMF) are not reproduced and show as
+// missing, the SCTP stream of the messages is not compared.
+package replay
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"free5gc/lib/ngap"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+	ocf_ngap "free5gc/src/ocf/ngap"
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+const DefaultOcfPort uint16 = 38412
+
+type Options struct {
+	Pcap  string
+	Stubs string
+	// OcfPort tells the uplink from the downlink messages of the capture
+	OcfPort uint16
+	// IgnoreIEs are the IDs of the IEs left out of the comparison
+	IgnoreIEs []int64
+	// Settle is waited after every uplink message for the work the OCF does outside the
+	// NGAP workers
+	Settle time.Duration
+}
+
+// step keeps the downlink messages which follow one uplink message
+type step struct {
+	index    int
+	trigger  string
+	expected map[string][]Message
+	actual   map[string][]downlink
+}
+
+type replayer struct {
+	options Options
+	ignore  map[int64]bool
+	sbi     *sbiServer
+	report  *Report
+
+	conns map[string]*conn // association -> connection
+	// ocfUeNgapIds maps the OCF UE NGAP IDs of the capture to the IDs allocated by the OCF
+	ocfUeNgapIds map[int64]int64
+}
+
+// Run replays the capture against the OCF context, which has to be initialized with the
+// configuration the capture was taken with
+func Run(options Options) (*Report, error) {
+	if options.OcfPort == 0 {
+		options.OcfPort = DefaultOcfPort
+	}
+	file, err := os.Open(options.Pcap)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := ReadPcap(file, options.OcfPort)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %+v", options.Pcap, err)
+	}
+	stubs, err := LoadStubs(options.Stubs)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := newSbiServer(stubs)
+	if err != nil {
+		return nil, err
+	}
+	defer server.close()
+	self := context.OCF_Self()
+	nrfUri := self.NrfUri
+	self.NrfUri = server.url
+	defer func() {
+		self.NrfUri = nrfUri
+	}()
+
+	r := &replayer{
+		options:      options,
+		ignore:       make(map[int64]bool),
+		sbi:          server,
+		report:       &Report{},
+		conns:        make(map[string]*conn),
+		ocfUeNgapIds: make(map[int64]int64),
+	}
+	for _, id := range options.IgnoreIEs {
+		r.ignore[id] = true
+	}
+	logger.ReplayLog.Infof("Replay %d NGAP message(s) of %s", len(messages), options.Pcap)
+	r.run(messages)
+	return r.report, nil
+}
+
+func (r *replayer) run(messages []Message) {
+	current := newStep(0, "")
+	for i := range messages {
+		message := &messages[i]
+		if !message.Uplink {
+			current.expected[message.Association()] = append(current.expected[message.Association()], *message)
+			continue
+		}
+		r.finish(current)
+		current = r.dispatch(message)
+	}
+	r.finish(current)
+
+	// the associations end with the capture
+	for _, c := range r.conns {
+		ocf_ngap.Dispatch(c, ngap_service.NonUeAssociatedStreamId, nil)
+	}
+	ngap_service.WaitIdle()
+	r.report.SbiRequests = append(r.report.SbiRequests, r.sbi.takeRequests()...)
+}
+
+func newStep(index int, trigger string) *step {
+	return &step{
+		index:    index,
+		trigger:  trigger,
+		expected: make(map[string][]Message),
+		actual:   make(map[string][]downlink),
+	}
+}
+
+// dispatch hands an uplink message to the OCF and collects what the OCF sends in response
+func (r *replayer) dispatch(message *Message) *step {
+	r.report.Uplinks++
+	association := message.Association()
+	c, ok := r.conns[association]
+	if !ok {
+		c = newConn(message.Ran, message.Ocf)
+		r.conns[association] = c
+	}
+
+	payload, trigger := message.Payload, "undecodable"
+	if pdu, err := ngap.Decoder(payload); err == nil {
+		trigger, _ = messageValue(pdu)
+		if r.mapOcfUeNgapId(pdu) {
+			if encoded, err := ngap.Encoder(*pdu); err == nil {
+				payload = encoded
+			} else {
+				logger.ReplayLog.Errorf("Step %d: encode %s error: %+v", r.report.Uplinks, trigger, err)
+			}
+		}
+	}
+
+	logger.ReplayLog.Debugf("Step %d: %s from %s", r.report.Uplinks, trigger, message.Ran)
+	ocf_ngap.Dispatch(c, message.StreamId, payload)
+	ngap_service.WaitIdle()
+	if r.options.Settle > 0 {
+		time.Sleep(r.options.Settle)
+		ngap_service.WaitIdle()
+	}
+	r.report.SbiRequests = append(r.report.SbiRequests, r.sbi.takeRequests()...)
+
+	current := newStep(r.report.Uplinks, trigger)
+	for key, c := range r.conns {
+		if sent := c.take(); len(sent) > 0 {
+			current.actual[key] = sent
+		}
+	}
+	return current
+}
+
+// mapOcfUeNgapId replaces the OCF UE NGAP ID of the capture by the ID the OCF allocated
+func (r *replayer) mapOcfUeNgapId(pdu *ngapType.NGAPPDU) bool {
+	ocfUeNgapId, _ := ngap_service.UeNgapIds(pdu)
+	if ocfUeNgapId == nil {
+		return false
+	}
+	id, ok := r.ocfUeNgapIds[*ocfUeNgapId]
+	if !ok || id == *ocfUeNgapId {
+		return false
+	}
+	*ocfUeNgapId = id
+	return true
+}
+
+// finish pairs the captured and the sent downlink messages of each association in order
+func (r *replayer) finish(current *step) {
+	keys := make(map[string]bool)
+	for key := range current.expected {
+		keys[key] = true
+	}
+	for key := range current.actual {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		expected, actual := current.expected[key], current.actual[key]
+		for i := 0; i < len(expected) || i < len(actual); i++ {
+			entry := DiffEntry{
+				Step:        current.index,
+				Trigger:     current.trigger,
+				Association: key,
+			}
+			switch {
+			case i >= len(actual):
+				entry.Expected, entry.Result = messageName(expected[i].Payload), ResultMissing
+			case i >= len(expected):
+				entry.Actual, entry.Result = messageName(actual[i].payload), ResultUnexpected
+			default:
+				r.pair(&entry, expected[i].Payload, actual[i].payload)
+			}
+			if entry.Result != ResultMatch {
+				logger.ReplayLog.Warnf("Step %d (%s) %s: expected %s, actual %s: %s %s", entry.Step,
+					entry.Trigger, entry.Association, orDash(entry.Expected), orDash(entry.Actual),
+					entry.Result, entry.Detail)
+			}
+			r.report.Entries = append(r.report.Entries, entry)
+		}
+	}
+}
+
+func (r *replayer) pair(entry *DiffEntry, expectedPayload, actualPayload []byte) {
+	expectedPdu, expectedErr := ngap.Decoder(expectedPayload)
+	actualPdu, actualErr := ngap.Decoder(actualPayload)
+	if expectedErr != nil || actualErr != nil {
+		entry.Expected, entry.Actual = messageName(expectedPayload), messageName(actualPayload)
+		entry.Result = ResultDiffers
+		entry.Detail = "undecodable message"
+		return
+	}
+
+	var expectedMessage, actualMessage reflect.Value
+	entry.Expected, expectedMessage = messageValue(expectedPdu)
+	entry.Actual, actualMessage = messageValue(actualPdu)
+	if expectedPdu.Present != actualPdu.Present || entry.Expected != entry.Actual {
+		entry.Result = ResultTypeMismatch
+		return
+	}
+
+	// the first message of a UE tells the ID the OCF allocated in place of the captured one
+	expectedId, _ := ngap_service.UeNgapIds(expectedPdu)
+	actualId, _ := ngap_service.UeNgapIds(actualPdu)
+	if expectedId != nil && actualId != nil {
+		if _, ok := r.ocfUeNgapIds[*expectedId]; !ok {
+			r.ocfUeNgapIds[*expectedId] = *actualId
+		}
+		*expectedId = r.ocfUeNgapIds[*expectedId]
+	}
+	entry.Result, entry.Detail = compare(expectedMessage, actualMessage, r.ignore)
+}
@@ -0,0 +1,178 @@
+package replay
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"free5gc/lib/ngap"
+	"free5gc/lib/ngap/ngapType"
+)
+
+type Result string
+
+const (
+	ResultMatch        Result = "match"
+	ResultDiffers      Result = "differs"
+	ResultTypeMismatch Result = "type mismatch"
+	// the OCF did not send a captured downlink message
+	ResultMissing Result = "missing"
+	// the OCF sent a message which is not in the capture
+	ResultUnexpected Result = "unexpected"
+)
+
+// DiffEntry compares one captured downlink message with the message the OCF sent in its place.
+// Step is the number of the uplink message the downlink messages follow, 0 for the downlink
+// messages captured before the first uplink message.
+type DiffEntry struct {
+	Step        int
+	Trigger     string
+	Association string
+	Expected    string
+	Actual      string
+	Result      Result
+	Detail      string
+}
+
+type Report struct {
+	Uplinks     int
+	Entries     []DiffEntry
+	SbiRequests []SbiRequest
+}
+
+// Mismatches is the number of downlink messages which do not match the capture
+func (r *Report) Mismatches() int {
+	mismatches := 0
+	for i := range r.Entries {
+		if r.Entries[i].Result != ResultMatch {
+			mismatches++
+		}
+	}
+	return mismatches
+}
+
+// Unstubbed is the number of SBI requests which have not been answered by a stub
+func (r *Report) Unstubbed() int {
+	unstubbed := 0
+	for i := range r.SbiRequests {
+		if !r.SbiRequests[i].Stubbed {
+			unstubbed++
+		}
+	}
+	return unstubbed
+}
+
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tTRIGGER\tASSOCIATION\tEXPECTED\tACTUAL\tRESULT\tDETAIL")
+	for _, entry := range r.Entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Step, entry.Trigger, entry.Association,
+			orDash(entry.Expected), orDash(entry.Actual), entry.Result, entry.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d uplink message(s), %d downlink message(s) compared, %d mismatch(es), "+
+		"%d SBI request(s) of which %d not stubbed\n", r.Uplinks, len(r.Entries), r.Mismatches(),
+		len(r.SbiRequests), r.Unstubbed())
+	return err
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// messageName is the message name of an encoded PDU
+func messageName(payload []byte) string {
+	pdu, err := ngap.Decoder(payload)
+	if err != nil {
+		return "undecodable"
+	}
+	name, _ := messageValue(pdu)
+	return name
+}
+
+// messageValue returns the name and the value of the message carried by the PDU
+func messageValue(pdu *ngapType.NGAPPDU) (string, reflect.Value) {
+	var value reflect.Value
+	switch {
+	case pdu.Present == ngapType.NGAPPDUPresentInitiatingMessage && pdu.InitiatingMessage != nil:
+		value = reflect.ValueOf(pdu.InitiatingMessage.Value)
+	case pdu.Present == ngapType.NGAPPDUPresentSuccessfulOutcome && pdu.SuccessfulOutcome != nil:
+		value = reflect.ValueOf(pdu.SuccessfulOutcome.Value)
+	case pdu.Present == ngapType.NGAPPDUPresentUnsuccessfulOutcome && pdu.UnsuccessfulOutcome != nil:
+		value = reflect.ValueOf(pdu.UnsuccessfulOutcome.Value)
+	default:
+		return "unknown", reflect.Value{}
+	}
+	for i := 0; i < value.NumField(); i++ {
+		message := value.Field(i)
+		if message.Kind() == reflect.Ptr && !message.IsNil() {
+			return value.Type().Field(i).Name, message.Elem()
+		}
+	}
+	return "unknown", reflect.Value{}
+}
+
+// protocolIEs returns the values of the top level IEs by IE ID
+func protocolIEs(message reflect.Value) map[int64][]interface{} {
+	ies := make(map[int64][]interface{})
+	if !message.IsValid() {
+		return ies
+	}
+	protocolIEs := message.FieldByName("ProtocolIEs")
+	if !protocolIEs.IsValid() {
+		return ies
+	}
+	list := protocolIEs.FieldByName("List")
+	for i := 0; i < list.Len(); i++ {
+		ie := list.Index(i)
+		id := ie.FieldByName("Id").FieldByName("Value").Int()
+		ies[id] = append(ies[id], ie.FieldByName("Value").Interface())
+	}
+	return ies
+}
+
+// compare compares the top level IEs of two messages of the same type, the IEs of ignore are
+// skipped. The detail lists the IDs of the differing, missing (-) and unexpected (+) IEs.
+func compare(expected, actual reflect.Value, ignore map[int64]bool) (Result, string) {
+	expectedIEs, actualIEs := protocolIEs(expected), protocolIEs(actual)
+	ids := make(map[int64]bool)
+	for id := range expectedIEs {
+		ids[id] = true
+	}
+	for id := range actualIEs {
+		ids[id] = true
+	}
+	sorted := make([]int64, 0, len(ids))
+	for id := range ids {
+		if !ignore[id] {
+			sorted = append(sorted, id)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var differences []string
+	for _, id := range sorted {
+		expectedValues, expectedOk := expectedIEs[id]
+		actualValues, actualOk := actualIEs[id]
+		switch {
+		case !actualOk:
+			differences = append(differences, fmt.Sprintf("-%d", id))
+		case !expectedOk:
+			differences = append(differences, fmt.Sprintf("+%d", id))
+		case !reflect.DeepEqual(expectedValues, actualValues):
+			differences = append(differences, fmt.Sprint(id))
+		}
+	}
+	if len(differences) == 0 {
+		return ResultMatch, ""
+	}
+	return ResultDiffers, "IE " + strings.Join(differences, ",")
+}
@@ -0,0 +1,218 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"gopkg.in/yaml.v2"
+
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/logger"
+)
+
+// Stubs are the responses to the SBI requests of the OCF, recorded from a live run or written
+// by hand
+type Stubs struct {
+	Exchanges []Exchange `yaml:"exchanges"`
+}
+
+// Exchange answers the requests whose method and URI (path and query) match. The exchanges are
+// used once each in file order; when every matching exchange is used the last one is repeated.
+type Exchange struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"` // regular expression
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+
+	pattern *regexp.Regexp
+	used    bool
+}
+
+// SbiRequest is a request of the OCF as shown in the report
+type SbiRequest struct {
+	Method string
+	Uri    string
+	Status int
+	// Stubbed is false for the requests answered by the built-in NRF discovery or by 404
+	Stubbed bool
+}
+
+func LoadStubs(file string) (*Stubs, error) {
+	stubs := &Stubs{}
+	if file == "" {
+		return stubs, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, stubs); err != nil {
+		return nil, fmt.Errorf("stubs %s: %+v", file, err)
+	}
+	for i := range stubs.Exchanges {
+		exchange := &stubs.Exchanges[i]
+		if exchange.pattern, err = regexp.Compile(exchange.Path); err != nil {
+			return nil, fmt.Errorf("stubs %s exchange %d: %+v", file, i, err)
+		}
+		if exchange.Status == 0 {
+			exchange.Status = http.StatusOK
+		}
+	}
+	return stubs, nil
+}
+
+// discoveredServices are the services listed in the NF profiles of the built-in NRF discovery
+var discoveredServices = map[models.NfType][]models.ServiceName{
+	models.NfType_AUSF: {models.ServiceName_NAUSF_AUTH},
+	models.NfType_UDM:  {models.ServiceName_NUDM_SDM, models.ServiceName_NUDM_UECM},
+	models.NfType_PCF:  {models.ServiceName_NPCF_AM_POLICY_CONTROL},
+	models.NfType_SMF:  {models.ServiceName_NSMF_PDUSESSION},
+	models.NfType_NSSF: {models.ServiceName_NNSSF_NSSELECTION},
+	models.NfType_OCF:  {models.ServiceName_NOCF_COMM},
+}
+
+// sbiServer is the NRF and every other NF the OCF talks to. The OCF clients use HTTP/2 over
+// cleartext with prior knowledge, hence the h2c handler.
+type sbiServer struct {
+	url      string
+	listener net.Listener
+	server   *http.Server
+
+	mutex    sync.Mutex
+	stubs    *Stubs
+	requests []SbiRequest
+}
+
+func newSbiServer(stubs *Stubs) (*sbiServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &sbiServer{
+		url:      "http://" + listener.Addr().String(),
+		listener: listener,
+		stubs:    stubs,
+	}
+	s.server = &http.Server{Handler: h2c.NewHandler(http.HandlerFunc(s.serveHTTP), &http2.Server{})}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.ReplayLog.Errorf("SBI stub server error: %+v", err)
+		}
+	}()
+	return s, nil
+}
+
+func (s *sbiServer) close() {
+	if err := s.server.Close(); err != nil {
+		logger.ReplayLog.Warnf("SBI stub server close error: %+v", err)
+	}
+}
+
+func (s *sbiServer) takeRequests() []SbiRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func (s *sbiServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	uri := r.URL.RequestURI()
+	request := SbiRequest{Method: r.Method, Uri: uri}
+
+	if exchange := s.match(r.Method, uri); exchange != nil {
+		for name, value := range exchange.Headers {
+			w.Header().Set(name, value)
+		}
+		if exchange.Body != "" && w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(exchange.Status)
+		if _, err := w.Write([]byte(exchange.Body)); err != nil {
+			logger.ReplayLog.Warnf("SBI stub write error: %+v", err)
+		}
+		request.Status, request.Stubbed = exchange.Status, true
+	} else if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/nnrf-disc/v1/nf-instances") {
+		request.Status = s.discover(w, models.NfType(r.URL.Query().Get("target-nf-type")))
+	} else {
+		logger.ReplayLog.Warnf("No stub for SBI request %s %s", r.Method, uri)
+		request.Status = http.StatusNotFound
+		writeJson(w, request.Status, "application/problem+json", models.ProblemDetails{
+			Title:  "Not Found",
+			Status: http.StatusNotFound,
+			Detail: fmt.Sprintf("no stub for %s %s", r.Method, uri),
+		})
+	}
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, request)
+	s.mutex.Unlock()
+}
+
+func (s *sbiServer) match(method, uri string) *Exchange {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var last *Exchange
+	for i := range s.stubs.Exchanges {
+		exchange := &s.stubs.Exchanges[i]
+		if !strings.EqualFold(exchange.Method, method) || !exchange.pattern.MatchString(uri) {
+			continue
+		}
+		if !exchange.used {
+			exchange.used = true
+			return exchange
+		}
+		last = exchange
+	}
+	return last
+}
+
+// discover answers an NF discovery with one NF profile whose services all point to the server
+func (s *sbiServer) discover(w http.ResponseWriter, nfType models.NfType) int {
+	var services []models.NfService
+	for i, serviceName := range discoveredServices[nfType] {
+		services = append(services, models.NfService{
+			ServiceInstanceId: fmt.Sprint(i),
+			ServiceName:       serviceName,
+			Scheme:            models.UriScheme_HTTP,
+			NfServiceStatus:   models.NfServiceStatus_REGISTERED,
+			ApiPrefix:         s.url,
+		})
+	}
+	result := models.SearchResult{
+		ValidityPeriod: 100,
+		NfInstances: []models.NfProfile{
+			{
+				NfInstanceId: "replay-" + strings.ToLower(string(nfType)),
+				NfType:       nfType,
+				NfStatus:     models.NfStatus_REGISTERED,
+				NfServices:   &services,
+			},
+		},
+	}
+	writeJson(w, http.StatusOK, "application/json", result)
+	return http.StatusOK
+}
+
+func writeJson(w http.ResponseWriter, status int, contentType string, body interface{}) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		logger.ReplayLog.Errorf("SBI stub marshal error: %+v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(content); err != nil {
+		logger.ReplayLog.Warnf("SBI stub write error: %+v", err)
+	}
+}
@@ -28,9 +28,12 @@ import (
 	"free5gc/src/ocf/logger"
 	"free5gc/src/ocf/mt"
 	"free5gc/src/ocf/ngap"
+	"free5gc/src/ocf/ngap/capture"
 	ngap_message "free5gc/src/ocf/ngap/message"
+	"free5gc/src/ocf/ngap/overload"
 	ngap_service "free5gc/src/ocf/ngap/service"
 	"free5gc/src/ocf/oam"
+	"free5gc/src/ocf/producer"
 	"free5gc/src/ocf/producer/callback"
 	"free5gc/src/ocf/util"
 )
@@ -67,18 +70,21 @@ func (*OCF) GetCliCmd() (flags []cli.Flag) {
 	return amfCLi
 }
 
-func (*OCF) Initialize(c *cli.Context) {
+// ConfigFile returns the ocf config file of -amfcfg or the default one
+func (*OCF) ConfigFile(c *cli.Context) string {
+	if amfcfg := c.String("amfcfg"); amfcfg != "" {
+		return amfcfg
+	}
+	return path_util.Gofree5gcPath("free5gc/config/amfcfg.conf")
+}
+
+func (ocf *OCF) Initialize(c *cli.Context) {
 
 	config = Config{
 		amfcfg: c.String("amfcfg"),
 	}
 
-	if config.amfcfg != "" {
-		factory.InitConfigFactory(config.amfcfg)
-	} else {
-		DefaultOcfConfigPath := path_util.Gofree5gcPath("free5gc/config/amfcfg.conf")
-		factory.InitConfigFactory(DefaultOcfConfigPath)
-	}
+	factory.InitConfigFactory(ocf.ConfigFile(c))
 
 	if app.ContextSelf().Logger.OCF.DebugLevel != "" {
 		level, err := logrus.ParseLevel(app.ContextSelf().Logger.OCF.DebugLevel)
@@ -145,7 +151,17 @@ func (ocf *OCF) Start() {
 
 	addr := fmt.Sprintf("%s:%d", self.BindingIPv4, self.SBIPort)
 
-	ngap_service.Run(self.NgapIpList, 38412, ngap.Dispatch)
+	parameters := self.Parameters()
+	if err := capture.Start(parameters.NgapCapture); err != nil {
+		initLog.Errorf("Start NGAP capture failed: %+v", err)
+	}
+	overload.Start(parameters.OverloadControl)
+
+	ngapHandler := ngap_service.Handler{
+		HandleMessage:      ngap.Dispatch,
+		HandleNotification: ngap.HandleSCTPNotification,
+	}
+	ngap_service.Run(ngap_service.NewSctpTransport(self.SctpParameters), self.NgapIpList, 38412, ngapHandler)
 
 	// Register to NRF
 	var profile models.NfProfile
@@ -169,6 +185,16 @@ func (ocf *OCF) Start() {
 		os.Exit(0)
 	}()
 
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+	go func() {
+		for range reloadChannel {
+			initLog.Infof("SIGHUP received, reload configuration %s", factory.ConfigFile())
+			// the procedure logs the result
+			_, _ = producer.OAMReloadConfigurationProcedure(nil)
+		}
+	}()
+
 	server, err := http2_util.NewServer(addr, util.OcfLogPath, router)
 
 	if server == nil {
@@ -243,6 +269,7 @@ func (ocf *OCF) Exec(c *cli.Context) error {
 func (ocf *OCF) Terminate() {
 	logger.InitLog.Infof("Terminating OCF...")
 	amfSelf := context.OCF_Self()
+	parameters := amfSelf.Parameters()
 
 	// TODO: forward registered UE contexts to target OCF in the same OCF set if there is one
 
@@ -258,15 +285,19 @@ func (ocf *OCF) Terminate() {
 
 	// send OCF status indication to ran to notify ran that this OCF will be unavailable
 	logger.InitLog.Infof("Send OCF Status Indication to Notify RANs due to OCF terminating")
-	unavailableGuamiList := ngap_message.BuildUnavailableGUAMIList(amfSelf.ServedGuamiList)
+	unavailableGuamiList := ngap_message.BuildUnavailableGUAMIList(parameters.ServedGuamiList)
 	amfSelf.OcfRanPool.Range(func(key, value interface{}) bool {
 		ran := value.(*context.OcfRan)
 		ngap_message.SendOCFStatusIndication(ran, unavailableGuamiList)
 		return true
 	})
 
+	overload.Stop()
 	ngap_service.Stop()
+	if err := capture.Stop(); err != nil {
+		logger.InitLog.Errorf("Stop NGAP capture error: %+v", err)
+	}
 
-	callback.SendOcfStatusChangeNotify((string)(models.StatusChange_UNAVAILABLE), amfSelf.ServedGuamiList)
+	callback.SendOcfStatusChangeNotify((string)(models.StatusChange_UNAVAILABLE), parameters.ServedGuamiList)
 	logger.InitLog.Infof("OCF terminated")
 }
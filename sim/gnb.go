@@ -0,0 +1,276 @@
+package sim
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"free5gc/lib/aper"
+	"free5gc/lib/ngap"
+	"free5gc/lib/ngap/ngapConvert"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+// the gNB sends UE-associated signalling on this stream, the OCF accepts it on any stream
+// other than the non-UE-associated one
+const ueAssociatedStreamId uint16 = 1
+
+const (
+	defaultN3Address = "127.0.0.1"
+	readBufSize      = 8192
+)
+
+type gnb struct {
+	simulator *Simulator
+	config    *GnbConfig
+	conn      ngap_service.Conn
+
+	globalRanNodeId ngapType.GlobalRANNodeID
+	plmnIdentity    ngapType.PLMNIdentity
+	tac             aper.OctetString
+	nrCellIdentity  aper.BitString
+
+	mutex           sync.Mutex
+	ues             map[int64]*ue // RAN UE NGAP ID -> UE
+	nextRanUeNgapId int64
+
+	// nonUe receives the non-UE-associated messages, i.e. the NG Setup outcome
+	nonUe chan *ngapType.NGAPPDU
+}
+
+func newGnb(simulator *Simulator, config *GnbConfig) *gnb {
+	g := &gnb{
+		simulator:    simulator,
+		config:       config,
+		plmnIdentity: ngapConvert.PlmnIdToNgap(config.PlmnId),
+		tac:          ngapConvert.TacToNgap(config.Tac),
+		ues:          make(map[int64]*ue),
+		nonUe:        make(chan *ngapType.NGAPPDU, 4),
+	}
+	gnbIdLen := len(config.GnbId) * 4
+	g.globalRanNodeId = ngapType.GlobalRANNodeID{
+		Present: ngapType.GlobalRANNodeIDPresentGlobalGNBID,
+		GlobalGNBID: &ngapType.GlobalGNBID{
+			PLMNIdentity: g.plmnIdentity,
+			GNBID: ngapType.GNBID{
+				Present: ngapType.GNBIDPresentGNBID,
+			},
+		},
+	}
+	gnbId := ngapConvert.HexToBitString(config.GnbId, gnbIdLen)
+	g.globalRanNodeId.GlobalGNBID.GNBID.GNBID = &gnbId
+
+	// NR Cell Identity is the gNB ID followed by the cell 1 of the gNB (TS 38.413 9.3.1.7)
+	cellId := make([]byte, 5)
+	copy(cellId, gnbId.Bytes)
+	cellIdBits := 36 - gnbIdLen
+	cellId[(gnbIdLen+cellIdBits-1)/8] |= 0x01 << uint(7-(gnbIdLen+cellIdBits-1)%8)
+	g.nrCellIdentity = aper.BitString{Bytes: cellId, BitLength: 36}
+	return g
+}
+
+func (g *gnb) connect() error {
+	ocf := g.simulator.scenario.Ocf
+	var conn ngap_service.Conn
+	var err error
+	if g.simulator.dial != nil {
+		conn, err = g.simulator.dial()
+	} else if ocf.Transport == TransportTcp {
+		var tcpConn net.Conn
+		tcpConn, err = net.Dial("tcp", net.JoinHostPort(ocf.Addresses[0], fmt.Sprint(ocf.Port)))
+		if err == nil {
+			conn = ngap_service.NewFramedConn(tcpConn)
+		}
+	} else {
+		conn, err = ngap_service.DialSctp(ocf.Addresses, ocf.Port, context.SctpParameters{
+			NumOstreams:    context.DefaultSctpNumOstreams,
+			MaxInstreams:   context.DefaultSctpMaxInstreams,
+			MaxAttempts:    context.DefaultSctpMaxAttempts,
+			MaxInitTimeout: context.DefaultSctpMaxInitTimeout,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("gNB[%s] connect: %+v", g.config.Name, err)
+	}
+	g.conn = conn
+	go g.readLoop()
+	return nil
+}
+
+func (g *gnb) close() {
+	if g.conn == nil {
+		return
+	}
+	if err := g.conn.Close(); err != nil {
+		logger.SimLog.Warnf("gNB[%s] close error: %+v", g.config.Name, err)
+	}
+}
+
+func (g *gnb) ngSetup() error {
+	pkt, err := buildNGSetupRequest(g)
+	if err != nil {
+		return err
+	}
+	if err := g.send(pkt); err != nil {
+		return err
+	}
+
+	select {
+	case pdu := <-g.nonUe:
+		switch {
+		case pdu.SuccessfulOutcome != nil && pdu.SuccessfulOutcome.Value.NGSetupResponse != nil:
+			return nil
+		case pdu.UnsuccessfulOutcome != nil && pdu.UnsuccessfulOutcome.Value.NGSetupFailure != nil:
+			return fmt.Errorf("gNB[%s] received NG Setup Failure", g.config.Name)
+		default:
+			return fmt.Errorf("gNB[%s] received unexpected NGAP message while waiting for NG Setup Response",
+				g.config.Name)
+		}
+	case <-time.After(g.simulator.scenario.Timeout):
+		return fmt.Errorf("gNB[%s] NG Setup timeout", g.config.Name)
+	}
+}
+
+func (g *gnb) readLoop() {
+	buf := make([]byte, readBufSize)
+	for {
+		n, info, _, err := g.conn.ReadMsg(buf)
+		if err != nil {
+			logger.SimLog.Debugf("gNB[%s] read loop stopped: %+v", g.config.Name, err)
+			return
+		}
+		if info == nil {
+			continue
+		}
+		pdu, err := ngap.Decoder(buf[:n])
+		if err != nil {
+			logger.SimLog.Errorf("gNB[%s] NGAP decode error: %+v", g.config.Name, err)
+			continue
+		}
+		g.route(pdu)
+	}
+}
+
+// route hands a downlink message to its UE, by RAN UE NGAP ID or for a Handover Request by
+// the source to target transparent container
+func (g *gnb) route(pdu *ngapType.NGAPPDU) {
+	if pdu.InitiatingMessage != nil && pdu.InitiatingMessage.Value.HandoverRequest != nil {
+		for _, ie := range pdu.InitiatingMessage.Value.HandoverRequest.ProtocolIEs.List {
+			if ie.Id.Value != ngapType.ProtocolIEIDSourceToTargetTransparentContainer {
+				continue
+			}
+			supi := strings.TrimPrefix(string(ie.Value.SourceToTargetTransparentContainer.Value),
+				transparentContainerPrefix)
+			if u, ok := g.simulator.ues[supi]; ok {
+				g.deliver(u, pdu)
+				return
+			}
+		}
+		logger.SimLog.Warnf("gNB[%s] Handover Request for an unknown UE", g.config.Name)
+		return
+	}
+
+	_, ranUeNgapId := ngap_service.UeNgapIds(pdu)
+	if ranUeNgapId == nil {
+		select {
+		case g.nonUe <- pdu:
+		default:
+			logger.SimLog.Warnf("gNB[%s] drops a non-UE-associated message", g.config.Name)
+		}
+		return
+	}
+
+	g.mutex.Lock()
+	u, ok := g.ues[*ranUeNgapId]
+	g.mutex.Unlock()
+	if !ok {
+		logger.SimLog.Warnf("gNB[%s] message for an unknown RAN UE NGAP ID %d", g.config.Name, *ranUeNgapId)
+		return
+	}
+	g.deliver(u, pdu)
+}
+
+// deliver does not block the read loop on a UE which does not drain its messages, e.g. after
+// a failed procedure, the other UEs of the gNB would stop receiving theirs
+func (g *gnb) deliver(u *ue, pdu *ngapType.NGAPPDU) {
+	select {
+	case u.downlink <- downlinkPdu{gnb: g, pdu: pdu}:
+	default:
+		logger.SimLog.Warnf("gNB[%s] drops a message of UE[%s]", g.config.Name, u.supi)
+	}
+}
+
+// attach allocates a RAN UE NGAP ID of the gNB to u
+func (g *gnb) attach(u *ue) int64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.nextRanUeNgapId++
+	g.ues[g.nextRanUeNgapId] = u
+	return g.nextRanUeNgapId
+}
+
+func (g *gnb) detach(ranUeNgapId int64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.ues, ranUeNgapId)
+}
+
+func (g *gnb) served(ranUeNgapId int64) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	_, ok := g.ues[ranUeNgapId]
+	return ok
+}
+
+func (g *gnb) send(pkt []byte) error {
+	_, err := g.conn.WriteMsg(pkt, &ngap_service.MsgInfo{
+		Stream: ngap_service.NonUeAssociatedStreamId,
+		PPID:   ngap_service.NGAP_PPID,
+	})
+	return err
+}
+
+func (g *gnb) sendUe(pkt []byte) error {
+	_, err := g.conn.WriteMsg(pkt, &ngap_service.MsgInfo{
+		Stream: ueAssociatedStreamId,
+		PPID:   ngap_service.NGAP_PPID,
+	})
+	return err
+}
+
+func (g *gnb) userLocationInformation() *ngapType.UserLocationInformation {
+	return &ngapType.UserLocationInformation{
+		Present: ngapType.UserLocationInformationPresentUserLocationInformationNR,
+		UserLocationInformationNR: &ngapType.UserLocationInformationNR{
+			NRCGI: ngapType.NRCGI{
+				PLMNIdentity:   g.plmnIdentity,
+				NRCellIdentity: ngapType.NRCellIdentity{Value: g.nrCellIdentity},
+			},
+			TAI: ngapType.TAI{
+				PLMNIdentity: g.plmnIdentity,
+				TAC:          ngapType.TAC{Value: g.tac},
+			},
+		},
+	}
+}
+
+// upTransportLayerInformation is the N3 tunnel of a PDU session, the TEID is unique per UE
+// and session
+func (g *gnb) upTransportLayerInformation(u *ue, session *pduSession) ngapType.UPTransportLayerInformation {
+	n3Address := g.config.N3Address
+	if n3Address == "" {
+		n3Address = defaultN3Address
+	}
+	return ngapType.UPTransportLayerInformation{
+		Present: ngapType.UPTransportLayerInformationPresentGTPTunnel,
+		GTPTunnel: &ngapType.GTPTunnel{
+			TransportLayerAddress: ngapConvert.IPAddressToNgap(n3Address, ""),
+			GTPTEID:               ngapType.GTPTEID{Value: gtpTeid(uint32(u.index)<<4 | uint32(session.id))},
+		},
+	}
+}
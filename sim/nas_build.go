@@ -0,0 +1,281 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"free5gc/lib/nas"
+	"free5gc/lib/nas/nasConvert"
+	"free5gc/lib/nas/nasMessage"
+	"free5gc/lib/nas/nasType"
+	"free5gc/lib/openapi/models"
+)
+
+// UE security capability of the simulated UEs: 5G-EA0, 128-5G-EA2 and 128-5G-IA2
+var ueSecurityCapability = []uint8{0xa0, 0x20}
+
+func buildRegistrationRequest(u *ue, registrationType uint8) *nas.Message {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeRegistrationRequest)
+
+	registrationRequest := nasMessage.NewRegistrationRequest(0)
+	registrationRequest.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+	registrationRequest.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
+	registrationRequest.SpareHalfOctetAndSecurityHeaderType.SetSpareHalfOctet(0)
+	registrationRequest.RegistrationRequestMessageIdentity.SetMessageType(nas.MsgTypeRegistrationRequest)
+	registrationRequest.NgksiAndRegistrationType5GS.SetTSC(nasMessage.TypeOfSecurityContextFlagNative)
+	registrationRequest.NgksiAndRegistrationType5GS.SetNasKeySetIdentifiler(7) // no key is available
+	registrationRequest.NgksiAndRegistrationType5GS.SetFOR(1)
+	registrationRequest.NgksiAndRegistrationType5GS.SetRegistrationType5GS(registrationType)
+
+	suci := u.suci()
+	registrationRequest.MobileIdentity5GS = nasType.MobileIdentity5GS{
+		Len:    uint16(len(suci)),
+		Buffer: suci,
+	}
+
+	registrationRequest.UESecurityCapability =
+		nasType.NewUESecurityCapability(nasMessage.RegistrationRequestUESecurityCapabilityType)
+	registrationRequest.UESecurityCapability.SetLen(uint8(len(ueSecurityCapability)))
+	registrationRequest.UESecurityCapability.Buffer = ueSecurityCapability
+
+	requestedNssai := nasConvert.SnssaiToNas(u.config.SNssai)
+	registrationRequest.RequestedNSSAI = nasType.NewRequestedNSSAI(nasMessage.RegistrationRequestRequestedNSSAIType)
+	registrationRequest.RequestedNSSAI.SetLen(uint8(len(requestedNssai)))
+	registrationRequest.RequestedNSSAI.Buffer = requestedNssai
+
+	m.GmmMessage.RegistrationRequest = registrationRequest
+	return m
+}
+
+func buildAuthenticationResponse(resStar []byte) *nas.Message {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeAuthenticationResponse)
+
+	authenticationResponse := nasMessage.NewAuthenticationResponse(0)
+	authenticationResponse.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+	authenticationResponse.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
+	authenticationResponse.SpareHalfOctetAndSecurityHeaderType.SetSpareHalfOctet(0)
+	authenticationResponse.AuthenticationResponseMessageIdentity.SetMessageType(nas.MsgTypeAuthenticationResponse)
+
+	var res [16]uint8
+	copy(res[:], resStar)
+	authenticationResponse.AuthenticationResponseParameter =
+		nasType.NewAuthenticationResponseParameter(nasMessage.AuthenticationResponseAuthenticationResponseParameterType)
+	authenticationResponse.AuthenticationResponseParameter.SetLen(uint8(len(res)))
+	authenticationResponse.AuthenticationResponseParameter.SetRES(res)
+
+	m.GmmMessage.AuthenticationResponse = authenticationResponse
+	return m
+}
+
+func buildSecurityModeComplete(u *ue, nasMessageContainer []byte) *nas.Message {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeSecurityModeComplete)
+	m.SecurityHeader = nas.SecurityHeader{
+		ProtocolDiscriminator: nasMessage.Epd5GSMobilityManagementMessage,
+		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtectedAndCipheredWithNew5gNasSecurityContext,
+	}
+
+	securityModeComplete := nasMessage.NewSecurityModeComplete(0)
+	securityModeComplete.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+	securityModeComplete.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
+	securityModeComplete.SpareHalfOctetAndSecurityHeaderType.SetSpareHalfOctet(0)
+	securityModeComplete.SecurityModeCompleteMessageIdentity.SetMessageType(nas.MsgTypeSecurityModeComplete)
+
+	securityModeComplete.IMEISV = nasType.NewIMEISV(nasMessage.SecurityModeCompleteIMEISVType)
+	securityModeComplete.IMEISV.SetLen(9)
+	securityModeComplete.IMEISV.Octet = u.imeisv()
+
+	if nasMessageContainer != nil {
+		securityModeComplete.NASMessageContainer =
+			nasType.NewNASMessageContainer(nasMessage.SecurityModeCompleteNASMessageContainerType)
+		securityModeComplete.NASMessageContainer.SetLen(uint16(len(nasMessageContainer)))
+		securityModeComplete.NASMessageContainer.SetNASMessageContainerContents(nasMessageContainer)
+	}
+
+	m.GmmMessage.SecurityModeComplete = securityModeComplete
+	return m
+}
+
+func buildRegistrationComplete() *nas.Message {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeRegistrationComplete)
+	m.SecurityHeader = nas.SecurityHeader{
+		ProtocolDiscriminator: nasMessage.Epd5GSMobilityManagementMessage,
+		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtectedAndCiphered,
+	}
+
+	registrationComplete := nasMessage.NewRegistrationComplete(0)
+	registrationComplete.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+	registrationComplete.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
+	registrationComplete.SpareHalfOctetAndSecurityHeaderType.SetSpareHalfOctet(0)
+	registrationComplete.RegistrationCompleteMessageIdentity.SetMessageType(nas.MsgTypeRegistrationComplete)
+
+	m.GmmMessage.RegistrationComplete = registrationComplete
+	return m
+}
+
+func buildPduSessionEstablishmentRequest(pduSessionId uint8) ([]byte, error) {
+	m := nas.NewMessage()
+	m.GsmMessage = nas.NewGsmMessage()
+	m.GsmHeader.SetMessageType(nas.MsgTypePDUSessionEstablishmentRequest)
+
+	pduSessionEstablishmentRequest := nasMessage.NewPDUSessionEstablishmentRequest(0)
+	pduSessionEstablishmentRequest.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSSessionManagementMessage)
+	pduSessionEstablishmentRequest.SetMessageType(nas.MsgTypePDUSessionEstablishmentRequest)
+	pduSessionEstablishmentRequest.PDUSessionID.SetPDUSessionID(pduSessionId)
+	pduSessionEstablishmentRequest.PTI.SetPTI(0x00)
+	pduSessionEstablishmentRequest.IntegrityProtectionMaximumDataRate.
+		SetMaximumDataRatePerUEForUserPlaneIntegrityProtectionForDownLink(0xff)
+	pduSessionEstablishmentRequest.IntegrityProtectionMaximumDataRate.
+		SetMaximumDataRatePerUEForUserPlaneIntegrityProtectionForUpLink(0xff)
+	pduSessionEstablishmentRequest.PDUSessionType =
+		nasType.NewPDUSessionType(nasMessage.PDUSessionEstablishmentRequestPDUSessionTypeType)
+	pduSessionEstablishmentRequest.PDUSessionType.SetPDUSessionTypeValue(nasMessage.PDUSessionTypeIPv4)
+
+	m.GsmMessage.PDUSessionEstablishmentRequest = pduSessionEstablishmentRequest
+
+	data := new(bytes.Buffer)
+	if err := m.GsmMessageEncode(data); err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+func buildULNASTransport(pduSessionId uint8, requestType uint8, dnn string, sNssai *models.Snssai,
+	payload []byte) (*nas.Message, error) {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeULNASTransport)
+	m.SecurityHeader = nas.SecurityHeader{
+		ProtocolDiscriminator: nasMessage.Epd5GSMobilityManagementMessage,
+		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtectedAndCiphered,
+	}
+
+	ulNasTransport := nasMessage.NewULNASTransport(0)
+	ulNasTransport.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
+	ulNasTransport.SetMessageType(nas.MsgTypeULNASTransport)
+	ulNasTransport.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+
+	ulNasTransport.PduSessionID2Value = new(nasType.PduSessionID2Value)
+	ulNasTransport.PduSessionID2Value.SetIei(nasMessage.ULNASTransportPduSessionID2ValueType)
+	ulNasTransport.PduSessionID2Value.SetPduSessionID2Value(pduSessionId)
+
+	ulNasTransport.RequestType = new(nasType.RequestType)
+	ulNasTransport.RequestType.SetIei(nasMessage.ULNASTransportRequestTypeType)
+	ulNasTransport.RequestType.SetRequestTypeValue(requestType)
+
+	if dnn != "" {
+		ulNasTransport.DNN = new(nasType.DNN)
+		ulNasTransport.DNN.SetIei(nasMessage.ULNASTransportDNNType)
+		ulNasTransport.DNN.SetLen(uint8(len(dnn)))
+		ulNasTransport.DNN.SetDNN([]byte(dnn))
+	}
+
+	if sNssai != nil {
+		var sd [3]uint8
+		if sNssai.Sd != "" {
+			sdBytes, err := hex.DecodeString(sNssai.Sd)
+			if err != nil {
+				return nil, err
+			}
+			copy(sd[:], sdBytes)
+		}
+		ulNasTransport.SNSSAI = nasType.NewSNSSAI(nasMessage.ULNASTransportSNSSAIType)
+		ulNasTransport.SNSSAI.SetLen(4)
+		ulNasTransport.SNSSAI.SetSST(uint8(sNssai.Sst))
+		ulNasTransport.SNSSAI.SetSD(sd)
+	}
+
+	ulNasTransport.SpareHalfOctetAndPayloadContainerType.SetPayloadContainerType(
+		nasMessage.PayloadContainerTypeN1SMInfo)
+	ulNasTransport.PayloadContainer.SetLen(uint16(len(payload)))
+	ulNasTransport.PayloadContainer.SetPayloadContainerContents(payload)
+
+	m.GmmMessage.ULNASTransport = ulNasTransport
+	return m, nil
+}
+
+func buildServiceRequest(u *ue, serviceType uint8) *nas.Message {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeServiceRequest)
+	// TS 24.501 4.4.6: the initial service request is integrity protected but not ciphered
+	m.SecurityHeader = nas.SecurityHeader{
+		ProtocolDiscriminator: nasMessage.Epd5GSMobilityManagementMessage,
+		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtected,
+	}
+
+	serviceRequest := nasMessage.NewServiceRequest(0)
+	serviceRequest.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+	serviceRequest.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
+	serviceRequest.SpareHalfOctetAndSecurityHeaderType.SetSpareHalfOctet(0)
+	serviceRequest.ServiceRequestMessageIdentity.SetMessageType(nas.MsgTypeServiceRequest)
+	serviceRequest.ServiceTypeAndNgksi.SetServiceTypeValue(serviceType)
+	serviceRequest.ServiceTypeAndNgksi.SetTSC(nasMessage.TypeOfSecurityContextFlagNative)
+	serviceRequest.ServiceTypeAndNgksi.SetNasKeySetIdentifiler(u.ngKsi)
+
+	sTmsi := u.sTmsi()
+	serviceRequest.TMSI5GS.SetLen(uint16(len(sTmsi)))
+	copy(serviceRequest.TMSI5GS.Octet[:], sTmsi)
+
+	if len(u.sessions) > 0 {
+		var psi [16]bool
+		for pduSessionId := range u.sessions {
+			psi[pduSessionId] = true
+		}
+		buf := nasConvert.PSIToBuf(psi)
+		serviceRequest.UplinkDataStatus = nasType.NewUplinkDataStatus(nasMessage.ServiceRequestUplinkDataStatusType)
+		serviceRequest.UplinkDataStatus.SetLen(uint8(len(buf)))
+		serviceRequest.UplinkDataStatus.Buffer = buf
+		serviceRequest.PDUSessionStatus = nasType.NewPDUSessionStatus(nasMessage.ServiceRequestPDUSessionStatusType)
+		serviceRequest.PDUSessionStatus.SetLen(uint8(len(buf)))
+		serviceRequest.PDUSessionStatus.Buffer = buf
+	}
+
+	m.GmmMessage.ServiceRequest = serviceRequest
+	return m
+}
+
+func buildDeregistrationRequest(u *ue, switchOff uint8) *nas.Message {
+	m := nas.NewMessage()
+	m.GmmMessage = nas.NewGmmMessage()
+	m.GmmHeader.SetMessageType(nas.MsgTypeDeregistrationRequestUEOriginatingDeregistration)
+	m.SecurityHeader = nas.SecurityHeader{
+		ProtocolDiscriminator: nasMessage.Epd5GSMobilityManagementMessage,
+		SecurityHeaderType:    nas.SecurityHeaderTypeIntegrityProtectedAndCiphered,
+	}
+
+	deregistrationRequest := nasMessage.NewDeregistrationRequestUEOriginatingDeregistration(0)
+	deregistrationRequest.ExtendedProtocolDiscriminator.SetExtendedProtocolDiscriminator(
+		nasMessage.Epd5GSMobilityManagementMessage)
+	deregistrationRequest.SpareHalfOctetAndSecurityHeaderType.SetSecurityHeaderType(nas.SecurityHeaderTypePlainNas)
+	deregistrationRequest.SpareHalfOctetAndSecurityHeaderType.SetSpareHalfOctet(0)
+	deregistrationRequest.DeregistrationRequestMessageIdentity.SetMessageType(
+		nas.MsgTypeDeregistrationRequestUEOriginatingDeregistration)
+	deregistrationRequest.NgksiAndDeregistrationType.SetAccessType(nasMessage.AccessType3GPP)
+	deregistrationRequest.NgksiAndDeregistrationType.SetSwitchOff(switchOff)
+	deregistrationRequest.NgksiAndDeregistrationType.SetTSC(nasMessage.TypeOfSecurityContextFlagNative)
+	deregistrationRequest.NgksiAndDeregistrationType.SetNasKeySetIdentifiler(u.ngKsi)
+
+	guti := u.guti[:]
+	deregistrationRequest.MobileIdentity5GS = nasType.MobileIdentity5GS{
+		Len:    uint16(len(guti)),
+		Buffer: guti,
+	}
+
+	m.GmmMessage.DeregistrationRequestUEOriginatingDeregistration = deregistrationRequest
+	return m
+}
@@ -0,0 +1,134 @@
+package sim
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	"free5gc/lib/UeauCommon"
+	"free5gc/lib/milenage"
+	"free5gc/lib/nas"
+	"free5gc/lib/nas/nasMessage"
+	"free5gc/lib/nas/security"
+	"free5gc/src/ocf/nas/nas_security"
+)
+
+// encodeNas is the UE side of nas_security.Encode: uplink messages are protected by the OCF's own
+// nas_security.Protect with the security header type of msg once the UE has a security context
+func (u *ue) encodeNas(msg *nas.Message) ([]byte, error) {
+	sec := u.securityContext
+	if !sec.SecurityContextAvailable || msg.SecurityHeader.SecurityHeaderType == nas.SecurityHeaderTypePlainNas {
+		return msg.PlainNasEncode()
+	}
+	return nas_security.Protect(sec, msg, security.DirectionUplink)
+}
+
+// decodeNas is the UE side of nas_security.Decode. The Security Mode Command is the only
+// message protected with a new security context, the UE takes the selected algorithms of the
+// command to derive the NAS keys before the integrity check.
+func (u *ue) decodeNas(payload []byte) (*nas.Message, error) {
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("Nas payload is empty")
+	}
+
+	sec := u.securityContext
+	securityHeaderType := uint8(nas.GetSecurityHeaderType(payload) & 0x0f)
+	switch securityHeaderType {
+	case nas.SecurityHeaderTypePlainNas:
+		msg := new(nas.Message)
+		msg.SecurityHeaderType = securityHeaderType
+		err := msg.PlainNasDecode(&payload)
+		return msg, err
+	case nas.SecurityHeaderTypeIntegrityProtectedWithNew5gNasSecurityContext:
+		if len(payload) < 7 {
+			return nil, fmt.Errorf("Security protected NAS message is too short")
+		}
+		plain := new(nas.Message)
+		contents := payload[7:]
+		if err := plain.PlainNasDecode(&contents); err != nil {
+			return nil, err
+		}
+		if plain.GmmMessage == nil || plain.GmmMessage.SecurityModeCommand == nil {
+			return nil, fmt.Errorf("Message with a new security context is not a Security Mode Command")
+		}
+		algorithms := plain.GmmMessage.SecurityModeCommand.SelectedNASSecurityAlgorithms
+		sec.CipheringAlg = algorithms.GetTypeOfCipheringAlgorithm()
+		sec.IntegrityAlg = algorithms.GetTypeOfIntegrityProtectionAlgorithm()
+		sec.DerivateAlgKey()
+		sec.SecurityContextAvailable = true
+	}
+
+	msg, macOk, err := nas_security.Unprotect(sec, payload, security.DirectionDownlink)
+	if err != nil {
+		return nil, err
+	}
+	if !macOk {
+		return nil, fmt.Errorf("NAS MAC verification failed")
+	}
+	return msg, nil
+}
+
+// authenticate runs the USIM side of 5G AKA (TS 33.501 6.1.3.2) and returns RES*, the keys
+// up to KOCF are stored in the security context of the UE
+func (u *ue) authenticate(authenticationRequest *nasMessage.AuthenticationRequest) ([]byte, error) {
+	if authenticationRequest.AuthenticationParameterRAND == nil ||
+		authenticationRequest.AuthenticationParameterAUTN == nil {
+		return nil, fmt.Errorf("Authentication Request without RAND or AUTN, only 5G AKA is supported")
+	}
+	rand := authenticationRequest.AuthenticationParameterRAND.GetRANDValue()
+	autn := authenticationRequest.AuthenticationParameterAUTN.GetAUTN()
+
+	res := make([]byte, 8)
+	ck := make([]byte, 16)
+	ik := make([]byte, 16)
+	ak := make([]byte, 6)
+	akStar := make([]byte, 6)
+	if err := milenage.F2345(u.opc, u.k, rand[:], res, ck, ik, ak, akStar); err != nil {
+		return nil, err
+	}
+
+	// AUTN = SQN xor AK || AMF || MAC
+	sqnXorAk := autn[0:6]
+	sqn := make([]byte, 6)
+	for i := range sqn {
+		sqn[i] = sqnXorAk[i] ^ ak[i]
+	}
+	macA := make([]byte, 8)
+	macS := make([]byte, 8)
+	if err := milenage.F1(u.opc, u.k, rand[:], sqn, autn[6:8], macA, macS); err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(macA, autn[8:16]) {
+		return nil, fmt.Errorf("AUTN MAC failure(received: %x, expected: %x)", autn[8:16], macA)
+	}
+
+	key := append(ck, ik...)
+	snName := []byte(u.servingNetworkName())
+
+	// TS 33.501 Annex A.2, A.4 and A.6
+	kausf := UeauCommon.GetKDFValue(key, UeauCommon.FC_FOR_KAUSF_DERIVATION,
+		snName, UeauCommon.KDFLen(snName), sqnXorAk, UeauCommon.KDFLen(sqnXorAk))
+	resStar := UeauCommon.GetKDFValue(key, UeauCommon.FC_FOR_RES_STAR_XRES_STAR_DERIVATION,
+		snName, UeauCommon.KDFLen(snName), rand[:], UeauCommon.KDFLen(rand[:]), res, UeauCommon.KDFLen(res))
+	kseaf := UeauCommon.GetKDFValue(kausf, UeauCommon.FC_FOR_KSEAF_DERIVATION, snName, UeauCommon.KDFLen(snName))
+
+	sec := u.securityContext
+	sec.ABBA = authenticationRequest.ABBA.GetABBAContents()
+	sec.Kseaf = hex.EncodeToString(kseaf)
+	sec.DerivateKamf()
+	if sec.Kamf == "" {
+		return nil, fmt.Errorf("KOCF derivation failed for SUPI[%s]", sec.Supi)
+	}
+	u.ngKsi = authenticationRequest.SpareHalfOctetAndNgksi.GetNasKeySetIdentifiler()
+
+	return resStar[len(resStar)/2:], nil
+}
+
+// servingNetworkName is the serving network name of TS 24.501 9.12.1
+func (u *ue) servingNetworkName() string {
+	mnc := u.config.PlmnId.Mnc
+	if len(mnc) == 2 {
+		mnc = "0" + mnc
+	}
+	return fmt.Sprintf("5G:mnc%s.mcc%s.3gppnetwork.org", mnc, u.config.PlmnId.Mcc)
+}
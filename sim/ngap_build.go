@@ -0,0 +1,698 @@
+package sim
+
+import (
+	"encoding/binary"
+
+	"free5gc/lib/aper"
+	"free5gc/lib/nas/nasConvert"
+	"free5gc/lib/nas/nasType"
+	"free5gc/lib/ngap"
+	"free5gc/lib/ngap/ngapType"
+	ngap_message "free5gc/src/ocf/ngap/message"
+)
+
+// The builders of this file are the RAN side counterparts of ngap/message/build.go, the IEs both sides
+// of N2 carry are built by the shared builders of ngap/message
+
+func buildNGSetupRequest(g *gnb) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeNGSetup
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentReject
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentNGSetupRequest
+	initiatingMessage.Value.NGSetupRequest = new(ngapType.NGSetupRequest)
+
+	nGSetupRequestIEs := &initiatingMessage.Value.NGSetupRequest.ProtocolIEs
+
+	// Global RAN Node ID
+	ie := ngapType.NGSetupRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDGlobalRANNodeID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.NGSetupRequestIEsPresentGlobalRANNodeID
+	globalRANNodeID := g.globalRanNodeId
+	ie.Value.GlobalRANNodeID = &globalRANNodeID
+	nGSetupRequestIEs.List = append(nGSetupRequestIEs.List, ie)
+
+	// RAN Node Name
+	ie = ngapType.NGSetupRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANNodeName
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.NGSetupRequestIEsPresentRANNodeName
+	ie.Value.RANNodeName = &ngapType.RANNodeName{Value: g.config.Name}
+	nGSetupRequestIEs.List = append(nGSetupRequestIEs.List, ie)
+
+	// Supported TA List
+	ie = ngapType.NGSetupRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDSupportedTAList
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.NGSetupRequestIEsPresentSupportedTAList
+	ie.Value.SupportedTAList = new(ngapType.SupportedTAList)
+
+	supportedTAItem := ngapType.SupportedTAItem{}
+	supportedTAItem.TAC.Value = g.tac
+	broadcastPLMNItem := ngapType.BroadcastPLMNItem{}
+	broadcastPLMNItem.PLMNIdentity = g.plmnIdentity
+	broadcastPLMNItem.TAISliceSupportList = ngap_message.BuildIESliceSupportList(g.config.SNssaiList)
+	supportedTAItem.BroadcastPLMNList.List = append(supportedTAItem.BroadcastPLMNList.List, broadcastPLMNItem)
+	ie.Value.SupportedTAList.List = append(ie.Value.SupportedTAList.List, supportedTAItem)
+	nGSetupRequestIEs.List = append(nGSetupRequestIEs.List, ie)
+
+	// Default Paging DRX
+	ie = ngapType.NGSetupRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDDefaultPagingDRX
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.NGSetupRequestIEsPresentDefaultPagingDRX
+	ie.Value.DefaultPagingDRX = &ngapType.PagingDRX{Value: ngapType.PagingDRXPresentV128}
+	nGSetupRequestIEs.List = append(nGSetupRequestIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildInitialUEMessage(u *ue, nasPdu []byte, withSTmsi bool) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeInitialUEMessage
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentIgnore
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentInitialUEMessage
+	initiatingMessage.Value.InitialUEMessage = new(ngapType.InitialUEMessage)
+
+	initialUEMessageIEs := &initiatingMessage.Value.InitialUEMessage.ProtocolIEs
+
+	// RAN UE NGAP ID
+	ie := ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: u.ranUeNgapId}
+	initialUEMessageIEs.List = append(initialUEMessageIEs.List, ie)
+
+	// NAS PDU
+	ie = ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDNASPDU
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentNASPDU
+	ie.Value.NASPDU = &ngapType.NASPDU{Value: nasPdu}
+	initialUEMessageIEs.List = append(initialUEMessageIEs.List, ie)
+
+	// User Location Information
+	ie = ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUserLocationInformation
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentUserLocationInformation
+	ie.Value.UserLocationInformation = u.gnb.userLocationInformation()
+	initialUEMessageIEs.List = append(initialUEMessageIEs.List, ie)
+
+	// RRC Establishment Cause
+	ie = ngapType.InitialUEMessageIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRRCEstablishmentCause
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.InitialUEMessageIEsPresentRRCEstablishmentCause
+	ie.Value.RRCEstablishmentCause = &ngapType.RRCEstablishmentCause{
+		Value: ngapType.RRCEstablishmentCausePresentMoSignalling,
+	}
+	initialUEMessageIEs.List = append(initialUEMessageIEs.List, ie)
+
+	// 5G-S-TMSI (optional)
+	if withSTmsi {
+		_, guti := nasConvert.GutiToString(u.guti[:])
+		fiveGSTMSI, err := ngap_message.BuildIEFiveGSTMSI(guti)
+		if err != nil {
+			return nil, err
+		}
+		ie = ngapType.InitialUEMessageIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDFiveGSTMSI
+		ie.Criticality.Value = ngapType.CriticalityPresentReject
+		ie.Value.Present = ngapType.InitialUEMessageIEsPresentFiveGSTMSI
+		ie.Value.FiveGSTMSI = &fiveGSTMSI
+		initialUEMessageIEs.List = append(initialUEMessageIEs.List, ie)
+	} else {
+		// UE Context Request (optional), the Registration Accept comes with the Initial Context Setup
+		ie = ngapType.InitialUEMessageIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDUEContextRequest
+		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+		ie.Value.Present = ngapType.InitialUEMessageIEsPresentUEContextRequest
+		ie.Value.UEContextRequest = &ngapType.UEContextRequest{Value: ngapType.UEContextRequestPresentRequested}
+		initialUEMessageIEs.List = append(initialUEMessageIEs.List, ie)
+	}
+
+	return ngap.Encoder(pdu)
+}
+
+func buildUplinkNasTransport(u *ue, nasPdu []byte) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeUplinkNASTransport
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentIgnore
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentUplinkNASTransport
+	initiatingMessage.Value.UplinkNASTransport = new(ngapType.UplinkNASTransport)
+
+	uplinkNasTransportIEs := &initiatingMessage.Value.UplinkNASTransport.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: u.ocfUeNgapId}
+	uplinkNasTransportIEs.List = append(uplinkNasTransportIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: u.ranUeNgapId}
+	uplinkNasTransportIEs.List = append(uplinkNasTransportIEs.List, ie)
+
+	// NAS PDU
+	ie = ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDNASPDU
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentNASPDU
+	ie.Value.NASPDU = &ngapType.NASPDU{Value: nasPdu}
+	uplinkNasTransportIEs.List = append(uplinkNasTransportIEs.List, ie)
+
+	// User Location Information
+	ie = ngapType.UplinkNASTransportIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUserLocationInformation
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.UplinkNASTransportIEsPresentUserLocationInformation
+	ie.Value.UserLocationInformation = u.gnb.userLocationInformation()
+	uplinkNasTransportIEs.List = append(uplinkNasTransportIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildInitialContextSetupResponse(u *ue, setupList *ngapType.PDUSessionResourceSetupListCxtRes) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentSuccessfulOutcome
+	pdu.SuccessfulOutcome = new(ngapType.SuccessfulOutcome)
+
+	successfulOutcome := pdu.SuccessfulOutcome
+	successfulOutcome.ProcedureCode.Value = ngapType.ProcedureCodeInitialContextSetup
+	successfulOutcome.Criticality.Value = ngapType.CriticalityPresentReject
+	successfulOutcome.Value.Present = ngapType.SuccessfulOutcomePresentInitialContextSetupResponse
+	successfulOutcome.Value.InitialContextSetupResponse = new(ngapType.InitialContextSetupResponse)
+
+	initialContextSetupResponseIEs := &successfulOutcome.Value.InitialContextSetupResponse.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.InitialContextSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.InitialContextSetupResponseIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: u.ocfUeNgapId}
+	initialContextSetupResponseIEs.List = append(initialContextSetupResponseIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.InitialContextSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.InitialContextSetupResponseIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: u.ranUeNgapId}
+	initialContextSetupResponseIEs.List = append(initialContextSetupResponseIEs.List, ie)
+
+	// PDU Session Resource Setup Response List (optional)
+	if setupList != nil && len(setupList.List) > 0 {
+		ie = ngapType.InitialContextSetupResponseIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceSetupListCxtRes
+		ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+		ie.Value.Present = ngapType.InitialContextSetupResponseIEsPresentPDUSessionResourceSetupListCxtRes
+		ie.Value.PDUSessionResourceSetupListCxtRes = setupList
+		initialContextSetupResponseIEs.List = append(initialContextSetupResponseIEs.List, ie)
+	}
+
+	return ngap.Encoder(pdu)
+}
+
+func buildPDUSessionResourceSetupResponse(u *ue, setupList ngapType.PDUSessionResourceSetupListSURes) ([]byte,
+	error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentSuccessfulOutcome
+	pdu.SuccessfulOutcome = new(ngapType.SuccessfulOutcome)
+
+	successfulOutcome := pdu.SuccessfulOutcome
+	successfulOutcome.ProcedureCode.Value = ngapType.ProcedureCodePDUSessionResourceSetup
+	successfulOutcome.Criticality.Value = ngapType.CriticalityPresentReject
+	successfulOutcome.Value.Present = ngapType.SuccessfulOutcomePresentPDUSessionResourceSetupResponse
+	successfulOutcome.Value.PDUSessionResourceSetupResponse = new(ngapType.PDUSessionResourceSetupResponse)
+
+	pDUSessionResourceSetupResponseIEs := &successfulOutcome.Value.PDUSessionResourceSetupResponse.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.PDUSessionResourceSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PDUSessionResourceSetupResponseIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: u.ocfUeNgapId}
+	pDUSessionResourceSetupResponseIEs.List = append(pDUSessionResourceSetupResponseIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.PDUSessionResourceSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PDUSessionResourceSetupResponseIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: u.ranUeNgapId}
+	pDUSessionResourceSetupResponseIEs.List = append(pDUSessionResourceSetupResponseIEs.List, ie)
+
+	// PDU Session Resource Setup Response List
+	ie = ngapType.PDUSessionResourceSetupResponseIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceSetupListSURes
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PDUSessionResourceSetupResponseIEsPresentPDUSessionResourceSetupListSURes
+	ie.Value.PDUSessionResourceSetupListSURes = &setupList
+	pDUSessionResourceSetupResponseIEs.List = append(pDUSessionResourceSetupResponseIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildUEContextReleaseRequest(u *ue) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeUEContextReleaseRequest
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentIgnore
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentUEContextReleaseRequest
+	initiatingMessage.Value.UEContextReleaseRequest = new(ngapType.UEContextReleaseRequest)
+
+	uEContextReleaseRequestIEs := &initiatingMessage.Value.UEContextReleaseRequest.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.UEContextReleaseRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UEContextReleaseRequestIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: u.ocfUeNgapId}
+	uEContextReleaseRequestIEs.List = append(uEContextReleaseRequestIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.UEContextReleaseRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.UEContextReleaseRequestIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: u.ranUeNgapId}
+	uEContextReleaseRequestIEs.List = append(uEContextReleaseRequestIEs.List, ie)
+
+	// PDU Session Resource List (optional)
+	if len(u.sessions) > 0 {
+		ie = ngapType.UEContextReleaseRequestIEs{}
+		ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceListCxtRelReq
+		ie.Criticality.Value = ngapType.CriticalityPresentReject
+		ie.Value.Present = ngapType.UEContextReleaseRequestIEsPresentPDUSessionResourceListCxtRelReq
+		ie.Value.PDUSessionResourceListCxtRelReq = new(ngapType.PDUSessionResourceListCxtRelReq)
+		for _, session := range u.sortedSessions() {
+			item := ngapType.PDUSessionResourceItemCxtRelReq{}
+			item.PDUSessionID.Value = int64(session.id)
+			ie.Value.PDUSessionResourceListCxtRelReq.List = append(ie.Value.PDUSessionResourceListCxtRelReq.List, item)
+		}
+		uEContextReleaseRequestIEs.List = append(uEContextReleaseRequestIEs.List, ie)
+	}
+
+	// Cause
+	ie = ngapType.UEContextReleaseRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDCause
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.UEContextReleaseRequestIEsPresentCause
+	ie.Value.Cause = &ngapType.Cause{
+		Present: ngapType.CausePresentRadioNetwork,
+		RadioNetwork: &ngapType.CauseRadioNetwork{
+			Value: ngapType.CauseRadioNetworkPresentUserInactivity,
+		},
+	}
+	uEContextReleaseRequestIEs.List = append(uEContextReleaseRequestIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildUEContextReleaseComplete(ocfUeNgapId, ranUeNgapId int64) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentSuccessfulOutcome
+	pdu.SuccessfulOutcome = new(ngapType.SuccessfulOutcome)
+
+	successfulOutcome := pdu.SuccessfulOutcome
+	successfulOutcome.ProcedureCode.Value = ngapType.ProcedureCodeUEContextRelease
+	successfulOutcome.Criticality.Value = ngapType.CriticalityPresentReject
+	successfulOutcome.Value.Present = ngapType.SuccessfulOutcomePresentUEContextReleaseComplete
+	successfulOutcome.Value.UEContextReleaseComplete = new(ngapType.UEContextReleaseComplete)
+
+	uEContextReleaseCompleteIEs := &successfulOutcome.Value.UEContextReleaseComplete.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.UEContextReleaseCompleteIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.UEContextReleaseCompleteIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: ocfUeNgapId}
+	uEContextReleaseCompleteIEs.List = append(uEContextReleaseCompleteIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.UEContextReleaseCompleteIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.UEContextReleaseCompleteIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapId}
+	uEContextReleaseCompleteIEs.List = append(uEContextReleaseCompleteIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildHandoverRequired(u *ue, target *gnb) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeHandoverPreparation
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentReject
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentHandoverRequired
+	initiatingMessage.Value.HandoverRequired = new(ngapType.HandoverRequired)
+
+	handoverRequiredIEs := &initiatingMessage.Value.HandoverRequired.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: u.ocfUeNgapId}
+	handoverRequiredIEs.List = append(handoverRequiredIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: u.ranUeNgapId}
+	handoverRequiredIEs.List = append(handoverRequiredIEs.List, ie)
+
+	// Handover Type
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDHandoverType
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentHandoverType
+	ie.Value.HandoverType = &ngapType.HandoverType{Value: ngapType.HandoverTypePresentIntra5gs}
+	handoverRequiredIEs.List = append(handoverRequiredIEs.List, ie)
+
+	// Cause
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDCause
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentCause
+	ie.Value.Cause = &ngapType.Cause{
+		Present: ngapType.CausePresentRadioNetwork,
+		RadioNetwork: &ngapType.CauseRadioNetwork{
+			Value: ngapType.CauseRadioNetworkPresentHandoverDesirableForRadioReason,
+		},
+	}
+	handoverRequiredIEs.List = append(handoverRequiredIEs.List, ie)
+
+	// Target ID
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDTargetID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentTargetID
+	ie.Value.TargetID = &ngapType.TargetID{
+		Present: ngapType.TargetIDPresentTargetRANNodeID,
+		TargetRANNodeID: &ngapType.TargetRANNodeID{
+			GlobalRANNodeID: target.globalRanNodeId,
+			SelectedTAI: ngapType.TAI{
+				PLMNIdentity: target.plmnIdentity,
+				TAC:          ngapType.TAC{Value: target.tac},
+			},
+		},
+	}
+	handoverRequiredIEs.List = append(handoverRequiredIEs.List, ie)
+
+	// PDU Session Resource List
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceListHORqd
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentPDUSessionResourceListHORqd
+	ie.Value.PDUSessionResourceListHORqd = new(ngapType.PDUSessionResourceListHORqd)
+	for _, session := range u.sortedSessions() {
+		transfer, err := aper.MarshalWithParams(ngapType.HandoverRequiredTransfer{}, "valueExt")
+		if err != nil {
+			return nil, err
+		}
+		item := ngapType.PDUSessionResourceItemHORqd{}
+		item.PDUSessionID.Value = int64(session.id)
+		item.HandoverRequiredTransfer = transfer
+		ie.Value.PDUSessionResourceListHORqd.List = append(ie.Value.PDUSessionResourceListHORqd.List, item)
+	}
+	handoverRequiredIEs.List = append(handoverRequiredIEs.List, ie)
+
+	// Source to Target Transparent Container
+	ie = ngapType.HandoverRequiredIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDSourceToTargetTransparentContainer
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequiredIEsPresentSourceToTargetTransparentContainer
+	ie.Value.SourceToTargetTransparentContainer = &ngapType.SourceToTargetTransparentContainer{
+		Value: u.transparentContainer(),
+	}
+	handoverRequiredIEs.List = append(handoverRequiredIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildHandoverRequestAcknowledge(u *ue, target *gnb, ocfUeNgapId, ranUeNgapId int64,
+	setupList *ngapType.PDUSessionResourceSetupListHOReq) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentSuccessfulOutcome
+	pdu.SuccessfulOutcome = new(ngapType.SuccessfulOutcome)
+
+	successfulOutcome := pdu.SuccessfulOutcome
+	successfulOutcome.ProcedureCode.Value = ngapType.ProcedureCodeHandoverResourceAllocation
+	successfulOutcome.Criticality.Value = ngapType.CriticalityPresentReject
+	successfulOutcome.Value.Present = ngapType.SuccessfulOutcomePresentHandoverRequestAcknowledge
+	successfulOutcome.Value.HandoverRequestAcknowledge = new(ngapType.HandoverRequestAcknowledge)
+
+	handoverRequestAcknowledgeIEs := &successfulOutcome.Value.HandoverRequestAcknowledge.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: ocfUeNgapId}
+	handoverRequestAcknowledgeIEs.List = append(handoverRequestAcknowledgeIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapId}
+	handoverRequestAcknowledgeIEs.List = append(handoverRequestAcknowledgeIEs.List, ie)
+
+	// PDU Session Resource Admitted List
+	ie = ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceAdmittedList
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentPDUSessionResourceAdmittedList
+	ie.Value.PDUSessionResourceAdmittedList = new(ngapType.PDUSessionResourceAdmittedList)
+	if setupList != nil {
+		for _, setupItem := range setupList.List {
+			session := u.sessions[uint8(setupItem.PDUSessionID.Value)]
+			if session == nil {
+				continue
+			}
+			transfer := ngapType.HandoverRequestAcknowledgeTransfer{}
+			transfer.DLNGUUPTNLInformation = target.upTransportLayerInformation(u, session)
+			for _, qosFlowId := range session.qosFlowIds {
+				qosFlowItem := ngapType.QosFlowItemWithDataForwarding{}
+				qosFlowItem.QosFlowIdentifier.Value = qosFlowId
+				transfer.QosFlowSetupResponseList.List = append(transfer.QosFlowSetupResponseList.List, qosFlowItem)
+			}
+			encodedTransfer, err := aper.MarshalWithParams(transfer, "valueExt")
+			if err != nil {
+				return nil, err
+			}
+			item := ngapType.PDUSessionResourceAdmittedItem{}
+			item.PDUSessionID = setupItem.PDUSessionID
+			item.HandoverRequestAcknowledgeTransfer = encodedTransfer
+			ie.Value.PDUSessionResourceAdmittedList.List = append(ie.Value.PDUSessionResourceAdmittedList.List, item)
+		}
+	}
+	handoverRequestAcknowledgeIEs.List = append(handoverRequestAcknowledgeIEs.List, ie)
+
+	// Target to Source Transparent Container
+	ie = ngapType.HandoverRequestAcknowledgeIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDTargetToSourceTransparentContainer
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverRequestAcknowledgeIEsPresentTargetToSourceTransparentContainer
+	ie.Value.TargetToSourceTransparentContainer = &ngapType.TargetToSourceTransparentContainer{
+		Value: u.transparentContainer(),
+	}
+	handoverRequestAcknowledgeIEs.List = append(handoverRequestAcknowledgeIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildHandoverNotify(u *ue, target *gnb, ocfUeNgapId, ranUeNgapId int64) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodeHandoverNotification
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentIgnore
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentHandoverNotify
+	initiatingMessage.Value.HandoverNotify = new(ngapType.HandoverNotify)
+
+	handoverNotifyIEs := &initiatingMessage.Value.HandoverNotify.ProtocolIEs
+
+	// OCF UE NGAP ID
+	ie := ngapType.HandoverNotifyIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverNotifyIEsPresentOCFUENGAPID
+	ie.Value.OCFUENGAPID = &ngapType.OCFUENGAPID{Value: ocfUeNgapId}
+	handoverNotifyIEs.List = append(handoverNotifyIEs.List, ie)
+
+	// RAN UE NGAP ID
+	ie = ngapType.HandoverNotifyIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.HandoverNotifyIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapId}
+	handoverNotifyIEs.List = append(handoverNotifyIEs.List, ie)
+
+	// User Location Information
+	ie = ngapType.HandoverNotifyIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUserLocationInformation
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.HandoverNotifyIEsPresentUserLocationInformation
+	ie.Value.UserLocationInformation = target.userLocationInformation()
+	handoverNotifyIEs.List = append(handoverNotifyIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+func buildPathSwitchRequest(u *ue, target *gnb, ranUeNgapId int64) ([]byte, error) {
+	var pdu ngapType.NGAPPDU
+	pdu.Present = ngapType.NGAPPDUPresentInitiatingMessage
+	pdu.InitiatingMessage = new(ngapType.InitiatingMessage)
+
+	initiatingMessage := pdu.InitiatingMessage
+	initiatingMessage.ProcedureCode.Value = ngapType.ProcedureCodePathSwitchRequest
+	initiatingMessage.Criticality.Value = ngapType.CriticalityPresentReject
+	initiatingMessage.Value.Present = ngapType.InitiatingMessagePresentPathSwitchRequest
+	initiatingMessage.Value.PathSwitchRequest = new(ngapType.PathSwitchRequest)
+
+	pathSwitchRequestIEs := &initiatingMessage.Value.PathSwitchRequest.ProtocolIEs
+
+	// RAN UE NGAP ID
+	ie := ngapType.PathSwitchRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDRANUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.PathSwitchRequestIEsPresentRANUENGAPID
+	ie.Value.RANUENGAPID = &ngapType.RANUENGAPID{Value: ranUeNgapId}
+	pathSwitchRequestIEs.List = append(pathSwitchRequestIEs.List, ie)
+
+	// Source OCF UE NGAP ID
+	ie = ngapType.PathSwitchRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDSourceOCFUENGAPID
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.PathSwitchRequestIEsPresentSourceOCFUENGAPID
+	ie.Value.SourceOCFUENGAPID = &ngapType.OCFUENGAPID{Value: u.ocfUeNgapId}
+	pathSwitchRequestIEs.List = append(pathSwitchRequestIEs.List, ie)
+
+	// User Location Information
+	ie = ngapType.PathSwitchRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUserLocationInformation
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PathSwitchRequestIEsPresentUserLocationInformation
+	ie.Value.UserLocationInformation = target.userLocationInformation()
+	pathSwitchRequestIEs.List = append(pathSwitchRequestIEs.List, ie)
+
+	// UE Security Capabilities, NR algorithms as in the UE security capability of the UE
+	ie = ngapType.PathSwitchRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDUESecurityCapabilities
+	ie.Criticality.Value = ngapType.CriticalityPresentIgnore
+	ie.Value.Present = ngapType.PathSwitchRequestIEsPresentUESecurityCapabilities
+	ueSecurityCapabilities := ngap_message.BuildIEUESecurityCapabilities(&nasType.UESecurityCapability{
+		Len:    uint8(len(ueSecurityCapability)),
+		Buffer: ueSecurityCapability,
+	})
+	ie.Value.UESecurityCapabilities = &ueSecurityCapabilities
+	pathSwitchRequestIEs.List = append(pathSwitchRequestIEs.List, ie)
+
+	// PDU Session Resource to be Switched in Downlink List
+	ie = ngapType.PathSwitchRequestIEs{}
+	ie.Id.Value = ngapType.ProtocolIEIDPDUSessionResourceToBeSwitchedDLList
+	ie.Criticality.Value = ngapType.CriticalityPresentReject
+	ie.Value.Present = ngapType.PathSwitchRequestIEsPresentPDUSessionResourceToBeSwitchedDLList
+	ie.Value.PDUSessionResourceToBeSwitchedDLList = new(ngapType.PDUSessionResourceToBeSwitchedDLList)
+	for _, session := range u.sortedSessions() {
+		transfer := ngapType.PathSwitchRequestTransfer{}
+		transfer.DLNGUUPTNLInformation = target.upTransportLayerInformation(u, session)
+		for _, qosFlowId := range session.qosFlowIds {
+			qosFlowItem := ngapType.QosFlowAcceptedItem{}
+			qosFlowItem.QosFlowIdentifier.Value = qosFlowId
+			transfer.QosFlowAcceptedList.List = append(transfer.QosFlowAcceptedList.List, qosFlowItem)
+		}
+		encodedTransfer, err := aper.MarshalWithParams(transfer, "valueExt")
+		if err != nil {
+			return nil, err
+		}
+		item := ngapType.PDUSessionResourceToBeSwitchedDLItem{}
+		item.PDUSessionID.Value = int64(session.id)
+		item.PathSwitchRequestTransfer = encodedTransfer
+		ie.Value.PDUSessionResourceToBeSwitchedDLList.List = append(ie.Value.PDUSessionResourceToBeSwitchedDLList.List,
+			item)
+	}
+	pathSwitchRequestIEs.List = append(pathSwitchRequestIEs.List, ie)
+
+	return ngap.Encoder(pdu)
+}
+
+// buildPDUSessionResourceSetupResponseTransfer answers the setup request transfer of a session
+// with the N3 tunnel of the gNB and all QoS flows of the request
+func buildPDUSessionResourceSetupResponseTransfer(u *ue, g *gnb, session *pduSession) ([]byte, error) {
+	transfer := ngapType.PDUSessionResourceSetupResponseTransfer{}
+	transfer.DLQosFlowPerTNLInformation.UPTransportLayerInformation = g.upTransportLayerInformation(u, session)
+	for _, qosFlowId := range session.qosFlowIds {
+		item := ngapType.AssociatedQosFlowItem{}
+		item.QosFlowIdentifier.Value = qosFlowId
+		transfer.DLQosFlowPerTNLInformation.AssociatedQosFlowList.List = append(
+			transfer.DLQosFlowPerTNLInformation.AssociatedQosFlowList.List, item)
+	}
+	return aper.MarshalWithParams(transfer, "valueExt")
+}
+
+// qosFlowIds returns the QoS flows of a PDU Session Resource Setup Request Transfer
+func qosFlowIds(encodedTransfer []byte) []int64 {
+	transfer := ngapType.PDUSessionResourceSetupRequestTransfer{}
+	if err := aper.UnmarshalWithParams(encodedTransfer, &transfer, "valueExt"); err != nil {
+		return []int64{defaultQosFlowId}
+	}
+	var ids []int64
+	for _, ie := range transfer.ProtocolIEs.List {
+		if ie.Id.Value == ngapType.ProtocolIEIDQosFlowSetupRequestList && ie.Value.QosFlowSetupRequestList != nil {
+			for _, item := range ie.Value.QosFlowSetupRequestList.List {
+				ids = append(ids, item.QosFlowIdentifier.Value)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		ids = []int64{defaultQosFlowId}
+	}
+	return ids
+}
+
+func gtpTeid(teid uint32) aper.OctetString {
+	value := make(aper.OctetString, 4)
+	binary.BigEndian.PutUint32(value, teid)
+	return value
+}
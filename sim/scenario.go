@@ -0,0 +1,212 @@
+package sim
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"free5gc/lib/openapi/models"
+)
+
+// Procedures of a scenario
+const (
+	ProcedureNGSetup                 = "ngSetup"
+	ProcedureRegistration            = "registration"
+	ProcedurePduSessionEstablishment = "pduSessionEstablishment"
+	ProcedureAnRelease               = "anRelease"
+	ProcedureServiceRequest          = "serviceRequest"
+	ProcedureXnHandover              = "xnHandover"
+	ProcedureN2Handover              = "n2Handover"
+	ProcedureDeregistration          = "deregistration"
+)
+
+const (
+	TransportSctp = "sctp"
+	TransportTcp  = "tcp"
+)
+
+const (
+	defaultOcfPort      = 38412
+	defaultTimeout      = 5 * time.Second
+	defaultAuthMgmtFlag = "8000"
+	defaultQosFlowId    = 1
+)
+
+// Scenario is the YAML file of the sim command
+type Scenario struct {
+	Ocf OcfConfig `yaml:"ocf"`
+
+	Gnbs []GnbConfig `yaml:"gnbs"`
+
+	Ues UeConfig `yaml:"ues"`
+
+	// Procedures are run in order by every UE, the whole list is run Repeat times
+	Procedures []Procedure `yaml:"procedures"`
+
+	Repeat int `yaml:"repeat,omitempty"`
+
+	// Timeout of every response the simulator waits for
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// OcfConfig is the NGAP server under test
+type OcfConfig struct {
+	Addresses []string `yaml:"addresses"`
+
+	Port int `yaml:"port,omitempty"`
+
+	// Transport is sctp (default) or tcp, the framed TCP transport of the NGAP server
+	Transport string `yaml:"transport,omitempty"`
+}
+
+type GnbConfig struct {
+	Name string `yaml:"name"`
+
+	// GnbId is the 22 to 32 bits gNB ID in hex, e.g. 000001
+	GnbId string `yaml:"gnbId"`
+
+	PlmnId models.PlmnId `yaml:"plmnId"`
+
+	Tac string `yaml:"tac"`
+
+	SNssaiList []models.Snssai `yaml:"sNssaiList"`
+
+	// N3Address is the GTP-U address sent to the OCF in the N2 SM information
+	N3Address string `yaml:"n3Address,omitempty"`
+}
+
+// UeConfig describes the simulated UEs, they differ only by their SUPI
+type UeConfig struct {
+	// Gnb is the name of the gNB the UEs register on
+	Gnb string `yaml:"gnb"`
+
+	Count int `yaml:"count,omitempty"`
+
+	// Concurrency is the number of UEs running the procedures at the same time
+	Concurrency int `yaml:"concurrency,omitempty"`
+
+	// Supi of the first UE, the MSIN is incremented for the others, e.g. imsi-208930000000001
+	Supi string `yaml:"supi"`
+
+	PlmnId models.PlmnId `yaml:"plmnId"`
+
+	RoutingIndicator string `yaml:"routingIndicator,omitempty"`
+
+	// K, Opc and AuthMgmtField are hex strings shared by all UEs
+	K string `yaml:"k"`
+
+	Opc string `yaml:"opc"`
+
+	AuthMgmtField string `yaml:"authMgmtField,omitempty"`
+
+	SNssai models.Snssai `yaml:"sNssai"`
+
+	Dnn string `yaml:"dnn"`
+}
+
+type Procedure struct {
+	Name string `yaml:"name"`
+
+	// TargetGnb is the name of the target gNB of a handover
+	TargetGnb string `yaml:"targetGnb,omitempty"`
+
+	PduSessionId uint8 `yaml:"pduSessionId,omitempty"`
+
+	// Delay before the procedure is started
+	Delay time.Duration `yaml:"delay,omitempty"`
+}
+
+func LoadScenario(file string) (*Scenario, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	scenario := &Scenario{}
+	if err := yaml.Unmarshal(content, scenario); err != nil {
+		return nil, err
+	}
+	if err := scenario.setDefaults(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %+v", file, err)
+	}
+	return scenario, nil
+}
+
+func (s *Scenario) setDefaults() error {
+	if len(s.Ocf.Addresses) == 0 {
+		return fmt.Errorf("no OCF address")
+	}
+	if s.Ocf.Port == 0 {
+		s.Ocf.Port = defaultOcfPort
+	}
+	switch s.Ocf.Transport {
+	case "":
+		s.Ocf.Transport = TransportSctp
+	case TransportSctp, TransportTcp:
+	default:
+		return fmt.Errorf("unknown transport %s", s.Ocf.Transport)
+	}
+	if s.Timeout == 0 {
+		s.Timeout = defaultTimeout
+	}
+	if s.Repeat == 0 {
+		s.Repeat = 1
+	}
+
+	if len(s.Gnbs) == 0 {
+		return fmt.Errorf("no gNB")
+	}
+	names := make(map[string]bool)
+	for _, gnb := range s.Gnbs {
+		if names[gnb.Name] {
+			return fmt.Errorf("duplicated gNB name %s", gnb.Name)
+		}
+		names[gnb.Name] = true
+		if len(gnb.GnbId) < 6 || len(gnb.GnbId) > 8 {
+			return fmt.Errorf("gNB ID %s of %s is not 22 to 32 bits", gnb.GnbId, gnb.Name)
+		}
+		if len(gnb.Tac) != 6 {
+			return fmt.Errorf("TAC %s of %s is not 3 octets", gnb.Tac, gnb.Name)
+		}
+	}
+
+	if s.Ues.Gnb == "" {
+		s.Ues.Gnb = s.Gnbs[0].Name
+	} else if !names[s.Ues.Gnb] {
+		return fmt.Errorf("unknown gNB %s of the UEs", s.Ues.Gnb)
+	}
+	if s.Ues.Count == 0 && len(s.Procedures) > 0 {
+		s.Ues.Count = 1
+	}
+	if s.Ues.Concurrency == 0 || s.Ues.Concurrency > s.Ues.Count {
+		s.Ues.Concurrency = s.Ues.Count
+	}
+	if s.Ues.RoutingIndicator == "" {
+		s.Ues.RoutingIndicator = "0000"
+	} else if len(s.Ues.RoutingIndicator) != 4 {
+		return fmt.Errorf("routing indicator %s is not 4 digits", s.Ues.RoutingIndicator)
+	}
+	if s.Ues.AuthMgmtField == "" {
+		s.Ues.AuthMgmtField = defaultAuthMgmtFlag
+	}
+
+	for i := range s.Procedures {
+		procedure := &s.Procedures[i]
+		switch procedure.Name {
+		case ProcedureRegistration, ProcedureAnRelease, ProcedureServiceRequest, ProcedureDeregistration:
+		case ProcedurePduSessionEstablishment:
+			if procedure.PduSessionId == 0 {
+				procedure.PduSessionId = 1
+			}
+		case ProcedureXnHandover, ProcedureN2Handover:
+			if !names[procedure.TargetGnb] {
+				return fmt.Errorf("unknown target gNB %s of %s", procedure.TargetGnb, procedure.Name)
+			}
+		default:
+			return fmt.Errorf("unknown procedure %s", procedure.Name)
+		}
+	}
+	return nil
+}
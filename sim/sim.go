@@ -0,0 +1,109 @@
+// Package sim is a gNB and UE simulator which runs the scenario of a YAML file against the
+// NGAP interface of an OCF and reports the latency of every procedure.
+package sim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"free5gc/src/ocf/logger"
+	ngap_service "free5gc/src/ocf/ngap/service"
+)
+
+type Simulator struct {
+	scenario *Scenario
+	stats    *Stats
+	gnbs     map[string]*gnb // name -> gNB
+	ues      map[string]*ue  // SUPI -> UE
+
+	// dial replaces the transport of the scenario, the tests dial the memory transport with it
+	dial func() (ngap_service.Conn, error)
+}
+
+func New(scenario *Scenario) (*Simulator, error) {
+	s := &Simulator{
+		scenario: scenario,
+		stats:    NewStats(),
+		gnbs:     make(map[string]*gnb),
+		ues:      make(map[string]*ue),
+	}
+	for i := range scenario.Gnbs {
+		config := &scenario.Gnbs[i]
+		s.gnbs[config.Name] = newGnb(s, config)
+	}
+	for i := 0; i < scenario.Ues.Count; i++ {
+		u, err := newUe(s, &scenario.Ues, i)
+		if err != nil {
+			return nil, err
+		}
+		s.ues[u.supi] = u
+	}
+	return s, nil
+}
+
+// Run sets up every gNB and runs the procedures of the scenario with the UEs, a UE stops
+// at its first failed procedure. An error is returned only if the gNBs can not be set up.
+func (s *Simulator) Run() (*Stats, error) {
+	defer func() {
+		for _, g := range s.gnbs {
+			g.close()
+		}
+	}()
+
+	for i := range s.scenario.Gnbs {
+		g := s.gnbs[s.scenario.Gnbs[i].Name]
+		if err := g.connect(); err != nil {
+			return s.stats, err
+		}
+		start := time.Now()
+		err := g.ngSetup()
+		s.stats.Record(ProcedureNGSetup, start, err)
+		if err != nil {
+			return s.stats, err
+		}
+		logger.SimLog.Infof("gNB[%s] NG Setup done", g.config.Name)
+	}
+
+	ues := make(chan *ue)
+	var wg sync.WaitGroup
+	for i := 0; i < s.scenario.Ues.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range ues {
+				s.runUe(u)
+			}
+		}()
+	}
+	for _, u := range s.ues {
+		ues <- u
+	}
+	close(ues)
+	wg.Wait()
+
+	return s.stats, nil
+}
+
+func (s *Simulator) runUe(u *ue) {
+	for repeat := 0; repeat < s.scenario.Repeat; repeat++ {
+		for i := range s.scenario.Procedures {
+			procedure := &s.scenario.Procedures[i]
+			if procedure.Delay > 0 {
+				time.Sleep(procedure.Delay)
+			}
+			start := time.Now()
+			err := u.run(procedure)
+			s.stats.Record(procedure.Name, start, err)
+			if err != nil {
+				logger.SimLog.Errorf("UE[%s] %s failed: %+v", u.supi, procedure.Name, err)
+				return
+			}
+			logger.SimLog.Debugf("UE[%s] %s done in %s", u.supi, procedure.Name, time.Since(start))
+		}
+	}
+}
+
+func (s *Simulator) String() string {
+	return fmt.Sprintf("%d gNB(s), %d UE(s)", len(s.gnbs), len(s.ues))
+}
@@ -0,0 +1,269 @@
+package sim
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/UeauCommon"
+	"free5gc/lib/milenage"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/factory"
+	"free5gc/src/ocf/ngap"
+	ngap_service "free5gc/src/ocf/ngap/service"
+	"free5gc/src/ocf/util"
+)
+
+func TestUeIdentities(t *testing.T) {
+	config := &UeConfig{
+		Supi:             "imsi-208930000000001",
+		PlmnId:           models.PlmnId{Mcc: "208", Mnc: "93"},
+		RoutingIndicator: "0000",
+		K:                "5122250214c33e723a5dd523fc145fc0",
+		Opc:              "981d464c7c52eb6e5036234984ad0bcf",
+	}
+	u, err := newUe(nil, config, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "imsi-208930000000003", u.supi)
+
+	// SUCI with the null scheme: MCC 208, MNC 93, MSIN 0000000003
+	assert.Equal(t, []uint8{0x01, 0x02, 0xf8, 0x39, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x30},
+		u.suci())
+
+	imeisv := u.imeisv()
+	assert.Equal(t, uint8(0x35), imeisv[0])
+	assert.Equal(t, uint8(0xf0), imeisv[8]&0xf0)
+
+	u.guti = [11]byte{0xf2, 0x02, 0xf8, 0x39, 0xca, 0xfe, 0x00, 0x00, 0x00, 0x00, 0x01}
+	assert.Equal(t, []uint8{0xf4, 0xfe, 0x00, 0x00, 0x00, 0x00, 0x01}, u.sTmsi())
+}
+
+func TestStatsSummary(t *testing.T) {
+	stats := NewStats()
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		stats.Record(ProcedureRegistration, now.Add(-time.Duration(i)*time.Millisecond), nil)
+	}
+	stats.Record(ProcedureRegistration, now, errors.New("timeout"))
+	stats.Record(ProcedureDeregistration, now, errors.New("timeout"))
+
+	summary := stats.Summary()
+	assert.Len(t, summary, 2)
+	assert.Equal(t, ProcedureRegistration, summary[0].Procedure)
+	assert.Equal(t, 10, summary[0].Success)
+	assert.Equal(t, 1, summary[0].Failure)
+	assert.True(t, summary[0].Min >= time.Millisecond)
+	assert.True(t, summary[0].Max >= summary[0].P95)
+	assert.True(t, summary[0].P95 >= summary[0].P50)
+	assert.Equal(t, ProcedureDeregistration, summary[1].Procedure)
+	assert.Equal(t, 0, summary[1].Success)
+}
+
+// fakeCore serves the NRF, AUSF, UDM and PCF operations of an initial registration with
+// 5G AKA for the UEs of config, all the services are in a single NF profile
+func fakeCore(t *testing.T, config *UeConfig) *httptest.Server {
+	k, err := hex.DecodeString(config.K)
+	require.NoError(t, err)
+	opc, err := hex.DecodeString(config.Opc)
+	require.NoError(t, err)
+	authMgmtField, err := hex.DecodeString(config.AuthMgmtField)
+	require.NoError(t, err)
+
+	var server *httptest.Server
+	var mutex sync.Mutex
+	kseafs := make(map[string]string) // SUCI -> Kseaf of the pending authentication
+
+	reply := func(w http.ResponseWriter, status int, body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			t.Errorf("fake core: %+v", err)
+		}
+	}
+	service := func(name models.ServiceName) models.NfService {
+		return models.NfService{
+			ServiceInstanceId: string(name),
+			ServiceName:       name,
+			Scheme:            models.UriScheme_HTTP,
+			NfServiceStatus:   models.NfServiceStatus_REGISTERED,
+			ApiPrefix:         server.URL,
+		}
+	}
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/nf-instances"):
+			reply(w, http.StatusOK, models.SearchResult{NfInstances: []models.NfProfile{{
+				NfInstanceId: "fake-core",
+				NfStatus:     models.NfStatus_REGISTERED,
+				NfServices: &[]models.NfService{
+					service(models.ServiceName_NAUSF_AUTH),
+					service(models.ServiceName_NUDM_UECM),
+					service(models.ServiceName_NUDM_SDM),
+					service(models.ServiceName_NPCF_AM_POLICY_CONTROL),
+				},
+			}}})
+		case strings.HasSuffix(path, "/ue-authentications"):
+			var authInfo models.AuthenticationInfo
+			if err := json.NewDecoder(r.Body).Decode(&authInfo); err != nil {
+				t.Errorf("fake core: %+v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			snName := []byte(authInfo.ServingNetworkName)
+
+			rand := make([]byte, 16)
+			sqn := []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x21}
+			macA, macS := make([]byte, 8), make([]byte, 8)
+			res, ck, ik := make([]byte, 8), make([]byte, 16), make([]byte, 16)
+			ak, akStar := make([]byte, 6), make([]byte, 6)
+			if err := milenage.F1(opc, k, rand, sqn, authMgmtField, macA, macS); err != nil {
+				t.Errorf("fake core: %+v", err)
+			}
+			if err := milenage.F2345(opc, k, rand, res, ck, ik, ak, akStar); err != nil {
+				t.Errorf("fake core: %+v", err)
+			}
+			sqnXorAk := make([]byte, 6)
+			for i := range sqn {
+				sqnXorAk[i] = sqn[i] ^ ak[i]
+			}
+			autn := append(append(append([]byte{}, sqnXorAk...), authMgmtField...), macA...)
+
+			// TS 33.501 Annex A.2, A.4, A.5 and A.6
+			key := append(ck, ik...)
+			kausf := UeauCommon.GetKDFValue(key, UeauCommon.FC_FOR_KAUSF_DERIVATION,
+				snName, UeauCommon.KDFLen(snName), sqnXorAk, UeauCommon.KDFLen(sqnXorAk))
+			xresStar := UeauCommon.GetKDFValue(key, UeauCommon.FC_FOR_RES_STAR_XRES_STAR_DERIVATION,
+				snName, UeauCommon.KDFLen(snName), rand, UeauCommon.KDFLen(rand), res, UeauCommon.KDFLen(res))
+			hxresStar := sha256.Sum256(append(append([]byte{}, rand...), xresStar[16:]...))
+			kseaf := UeauCommon.GetKDFValue(kausf, UeauCommon.FC_FOR_KSEAF_DERIVATION, snName, UeauCommon.KDFLen(snName))
+
+			mutex.Lock()
+			kseafs[authInfo.SupiOrSuci] = hex.EncodeToString(kseaf)
+			mutex.Unlock()
+			reply(w, http.StatusCreated, models.UeAuthenticationCtx{
+				AuthType: models.AuthType__5_G_AKA,
+				Var5gAuthData: models.Av5gAka{
+					Rand:      hex.EncodeToString(rand),
+					HxresStar: hex.EncodeToString(hxresStar[16:]),
+					Autn:      hex.EncodeToString(autn),
+				},
+				Links: map[string]models.LinksValueSchema{"link": {
+					Href: server.URL + "/nausf-auth/v1/ue-authentications/" + authInfo.SupiOrSuci + "/5g-aka-confirmation",
+				}},
+			})
+		case strings.HasSuffix(path, "/5g-aka-confirmation"):
+			suci := strings.TrimSuffix(path[strings.Index(path, "/ue-authentications/")+len("/ue-authentications/"):],
+				"/5g-aka-confirmation")
+			mutex.Lock()
+			kseaf := kseafs[suci]
+			mutex.Unlock()
+			reply(w, http.StatusOK, models.ConfirmationDataResponse{
+				AuthResult: models.AuthResult_SUCCESS,
+				Supi:       config.Supi,
+				Kseaf:      kseaf,
+			})
+		case strings.Contains(path, "/registrations/"):
+			reply(w, http.StatusCreated, struct{}{})
+		case strings.HasSuffix(path, "/nssai"):
+			reply(w, http.StatusOK, models.Nssai{DefaultSingleNssais: []models.Snssai{config.SNssai}})
+		case strings.HasSuffix(path, "/am-data"):
+			reply(w, http.StatusOK, map[string]interface{}{
+				"gpsis":            []string{"msisdn-0900000000"},
+				"subscribedUeAmbr": map[string]string{"uplink": "1 Gbps", "downlink": "2 Gbps"},
+			})
+		case strings.HasSuffix(path, "/smf-select-data"), strings.HasSuffix(path, "/ue-context-in-smf-data"):
+			reply(w, http.StatusOK, struct{}{})
+		case strings.HasSuffix(path, "/sdm-subscriptions"):
+			reply(w, http.StatusCreated, struct{}{})
+		case strings.HasSuffix(path, "/policies"):
+			w.Header().Set("Location", server.URL+"/npcf-am-policy-control/v1/policies/1")
+			reply(w, http.StatusCreated, struct{}{})
+		default:
+			t.Errorf("fake core: unexpected %s %s", r.Method, path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+// TestScenarioOverMemoryTransport runs the NG Setup and a registration of the simulator
+// against the NGAP server of the OCF over the memory transport
+func TestScenarioOverMemoryTransport(t *testing.T) {
+	factory.InitConfigFactory("../util/test/testAmfcfg.conf")
+	amfSelf := context.OCF_Self()
+	util.InitOcfContext(amfSelf)
+
+	scenario := &Scenario{
+		Ocf: OcfConfig{Addresses: []string{"127.0.0.1"}},
+		Gnbs: []GnbConfig{{
+			Name:       "gnb1",
+			GnbId:      "000001",
+			PlmnId:     models.PlmnId{Mcc: "208", Mnc: "93"},
+			Tac:        "000001",
+			SNssaiList: []models.Snssai{{Sst: 1, Sd: "010203"}},
+			N3Address:  "127.0.0.1",
+		}},
+		Ues: UeConfig{
+			Supi:   "imsi-208930000000001",
+			PlmnId: models.PlmnId{Mcc: "208", Mnc: "93"},
+			K:      "5122250214c33e723a5dd523fc145fc0",
+			Opc:    "981d464c7c52eb6e5036234984ad0bcf",
+			SNssai: models.Snssai{Sst: 1, Sd: "010203"},
+			Dnn:    "internet",
+		},
+		Procedures: []Procedure{{Name: ProcedureRegistration}},
+	}
+	require.NoError(t, scenario.setDefaults())
+
+	core := fakeCore(t, &scenario.Ues)
+	defer core.Close()
+	nrfUri := amfSelf.NrfUri
+	amfSelf.NrfUri = core.URL
+	defer func() { amfSelf.NrfUri = nrfUri }()
+
+	transport := &ngap_service.MemTransport{NumStreams: 3}
+	ngap_service.Run(transport, amfSelf.NgapIpList, defaultOcfPort, ngap_service.Handler{
+		HandleMessage:      ngap.Dispatch,
+		HandleNotification: ngap.HandleSCTPNotification,
+	})
+	defer ngap_service.Stop()
+
+	s, err := New(scenario)
+	require.NoError(t, err)
+	s.dial = func() (ngap_service.Conn, error) {
+		return transport.Dial()
+	}
+	stats, err := s.Run()
+	require.NoError(t, err)
+
+	summary := stats.Summary()
+	require.Len(t, summary, 2)
+	assert.Equal(t, ProcedureNGSetup, summary[0].Procedure)
+	assert.Equal(t, 1, summary[0].Success)
+	assert.Equal(t, ProcedureRegistration, summary[1].Procedure)
+	assert.Equal(t, 1, summary[1].Success)
+	assert.Equal(t, 0, summary[1].Failure)
+
+	// the OCF handles the Registration Complete after the simulator has sent it
+	ocfUe, ok := amfSelf.OcfUeFindBySupi(scenario.Ues.Supi)
+	require.True(t, ok)
+	state := ocfUe.State[models.AccessType__3_GPP_ACCESS]
+	deadline := time.Now().Add(scenario.Timeout)
+	for !state.Is(context.Registered) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, state.Is(context.Registered))
+}
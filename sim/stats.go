@@ -0,0 +1,104 @@
+package sim
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Stats collects the result and latency of every procedure run by the simulator
+type Stats struct {
+	mutex      sync.Mutex
+	order      []string
+	procedures map[string]*procedureStats
+}
+
+type procedureStats struct {
+	success   int
+	failure   int
+	latencies []time.Duration
+}
+
+// ProcedureStats is the summary of one procedure
+type ProcedureStats struct {
+	Procedure string
+	Success   int
+	Failure   int
+	Min       time.Duration
+	Avg       time.Duration
+	P50       time.Duration
+	P95       time.Duration
+	Max       time.Duration
+}
+
+func NewStats() *Stats {
+	return &Stats{procedures: make(map[string]*procedureStats)}
+}
+
+// Record adds the result of a procedure started at start, only successful procedures count
+// in the latency
+func (s *Stats) Record(procedure string, start time.Time, err error) {
+	latency := time.Since(start)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stats, ok := s.procedures[procedure]
+	if !ok {
+		stats = &procedureStats{}
+		s.procedures[procedure] = stats
+		s.order = append(s.order, procedure)
+	}
+	if err != nil {
+		stats.failure++
+		return
+	}
+	stats.success++
+	stats.latencies = append(stats.latencies, latency)
+}
+
+// Summary returns the stats of the procedures in the order they were first run
+func (s *Stats) Summary() []ProcedureStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	summary := make([]ProcedureStats, 0, len(s.order))
+	for _, procedure := range s.order {
+		stats := s.procedures[procedure]
+		item := ProcedureStats{
+			Procedure: procedure,
+			Success:   stats.success,
+			Failure:   stats.failure,
+		}
+		if n := len(stats.latencies); n > 0 {
+			latencies := make([]time.Duration, n)
+			copy(latencies, stats.latencies)
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+			var total time.Duration
+			for _, latency := range latencies {
+				total += latency
+			}
+			item.Min = latencies[0]
+			item.Avg = total / time.Duration(n)
+			item.P50 = latencies[(n-1)*50/100]
+			item.P95 = latencies[(n-1)*95/100]
+			item.Max = latencies[n-1]
+		}
+		summary = append(summary, item)
+	}
+	return summary
+}
+
+func (s *Stats) Report(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROCEDURE\tSUCCESS\tFAILURE\tMIN\tAVG\tP50\tP95\tMAX")
+	for _, item := range s.Summary() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", item.Procedure, item.Success, item.Failure,
+			item.Min.Round(time.Microsecond), item.Avg.Round(time.Microsecond), item.P50.Round(time.Microsecond),
+			item.P95.Round(time.Microsecond), item.Max.Round(time.Microsecond))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,681 @@
+package sim
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"free5gc/lib/nas"
+	"free5gc/lib/nas/nasMessage"
+	"free5gc/lib/ngap/ngapConvert"
+	"free5gc/lib/ngap/ngapType"
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/logger"
+)
+
+// prefix of the transparent containers of a handover, the target gNB finds the UE of a
+// Handover Request with it since the request carries no RAN UE NGAP ID
+const transparentContainerPrefix = "sim:"
+
+type pduSession struct {
+	id         uint8
+	snssai     models.Snssai
+	qosFlowIds []int64
+}
+
+type downlinkPdu struct {
+	gnb *gnb
+	pdu *ngapType.NGAPPDU
+}
+
+// event is one downlink NGAP message, or one NAS message carried by it
+type event struct {
+	gnb *gnb
+	pdu *ngapType.NGAPPDU
+	nas *nas.Message
+}
+
+type ue struct {
+	simulator *Simulator
+	config    *UeConfig
+	index     int
+	supi      string
+	msin      string
+	k         []byte
+	opc       []byte
+
+	// securityContext keeps the keys and NAS COUNTs of the UE, the key derivations are shared
+	// with the OCF
+	securityContext *context.OcfUe
+	ngKsi           uint8
+
+	gnb         *gnb
+	ranUeNgapId int64
+	ocfUeNgapId int64
+	connected   bool
+
+	registered bool
+	guti       [11]byte
+	sessions   map[uint8]*pduSession
+
+	downlink chan downlinkPdu
+}
+
+func newUe(simulator *Simulator, config *UeConfig, index int) (*ue, error) {
+	supi := strings.TrimPrefix(config.Supi, "imsi-")
+	plmnLen := len(config.PlmnId.Mcc) + len(config.PlmnId.Mnc)
+	if len(supi) <= plmnLen {
+		return nil, fmt.Errorf("SUPI %s is too short", config.Supi)
+	}
+	firstMsin := supi[plmnLen:]
+	msinValue, err := strconv.ParseUint(firstMsin, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("SUPI %s: %+v", config.Supi, err)
+	}
+	msin := fmt.Sprintf("%0*d", len(firstMsin), msinValue+uint64(index))
+
+	k, err := hex.DecodeString(config.K)
+	if err != nil {
+		return nil, fmt.Errorf("K: %+v", err)
+	}
+	opc, err := hex.DecodeString(config.Opc)
+	if err != nil {
+		return nil, fmt.Errorf("OPc: %+v", err)
+	}
+
+	u := &ue{
+		simulator: simulator,
+		config:    config,
+		index:     index,
+		supi:      "imsi-" + supi[:plmnLen] + msin,
+		msin:      msin,
+		k:         k,
+		opc:       opc,
+		ngKsi:     7,
+		sessions:  make(map[uint8]*pduSession),
+		downlink:  make(chan downlinkPdu, 16),
+	}
+	u.securityContext = &context.OcfUe{Supi: u.supi}
+	return u, nil
+}
+
+func (u *ue) run(procedure *Procedure) error {
+	switch procedure.Name {
+	case ProcedureRegistration:
+		return u.registration()
+	case ProcedurePduSessionEstablishment:
+		return u.pduSessionEstablishment(procedure.PduSessionId)
+	case ProcedureAnRelease:
+		return u.anRelease()
+	case ProcedureServiceRequest:
+		return u.serviceRequest()
+	case ProcedureXnHandover:
+		return u.xnHandover(u.simulator.gnbs[procedure.TargetGnb])
+	case ProcedureN2Handover:
+		return u.n2Handover(u.simulator.gnbs[procedure.TargetGnb])
+	case ProcedureDeregistration:
+		return u.deregistration()
+	default:
+		return fmt.Errorf("unknown procedure %s", procedure.Name)
+	}
+}
+
+func (u *ue) registration() error {
+	if u.connected {
+		return fmt.Errorf("UE is already connected")
+	}
+	u.securityContext.SecurityContextAvailable = false
+	u.ngKsi = 7
+	u.registered = false
+	u.sessions = make(map[uint8]*pduSession)
+
+	registrationRequest := buildRegistrationRequest(u, nasMessage.RegistrationType5GSInitialRegistration)
+	nasPdu, err := u.encodeNas(registrationRequest)
+	if err != nil {
+		return err
+	}
+	if err := u.sendInitialUEMessage(nasPdu, false); err != nil {
+		return err
+	}
+
+	authenticationRequest, err := u.expectGmm(nas.MsgTypeAuthenticationRequest)
+	if err != nil {
+		return err
+	}
+	resStar, err := u.authenticate(authenticationRequest.GmmMessage.AuthenticationRequest)
+	if err != nil {
+		return err
+	}
+	if err := u.sendNas(buildAuthenticationResponse(resStar)); err != nil {
+		return err
+	}
+
+	if _, err := u.expectGmm(nas.MsgTypeSecurityModeCommand); err != nil {
+		return err
+	}
+	// the complete registration request is sent again under protection (TS 24.501 4.4.6)
+	container, err := registrationRequest.PlainNasEncode()
+	if err != nil {
+		return err
+	}
+	if err := u.sendNas(buildSecurityModeComplete(u, container)); err != nil {
+		return err
+	}
+
+	registrationAccept, err := u.expectGmm(nas.MsgTypeRegistrationAccept)
+	if err != nil {
+		return err
+	}
+	if guti := registrationAccept.GmmMessage.RegistrationAccept.GUTI5G; guti != nil {
+		u.guti = guti.Octet
+	}
+	if err := u.sendNas(buildRegistrationComplete()); err != nil {
+		return err
+	}
+	u.registered = true
+	return nil
+}
+
+func (u *ue) pduSessionEstablishment(pduSessionId uint8) error {
+	if !u.registered || !u.connected {
+		return fmt.Errorf("UE is not registered and connected")
+	}
+	if _, exists := u.sessions[pduSessionId]; exists {
+		return fmt.Errorf("PDU session %d already exists", pduSessionId)
+	}
+
+	payload, err := buildPduSessionEstablishmentRequest(pduSessionId)
+	if err != nil {
+		return err
+	}
+	sNssai := u.config.SNssai
+	ulNasTransport, err := buildULNASTransport(pduSessionId, nasMessage.ULNASTransportRequestTypeInitialRequest,
+		u.config.Dnn, &sNssai, payload)
+	if err != nil {
+		return err
+	}
+	if err := u.sendNas(ulNasTransport); err != nil {
+		return err
+	}
+
+	// the accept is carried by the PDU Session Resource Setup Request answered by receive
+	return u.receive(func(e *event) (bool, error) {
+		if e.nas == nil || e.nas.GmmMessage == nil || e.nas.GmmMessage.DLNASTransport == nil {
+			return false, nil
+		}
+		dlNasTransport := e.nas.GmmMessage.DLNASTransport
+		if dlNasTransport.PduSessionID2Value == nil ||
+			dlNasTransport.PduSessionID2Value.GetPduSessionID2Value() != pduSessionId {
+			return false, nil
+		}
+		gsm := new(nas.Message)
+		payload := dlNasTransport.PayloadContainer.GetPayloadContainerContents()
+		if err := gsm.GsmMessageDecode(&payload); err != nil {
+			return true, err
+		}
+		switch gsm.GsmHeader.GetMessageType() {
+		case nas.MsgTypePDUSessionEstablishmentAccept:
+			if _, exists := u.sessions[pduSessionId]; !exists {
+				return true, fmt.Errorf("PDU session %d accepted without N2 resources", pduSessionId)
+			}
+			return true, nil
+		case nas.MsgTypePDUSessionEstablishmentReject:
+			return true, fmt.Errorf("PDU session %d rejected", pduSessionId)
+		}
+		return false, nil
+	})
+}
+
+func (u *ue) anRelease() error {
+	if !u.connected {
+		return fmt.Errorf("UE is not connected")
+	}
+	if err := u.sendNgap(buildUEContextReleaseRequest(u)); err != nil {
+		return err
+	}
+	return u.expectRelease()
+}
+
+func (u *ue) serviceRequest() error {
+	if !u.registered || u.connected {
+		return fmt.Errorf("UE is not registered and idle")
+	}
+	nasPdu, err := u.encodeNas(buildServiceRequest(u, nasMessage.ServiceTypeSignalling))
+	if err != nil {
+		return err
+	}
+	if err := u.sendInitialUEMessage(nasPdu, true); err != nil {
+		return err
+	}
+	_, err = u.expectGmm(nas.MsgTypeServiceAccept)
+	return err
+}
+
+// n2Handover moves the UE to target through the OCF (TS 23.502 4.9.1.3)
+func (u *ue) n2Handover(target *gnb) error {
+	if !u.connected || len(u.sessions) == 0 {
+		return fmt.Errorf("N2 handover needs a connected UE with a PDU session")
+	}
+	if target == u.gnb {
+		return fmt.Errorf("UE is already served by %s", target.config.Name)
+	}
+	source := u.gnb
+	sourceRanUeNgapId := u.ranUeNgapId
+	if err := u.sendNgap(buildHandoverRequired(u, target)); err != nil {
+		return err
+	}
+
+	// the target allocates the resources before the source gets the Handover Command
+	var targetOcfUeNgapId, targetRanUeNgapId int64
+	err := u.receive(func(e *event) (bool, error) {
+		if e.nas != nil || e.gnb != target || e.pdu.InitiatingMessage == nil ||
+			e.pdu.InitiatingMessage.Value.HandoverRequest == nil {
+			return false, nil
+		}
+		var setupList *ngapType.PDUSessionResourceSetupListHOReq
+		for _, ie := range e.pdu.InitiatingMessage.Value.HandoverRequest.ProtocolIEs.List {
+			switch ie.Id.Value {
+			case ngapType.ProtocolIEIDOCFUENGAPID:
+				targetOcfUeNgapId = ie.Value.OCFUENGAPID.Value
+			case ngapType.ProtocolIEIDPDUSessionResourceSetupListHOReq:
+				setupList = ie.Value.PDUSessionResourceSetupListHOReq
+			}
+		}
+		targetRanUeNgapId = target.attach(u)
+		pkt, err := buildHandoverRequestAcknowledge(u, target, targetOcfUeNgapId, targetRanUeNgapId, setupList)
+		if err != nil {
+			return true, err
+		}
+		return true, target.sendUe(pkt)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = u.receive(func(e *event) (bool, error) {
+		if e.nas != nil || e.gnb != source || e.pdu.SuccessfulOutcome == nil {
+			return false, nil
+		}
+		if e.pdu.SuccessfulOutcome.Value.HandoverCommand == nil {
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the UE has reached the target, the OCF releases the context of the source
+	u.gnb = target
+	u.ocfUeNgapId = targetOcfUeNgapId
+	u.ranUeNgapId = targetRanUeNgapId
+	pkt, err := buildHandoverNotify(u, target, targetOcfUeNgapId, targetRanUeNgapId)
+	if err != nil {
+		return err
+	}
+	if err := target.sendUe(pkt); err != nil {
+		return err
+	}
+	return u.receive(func(e *event) (bool, error) {
+		return e.nas == nil && e.gnb == source && e.pdu.InitiatingMessage != nil &&
+			e.pdu.InitiatingMessage.Value.UEContextReleaseCommand != nil && !source.served(sourceRanUeNgapId), nil
+	})
+}
+
+// xnHandover moves the UE to target and switches the path on the OCF (TS 23.502 4.9.1.2)
+func (u *ue) xnHandover(target *gnb) error {
+	if !u.connected || len(u.sessions) == 0 {
+		return fmt.Errorf("Xn handover needs a connected UE with a PDU session")
+	}
+	if target == u.gnb {
+		return fmt.Errorf("UE is already served by %s", target.config.Name)
+	}
+	source := u.gnb
+	sourceRanUeNgapId := u.ranUeNgapId
+	targetRanUeNgapId := target.attach(u)
+	pkt, err := buildPathSwitchRequest(u, target, targetRanUeNgapId)
+	if err != nil {
+		return err
+	}
+	if err := target.sendUe(pkt); err != nil {
+		return err
+	}
+
+	return u.receive(func(e *event) (bool, error) {
+		if e.nas != nil || e.gnb != target {
+			return false, nil
+		}
+		if e.pdu.UnsuccessfulOutcome != nil && e.pdu.UnsuccessfulOutcome.Value.PathSwitchRequestFailure != nil {
+			target.detach(targetRanUeNgapId)
+			return true, fmt.Errorf("path switch request failed")
+		}
+		if e.pdu.SuccessfulOutcome == nil || e.pdu.SuccessfulOutcome.Value.PathSwitchRequestAcknowledge == nil {
+			return false, nil
+		}
+		// the source releases the UE on the Xn UE Context Release of the target
+		source.detach(sourceRanUeNgapId)
+		u.gnb = target
+		u.ranUeNgapId = targetRanUeNgapId
+		return true, nil
+	})
+}
+
+func (u *ue) deregistration() error {
+	if !u.registered {
+		return fmt.Errorf("UE is not registered")
+	}
+	deregistrationRequest := buildDeregistrationRequest(u, 0)
+	if u.connected {
+		if err := u.sendNas(deregistrationRequest); err != nil {
+			return err
+		}
+	} else {
+		nasPdu, err := u.encodeNas(deregistrationRequest)
+		if err != nil {
+			return err
+		}
+		if err := u.sendInitialUEMessage(nasPdu, true); err != nil {
+			return err
+		}
+	}
+	if _, err := u.expectGmm(nas.MsgTypeDeregistrationAcceptUEOriginatingDeregistration); err != nil {
+		return err
+	}
+	u.registered = false
+	u.sessions = make(map[uint8]*pduSession)
+	return u.expectRelease()
+}
+
+func (u *ue) sendInitialUEMessage(nasPdu []byte, withSTmsi bool) error {
+	u.gnb = u.simulator.gnbs[u.config.Gnb]
+	u.ranUeNgapId = u.gnb.attach(u)
+	u.connected = true
+	pkt, err := buildInitialUEMessage(u, nasPdu, withSTmsi)
+	if err != nil {
+		return err
+	}
+	return u.gnb.sendUe(pkt)
+}
+
+func (u *ue) sendNas(msg *nas.Message) error {
+	nasPdu, err := u.encodeNas(msg)
+	if err != nil {
+		return err
+	}
+	pkt, err := buildUplinkNasTransport(u, nasPdu)
+	if err != nil {
+		return err
+	}
+	return u.gnb.sendUe(pkt)
+}
+
+func (u *ue) sendNgap(pkt []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	return u.gnb.sendUe(pkt)
+}
+
+// expectGmm waits for the 5GMM message of msgType, a reject of the procedure fails the wait
+func (u *ue) expectGmm(msgType uint8) (*nas.Message, error) {
+	var msg *nas.Message
+	err := u.receive(func(e *event) (bool, error) {
+		if e.nas == nil || e.nas.GmmMessage == nil {
+			return false, nil
+		}
+		switch received := e.nas.GmmHeader.GetMessageType(); received {
+		case msgType:
+			msg = e.nas
+			return true, nil
+		case nas.MsgTypeAuthenticationReject, nas.MsgTypeRegistrationReject, nas.MsgTypeServiceReject,
+			nas.MsgTypeSecurityModeReject:
+			return true, fmt.Errorf("received NAS message type %d while waiting for %d", received, msgType)
+		}
+		return false, nil
+	})
+	return msg, err
+}
+
+// expectRelease waits until the OCF has released the N2 connection of the UE
+func (u *ue) expectRelease() error {
+	return u.receive(func(e *event) (bool, error) {
+		return e.nas == nil && !u.connected, nil
+	})
+}
+
+// receive handles the downlink messages of the UE until done returns true. The gNB side of
+// the procedures the OCF starts is answered here: contexts and PDU session resources are set
+// up and released, NAS PDUs are deciphered and passed to done one by one.
+func (u *ue) receive(done func(e *event) (bool, error)) error {
+	timer := time.NewTimer(u.simulator.scenario.Timeout)
+	defer timer.Stop()
+	for {
+		var downlink downlinkPdu
+		select {
+		case downlink = <-u.downlink:
+		case <-timer.C:
+			return fmt.Errorf("timeout")
+		}
+
+		nasPdus, err := u.handleNgap(downlink.gnb, downlink.pdu)
+		if err != nil {
+			return err
+		}
+		if finished, err := done(&event{gnb: downlink.gnb, pdu: downlink.pdu}); finished || err != nil {
+			return err
+		}
+		for _, nasPdu := range nasPdus {
+			msg, err := u.decodeNas(nasPdu)
+			if err != nil {
+				return err
+			}
+			if finished, err := done(&event{gnb: downlink.gnb, pdu: downlink.pdu, nas: msg}); finished || err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleNgap answers the NGAP procedures started by the OCF and returns the NAS PDUs of pdu
+func (u *ue) handleNgap(g *gnb, pdu *ngapType.NGAPPDU) ([][]byte, error) {
+	var nasPdus [][]byte
+	switch pdu.Present {
+	case ngapType.NGAPPDUPresentInitiatingMessage:
+		value := &pdu.InitiatingMessage.Value
+		switch {
+		case value.DownlinkNASTransport != nil:
+			for _, ie := range value.DownlinkNASTransport.ProtocolIEs.List {
+				switch ie.Id.Value {
+				case ngapType.ProtocolIEIDOCFUENGAPID:
+					// the OCF UE NGAP ID is learned from the first downlink message
+					u.ocfUeNgapId = ie.Value.OCFUENGAPID.Value
+				case ngapType.ProtocolIEIDNASPDU:
+					nasPdus = append(nasPdus, ie.Value.NASPDU.Value)
+				}
+			}
+		case value.InitialContextSetupRequest != nil:
+			setupList := new(ngapType.PDUSessionResourceSetupListCxtRes)
+			for _, ie := range value.InitialContextSetupRequest.ProtocolIEs.List {
+				switch ie.Id.Value {
+				case ngapType.ProtocolIEIDOCFUENGAPID:
+					u.ocfUeNgapId = ie.Value.OCFUENGAPID.Value
+				case ngapType.ProtocolIEIDNASPDU:
+					nasPdus = append(nasPdus, ie.Value.NASPDU.Value)
+				case ngapType.ProtocolIEIDPDUSessionResourceSetupListCxtReq:
+					for _, item := range ie.Value.PDUSessionResourceSetupListCxtReq.List {
+						session := u.setupSession(item.PDUSessionID.Value, item.SNSSAI,
+							item.PDUSessionResourceSetupRequestTransfer)
+						transfer, err := buildPDUSessionResourceSetupResponseTransfer(u, g, session)
+						if err != nil {
+							return nil, err
+						}
+						setupList.List = append(setupList.List, ngapType.PDUSessionResourceSetupItemCxtRes{
+							PDUSessionID:                            item.PDUSessionID,
+							PDUSessionResourceSetupResponseTransfer: transfer,
+						})
+						if item.NASPDU != nil {
+							nasPdus = append(nasPdus, item.NASPDU.Value)
+						}
+					}
+				}
+			}
+			pkt, err := buildInitialContextSetupResponse(u, setupList)
+			if err != nil {
+				return nil, err
+			}
+			if err := g.sendUe(pkt); err != nil {
+				return nil, err
+			}
+		case value.PDUSessionResourceSetupRequest != nil:
+			setupList := ngapType.PDUSessionResourceSetupListSURes{}
+			for _, ie := range value.PDUSessionResourceSetupRequest.ProtocolIEs.List {
+				switch ie.Id.Value {
+				case ngapType.ProtocolIEIDNASPDU:
+					nasPdus = append(nasPdus, ie.Value.NASPDU.Value)
+				case ngapType.ProtocolIEIDPDUSessionResourceSetupListSUReq:
+					for _, item := range ie.Value.PDUSessionResourceSetupListSUReq.List {
+						session := u.setupSession(item.PDUSessionID.Value, item.SNSSAI,
+							item.PDUSessionResourceSetupRequestTransfer)
+						transfer, err := buildPDUSessionResourceSetupResponseTransfer(u, g, session)
+						if err != nil {
+							return nil, err
+						}
+						setupList.List = append(setupList.List, ngapType.PDUSessionResourceSetupItemSURes{
+							PDUSessionID:                            item.PDUSessionID,
+							PDUSessionResourceSetupResponseTransfer: transfer,
+						})
+						if item.PDUSessionNASPDU != nil {
+							nasPdus = append(nasPdus, item.PDUSessionNASPDU.Value)
+						}
+					}
+				}
+			}
+			pkt, err := buildPDUSessionResourceSetupResponse(u, setupList)
+			if err != nil {
+				return nil, err
+			}
+			if err := g.sendUe(pkt); err != nil {
+				return nil, err
+			}
+		case value.UEContextReleaseCommand != nil:
+			for _, ie := range value.UEContextReleaseCommand.ProtocolIEs.List {
+				if ie.Id.Value != ngapType.ProtocolIEIDUENGAPIDs {
+					continue
+				}
+				ocfUeNgapId, ranUeNgapId := u.ocfUeNgapId, u.ranUeNgapId
+				if pair := ie.Value.UENGAPIDs.UENGAPIDPair; pair != nil {
+					ocfUeNgapId, ranUeNgapId = pair.OCFUENGAPID.Value, pair.RANUENGAPID.Value
+				}
+				pkt, err := buildUEContextReleaseComplete(ocfUeNgapId, ranUeNgapId)
+				if err != nil {
+					return nil, err
+				}
+				if err := g.sendUe(pkt); err != nil {
+					return nil, err
+				}
+				g.detach(ranUeNgapId)
+				if g == u.gnb && ranUeNgapId == u.ranUeNgapId {
+					u.connected = false
+				}
+			}
+		case value.ErrorIndication != nil:
+			return nil, fmt.Errorf("received Error Indication")
+		}
+	case ngapType.NGAPPDUPresentUnsuccessfulOutcome:
+		if pdu.UnsuccessfulOutcome.Value.InitialContextSetupFailure != nil {
+			return nil, fmt.Errorf("received Initial Context Setup Failure")
+		}
+	}
+	return nasPdus, nil
+}
+
+func (u *ue) setupSession(pduSessionId int64, snssai ngapType.SNSSAI, transfer []byte) *pduSession {
+	session := &pduSession{
+		id:         uint8(pduSessionId),
+		snssai:     ngapConvert.SNssaiToModels(snssai),
+		qosFlowIds: qosFlowIds(transfer),
+	}
+	u.sessions[session.id] = session
+	logger.SimLog.Debugf("UE[%s] PDU session %d set up with QoS flows %v", u.supi, session.id, session.qosFlowIds)
+	return session
+}
+
+func (u *ue) sortedSessions() []*pduSession {
+	sessions := make([]*pduSession, 0, len(u.sessions))
+	for _, session := range u.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].id < sessions[j].id })
+	return sessions
+}
+
+func (u *ue) transparentContainer() []byte {
+	return []byte(transparentContainerPrefix + u.supi)
+}
+
+// suci is the SUCI with the null protection scheme (TS 24.501 9.11.3.4)
+func (u *ue) suci() []uint8 {
+	plmnId := u.config.PlmnId
+	mnc := plmnId.Mnc
+	if len(mnc) == 2 {
+		mnc += "f"
+	}
+	suci := []uint8{nasMessage.SupiFormatImsi<<4 | nasMessage.MobileIdentity5GSTypeSuci}
+	suci = append(suci,
+		digit(plmnId.Mcc[1])<<4|digit(plmnId.Mcc[0]),
+		digit(mnc[2])<<4|digit(plmnId.Mcc[2]),
+		digit(mnc[1])<<4|digit(mnc[0]))
+	routingIndicator := u.config.RoutingIndicator
+	suci = append(suci,
+		digit(routingIndicator[1])<<4|digit(routingIndicator[0]),
+		digit(routingIndicator[3])<<4|digit(routingIndicator[2]))
+	// protection scheme and home network public key identifier
+	suci = append(suci, 0x00, 0x00)
+	return append(suci, bcd(u.msin)...)
+}
+
+// imeisv is the IMEISV of the UE as 5GS mobile identity, it is unique per MSIN
+func (u *ue) imeisv() [9]uint8 {
+	msin := u.msin
+	if len(msin) > 6 {
+		msin = msin[len(msin)-6:]
+	}
+	digits := fmt.Sprintf("35609204%06s00", msin)
+	var imeisv [9]uint8
+	imeisv[0] = digit(digits[0])<<4 | nasMessage.MobileIdentity5GSTypeImeisv
+	for i := 1; i < len(digits); i += 2 {
+		high := uint8(0x0f)
+		if i+1 < len(digits) {
+			high = digit(digits[i+1])
+		}
+		imeisv[(i+1)/2] = high<<4 | digit(digits[i])
+	}
+	return imeisv
+}
+
+// sTmsi is the 5G-S-TMSI of the allocated 5G-GUTI
+func (u *ue) sTmsi() []uint8 {
+	sTmsi := []uint8{0xf0 | nasMessage.MobileIdentity5GSType5gSTmsi}
+	return append(sTmsi, u.guti[5:11]...)
+}
+
+func digit(c byte) uint8 {
+	return uint8(c - '0')
+}
+
+// bcd packs digits into semi-octets, an odd number of digits is padded with 0xf
+func bcd(digits string) []uint8 {
+	var buf []uint8
+	for i := 0; i < len(digits); i += 2 {
+		high := uint8(0x0f)
+		if i+1 < len(digits) {
+			high = digit(digits[i+1])
+		}
+		buf = append(buf, high<<4|digit(digits[i]))
+	}
+	return buf
+}
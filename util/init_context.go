@@ -3,6 +3,7 @@ package util
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -18,14 +19,25 @@ func InitOcfContext(context *context.OCFContext) {
 	logger.UtilLog.Infof("amfconfig Info: Version[%s] Description[%s]", config.Info.Version, config.Info.Description)
 	configuration := config.Configuration
 	context.NfId = uuid.New().String()
-	if configuration.OcfName != "" {
-		context.Name = configuration.OcfName
-	}
 	if configuration.NgapIpList != nil {
 		context.NgapIpList = configuration.NgapIpList
 	} else {
 		context.NgapIpList = []string{"127.0.0.1"} // default localhost
 	}
+	if sctp := configuration.Sctp; sctp != nil {
+		if sctp.NumOstreams != 0 {
+			context.SctpParameters.NumOstreams = sctp.NumOstreams
+		}
+		if sctp.MaxInstreams != 0 {
+			context.SctpParameters.MaxInstreams = sctp.MaxInstreams
+		}
+		if sctp.MaxAttempts != 0 {
+			context.SctpParameters.MaxAttempts = sctp.MaxAttempts
+		}
+		if sctp.MaxInitTimeout != 0 {
+			context.SctpParameters.MaxInitTimeout = sctp.MaxInitTimeout
+		}
+	}
 	sbi := configuration.Sbi
 	if sbi.Scheme != "" {
 		context.UriScheme = models.UriScheme(sbi.Scheme)
@@ -55,28 +67,208 @@ func InitOcfContext(context *context.OCFContext) {
 	}
 	serviceNameList := configuration.ServiceNameList
 	context.InitNFService(serviceNameList, config.Info.Version)
-	context.ServedGuamiList = configuration.ServedGumaiList
-	context.SupportTaiLists = configuration.SupportTAIList
-	for i := range context.SupportTaiLists {
-		context.SupportTaiLists[i].Tac = TACConfigToModels(context.SupportTaiLists[i].Tac)
-	}
-	context.PlmnSupportList = configuration.PlmnSupportList
-	context.SupportDnnLists = configuration.SupportDnnList
 	if configuration.NrfUri != "" {
 		context.NrfUri = configuration.NrfUri
 	} else {
 		logger.UtilLog.Warn("NRF Uri is empty! Using localhost as NRF IPv4 address.")
 		context.NrfUri = fmt.Sprintf("%s://%s:%d", context.UriScheme, "127.0.0.1", 29510)
 	}
-	security := configuration.Security
-	if security != nil {
-		context.SecurityAlgorithm.IntegrityOrder = getIntAlgOrder(security.IntegrityOrder)
-		context.SecurityAlgorithm.CipheringOrder = getEncAlgOrder(security.CipheringOrder)
+	initReloadableContext(context, configuration)
+}
+
+// initReloadableContext sets the parameters which can be changed by a reload of the configuration
+func initReloadableContext(amfSelf *context.OCFContext, configuration *factory.Configuration) {
+	parameters := context.DefaultParameters()
+	if configuration.OcfName != "" {
+		parameters.Name = configuration.OcfName
+	}
+	if configuration.RelativeCapacity != nil {
+		parameters.RelativeCapacity = *configuration.RelativeCapacity
+	}
+	parameters.ServedGuamiList = configuration.ServedGumaiList
+	parameters.NgapCapture = initNgapCapture(configuration.NgapCapture)
+	if overloadControl := configuration.OverloadControl; overloadControl != nil {
+		initOverloadControl(&parameters.OverloadControl, overloadControl)
+	}
+	// the configuration is left as it is written in the file so that it can be compared on reload
+	supportTaiLists := make([]models.Tai, len(configuration.SupportTAIList))
+	copy(supportTaiLists, configuration.SupportTAIList)
+	for i := range supportTaiLists {
+		supportTaiLists[i].Tac = TACConfigToModels(supportTaiLists[i].Tac)
+	}
+	parameters.SupportTaiLists = supportTaiLists
+	parameters.PlmnSupportList = configuration.PlmnSupportList
+	parameters.SupportDnnLists = configuration.SupportDnnList
+	parameters.Reauthentication = context.ReauthenticationParameters{NasCountHeadroom: context.DefaultNasCountHeadroom}
+	if security := configuration.Security; security != nil {
+		parameters.SecurityAlgorithm.IntegrityOrder = getIntAlgOrder(security.IntegrityOrder)
+		parameters.SecurityAlgorithm.CipheringOrder = getEncAlgOrder(security.CipheringOrder)
+		if security.NasCountHeadroom != 0 {
+			parameters.Reauthentication.NasCountHeadroom = uint32(security.NasCountHeadroom)
+		}
+		if reauthentication := security.Reauthentication; reauthentication != nil {
+			parameters.Reauthentication.Interval = time.Duration(reauthentication.Interval) * time.Hour
+			parameters.Reauthentication.Registrations = reauthentication.Registrations
+		}
+		parameters.KamfHorizontalDerivation = security.KamfHorizontalDerivation
+	}
+	parameters.NetworkName = configuration.NetworkName
+	parameters.T3502Value = configuration.T3502
+	parameters.T3512Value = configuration.T3512
+	parameters.Non3gppDeregistrationTimerValue = configuration.Non3gppDeregistrationTimer
+	parameters.Timers = initTimers(configuration.Timers)
+	if mico := configuration.Mico; mico != nil {
+		parameters.Mico.Enable = mico.Enable
+		parameters.Mico.AllPlmnRegistrationArea = mico.AllPlmnRegistrationArea
+		parameters.Mico.ActiveTime = mico.ActiveTime
+		parameters.Mico.ExtendedPeriodicTimer = mico.ExtendedPeriodicTimer
+	}
+	if emergency := configuration.Emergency; emergency != nil {
+		parameters.Emergency.Enable = emergency.Enable
+		parameters.Emergency.Dnn = emergency.Dnn
+		if emergency.Snssai != nil {
+			parameters.Emergency.Snssai = *emergency.Snssai
+		} else if len(parameters.PlmnSupportList) > 0 && len(parameters.PlmnSupportList[0].SNssaiList) > 0 {
+			parameters.Emergency.Snssai = parameters.PlmnSupportList[0].SNssaiList[0]
+		}
+		parameters.Emergency.SmfUri = emergency.SmfUri
+		parameters.Emergency.AllowWithoutSim = emergency.AllowWithoutSim
+	}
+	if gutiReallocation := configuration.GutiReallocation; gutiReallocation != nil {
+		parameters.GutiReallocation.Registration = gutiReallocation.Registration
+		parameters.GutiReallocation.ServiceRequests = gutiReallocation.ServiceRequests
+		parameters.GutiReallocation.Interval = time.Duration(gutiReallocation.Interval) * time.Second
+	}
+	if registrationArea := configuration.RegistrationArea; registrationArea != nil {
+		initRegistrationArea(&parameters.RegistrationArea, registrationArea)
+	}
+	amfSelf.SetParameters(parameters)
+}
+
+func initRegistrationArea(parameters *context.RegistrationAreaParameters, registrationArea *factory.RegistrationArea) {
+	if registrationArea.Strategy != "" {
+		parameters.Strategy = registrationArea.Strategy
+	}
+	if registrationArea.MaxTais != 0 {
+		parameters.MaxTais = registrationArea.MaxTais
+	}
+	if registrationArea.HistorySize != 0 {
+		parameters.HistorySize = registrationArea.HistorySize
+	}
+	// the TACs are converted as the ones of the supported TAIs, the configuration is left as it is
+	parameters.TaiGroups = make([][]models.Tai, len(registrationArea.TaiGroups))
+	for i, group := range registrationArea.TaiGroups {
+		parameters.TaiGroups[i] = make([]models.Tai, len(group))
+		copy(parameters.TaiGroups[i], group)
+		for j := range parameters.TaiGroups[i] {
+			parameters.TaiGroups[i][j].Tac = TACConfigToModels(parameters.TaiGroups[i][j].Tac)
+		}
+	}
+}
+
+func initTimers(timers *factory.Timers) context.TimerParameters {
+	parameters := context.DefaultTimerParameters()
+	if timers == nil {
+		return parameters
+	}
+	parameters.Default = mergeTimers(parameters.Default, &timers.TimerSet, true)
+	for _, override := range timers.AccessTypeOverrides {
+		overrideTimers := mergeTimers(parameters.Default, &override.TimerSet, false)
+		if parameters.AccessType[override.AccessType] == nil {
+			parameters.AccessType[override.AccessType] = overrideTimers
+			continue
+		}
+		for name, timer := range overrideTimers {
+			parameters.AccessType[override.AccessType][name] = timer
+		}
+	}
+	for _, override := range timers.SnssaiOverrides {
+		parameters.Snssai = append(parameters.Snssai, context.SnssaiTimers{
+			Snssai: override.Snssai,
+			Timers: mergeTimers(parameters.Default, &override.TimerSet, false),
+		})
+	}
+	return parameters
+}
+
+// mergeTimers completes the timers set in timerSet with the defaults, the unset timers are
+// returned only if all is true
+func mergeTimers(defaults map[context.TimerName]context.Timer, timerSet *factory.TimerSet, all bool) (
+	timers map[context.TimerName]context.Timer) {
+	timers = make(map[context.TimerName]context.Timer)
+	for name, configured := range timerSet.Timers() {
+		timer := defaults[name]
+		if configured == nil {
+			if all {
+				timers[name] = timer
+			}
+			continue
+		}
+		if configured.Value != 0 {
+			timer.Value = time.Duration(configured.Value) * time.Millisecond
+		}
+		if configured.MaxRetryTimes != nil {
+			timer.MaxRetryTimes = *configured.MaxRetryTimes
+		}
+		timers[name] = timer
+	}
+	return
+}
+
+func initNgapCapture(ngapCapture *factory.NgapCapture) context.NgapCaptureParameters {
+	parameters := context.DefaultNgapCaptureParameters()
+	if ngapCapture == nil {
+		return parameters
+	}
+	parameters.Enable = ngapCapture.Enable
+	if ngapCapture.Directory != "" {
+		parameters.Directory = ngapCapture.Directory
+	}
+	if ngapCapture.MaxFileSize != 0 {
+		parameters.MaxFileSize = ngapCapture.MaxFileSize * 1024 * 1024
+	}
+	if ngapCapture.MaxFiles != 0 {
+		parameters.MaxFiles = ngapCapture.MaxFiles
+	}
+	parameters.RanList = ngapCapture.RanList
+	return parameters
+}
+
+func initOverloadControl(parameters *context.OverloadControlParameters, overloadControl *factory.OverloadControl) {
+	parameters.Enable = overloadControl.Enable
+	if overloadControl.CheckInterval != 0 {
+		parameters.CheckInterval = time.Duration(overloadControl.CheckInterval) * time.Second
+	}
+	if queueDepth := overloadControl.QueueDepth; queueDepth != nil {
+		parameters.QueueDepthStart = queueDepth.Start
+		parameters.QueueDepthStop = queueDepth.Stop
+	}
+	if sbiLatency := overloadControl.SbiLatency; sbiLatency != nil {
+		parameters.SbiLatencyStart = time.Duration(sbiLatency.Start) * time.Millisecond
+		parameters.SbiLatencyStop = time.Duration(sbiLatency.Stop) * time.Millisecond
+	}
+	parameters.UeCapacity = overloadControl.UeCapacity
+	if ueLoad := overloadControl.UeLoad; ueLoad != nil {
+		parameters.UeLoadStart = ueLoad.Start
+		parameters.UeLoadStop = ueLoad.Stop
+	}
+	switch overloadControl.Action {
+	case "":
+	case context.OverloadActionRejectNonEmergencyMoDt, context.OverloadActionRejectRrcCrSignalling,
+		context.OverloadActionPermitEmergencyAndMt, context.OverloadActionPermitHighPriorityAndMt:
+		parameters.Action = overloadControl.Action
+	default:
+		logger.UtilLog.Warnf("Unsupported overload action [%s], use [%s]", overloadControl.Action, parameters.Action)
+	}
+	if reduction := overloadControl.TrafficLoadReduction; reduction != 0 {
+		if reduction < 1 || reduction > 99 {
+			logger.UtilLog.Warnf("Traffic load reduction [%d] out of range (1~99), use [%d]", reduction,
+				parameters.TrafficLoadReduction)
+		} else {
+			parameters.TrafficLoadReduction = reduction
+		}
 	}
-	context.NetworkName = configuration.NetworkName
-	context.T3502Value = configuration.T3502
-	context.T3512Value = configuration.T3512
-	context.Non3gppDeregistrationTimerValue = configuration.Non3gppDeregistrationTimer
+	parameters.SliceList = overloadControl.SliceList
 }
 
 func getIntAlgOrder(integrityOrder []string) (intOrder []uint8) {
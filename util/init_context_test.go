@@ -0,0 +1,37 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/factory"
+)
+
+func TestInitTimers(t *testing.T) {
+	config, err := factory.ReadConfig("test/testAmfcfg2.conf")
+	require.Nil(t, err)
+	timers := initTimers(config.Configuration.Timers)
+
+	defaultSnssai := models.Snssai{Sst: 1, Sd: "010203"}
+	slowSnssai := models.Snssai{Sst: 2, Sd: "445566"}
+	threeGpp := models.AccessType__3_GPP_ACCESS
+	nonThreeGpp := models.AccessType_NON_3_GPP_ACCESS
+
+	assert.Equal(t, context.Timer{Value: 6 * time.Second, MaxRetryTimes: context.MaxT3560RetryTimes},
+		timers.Timer(context.TimerT3560, threeGpp, nil))
+	// the access type override keeps the value of the default
+	assert.Equal(t, context.Timer{Value: 6 * time.Second, MaxRetryTimes: 2},
+		timers.Timer(context.TimerT3560, nonThreeGpp, []models.Snssai{defaultSnssai}))
+	// the S-NSSAI override comes first and is completed with the default
+	assert.Equal(t, context.Timer{Value: 30 * time.Second, MaxRetryTimes: context.MaxT3560RetryTimes},
+		timers.Timer(context.TimerT3560, nonThreeGpp, []models.Snssai{defaultSnssai, slowSnssai}))
+	assert.Equal(t, context.Timer{Value: 30 * time.Second, MaxRetryTimes: 6},
+		timers.Timer(context.TimerT3550, threeGpp, []models.Snssai{slowSnssai}))
+	assert.Equal(t, context.Timer{Value: context.TimeT3550, MaxRetryTimes: context.MaxT3550RetryTimes},
+		timers.Timer(context.TimerT3550, threeGpp, []models.Snssai{defaultSnssai}))
+}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/factory"
+)
+
+// configurationParameter is a parameter of the configuration compared on reload
+type configurationParameter struct {
+	name       string // as it is written in the configuration file
+	value      func(configuration *factory.Configuration) interface{}
+	reloadable bool
+}
+
+var configurationParameters = []configurationParameter{
+	{"amfName", func(c *factory.Configuration) interface{} { return c.OcfName }, true},
+	{"relativeCapacity", func(c *factory.Configuration) interface{} { return c.RelativeCapacity }, true},
+	{"ngapIpList", func(c *factory.Configuration) interface{} { return c.NgapIpList }, false},
+	{"sctp", func(c *factory.Configuration) interface{} { return c.Sctp }, false},
+	{"ngapCapture", func(c *factory.Configuration) interface{} { return c.NgapCapture }, true},
+	{"overloadControl", func(c *factory.Configuration) interface{} { return c.OverloadControl }, true},
+	{"sbi", func(c *factory.Configuration) interface{} { return c.Sbi }, false},
+	{"serviceNameList", func(c *factory.Configuration) interface{} { return c.ServiceNameList }, false},
+	{"servedGuamiList", func(c *factory.Configuration) interface{} { return c.ServedGumaiList }, true},
+	{"supportTaiList", func(c *factory.Configuration) interface{} { return c.SupportTAIList }, true},
+	{"plmnSupportList", func(c *factory.Configuration) interface{} { return c.PlmnSupportList }, true},
+	{"supportDnnList", func(c *factory.Configuration) interface{} { return c.SupportDnnList }, true},
+	{"nrfUri", func(c *factory.Configuration) interface{} { return c.NrfUri }, false},
+	{"security", func(c *factory.Configuration) interface{} { return c.Security }, true},
+	{"networkName", func(c *factory.Configuration) interface{} { return c.NetworkName }, true},
+	{"t3502", func(c *factory.Configuration) interface{} { return c.T3502 }, true},
+	{"t3512", func(c *factory.Configuration) interface{} { return c.T3512 }, true},
+	{"mon3gppDeregistrationTimer", func(c *factory.Configuration) interface{} {
+		return c.Non3gppDeregistrationTimer
+	}, true},
+	{"timers", func(c *factory.Configuration) interface{} { return c.Timers }, true},
+	{"mico", func(c *factory.Configuration) interface{} { return c.Mico }, true},
+	{"emergency", func(c *factory.Configuration) interface{} { return c.Emergency }, true},
+	{"gutiReallocation", func(c *factory.Configuration) interface{} { return c.GutiReallocation }, true},
+	{"registrationArea", func(c *factory.Configuration) interface{} { return c.RegistrationArea }, true},
+}
+
+// DiffConfiguration returns the parameters which differ between two configurations, split into
+// the ones which can be reloaded and the ones which need a restart of the OCF
+func DiffConfiguration(previous, configuration *factory.Configuration) (reloadable, restart []string) {
+	for _, parameter := range configurationParameters {
+		if reflect.DeepEqual(parameter.value(previous), parameter.value(configuration)) {
+			continue
+		}
+		if parameter.reloadable {
+			reloadable = append(reloadable, parameter.name)
+		} else {
+			restart = append(restart, parameter.name)
+		}
+	}
+	return
+}
+
+// ReloadOcfContext applies the reloadable parameters of a new configuration to the OCF context and
+// returns the changed ones. Nothing is applied if a changed parameter is invalid or needs a restart.
+func ReloadOcfContext(amfSelf *context.OCFContext, previous, configuration *factory.Configuration) (
+	[]string, error) {
+	changed, restart := DiffConfiguration(previous, configuration)
+	if len(restart) != 0 {
+		return nil, fmt.Errorf("%s cannot be changed without a restart of the OCF", strings.Join(restart, ", "))
+	}
+	if len(changed) == 0 {
+		return changed, nil
+	}
+	// the problems of the unchanged parameters are already those of the running configuration
+	if err := factory.Validate(&factory.Config{Info: &factory.Info{}, Configuration: configuration}); err != nil {
+		var problems []string
+		for _, problem := range err.(*factory.ValidationError).Problems {
+			for _, name := range changed {
+				if strings.HasPrefix(problem, "configuration."+name+":") ||
+					strings.HasPrefix(problem, "configuration."+name+".") ||
+					strings.HasPrefix(problem, "configuration."+name+"[") {
+					problems = append(problems, problem)
+				}
+			}
+		}
+		if len(problems) != 0 {
+			return nil, &factory.ValidationError{Problems: problems}
+		}
+	}
+	initReloadableContext(amfSelf, configuration)
+	return changed, nil
+}
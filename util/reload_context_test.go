@@ -0,0 +1,65 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"free5gc/lib/openapi/models"
+	"free5gc/src/ocf/context"
+	"free5gc/src/ocf/factory"
+)
+
+func TestReloadOcfContext(t *testing.T) {
+	previous, err := factory.ReadConfig("test/testAmfcfg2.conf")
+	require.Nil(t, err)
+	amfSelf := &context.OCFContext{}
+	initReloadableContext(amfSelf, previous.Configuration)
+	assert.Equal(t, int64(128), amfSelf.Parameters().RelativeCapacity)
+
+	// the file is read again without change
+	configuration, err := factory.ReadConfig("test/testAmfcfg2.conf")
+	require.Nil(t, err)
+	changed, err := ReloadOcfContext(amfSelf, previous.Configuration, configuration.Configuration)
+	assert.Nil(t, err)
+	assert.Empty(t, changed)
+
+	configuration.Configuration.SupportTAIList = append(configuration.Configuration.SupportTAIList,
+		models.Tai{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, Tac: "3"})
+	configuration.Configuration.T3512 = 3600
+	changed, err = ReloadOcfContext(amfSelf, previous.Configuration, configuration.Configuration)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"supportTaiList", "t3512"}, changed)
+	assert.Len(t, amfSelf.Parameters().SupportTaiLists, 4)
+	assert.Equal(t, "000003", amfSelf.Parameters().SupportTaiLists[3].Tac)
+	assert.Equal(t, 3600, amfSelf.Parameters().T3512Value)
+
+	configuration.Configuration.NgapIpList = []string{"127.0.0.2"}
+	configuration.Configuration.T3512 = 60
+	_, err = ReloadOcfContext(amfSelf, previous.Configuration, configuration.Configuration)
+	assert.EqualError(t, err, "ngapIpList cannot be changed without a restart of the OCF")
+	assert.Equal(t, 3600, amfSelf.Parameters().T3512Value)
+
+	configuration.Configuration.NgapIpList = previous.Configuration.NgapIpList
+	configuration.Configuration.SupportTAIList = nil
+	_, err = ReloadOcfContext(amfSelf, previous.Configuration, configuration.Configuration)
+	require.IsType(t, &factory.ValidationError{}, err)
+	assert.Equal(t, []string{"configuration.supportTaiList: is empty"}, err.(*factory.ValidationError).Problems)
+	assert.Equal(t, 3600, amfSelf.Parameters().T3512Value)
+
+	// the reload publishes new parameters, the ones read before are left as they are
+	parameters := amfSelf.Parameters()
+	configuration, err = factory.ReadConfig("test/testAmfcfg2.conf")
+	require.Nil(t, err)
+	configuration.Configuration.ServedGumaiList = []models.Guami{
+		{PlmnId: &models.PlmnId{Mcc: "208", Mnc: "93"}, OcfId: "cafe01"},
+	}
+	changed, err = ReloadOcfContext(amfSelf, previous.Configuration, configuration.Configuration)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"servedGuamiList"}, changed)
+	require.Len(t, amfSelf.Parameters().ServedGuamiList, 1)
+	assert.Equal(t, "cafe01", amfSelf.Parameters().ServedGuamiList[0].OcfId)
+	assert.Len(t, parameters.ServedGuamiList, 2)
+	assert.Equal(t, 3600, parameters.T3512Value)
+}
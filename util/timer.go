@@ -44,6 +44,19 @@ func StopT3550(ue *context.OcfUe) {
 	ue.T3550RetryTimes = 0
 }
 
+func StopT3555(ue *context.OcfUe) {
+	if ue == nil {
+		logger.UtilLog.Errorln("OcfUe is nil")
+		return
+	}
+
+	if ue.T3555 != nil {
+		ue.T3555.Stop()
+		ue.T3555 = nil
+	}
+	ue.T3555RetryTimes = 0
+}
+
 func StopT3560(ue *context.OcfUe) {
 	if ue == nil {
 		logger.UtilLog.Errorln("OcfUe is nil")
@@ -69,3 +82,51 @@ func StopT3565(ue *context.OcfUe) {
 	}
 	ue.T3565RetryTimes = 0
 }
+
+func StopMobileReachableTimer(ue *context.OcfUe) {
+	if ue == nil {
+		logger.UtilLog.Errorln("OcfUe is nil")
+		return
+	}
+
+	if ue.MobileReachableTimer != nil {
+		ue.MobileReachableTimer.Stop()
+		ue.MobileReachableTimer = nil
+	}
+}
+
+func StopImplicitDeregistrationTimer(ue *context.OcfUe) {
+	if ue == nil {
+		logger.UtilLog.Errorln("OcfUe is nil")
+		return
+	}
+
+	if ue.ImplicitDeregistrationTimer != nil {
+		ue.ImplicitDeregistrationTimer.Stop()
+		ue.ImplicitDeregistrationTimer = nil
+	}
+}
+
+func StopNon3gppImplicitDeregistrationTimer(ue *context.OcfUe) {
+	if ue == nil {
+		logger.UtilLog.Errorln("OcfUe is nil")
+		return
+	}
+
+	if ue.Non3gppImplicitDeregistrationTimer != nil {
+		ue.Non3gppImplicitDeregistrationTimer.Stop()
+		ue.Non3gppImplicitDeregistrationTimer = nil
+	}
+}
+
+func StopActiveTimer(ue *context.OcfUe) {
+	if ue == nil {
+		logger.UtilLog.Errorln("OcfUe is nil")
+		return
+	}
+
+	if ue.ActiveTimer != nil {
+		ue.ActiveTimer.Stop()
+		ue.ActiveTimer = nil
+	}
+}